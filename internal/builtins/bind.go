@@ -0,0 +1,96 @@
+package builtins
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// handleBind implements bind, which binds keys of the line editor as lines
+// of the inputrc file do: "keys: action", "keys: \"text\"" for a macro, or
+// "set name value". -l lists the actions and -p the bindings, -f reads a
+// file of bindings and -r removes the binding of keys. -x "keys: command"
+// binds keys to a shell command whose output is inserted at the cursor,
+// and -X to one whose output replaces the line.
+func handleBind(args []string) int {
+	return bind(args, os.Stdout)
+}
+
+// bind runs bind writing to stdout.
+func bind(args []string, stdout io.Writer) int {
+	if lineEditor == nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: bind: %v\n", errNoLineEditor)
+
+		return StatusFailure
+	}
+
+	status := StatusSuccess
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		flags := args[i][1:]
+		for j := 0; j < len(flags); j++ {
+			flag := flags[j]
+
+			switch flag {
+			case 'l':
+				for _, name := range lineEditor.Actions() {
+					_, _ = fmt.Fprintln(stdout, name)
+				}
+			case 'p':
+				for _, line := range lineEditor.Bindings() {
+					_, _ = fmt.Fprintln(stdout, line)
+				}
+			case 'f', 'r', 'x', 'X':
+				// The value is the rest of this word or the next argument
+				value := flags[j+1:]
+				if value == "" {
+					if i+1 >= len(args) {
+						_, _ = fmt.Fprintf(os.Stderr, "dsh: bind: -%c: option requires an argument\n", flag)
+
+						return StatusUsage
+					}
+					i++
+					value = args[i]
+				}
+				j = len(flags)
+
+				if err := bindOption(flag, value); err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "dsh: bind: %v\n", err)
+					status = StatusFailure
+				}
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: bind: -%c: %v\n", flag, ErrInvalidOption)
+
+				return StatusUsage
+			}
+		}
+	}
+
+	for _, line := range args[i:] {
+		if err := lineEditor.Bind(line); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: bind: %v\n", err)
+			status = StatusFailure
+		}
+	}
+
+	return status
+}
+
+// bindOption applies a flag of bind that takes a value.
+func bindOption(flag byte, value string) error {
+	switch flag {
+	case 'f':
+		return lineEditor.ReadInputrc(value)
+	case 'r':
+		return lineEditor.Unbind(value)
+	default:
+		return lineEditor.BindCommand(value, flag == 'X')
+	}
+}
@@ -0,0 +1,88 @@
+package builtins
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var errFakeBinding = errors.New("bad binding")
+
+// fakeEditor records what bind asks of the line editor, and fails for
+// lines containing "bad".
+type fakeEditor struct {
+	calls []string
+}
+
+func (f *fakeEditor) record(call, arg string) error {
+	f.calls = append(f.calls, call+" "+arg)
+	if strings.Contains(arg, "bad") {
+		return errFakeBinding
+	}
+
+	return nil
+}
+
+func (*fakeEditor) ReadLine(string) (string, error) { return "", nil }
+
+func (*fakeEditor) SetInputMode(bool, bool) (func(), error) { return func() {}, nil }
+
+func (f *fakeEditor) Bind(line string) error { return f.record("bind", line) }
+
+func (f *fakeEditor) BindCommand(line string, replace bool) error {
+	return f.record("command "+strconv.FormatBool(replace), line)
+}
+
+func (f *fakeEditor) Unbind(keys string) error { return f.record("unbind", keys) }
+
+func (f *fakeEditor) ReadInputrc(path string) error { return f.record("inputrc", path) }
+
+func (*fakeEditor) Bindings() []string { return []string{`"\C-t": kill-word`} }
+
+func (*fakeEditor) Actions() []string { return []string{"beginning-of-line"} }
+
+func TestBind(t *testing.T) {
+	editor := &fakeEditor{}
+	SetLineEditor(editor)
+	t.Cleanup(func() { SetLineEditor(nil) })
+
+	tests := []struct {
+		args   []string
+		status int
+		calls  []string
+	}{
+		{[]string{"bind", `"\C-t": kill-word`}, StatusSuccess, []string{`bind "\C-t": kill-word`}},
+		{[]string{"bind", "-x", `"\C-o": date`}, StatusSuccess, []string{`command false "\C-o": date`}},
+		{[]string{"bind", "-X", `"\C-o": date`}, StatusSuccess, []string{`command true "\C-o": date`}},
+		{[]string{"bind", "-finputrc", "-r", `"\C-b"`}, StatusSuccess, []string{"inputrc inputrc", `unbind "\C-b"`}},
+		{[]string{"bind", "bad", `"\C-b": backward-char`}, StatusFailure, []string{"bind bad", `bind "\C-b": backward-char`}},
+		{[]string{"bind", "-q"}, StatusUsage, nil},
+		{[]string{"bind", "-x"}, StatusUsage, nil},
+	}
+
+	for _, test := range tests {
+		editor.calls = nil
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+		if strings.Join(editor.calls, "\n") != strings.Join(test.calls, "\n") {
+			t.Errorf("%v: expected %q, got %q", test.args, test.calls, editor.calls)
+		}
+	}
+
+	output := captureStdout(t, func() { Run([]string{"bind", "-p"}) })
+	if output != "\"\\C-t\": kill-word\n" {
+		t.Errorf("Expected bind -p to print the bindings, got %q", output)
+	}
+
+	output = captureStdout(t, func() { Run([]string{"bind", "-l"}) })
+	if output != "beginning-of-line\n" {
+		t.Errorf("Expected bind -l to list the actions, got %q", output)
+	}
+
+	SetLineEditor(nil)
+	if status := Run([]string{"bind", "-l"}); status != StatusFailure {
+		t.Errorf("Expected bind without a line editor to fail, got status %d", status)
+	}
+}
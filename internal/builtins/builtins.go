@@ -4,98 +4,229 @@ package builtins
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"dsh/internal/variables"
+)
+
+// Exit statuses shared by the built-in commands.
+const (
+	StatusSuccess = 0
+	StatusFailure = 1
+	// StatusUsage reports a usage or syntax error, as test and [ do.
+	StatusUsage = 2
 )
 
 // BuiltinCommand represents a built-in shell command.
 type BuiltinCommand struct {
 	Name string
-	Func func([]string) bool
+	Func func([]string) int
 }
 
-// builtinCommands maps command names to their implementations.
-var builtinCommands = map[string]func([]string) bool{ //nolint:gochecknoglobals // Required for builtin command registry
-	"cd":   handleCD,
-	"pwd":  handlePWD,
-	"help": handleHelp,
-	"exit": handleExit,
-	"todo": handleTodo,
+// builtinNames are the names of the builtins, in order.
+var builtinNames = []string{ //nolint:gochecknoglobals // Fixed list of builtins
+	"[", "bind", "cd", "command", "declare", "dirs", "echo", "eval", "exec", "exit", "fc", "getopts", "hash",
+	"help", "kill", "popd", "pushd", "printf", "pwd", "read", "set", "shopt", "test", "times", "todo", "type",
+	"typeset", "ulimit", "umask", "unset", "which",
 }
 
+// Names returns the names of the builtins, in order, for help and for
+// completing command names.
+func Names() []string {
+	return slices.Clone(builtinNames)
+}
+
+// builtinCommand returns the implementation of the named built-in, which
+// receives its full argument vector and returns an exit status. It is a
+// function rather than a map so that builtins such as type and hash can
+// ask IsBuiltin about other names.
+func builtinCommand(name string) (func([]string) int, bool) {
+	switch name {
+	case "cd":
+		return handleCD, true
+	case "pwd":
+		return handlePWD, true
+	case "pushd":
+		return handlePushd, true
+	case "popd":
+		return handlePopd, true
+	case "dirs":
+		return handleDirs, true
+	case "help":
+		return handleHelp, true
+	case "exit":
+		return handleExit, true
+	case "todo":
+		return handleTodo, true
+	case "test":
+		return handleTest, true
+	case "[":
+		return handleBracket, true
+	case "declare", "typeset":
+		return handleDeclare, true
+	case "unset":
+		return handleUnset, true
+	case "set":
+		return handleSet, true
+	case "shopt":
+		return handleShopt, true
+	case "echo":
+		return handleEcho, true
+	case "printf":
+		return handlePrintf, true
+	case "read":
+		return handleRead, true
+	case "type":
+		return handleType, true
+	case "which":
+		return handleWhich, true
+	case "times":
+		return handleTimes, true
+	case "hash":
+		return handleHash, true
+	case "getopts":
+		return handleGetopts, true
+	case "kill":
+		return handleKill, true
+	case "ulimit":
+		return handleUlimit, true
+	case "umask":
+		return handleUmask, true
+	case "bind":
+		return handleBind, true
+	}
+
+	return nil, false
+}
+
+// shellBuiltins are run by the executor instead of Run, since they parse
+// and run commands or change the shell's own file descriptors. They are
+// builtins for every other purpose.
+var shellBuiltins = []string{"command", "eval", "exec", "fc"} //nolint:gochecknoglobals // Fixed list of executor builtins
+
 // IsBuiltin checks if a command is a built-in.
 func IsBuiltin(name string) bool {
-	_, exists := builtinCommands[name]
+	_, exists := builtinCommand(name)
 
-	return exists
+	return exists || slices.Contains(shellBuiltins, name)
 }
 
-// ExecuteBuiltin executes a built-in command.
-func ExecuteBuiltin(args []string) bool {
-	if len(args) == 0 {
-		return false
-	}
+// IsOutputBuiltin reports whether the named builtin only writes to its
+// standard output, so that the shell can run it for a pipeline stage or a
+// process substitution while it goes on with the other commands.
+func IsOutputBuiltin(name string) bool {
+	return name == "echo" || name == "printf"
+}
 
-	if fn, exists := builtinCommands[args[0]]; exists {
-		return fn(args)
+// IsListing reports whether args run a builtin only to list the shell's
+// own state: the directory stack, the command table or the key bindings.
+// A child shell would start without that state, so the shell runs these
+// for a pipeline stage or a process substitution too.
+func IsListing(args []string) bool {
+	switch args[0] {
+	case "dirs":
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-") && !isNumber(arg[1:]) && strings.Contains(arg, "c") {
+				return false
+			}
+		}
+
+		return true
+	case "hash":
+		return len(args) == 1
+	case "bind":
+		for _, arg := range args[1:] {
+			if len(arg) < 2 || arg[0] != '-' || strings.Trim(arg[1:], "lp") != "" {
+				return false
+			}
+		}
+
+		return len(args) > 1
 	}
 
 	return false
 }
 
-func handleExit(_ []string) bool {
-	// In tests, just return false to signal exit
-	// In real usage, the shell will handle the exit
-	return false
+// RunWithOutput runs an output builtin, or one that IsListing accepts,
+// writing to stdout rather than the shell's standard output, and returns
+// its exit status.
+func RunWithOutput(args []string, stdout io.Writer) int {
+	switch args[0] {
+	case "echo":
+		return echo(args, stdout)
+	case "printf":
+		return printf(args, stdout)
+	case "dirs":
+		return showDirs(args, stdout)
+	case "hash":
+		return hash(args, stdout)
+	case "bind":
+		return bind(args, stdout)
+	}
+
+	return StatusFailure
 }
 
-func handleCD(args []string) bool {
-	var target string
-	if len(args) < 2 {
-		target = os.Getenv("HOME")
-		if target == "" {
-			_, _ = fmt.Fprintf(os.Stderr, "dsh: cd: HOME not set\n")
+// EndsShell reports whether running the named built-in terminates the shell.
+func EndsShell(name string) bool {
+	return name == "exit"
+}
 
-			return false
-		}
-	} else {
-		target = args[1]
+// Run executes a built-in command and returns its exit status.
+func Run(args []string) int {
+	if len(args) == 0 {
+		return StatusFailure
 	}
 
-	err := os.Chdir(target)
-	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "dsh: cd: %v\n", err)
+	if fn, exists := builtinCommand(args[0]); exists {
+		return fn(args)
+	}
 
+	return StatusFailure
+}
+
+// ExecuteBuiltin executes a built-in command and reports whether it
+// succeeded. exit never succeeds, since it ends the shell.
+func ExecuteBuiltin(args []string) bool {
+	if len(args) == 0 || EndsShell(args[0]) {
 		return false
 	}
 
-	return true
+	return Run(args) == StatusSuccess
 }
 
-func handlePWD(_ []string) bool { //nolint:unparam // Always returns true as pwd command always succeeds
-	pwd, err := os.Getwd()
+// handleExit returns the status the shell should exit with: its argument,
+// or the status of the last command.
+func handleExit(args []string) int {
+	if len(args) < 2 {
+		return variables.LastStatus()
+	}
+
+	status, err := strconv.Atoi(args[1])
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "dsh: pwd: %v\n", err)
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: exit: %s: numeric argument required\n", args[1])
 
-		return true
+		return StatusUsage
 	}
 
-	_, _ = fmt.Fprintln(os.Stdout, pwd)
-
-	return true
+	return status & 0xff
 }
 
-func handleHelp(_ []string) bool {
+func handleHelp(_ []string) int {
 	_, _ = fmt.Fprintln(os.Stdout, "dsh - Daniel's Shell")
-	_, _ = fmt.Fprintln(os.Stdout, "Built-in commands: cd, exit, help, pwd, todo")
+	_, _ = fmt.Fprintln(os.Stdout, "Built-in commands: "+strings.Join(Names(), ", "))
 	_, _ = fmt.Fprintln(os.Stdout, "Features: quotes, pipes, I/O redirection, emacs-like editing, history, autosuggestions")
 
-	return true
+	return StatusSuccess
 }
 
-func handleTodo(args []string) bool {
+func handleTodo(args []string) int {
 	if len(args) < 2 {
 		// List todos
 		todos := loadTodos()
@@ -107,7 +238,7 @@ func handleTodo(args []string) bool {
 				_, _ = fmt.Fprintf(os.Stdout, "%d. %s\n", i+1, todo)
 			}
 		}
-		return true
+		return StatusSuccess
 	}
 
 	// Add new todo
@@ -115,11 +246,11 @@ func handleTodo(args []string) bool {
 	err := addTodo(todoText)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "dsh: todo: %v\n", err)
-		return false
+		return StatusFailure
 	}
 
 	_, _ = fmt.Fprintf(os.Stdout, "Added todo: %s\n", todoText)
-	return true
+	return StatusSuccess
 }
 
 func loadTodos() []string {
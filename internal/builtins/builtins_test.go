@@ -88,7 +88,7 @@ func TestExecuteBuiltin_EmptyArgs(t *testing.T) {
 
 func TestIsBuiltin(t *testing.T) {
 	t.Parallel()
-	builtins := []string{"cd", "pwd", "help", "exit", "todo"}
+	builtins := []string{"cd", "pwd", "help", "exit", "todo", "test", "[", "declare", "typeset", "unset", "echo", "printf"}
 
 	for _, cmd := range builtins {
 		if !IsBuiltin(cmd) {
@@ -96,7 +96,13 @@ func TestIsBuiltin(t *testing.T) {
 		}
 	}
 
-	nonBuiltins := []string{"ls", "echo", "cat", "grep"}
+	for _, name := range Names() {
+		if !IsBuiltin(name) {
+			t.Errorf("Names() lists %s, which IsBuiltin rejects", name)
+		}
+	}
+
+	nonBuiltins := []string{"ls", "sed", "cat", "grep"}
 	for _, cmd := range nonBuiltins {
 		if IsBuiltin(cmd) {
 			t.Errorf("IsBuiltin(%s) = true, want false", cmd)
@@ -104,6 +110,31 @@ func TestIsBuiltin(t *testing.T) {
 	}
 }
 
+func TestIsListing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"dirs"}, true},
+		{[]string{"dirs", "-v", "-1"}, true},
+		{[]string{"dirs", "-lc"}, false},
+		{[]string{"hash"}, true},
+		{[]string{"hash", "-r"}, false},
+		{[]string{"bind", "-p", "-l"}, true},
+		{[]string{"bind"}, false},
+		{[]string{"bind", "-x", `"\C-o": date`}, false},
+		{[]string{"echo"}, false},
+	}
+
+	for _, test := range tests {
+		if listing := IsListing(test.args); listing != test.expected {
+			t.Errorf("IsListing(%q) = %v, want %v", test.args, listing, test.expected)
+		}
+	}
+}
+
 func TestAddTodo(t *testing.T) {
 	// Create temporary home directory
 	tmpDir := t.TempDir()
@@ -161,3 +192,17 @@ func TestListTodos(t *testing.T) {
 		t.Error("First todo not found")
 	}
 }
+
+func TestRun_ExitStatus(t *testing.T) {
+	t.Parallel()
+
+	if status := Run([]string{"exit", "3"}); status != 3 {
+		t.Errorf("exit 3 should return status 3, got %d", status)
+	}
+	if status := Run([]string{"exit", "abc"}); status != StatusUsage {
+		t.Errorf("exit with non-numeric argument should return %d, got %d", StatusUsage, status)
+	}
+	if !EndsShell("exit") || EndsShell("cd") {
+		t.Error("only exit should end the shell")
+	}
+}
@@ -0,0 +1,378 @@
+package builtins
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dsh/internal/dirs"
+	"dsh/internal/variables"
+)
+
+// handleCD implements cd. Without an operand it goes to HOME, and with -
+// to OLDPWD. A relative directory that is not found from the current one
+// is searched for in CDPATH. -P resolves symbolic links; -L, the default,
+// keeps them, so that cd .. returns the way it came.
+func handleCD(args []string) int {
+	physical, i, ok := parseDirectoryFlags("cd", args)
+	if !ok {
+		return StatusUsage
+	}
+
+	operands := args[i:]
+	if len(operands) > 1 {
+		_, _ = fmt.Fprintln(os.Stderr, "dsh: cd: too many arguments")
+
+		return StatusFailure
+	}
+
+	var target string
+
+	printDir := false
+
+	switch {
+	case len(operands) == 0:
+		target = os.Getenv("HOME")
+		if target == "" {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: cd: HOME not set\n")
+
+			return StatusFailure
+		}
+	case operands[0] == "-":
+		previous, ok := variables.Get("OLDPWD")
+		if !ok || previous == "" {
+			_, _ = fmt.Fprintln(os.Stderr, "dsh: cd: OLDPWD not set")
+
+			return StatusFailure
+		}
+		target, printDir = previous, true
+	default:
+		target = operands[0]
+		if found, fromPath, ok := searchCDPath(target); ok {
+			target, printDir = found, fromPath
+		}
+	}
+
+	if err := dirs.Change(target, physical); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: cd: %v\n", err)
+
+		return StatusFailure
+	}
+
+	if printDir {
+		_, _ = fmt.Fprintln(os.Stdout, dirs.Current())
+	}
+
+	return StatusSuccess
+}
+
+// handlePWD implements pwd, printing the logical current directory, or
+// with -P the physical one.
+func handlePWD(args []string) int {
+	physical, _, ok := parseDirectoryFlags("pwd", args)
+	if !ok {
+		return StatusUsage
+	}
+
+	dir := dirs.Current()
+	if physical {
+		dir = dirs.Physical()
+	}
+
+	if dir == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "dsh: pwd: cannot determine the current directory")
+
+		return StatusFailure
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, dir)
+
+	return StatusSuccess
+}
+
+// parseDirectoryFlags reads the -L and -P flags of cd and pwd. It returns
+// whether links are resolved and the index of the first operand.
+func parseDirectoryFlags(name string, args []string) (bool, int, bool) {
+	physical := false
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'L':
+				physical = false
+			case 'P':
+				physical = true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: %s: -%c: %v\n", name, flag, ErrInvalidOption)
+
+				return false, i, false
+			}
+		}
+	}
+
+	return physical, i, true
+}
+
+// searchCDPath looks for dir in the directories of CDPATH. It reports
+// whether it was found and whether it came from a non-empty entry, in
+// which case cd prints where it went. Paths starting with /, . or .. are
+// never searched for.
+func searchCDPath(dir string) (string, bool, bool) {
+	cdpath, _ := variables.Get("CDPATH")
+	if cdpath == "" || dir == "" || filepath.IsAbs(dir) || dir == "." || dir == ".." ||
+		strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../") {
+		return "", false, false
+	}
+
+	for _, entry := range strings.Split(cdpath, ":") {
+		base := entry
+		if base == "" {
+			base = "."
+		}
+
+		candidate := filepath.Join(base, dir)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, entry != "", true
+		}
+	}
+
+	return "", false, false
+}
+
+// handlePushd implements pushd. With a directory it changes to it and
+// pushes the previous one onto the stack; with +N or -N it rotates that
+// entry to the top; alone it swaps the top two entries.
+func handlePushd(args []string) int {
+	operands := directoryOperands(args)
+	if len(operands) > 1 {
+		_, _ = fmt.Fprintln(os.Stderr, "dsh: pushd: too many arguments")
+
+		return StatusFailure
+	}
+
+	stack := dirs.Stack()
+
+	if len(operands) == 0 {
+		if len(stack) < 2 {
+			_, _ = fmt.Fprintln(os.Stderr, "dsh: pushd: no other directory")
+
+			return StatusFailure
+		}
+
+		return changeStack("pushd", stack[1], slices.Concat(stack[:1], stack[2:]))
+	}
+
+	if index, isIndex, ok := stackIndex("pushd", operands[0], len(stack)); isIndex {
+		if !ok {
+			return StatusFailure
+		}
+
+		rotated := slices.Concat(stack[index:], stack[:index])
+
+		return changeStack("pushd", rotated[0], rotated[1:])
+	}
+
+	return changeStack("pushd", operands[0], stack)
+}
+
+// handlePopd implements popd, which removes the top entry of the stack
+// and changes to the new top, or with +N or -N removes that entry.
+func handlePopd(args []string) int {
+	operands := directoryOperands(args)
+	if len(operands) > 1 {
+		_, _ = fmt.Fprintln(os.Stderr, "dsh: popd: too many arguments")
+
+		return StatusFailure
+	}
+
+	stack := dirs.Stack()
+	if len(stack) < 2 {
+		_, _ = fmt.Fprintln(os.Stderr, "dsh: popd: directory stack empty")
+
+		return StatusFailure
+	}
+
+	index := 0
+	if len(operands) == 1 {
+		var isIndex, ok bool
+		index, isIndex, ok = stackIndex("popd", operands[0], len(stack))
+		if !isIndex {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: popd: %s: invalid argument\n", operands[0])
+
+			return StatusUsage
+		}
+		if !ok {
+			return StatusFailure
+		}
+	}
+
+	if index == 0 {
+		return changeStack("popd", stack[1], stack[2:])
+	}
+
+	stack = slices.Delete(stack, index, index+1)
+	dirs.SetStack(stack[1:])
+	printStack(os.Stdout, stack, false, false, false)
+
+	return StatusSuccess
+}
+
+// handleDirs implements dirs, which prints the directory stack, or with
+// +N or -N one entry of it. -c clears the stack, -l prints full paths
+// rather than abbreviating HOME to ~, -p prints one entry per line and
+// -v numbers them.
+func handleDirs(args []string) int {
+	return showDirs(args, os.Stdout)
+}
+
+// showDirs runs dirs writing to stdout.
+func showDirs(args []string, stdout io.Writer) int {
+	var clearStack, long, perLine, numbered bool
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-' && !isNumber(args[i][1:]); i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'c':
+				clearStack = true
+			case 'l':
+				long = true
+			case 'p':
+				perLine = true
+			case 'v':
+				perLine, numbered = true, true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: dirs: -%c: %v\n", flag, ErrInvalidOption)
+
+				return StatusUsage
+			}
+		}
+	}
+
+	if clearStack {
+		dirs.SetStack(nil)
+
+		return StatusSuccess
+	}
+
+	stack := dirs.Stack()
+
+	switch operands := args[i:]; len(operands) {
+	case 0:
+		printStack(stdout, stack, long, perLine, numbered)
+	case 1:
+		index, isIndex, ok := stackIndex("dirs", operands[0], len(stack))
+		if !isIndex {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: dirs: %s: invalid argument\n", operands[0])
+
+			return StatusUsage
+		}
+		if !ok {
+			return StatusFailure
+		}
+
+		printStack(stdout, stack[index:index+1], long, false, false)
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "dsh: dirs: too many arguments")
+
+		return StatusFailure
+	}
+
+	return StatusSuccess
+}
+
+// changeStack changes to dir and makes rest the stack below it, then
+// prints the stack as pushd and popd do.
+func changeStack(name, dir string, rest []string) int {
+	if err := dirs.Change(dir, false); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %s: %v\n", name, err)
+
+		return StatusFailure
+	}
+
+	dirs.SetStack(rest)
+	printStack(os.Stdout, dirs.Stack(), false, false, false)
+
+	return StatusSuccess
+}
+
+// directoryOperands returns the operands of pushd and popd, after an
+// optional --.
+func directoryOperands(args []string) []string {
+	operands := args[1:]
+	if len(operands) > 0 && operands[0] == "--" {
+		operands = operands[1:]
+	}
+
+	return operands
+}
+
+// stackIndex parses a +N or -N operand into an index from the top of a
+// stack of size entries. It reports whether the operand has that form
+// and whether the entry exists, printing an error if it does not.
+func stackIndex(name, operand string, size int) (int, bool, bool) {
+	if len(operand) < 2 || (operand[0] != '+' && operand[0] != '-') || !isNumber(operand[1:]) {
+		return 0, false, false
+	}
+
+	n, err := strconv.Atoi(operand[1:])
+	if err != nil || n >= size {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %s: %s: directory stack index out of range\n", name, operand)
+
+		return 0, true, false
+	}
+
+	if operand[0] == '-' {
+		n = size - 1 - n
+	}
+
+	return n, true, true
+}
+
+// printStack prints the entries of the directory stack on one line, or
+// with perLine one per line, numbered if asked.
+func printStack(stdout io.Writer, stack []string, long, perLine, numbered bool) {
+	shown := make([]string, len(stack))
+	for i, dir := range stack {
+		shown[i] = dir
+		if !long {
+			shown[i] = dirs.Abbreviate(dir)
+		}
+	}
+
+	if !perLine {
+		_, _ = fmt.Fprintln(stdout, strings.Join(shown, " "))
+
+		return
+	}
+
+	for i, dir := range shown {
+		if numbered {
+			_, _ = fmt.Fprintf(stdout, "%2d  %s\n", i, dir)
+		} else {
+			_, _ = fmt.Fprintln(stdout, dir)
+		}
+	}
+}
+
+// isNumber reports whether text is a non-empty run of decimal digits.
+func isNumber(text string) bool {
+	return text != "" && strings.Trim(text, "0123456789") == ""
+}
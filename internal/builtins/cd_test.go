@@ -0,0 +1,115 @@
+package builtins
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"dsh/internal/dirs"
+)
+
+// setupDirs changes to a new directory holding the named subdirectories
+// and returns it.
+func setupDirs(t *testing.T, names ...string) string {
+	t.Helper()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(root, name), 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Chdir(root)
+	t.Setenv("OLDPWD", "")
+	t.Setenv("CDPATH", "")
+	t.Cleanup(func() { dirs.SetStack(nil) })
+
+	return root
+}
+
+func TestCD_Previous(t *testing.T) {
+	root := setupDirs(t, "one")
+
+	if status := Run([]string{"cd", "-"}); status != StatusFailure {
+		t.Errorf("cd - without OLDPWD: expected status %d, got %d", StatusFailure, status)
+	}
+
+	Run([]string{"cd", "one"})
+	if status := Run([]string{"cd", "-"}); status != StatusSuccess {
+		t.Fatalf("cd - failed with status %d", status)
+	}
+	if dirs.Current() != root || os.Getenv("OLDPWD") != filepath.Join(root, "one") {
+		t.Errorf("Expected to be back in %s with OLDPWD one, got %s and %s", root, dirs.Current(), os.Getenv("OLDPWD"))
+	}
+}
+
+func TestCD_CDPath(t *testing.T) {
+	root := setupDirs(t, "projects/app", "other")
+	t.Setenv("CDPATH", ":"+filepath.Join(root, "projects"))
+
+	if status := Run([]string{"cd", "other"}); status != StatusSuccess || dirs.Current() != filepath.Join(root, "other") {
+		t.Errorf("Expected the current directory to be searched first, got status %d in %s", status, dirs.Current())
+	}
+
+	if status := Run([]string{"cd", "app"}); status != StatusSuccess || dirs.Current() != filepath.Join(root, "projects", "app") {
+		t.Errorf("Expected app to be found in CDPATH, got status %d in %s", status, dirs.Current())
+	}
+
+	if status := Run([]string{"cd", "./app"}); status != StatusFailure {
+		t.Errorf("./app should not be searched for in CDPATH, got status %d", status)
+	}
+}
+
+func TestPushdPopd(t *testing.T) {
+	root := setupDirs(t, "a", "b")
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+
+	Run([]string{"pushd", a})
+	Run([]string{"pushd", b})
+	if stack := dirs.Stack(); !slices.Equal(stack, []string{b, a, root}) {
+		t.Fatalf("Expected stack %v, got %v", []string{b, a, root}, stack)
+	}
+
+	Run([]string{"pushd"})
+	if stack := dirs.Stack(); !slices.Equal(stack, []string{a, b, root}) {
+		t.Errorf("pushd alone should swap the top entries, got %v", stack)
+	}
+
+	Run([]string{"pushd", "+2"})
+	if stack := dirs.Stack(); !slices.Equal(stack, []string{root, a, b}) || dirs.Current() != root {
+		t.Errorf("pushd +2 should rotate the stack, got %v", stack)
+	}
+
+	Run([]string{"popd", "-0"})
+	if stack := dirs.Stack(); !slices.Equal(stack, []string{root, a}) {
+		t.Errorf("popd -0 should remove the bottom entry, got %v", stack)
+	}
+
+	Run([]string{"popd"})
+	if stack := dirs.Stack(); !slices.Equal(stack, []string{a}) || dirs.Current() != a {
+		t.Errorf("popd should change to the next entry, got %v", stack)
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"popd"}, StatusFailure},
+		{[]string{"pushd", "+5"}, StatusFailure},
+		{[]string{"dirs", "-v"}, StatusSuccess},
+		{[]string{"dirs", "+0"}, StatusSuccess},
+		{[]string{"dirs", "-x"}, StatusUsage},
+		{[]string{"pwd", "-P"}, StatusSuccess},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
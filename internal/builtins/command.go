@@ -0,0 +1,76 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+
+	"dsh/internal/commands"
+	"dsh/internal/parser"
+)
+
+// Kinds of command name, as type -t prints them.
+const (
+	kindKeyword = "keyword"
+	kindBuiltin = "builtin"
+	kindFile    = "file"
+)
+
+// resolve returns what name runs: a keyword, a builtin or a file with its
+// path. dsh has no aliases or shell functions, which would come first.
+func resolve(name string) (string, string, bool) {
+	switch {
+	case parser.IsReservedWord(name):
+		return kindKeyword, "", true
+	case IsBuiltin(name):
+		return kindBuiltin, "", true
+	}
+
+	path, ok := commands.Find(name)
+	if !ok {
+		return "", "", false
+	}
+
+	return kindFile, path, true
+}
+
+// describe returns the sentence type and command -V print for name.
+func describe(name, kind, path string) string {
+	switch kind {
+	case kindKeyword:
+		return name + " is a shell keyword"
+	case kindBuiltin:
+		return name + " is a shell builtin"
+	}
+
+	if hashed, ok := commands.Hashed(name); ok && hashed == path {
+		return fmt.Sprintf("%s is hashed (%s)", name, path)
+	}
+
+	return name + " is " + path
+}
+
+// DescribeCommand prints how name resolves, as command -v does: the name
+// of a keyword or builtin, or the path of an external command. With
+// verbose it prints a sentence instead, as command -V does. It reports
+// whether name was found; verbose also reports a missing name on stderr.
+func DescribeCommand(name string, verbose bool) bool {
+	kind, path, ok := resolve(name)
+	if !ok {
+		if verbose {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: command: %s: not found\n", name)
+		}
+
+		return false
+	}
+
+	switch {
+	case verbose:
+		_, _ = fmt.Fprintln(os.Stdout, describe(name, kind, path))
+	case kind == kindFile:
+		_, _ = fmt.Fprintln(os.Stdout, path)
+	default:
+		_, _ = fmt.Fprintln(os.Stdout, name)
+	}
+
+	return true
+}
@@ -0,0 +1,25 @@
+package builtins
+
+import "testing"
+
+func TestDescribeCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		found bool
+	}{
+		{"if", true},
+		{"eval", true},
+		{"cd", true},
+		{"sh", true},
+		{"/bin/sh", true},
+		{"dsh_no_such_command", false},
+	}
+
+	for _, test := range tests {
+		for _, verbose := range []bool{false, true} {
+			if found := DescribeCommand(test.name, verbose); found != test.found {
+				t.Errorf("DescribeCommand(%q, %v): expected %v, got %v", test.name, verbose, test.found, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"dsh/internal/expand"
+	"dsh/internal/lexer"
+	"dsh/internal/variables"
+)
+
+var (
+	// ErrNotIdentifier indicates a variable name that is not valid.
+	ErrNotIdentifier = errors.New("not a valid identifier")
+	// ErrVariableNotFound indicates declare -p for an unset variable.
+	ErrVariableNotFound = errors.New("not found")
+	// ErrInvalidOption indicates an option a builtin does not accept.
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+// IsDeclaration reports whether name is a builtin whose arguments may be
+// assignments, which the executor passes through unexpanded.
+func IsDeclaration(name string) bool {
+	return name == "declare" || name == "typeset"
+}
+
+// handleDeclare implements declare and typeset: -a and -A create indexed
+// and associative arrays, and -p prints variables in a reusable form.
+func handleDeclare(args []string) int {
+	kind := variables.Scalar
+	printMode := false
+
+	i := 1
+	for ; i < len(args) && strings.HasPrefix(args[i], "-") && args[i] != "-"; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'a':
+				kind = variables.Indexed
+			case 'A':
+				kind = variables.Associative
+			case 'p':
+				printMode = true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: %s: -%c: %v\n", args[0], flag, ErrInvalidOption)
+
+				return StatusUsage
+			}
+		}
+	}
+
+	names := args[i:]
+	if len(names) == 0 {
+		for _, name := range variables.Names() {
+			printDeclaration(name)
+		}
+
+		return StatusSuccess
+	}
+
+	status := StatusSuccess
+
+	for _, arg := range names {
+		err := declareOne(arg, kind, printMode)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: %s: %v\n", args[0], err)
+			status = StatusFailure
+		}
+	}
+
+	return status
+}
+
+func declareOne(arg string, kind variables.Kind, printMode bool) error {
+	name := assignmentName(arg)
+	if !lexer.IsName(name) {
+		return fmt.Errorf("`%s': %w", arg, ErrNotIdentifier)
+	}
+
+	if printMode {
+		if !variables.IsSet(name) {
+			return fmt.Errorf("%s: %w", name, ErrVariableNotFound)
+		}
+		printDeclaration(name)
+
+		return nil
+	}
+
+	err := variables.Declare(name, kind)
+	if err != nil {
+		return err
+	}
+
+	if name == arg {
+		return nil
+	}
+
+	return expand.Assign(arg)
+}
+
+// assignmentName returns the variable name an argument refers to: the part
+// before any subscript, += or =.
+func assignmentName(arg string) string {
+	end := strings.IndexAny(arg, "[+=")
+	if end < 0 {
+		return arg
+	}
+
+	return arg[:end]
+}
+
+// printDeclaration writes a declare command that recreates name.
+func printDeclaration(name string) {
+	switch variables.KindOf(name) {
+	case variables.Indexed:
+		_, _ = fmt.Fprintf(os.Stdout, "declare -a %s=%s\n", name, declarationValue(name))
+	case variables.Associative:
+		_, _ = fmt.Fprintf(os.Stdout, "declare -A %s=%s\n", name, declarationValue(name))
+	case variables.Scalar:
+		_, _ = fmt.Fprintf(os.Stdout, "declare -- %s=%s\n", name, declarationValue(name))
+	}
+}
+
+// declarationValue returns the value of name as it is written in an
+// assignment: a quoted string, or a parenthesised list for arrays.
+func declarationValue(name string) string {
+	if variables.KindOf(name) == variables.Scalar {
+		value, _ := variables.Get(name)
+
+		return quoteValue(value)
+	}
+
+	keys := variables.Keys(name)
+	values := variables.GetArray(name)
+	elements := make([]string, len(keys))
+	for i, key := range keys {
+		elements[i] = fmt.Sprintf("[%s]=%s", key, quoteValue(values[i]))
+	}
+
+	return "(" + strings.Join(elements, " ") + ")"
+}
+
+// quoteValue double-quotes value, escaping the characters that are special
+// inside double quotes.
+func quoteValue(value string) string {
+	var result strings.Builder
+	result.WriteByte('"')
+
+	for _, ch := range value {
+		if strings.ContainsRune("\"\\$`", ch) {
+			result.WriteByte('\\')
+		}
+		result.WriteRune(ch)
+	}
+	result.WriteByte('"')
+
+	return result.String()
+}
+
+// handleUnset removes variables, or single elements written as name[key].
+func handleUnset(args []string) int {
+	status := StatusSuccess
+
+	for _, arg := range args[1:] {
+		if arg == "-v" {
+			continue
+		}
+
+		err := unsetOne(arg)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: unset: %v\n", err)
+			status = StatusFailure
+		}
+	}
+
+	return status
+}
+
+func unsetOne(arg string) error {
+	name, subscript, hasSubscript := strings.Cut(arg, "[")
+	if !lexer.IsName(name) || (hasSubscript && !strings.HasSuffix(subscript, "]")) {
+		return fmt.Errorf("`%s': %w", arg, ErrNotIdentifier)
+	}
+
+	if !hasSubscript {
+		variables.Unset(name)
+
+		return nil
+	}
+
+	subscript = strings.TrimSuffix(subscript, "]")
+	if subscript == "@" || subscript == "*" {
+		variables.Unset(name)
+
+		return nil
+	}
+
+	key, err := expand.Subscript(name, subscript)
+	if err != nil {
+		return err
+	}
+
+	return variables.UnsetElement(name, key)
+}
@@ -0,0 +1,71 @@
+package builtins
+
+import (
+	"reflect"
+	"testing"
+
+	"dsh/internal/variables"
+)
+
+func TestDeclare_Arrays(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("dsh_indexed")
+		variables.Unset("dsh_assoc")
+	})
+
+	if status := Run([]string{"declare", "-a", "dsh_indexed=(a b)"}); status != StatusSuccess {
+		t.Fatalf("declare -a failed with status %d", status)
+	}
+	if got := variables.GetArray("dsh_indexed"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %q", got)
+	}
+
+	if status := Run([]string{"typeset", "-A", "dsh_assoc"}); status != StatusSuccess {
+		t.Fatalf("typeset -A failed with status %d", status)
+	}
+	if variables.KindOf("dsh_assoc") != variables.Associative {
+		t.Error("Expected an associative array")
+	}
+
+	if status := Run([]string{"declare", "-A", "dsh_indexed"}); status != StatusFailure {
+		t.Errorf("Expected converting an indexed array to fail, got status %d", status)
+	}
+	if status := Run([]string{"declare", "-p", "dsh_missing"}); status != StatusFailure {
+		t.Errorf("Expected declare -p of an unset name to fail, got status %d", status)
+	}
+	if status := Run([]string{"declare", "-q"}); status != StatusUsage {
+		t.Errorf("Expected an invalid option to fail with status 2, got %d", status)
+	}
+	if status := Run([]string{"declare", "1bad=x"}); status != StatusFailure {
+		t.Errorf("Expected an invalid name to fail, got status %d", status)
+	}
+}
+
+func TestQuoteValue(t *testing.T) {
+	t.Parallel()
+
+	if got := quoteValue(`a "$b" \c`); got != `"a \"\$b\" \\c"` {
+		t.Errorf("Unexpected quoting %s", got)
+	}
+}
+
+func TestUnset(t *testing.T) {
+	variables.SetArray("dsh_unset", []string{"a", "b", "c"})
+	variables.Set("dsh_scalar", "x")
+	t.Cleanup(func() { variables.Unset("dsh_unset") })
+
+	if status := Run([]string{"unset", "dsh_unset[1]", "dsh_scalar"}); status != StatusSuccess {
+		t.Fatalf("unset failed with status %d", status)
+	}
+
+	if got := variables.Keys("dsh_unset"); !reflect.DeepEqual(got, []string{"0", "2"}) {
+		t.Errorf("Expected indices [0 2], got %v", got)
+	}
+	if variables.IsSet("dsh_scalar") {
+		t.Error("Expected dsh_scalar to be unset")
+	}
+
+	if status := Run([]string{"unset", "bad-name"}); status != StatusFailure {
+		t.Errorf("Expected an invalid name to fail, got status %d", status)
+	}
+}
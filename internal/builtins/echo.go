@@ -0,0 +1,187 @@
+package builtins
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// handleEcho implements echo, which prints its arguments separated by
+// spaces. -n leaves out the trailing newline and -e expands backslash
+// escapes, as %b of printf does; -E turns them off again. A word is only
+// taken as flags if every letter of it is one.
+func handleEcho(args []string) int {
+	return echo(args, os.Stdout)
+}
+
+// echo runs echo writing to stdout.
+func echo(args []string, stdout io.Writer) int {
+	newline, escapes := true, false
+
+	i := 1
+	for ; i < len(args) && isEchoFlags(args[i]); i++ {
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+	}
+
+	var out strings.Builder
+
+	for j, arg := range args[i:] {
+		if j > 0 {
+			out.WriteByte(' ')
+		}
+
+		if !escapes {
+			out.WriteString(arg)
+
+			continue
+		}
+
+		// \c ends the output, trailing newline included
+		text, stop := expandEscapes(arg, true)
+		out.WriteString(text)
+		if stop {
+			newline = false
+
+			break
+		}
+	}
+
+	if newline {
+		out.WriteByte('\n')
+	}
+
+	_, _ = io.WriteString(stdout, out.String())
+
+	return StatusSuccess
+}
+
+// isEchoFlags reports whether arg is a cluster of echo's flags.
+func isEchoFlags(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && strings.Trim(arg[1:], "neE") == ""
+}
+
+// expandEscapes expands the backslash escapes of text. For echo -e and
+// %b, octal escapes are written \0nnn and \c ends the output, which the
+// second result reports; in a printf format they are written \nnn.
+func expandEscapes(text string, echoStyle bool) (string, bool) {
+	var out strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 == len(text) {
+			out.WriteByte(text[i])
+
+			continue
+		}
+
+		i++
+		switch ch := text[i]; ch {
+		case 'a':
+			out.WriteByte('\a')
+		case 'b':
+			out.WriteByte('\b')
+		case 'e', 'E':
+			out.WriteByte('\x1b')
+		case 'f':
+			out.WriteByte('\f')
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		case 'v':
+			out.WriteByte('\v')
+		case '\\':
+			out.WriteByte('\\')
+		case 'c':
+			if echoStyle {
+				return out.String(), true
+			}
+			out.WriteString(`\c`)
+		case '"', '\'':
+			if echoStyle {
+				out.WriteByte('\\')
+			}
+			out.WriteByte(ch)
+		case 'x', 'u', 'U':
+			digits := 2
+			switch ch {
+			case 'u':
+				digits = 4
+			case 'U':
+				digits = 8
+			}
+			value, n := parseDigits(text[i+1:], 16, digits)
+			if n == 0 {
+				out.WriteByte('\\')
+				out.WriteByte(ch)
+
+				continue
+			}
+
+			if ch == 'x' {
+				out.WriteByte(byte(value))
+			} else {
+				out.WriteRune(rune(value))
+			}
+			i += n
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// Octal is \0nnn for echo and \nnn in a format
+			switch {
+			case echoStyle && ch == '0':
+				value, n := parseDigits(text[i+1:], 8, 3)
+				out.WriteByte(byte(value))
+				i += n
+			case !echoStyle:
+				value, n := parseDigits(text[i:], 8, 3)
+				out.WriteByte(byte(value))
+				i += n - 1
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(ch)
+			}
+		default:
+			out.WriteByte('\\')
+			out.WriteByte(ch)
+		}
+	}
+
+	return out.String(), false
+}
+
+// parseDigits parses up to limit digits of the given base at the start
+// of text, returning the value and how many digits it used.
+func parseDigits(text string, base, limit int) (uint64, int) {
+	n := 0
+	for n < len(text) && n < limit && isDigitOf(text[n], base) {
+		n++
+	}
+
+	if n == 0 {
+		return 0, 0
+	}
+
+	value, _ := strconv.ParseUint(text[:n], base, 64)
+
+	return value, n
+}
+
+// isDigitOf reports whether ch is a digit of an octal or hexadecimal
+// number.
+func isDigitOf(ch byte, base int) bool {
+	if base == 8 {
+		return ch >= '0' && ch <= '7'
+	}
+
+	return strings.IndexByte("0123456789abcdefABCDEF", ch) >= 0
+}
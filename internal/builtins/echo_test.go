@@ -0,0 +1,59 @@
+package builtins
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// captureStdout returns what run writes to standard output.
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	run()
+	os.Stdout = stdout
+	_ = writer.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestEcho(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"echo", "a", "b"}, "a b\n"},
+		{[]string{"echo"}, "\n"},
+		{[]string{"echo", "-n", "a"}, "a"},
+		{[]string{"echo", "-e", `a\tb\n`}, "a\tb\n\n"},
+		{[]string{"echo", `a\tb`}, "a\\tb\n"},
+		{[]string{"echo", "-eE", `a\tb`}, "a\\tb\n"},
+		{[]string{"echo", "-e", `\0101\x42é`}, "ABé\n"},
+		{[]string{"echo", "-e", `one\ctwo`, "three"}, "one"},
+		{[]string{"echo", "-x", "--", "-n"}, "-x -- -n\n"},
+	}
+
+	for _, test := range tests {
+		output := captureStdout(t, func() {
+			if status := Run(test.args); status != StatusSuccess {
+				t.Errorf("%v: expected success, got status %d", test.args, status)
+			}
+		})
+
+		if output != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.args, test.expected, output)
+		}
+	}
+}
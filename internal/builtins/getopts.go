@@ -0,0 +1,142 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"dsh/internal/lexer"
+	"dsh/internal/variables"
+)
+
+// getoptsUsage is printed when getopts is given too few arguments.
+const getoptsUsage = "getopts optstring name [arg ...]"
+
+// handleGetopts implements getopts, which reads the next option from the
+// arguments given or, without them, from the positional parameters. It
+// assigns the option letter to name and its argument, for a letter
+// followed by : in optstring, to OPTARG, and advances OPTIND, the index
+// of the next argument. Options may be clustered, as in -ab, and
+// an argument may follow its option in the same word. At the end of the
+// options, marked by --, - or a word not starting with -, it sets name
+// to ? and fails. An unknown option or a missing argument sets name to ?
+// and prints an error, unless optstring starts with :, which instead
+// sets OPTARG to the letter and name to ? or : respectively. OPTERR=0
+// also turns the errors off.
+func handleGetopts(args []string) int {
+	if len(args) < 3 {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: getopts: usage: %s\n", getoptsUsage)
+
+		return StatusUsage
+	}
+
+	optstring, name, operands := args[1], args[2], args[3:]
+	if len(operands) == 0 {
+		operands = variables.Positional()
+	}
+	if !lexer.IsName(name) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: getopts: `%s': %v\n", name, ErrNotIdentifier)
+
+		return StatusFailure
+	}
+
+	silent := strings.HasPrefix(optstring, ":")
+
+	index := 1
+	if value, ok := variables.Get("OPTIND"); ok {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			index = parsed
+		}
+	}
+
+	// The place within a cluster such as -abc is kept beside OPTIND
+	offset := max(variables.OptionOffset(), 1)
+
+	variables.Unset("OPTARG")
+
+	if index > len(operands) || !isOptionWord(operands[index-1]) || offset >= len(operands[index-1]) {
+		return endOptions(name, index)
+	}
+
+	word := operands[index-1]
+	if word == "--" {
+		return endOptions(name, index+1)
+	}
+
+	letter := word[offset]
+	offset++
+
+	// The rest of the word holds more options or this one's argument
+	next := func() {
+		if offset < len(word) {
+			variables.Set("OPTIND", strconv.Itoa(index))
+			variables.SetOptionOffset(offset)
+		} else {
+			variables.Set("OPTIND", strconv.Itoa(index+1))
+		}
+	}
+
+	spec := strings.IndexByte(optstring, letter)
+	if letter == ':' || spec < 0 {
+		reportOption(name, "?", letter, silent, "illegal option")
+		next()
+
+		return StatusSuccess
+	}
+
+	if spec+1 >= len(optstring) || optstring[spec+1] != ':' {
+		variables.Set(name, string(letter))
+		next()
+
+		return StatusSuccess
+	}
+
+	switch {
+	case offset < len(word):
+		variables.Set("OPTARG", word[offset:])
+		variables.Set(name, string(letter))
+		index++
+	case index < len(operands):
+		variables.Set("OPTARG", operands[index])
+		variables.Set(name, string(letter))
+		index += 2
+	default:
+		index++
+		reportOption(name, ":", letter, silent, "option requires an argument")
+	}
+	variables.Set("OPTIND", strconv.Itoa(index))
+
+	return StatusSuccess
+}
+
+// isOptionWord reports whether word holds options, which start with a -
+// that is not all of the word.
+func isOptionWord(word string) bool {
+	return len(word) > 1 && word[0] == '-'
+}
+
+// endOptions sets name and OPTIND once the options are used up.
+func endOptions(name string, index int) int {
+	variables.Set(name, "?")
+	variables.Set("OPTIND", strconv.Itoa(index))
+
+	return StatusFailure
+}
+
+// reportOption handles an unknown option or a missing argument. In
+// silent mode name is set to silentName and OPTARG to the letter;
+// otherwise name is set to ? and an error is printed unless OPTERR is 0.
+func reportOption(name, silentName string, letter byte, silent bool, message string) {
+	if silent {
+		variables.Set(name, silentName)
+		variables.Set("OPTARG", string(letter))
+
+		return
+	}
+
+	if opterr, ok := variables.Get("OPTERR"); !ok || opterr != "0" {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %s -- %c\n", message, letter)
+	}
+	variables.Set(name, "?")
+}
@@ -0,0 +1,111 @@
+package builtins
+
+import (
+	"testing"
+
+	"dsh/internal/variables"
+)
+
+// getoptsStep is the outcome of one call of getopts.
+type getoptsStep struct {
+	status int
+	opt    string
+	optarg string
+	optind string
+}
+
+// runGetopts calls getopts once for each step expected, starting over
+// from OPTIND=1, and compares the variables it sets.
+func runGetopts(t *testing.T, optstring string, args []string, steps []getoptsStep) {
+	t.Helper()
+
+	variables.Set("OPTIND", "1")
+	t.Cleanup(func() {
+		variables.Unset("OPTIND")
+		variables.Unset("OPTARG")
+		variables.Unset("OPTERR")
+		variables.Unset("opt")
+	})
+
+	for i, step := range steps {
+		status := handleGetopts(append([]string{"getopts", optstring, "opt"}, args...))
+		opt, _ := variables.Get("opt")
+		optarg, _ := variables.Get("OPTARG")
+		optind, _ := variables.Get("OPTIND")
+
+		got := getoptsStep{status: status, opt: opt, optarg: optarg, optind: optind}
+		if got != step {
+			t.Errorf("%q %v, call %d: expected %+v, got %+v", optstring, args, i+1, step, got)
+		}
+	}
+}
+
+func TestGetopts(t *testing.T) {
+	runGetopts(t, "ab:c", []string{"-ac", "-bval", "-b", "next", "--", "-a"}, []getoptsStep{
+		{StatusSuccess, "a", "", "1"},
+		{StatusSuccess, "c", "", "2"},
+		{StatusSuccess, "b", "val", "3"},
+		{StatusSuccess, "b", "next", "5"},
+		{StatusFailure, "?", "", "6"},
+	})
+}
+
+func TestGetopts_StartOver(t *testing.T) {
+	// OPTIND=1 in the middle of a cluster starts from its first letter
+	runGetopts(t, "ab", []string{"-ab"}, []getoptsStep{{StatusSuccess, "a", "", "1"}})
+	runGetopts(t, "ab", []string{"-ab"}, []getoptsStep{{StatusSuccess, "a", "", "1"}})
+}
+
+func TestGetopts_EndOfOptions(t *testing.T) {
+	runGetopts(t, "a", []string{"-a", "file", "-a"}, []getoptsStep{
+		{StatusSuccess, "a", "", "2"},
+		{StatusFailure, "?", "", "2"},
+	})
+
+	runGetopts(t, "a", []string{"-"}, []getoptsStep{
+		{StatusFailure, "?", "", "1"},
+	})
+
+	runGetopts(t, "a", nil, []getoptsStep{
+		{StatusFailure, "?", "", "1"},
+	})
+}
+
+func TestGetopts_PositionalParameters(t *testing.T) {
+	variables.SetPositional([]string{"-v", "-o", "out", "file"})
+	t.Cleanup(func() { variables.SetPositional(nil) })
+
+	runGetopts(t, "vo:", nil, []getoptsStep{
+		{StatusSuccess, "v", "", "2"},
+		{StatusSuccess, "o", "out", "4"},
+		{StatusFailure, "?", "", "4"},
+	})
+}
+
+func TestGetopts_Errors(t *testing.T) {
+	variables.Set("OPTERR", "0")
+	runGetopts(t, "ab:", []string{"-xa", "-b"}, []getoptsStep{
+		{StatusSuccess, "?", "", "1"},
+		{StatusSuccess, "a", "", "2"},
+		{StatusSuccess, "?", "", "3"},
+		{StatusFailure, "?", "", "3"},
+	})
+}
+
+func TestGetopts_Silent(t *testing.T) {
+	runGetopts(t, ":ab:", []string{"-x", "-b"}, []getoptsStep{
+		{StatusSuccess, "?", "x", "2"},
+		{StatusSuccess, ":", "b", "3"},
+		{StatusFailure, "?", "", "3"},
+	})
+}
+
+func TestGetopts_Usage(t *testing.T) {
+	if status := handleGetopts([]string{"getopts", "a"}); status != StatusUsage {
+		t.Errorf("Expected status %d, got %d", StatusUsage, status)
+	}
+
+	if status := handleGetopts([]string{"getopts", "a", "1opt"}); status != StatusFailure {
+		t.Errorf("Expected status %d, got %d", StatusFailure, status)
+	}
+}
@@ -0,0 +1,89 @@
+package builtins
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"dsh/internal/commands"
+)
+
+// handleHash implements hash. Without arguments it lists the remembered
+// commands with their hit counts; with names it looks them up and
+// remembers them. -r forgets everything and -d forgets the names given.
+func handleHash(args []string) int {
+	return hash(args, os.Stdout)
+}
+
+// hash runs hash writing to stdout.
+func hash(args []string, stdout io.Writer) int {
+	var reset, forget bool
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'r':
+				reset = true
+			case 'd':
+				forget = true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: hash: -%c: %v\n", flag, ErrInvalidOption)
+
+				return StatusUsage
+			}
+		}
+	}
+
+	if reset {
+		commands.Reset()
+	}
+
+	names := args[i:]
+	if len(names) == 0 {
+		if !reset && !forget {
+			printHashTable(stdout)
+		}
+
+		return StatusSuccess
+	}
+
+	status := StatusSuccess
+
+	for _, name := range names {
+		switch {
+		case forget:
+			if !commands.Forget(name) {
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: hash: %s: not found\n", name)
+				status = StatusFailure
+			}
+		case IsBuiltin(name):
+			// Builtins are never looked up in PATH
+		case !commands.Hash(name):
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: hash: %s: not found\n", name)
+			status = StatusFailure
+		}
+	}
+
+	return status
+}
+
+func printHashTable(stdout io.Writer) {
+	entries := commands.Entries()
+	if len(entries) == 0 {
+		_, _ = fmt.Fprintln(stdout, "hash: hash table empty")
+
+		return
+	}
+
+	_, _ = fmt.Fprintln(stdout, "hits\tcommand")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(stdout, "%4d\t%s\n", entry.Hits, entry.Path)
+	}
+}
@@ -0,0 +1,188 @@
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"dsh/internal/jobs"
+)
+
+// killUsage is printed when kill is given nothing to signal.
+const killUsage = "kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]"
+
+var (
+	// ErrInvalidSignal indicates a signal name or number kill does not know.
+	ErrInvalidSignal = errors.New("invalid signal specification")
+	// ErrNotProcessID indicates a kill target that is neither a number nor
+	// a job spec.
+	ErrNotProcessID = errors.New("arguments must be process or job IDs")
+)
+
+// signalNames returns the names of the signals without their SIG prefix,
+// indexed by number.
+func signalNames() []string {
+	return []string{
+		"", "HUP", "INT", "QUIT", "ILL", "TRAP", "ABRT", "BUS", "FPE",
+		"KILL", "USR1", "SEGV", "USR2", "PIPE", "ALRM", "TERM", "STKFLT",
+		"CHLD", "CONT", "STOP", "TSTP", "TTIN", "TTOU", "URG", "XCPU",
+		"XFSZ", "VTALRM", "PROF", "WINCH", "IO", "PWR", "SYS",
+	}
+}
+
+// handleKill implements kill, which sends a signal, TERM unless -s, -n or
+// -SIG names another, to processes and jobs. A job spec such as %1
+// signals every process of the job through its process group, and a
+// negative number signals a process group. With -l it lists the signals,
+// or translates between the names and numbers given.
+func handleKill(args []string) int {
+	sig := syscall.SIGTERM
+
+	i := 1
+	if i < len(args) && len(args[i]) > 1 && args[i][0] == '-' {
+		option := args[i][1:]
+
+		switch option {
+		case "l", "L":
+			return listSignals(args[i+1:])
+		case "-":
+			// Only the targets follow
+		case "s", "n":
+			if i+1 >= len(args) {
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: kill: -%s: option requires an argument\n", option)
+
+				return StatusUsage
+			}
+			i++
+			option = args[i]
+
+			fallthrough
+		default:
+			parsed, ok := parseSignal(option)
+			if !ok {
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: kill: %s: %v\n", option, ErrInvalidSignal)
+
+				return StatusFailure
+			}
+			sig = parsed
+		}
+		i++
+	}
+
+	if i < len(args) && args[i] == "--" {
+		i++
+	}
+
+	if i == len(args) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: kill: usage: %s\n", killUsage)
+
+		return StatusUsage
+	}
+
+	status := StatusSuccess
+	for _, target := range args[i:] {
+		if err := signalTarget(target, sig); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: kill: %v\n", err)
+			status = StatusFailure
+		}
+	}
+
+	return status
+}
+
+// signalTarget sends sig to a process ID or to the process group of a job.
+func signalTarget(target string, sig syscall.Signal) error {
+	pid := 0
+
+	if strings.HasPrefix(target, "%") {
+		job, err := jobs.Find(target)
+		if err != nil {
+			return err
+		}
+		pid = -job.Pgid
+	} else {
+		number, err := strconv.Atoi(target)
+		if err != nil {
+			return fmt.Errorf("%s: %w", target, ErrNotProcessID)
+		}
+		pid = number
+	}
+
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+
+	return nil
+}
+
+// parseSignal reads a signal number or name, with or without SIG and in
+// any case. 0 sends no signal, only checking that the target exists.
+func parseSignal(spec string) (syscall.Signal, bool) {
+	names := signalNames()
+
+	if number, err := strconv.Atoi(spec); err == nil {
+		if number < 0 || number >= len(names) {
+			return 0, false
+		}
+
+		return syscall.Signal(number), true
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+	for number, known := range names {
+		if number > 0 && known == name {
+			return syscall.Signal(number), true
+		}
+	}
+
+	return 0, false
+}
+
+// listSignals implements kill -l. Without arguments it lists every signal
+// with its number; otherwise it prints the name of each number, taking
+// the exit status of a command a signal ended as that signal, and the
+// number of each name.
+func listSignals(specs []string) int {
+	names := signalNames()
+
+	if len(specs) == 0 {
+		var out strings.Builder
+		for number := 1; number < len(names); number++ {
+			_, _ = fmt.Fprintf(&out, "%2d) SIG%s", number, names[number])
+			if number%5 == 0 || number == len(names)-1 {
+				out.WriteByte('\n')
+			} else {
+				out.WriteByte('\t')
+			}
+		}
+		_, _ = fmt.Fprint(os.Stdout, out.String())
+
+		return StatusSuccess
+	}
+
+	status := StatusSuccess
+	for _, spec := range specs {
+		if number, err := strconv.Atoi(spec); err == nil && number > 128 {
+			spec = strconv.Itoa(number - 128)
+		}
+
+		sig, ok := parseSignal(spec)
+		if !ok || sig == 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: kill: %s: %v\n", spec, ErrInvalidSignal)
+			status = StatusFailure
+
+			continue
+		}
+
+		if _, err := strconv.Atoi(spec); err == nil {
+			_, _ = fmt.Fprintln(os.Stdout, names[sig])
+		} else {
+			_, _ = fmt.Fprintln(os.Stdout, int(sig))
+		}
+	}
+
+	return status
+}
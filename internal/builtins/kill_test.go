@@ -0,0 +1,101 @@
+package builtins
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"dsh/internal/jobs"
+)
+
+func TestParseSignal(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]syscall.Signal{
+		"9":       syscall.SIGKILL,
+		"0":       0,
+		"TERM":    syscall.SIGTERM,
+		"SIGHUP":  syscall.SIGHUP,
+		"int":     syscall.SIGINT,
+		"sigusr1": syscall.SIGUSR1,
+	}
+
+	for spec, expected := range valid {
+		if sig, ok := parseSignal(spec); !ok || sig != expected {
+			t.Errorf("parseSignal(%q) = %v, %v, expected %v", spec, sig, ok, expected)
+		}
+	}
+
+	for _, spec := range []string{"", "-1", "32", "SIG", "NOPE"} {
+		if _, ok := parseSignal(spec); ok {
+			t.Errorf("parseSignal(%q) succeeded, expected it to fail", spec)
+		}
+	}
+}
+
+func TestKill_List(t *testing.T) {
+	output := captureStdout(t, func() { listSignals([]string{"15", "TERM", "sigkill", "130"}) })
+	if expected := "TERM\n15\n9\nINT\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	output = captureStdout(t, func() { listSignals(nil) })
+	if expected := " 1) SIGHUP\t 2) SIGINT\t"; len(output) < len(expected) || output[:len(expected)] != expected {
+		t.Errorf("Unexpected signal list %q", output)
+	}
+
+	if status := listSignals([]string{"64"}); status != StatusFailure {
+		t.Errorf("Expected status %d for an unknown signal, got %d", StatusFailure, status)
+	}
+}
+
+func TestKill_Job(t *testing.T) {
+	cmd := exec.CommandContext(context.Background(), "sleep", "10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep is not available: %v", err)
+	}
+
+	job := jobs.Add("sleep 10", []int{cmd.Process.Pid})
+	t.Cleanup(func() { jobs.Finish(job) })
+
+	if status := handleKill([]string{"kill", "-0", "%sleep"}); status != StatusSuccess {
+		t.Errorf("Expected kill -0 to find the job, got status %d", status)
+	}
+
+	if status := handleKill([]string{"kill", "-s", "INT", "%%"}); status != StatusSuccess {
+		t.Errorf("Expected status %d, got %d", StatusSuccess, status)
+	}
+
+	err := cmd.Wait()
+
+	var exitError *exec.ExitError
+	if !errors.As(err, &exitError) {
+		t.Fatalf("Expected the job to be killed, got %v", err)
+	}
+	if status, ok := exitError.Sys().(syscall.WaitStatus); !ok || status.Signal() != syscall.SIGINT {
+		t.Errorf("Expected the job to end with SIGINT, got %v", err)
+	}
+}
+
+func TestKill_Errors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"kill"}, StatusUsage},
+		{[]string{"kill", "-s"}, StatusUsage},
+		{[]string{"kill", "-NOPE", "1"}, StatusFailure},
+		{[]string{"kill", "-9"}, StatusUsage},
+		{[]string{"kill", "%99"}, StatusFailure},
+		{[]string{"kill", "-0", "pid"}, StatusFailure},
+	}
+
+	for _, test := range tests {
+		if status := handleKill(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package builtins
+
+import "errors"
+
+// errNoLineEditor indicates bind run before the shell installed its line
+// editor.
+var errNoLineEditor = errors.New("line editing is not available")
+
+// LineEditor is the shell's line editor as read and bind use it. The
+// shell installs it, since the editor is a layer above the builtins.
+type LineEditor interface {
+	// ReadLine reads a line with editing after prompt, keeping it out of
+	// the history, and returns io.EOF at the end of the input.
+	ReadLine(prompt string) (string, error)
+	// SetInputMode turns the echo and the line buffering of the terminal
+	// on or off until the function it returns is called.
+	SetInputMode(echo, canonical bool) (func(), error)
+	// Bind, BindCommand and Unbind change the key bindings as the lines of
+	// an inputrc file do, and ReadInputrc reads such a file.
+	Bind(line string) error
+	BindCommand(line string, replace bool) error
+	Unbind(keys string) error
+	ReadInputrc(path string) error
+	// Bindings lists the key bindings and Actions the editing actions.
+	Bindings() []string
+	Actions() []string
+}
+
+// lineEditor is installed by the shell.
+var lineEditor LineEditor //nolint:gochecknoglobals // Hook installed once at startup
+
+// SetLineEditor installs the line editor that read -e, read -s and bind
+// use. Until one is installed, read reads as without -e and bind fails.
+func SetLineEditor(editor LineEditor) {
+	lineEditor = editor
+}
@@ -0,0 +1,353 @@
+package builtins
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"dsh/internal/lexer"
+	"dsh/internal/variables"
+)
+
+// printfUsage is printed when printf is given no format.
+const printfUsage = "printf [-v var] format [arguments]"
+
+// handlePrintf implements printf, which writes its arguments under the
+// control of a format, reusing the format while arguments remain. With
+// -v var the output is assigned to var instead.
+func handlePrintf(args []string) int {
+	return printf(args, os.Stdout)
+}
+
+// printf runs printf writing to stdout.
+func printf(args []string, stdout io.Writer) int {
+	i := 1
+	target := ""
+
+	if i < len(args) && args[i] == "-v" {
+		if i+1 >= len(args) {
+			_, _ = fmt.Fprintln(os.Stderr, "dsh: printf: -v: option requires an argument")
+
+			return StatusUsage
+		}
+
+		target = args[i+1]
+		if !lexer.IsName(target) {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: printf: `%s': %v\n", target, ErrNotIdentifier)
+
+			return StatusFailure
+		}
+		i += 2
+	}
+
+	if i < len(args) && args[i] == "--" {
+		i++
+	}
+
+	if i >= len(args) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: printf: usage: %s\n", printfUsage)
+
+		return StatusUsage
+	}
+
+	p := &printer{args: args[i+1:], status: StatusSuccess}
+	p.run(args[i])
+
+	if target != "" {
+		variables.Set(target, p.out.String())
+	} else {
+		_, _ = io.WriteString(stdout, p.out.String())
+	}
+
+	return p.status
+}
+
+// printer formats the output of one printf.
+type printer struct {
+	out    strings.Builder
+	args   []string
+	next   int
+	status int
+	// stop is set by \c in %b and by an invalid format, which end the
+	// output.
+	stop bool
+}
+
+// run writes format once, and again as long as each pass consumes some
+// of the remaining arguments.
+func (p *printer) run(format string) {
+	for {
+		start := p.next
+		p.format(format)
+
+		if p.stop || p.next >= len(p.args) || p.next == start {
+			return
+		}
+	}
+}
+
+// format makes one pass over the format.
+func (p *printer) format(format string) {
+	for i := 0; i < len(format) && !p.stop; {
+		switch {
+		case format[i] == '\\':
+			end := escapeEnd(format, i)
+			text, _ := expandEscapes(format[i:end], false)
+			p.out.WriteString(text)
+			i = end
+		case format[i] == '%' && i+1 < len(format) && format[i+1] == '%':
+			p.out.WriteByte('%')
+			i += 2
+		case format[i] == '%':
+			i = p.conversion(format, i)
+		default:
+			p.out.WriteByte(format[i])
+			i++
+		}
+	}
+}
+
+// conversion writes the conversion that starts at format[start], which
+// is %, and returns the index after it.
+func (p *printer) conversion(format string, start int) int {
+	i := start + 1
+
+	// Flags, width and precision become a Go verb, which takes the same
+	// ones, with the * forms replaced by their arguments
+	var spec strings.Builder
+	spec.WriteByte('%')
+
+	for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+		spec.WriteByte(format[i])
+		i++
+	}
+
+	i = p.field(format, i, &spec)
+
+	hasPrecision := false
+	if i < len(format) && format[i] == '.' {
+		hasPrecision = true
+		spec.WriteByte('.')
+		i = p.field(format, i+1, &spec)
+	}
+
+	if i >= len(format) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: printf: %s: missing format character\n", format[start:])
+		p.status, p.stop = StatusFailure, true
+
+		return i
+	}
+
+	verb := format[i]
+	prefix := spec.String()
+
+	switch verb {
+	case 's':
+		_, _ = fmt.Fprintf(&p.out, prefix+"s", p.argument())
+	case 'b':
+		text, stop := expandEscapes(p.argument(), true)
+		_, _ = fmt.Fprintf(&p.out, prefix+"s", text)
+		p.stop = p.stop || stop
+	case 'q':
+		_, _ = fmt.Fprintf(&p.out, prefix+"s", shellQuote(p.argument()))
+	case 'c':
+		arg := p.argument()
+		if arg != "" {
+			r, _ := utf8.DecodeRuneInString(arg)
+			arg = string(r)
+		}
+		_, _ = fmt.Fprintf(&p.out, prefix+"s", arg)
+	case 'd', 'i':
+		_, _ = fmt.Fprintf(&p.out, prefix+"d", p.integer())
+	case 'u', 'o', 'x', 'X':
+		goVerb := string(verb)
+		if verb == 'u' {
+			goVerb = "d"
+		}
+		_, _ = fmt.Fprintf(&p.out, prefix+goVerb, uint64(p.integer())) //nolint:gosec // Negative numbers wrap, as in C
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		// C's %g has a precision of 6 unless given; Go's is the shortest
+		if !hasPrecision && (verb == 'g' || verb == 'G') {
+			prefix += ".6"
+		}
+		_, _ = fmt.Fprintf(&p.out, prefix+string(verb), p.float())
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: printf: %c: invalid format character\n", verb)
+		p.status, p.stop = StatusFailure, true
+	}
+
+	return i + 1
+}
+
+// field copies a width or precision to spec, taking it from the next
+// argument if it is *, and returns the index after it.
+func (p *printer) field(format string, i int, spec *strings.Builder) int {
+	if i < len(format) && format[i] == '*' {
+		spec.WriteString(strconv.FormatInt(p.integer(), 10))
+
+		return i + 1
+	}
+
+	for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+		spec.WriteByte(format[i])
+		i++
+	}
+
+	return i
+}
+
+// argument returns the next argument, or "" once they run out.
+func (p *printer) argument() string {
+	if p.next >= len(p.args) {
+		return ""
+	}
+
+	p.next++
+
+	return p.args[p.next-1]
+}
+
+// integer returns the next argument as an integer. Numbers may be
+// hexadecimal with 0x or octal with 0, and a leading quote gives the code
+// of the character after it.
+func (p *printer) integer() int64 {
+	arg := p.argument()
+
+	text := strings.TrimLeft(arg, " \t\n")
+	if text == "" {
+		return 0
+	}
+
+	if text[0] == '\'' || text[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(text[1:])
+		if r == utf8.RuneError {
+			return 0
+		}
+
+		return int64(r)
+	}
+
+	value, err := strconv.ParseInt(text, 0, 64)
+	if err != nil {
+		unsigned, uerr := strconv.ParseUint(text, 0, 64)
+		if uerr != nil {
+			p.invalidNumber(arg)
+
+			return 0
+		}
+		value = int64(unsigned) //nolint:gosec // Large values wrap, as in C
+	}
+
+	return value
+}
+
+// float returns the next argument as a floating point number.
+func (p *printer) float() float64 {
+	arg := p.argument()
+
+	text := strings.TrimLeft(arg, " \t\n")
+	if text == "" {
+		return 0
+	}
+
+	if text[0] == '\'' || text[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(text[1:])
+
+		return float64(r)
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		p.invalidNumber(arg)
+
+		return 0
+	}
+
+	return value
+}
+
+// invalidNumber reports an argument that is not a number. The output
+// goes on, with 0 in its place.
+func (p *printer) invalidNumber(arg string) {
+	_, _ = fmt.Fprintf(os.Stderr, "dsh: printf: %s: invalid number\n", arg)
+	p.status = StatusFailure
+}
+
+// escapeEnd returns the index after the backslash escape at format[i].
+func escapeEnd(format string, i int) int {
+	end := i + 2
+	if end > len(format) {
+		return len(format)
+	}
+
+	digits, base := 0, 16
+	switch ch := format[i+1]; ch {
+	case 'x':
+		digits = 2
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		// The first octal digit is already counted
+		digits, base = 2, 8
+	}
+
+	for n := 0; n < digits && end < len(format) && isDigitOf(format[end], base); n++ {
+		end++
+	}
+
+	return end
+}
+
+// shellQuote quotes text so that the shell reads it back as one word:
+// with backslashes, or as $'...' if it holds control characters.
+func shellQuote(text string) string {
+	if text == "" {
+		return "''"
+	}
+
+	if strings.IndexFunc(text, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		var quoted strings.Builder
+		quoted.WriteString("$'")
+
+		for _, r := range text {
+			switch r {
+			case '\n':
+				quoted.WriteString(`\n`)
+			case '\t':
+				quoted.WriteString(`\t`)
+			case '\r':
+				quoted.WriteString(`\r`)
+			case '\x1b':
+				quoted.WriteString(`\E`)
+			case '\'', '\\':
+				quoted.WriteByte('\\')
+				quoted.WriteRune(r)
+			default:
+				if r < ' ' || r == 0x7f {
+					_, _ = fmt.Fprintf(&quoted, `\%03o`, r)
+				} else {
+					quoted.WriteRune(r)
+				}
+			}
+		}
+		quoted.WriteByte('\'')
+
+		return quoted.String()
+	}
+
+	var quoted strings.Builder
+	for i, r := range text {
+		// ~ and # are only special at the start of a word
+		if strings.ContainsRune(" \t'\"\\$`|&;<>()*?[]{}!^", r) || (i == 0 && (r == '~' || r == '#')) {
+			quoted.WriteByte('\\')
+		}
+		quoted.WriteRune(r)
+	}
+
+	return quoted.String()
+}
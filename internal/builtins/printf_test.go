@@ -0,0 +1,89 @@
+package builtins
+
+import (
+	"testing"
+
+	"dsh/internal/variables"
+)
+
+func TestPrintf(t *testing.T) {
+	t.Cleanup(func() { variables.Unset("out") })
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"plain\\n"}, "plain\n"},
+		{[]string{"%s-%s,", "a", "b", "c"}, "a-b,c-,"},
+		{[]string{"%d %i %u", "42", "-7", "3"}, "42 -7 3"},
+		{[]string{"%x %X %o %#x", "255", "255", "8", "0x1f"}, "ff FF 10 0x1f"},
+		{[]string{"%5s|%-5s|%.2s", "ab", "cd", "efgh"}, "   ab|cd   |ef"},
+		{[]string{"%05d|%+d|% d", "42", "5", "5"}, "00042|+5| 5"},
+		{[]string{"%*d|%.*f", "4", "7", "1", "2.25"}, "   7|2.2"},
+		{[]string{"%f %.1e %g %g", "1.5", "1234", "1234567", "0.5"}, "1.500000 1.2e+03 1.23457e+06 0.5"},
+		{[]string{"%c%c", "hello", "world"}, "hw"},
+		{[]string{"%b|", `a\tb\0101`, `x\cy`, "never"}, "a\tbA|x"},
+		{[]string{"%q %q %q", "a b", "", "tab\there"}, `a\ b '' $'tab\there'`},
+		{[]string{`\101\x41%%\t`}, "AA%\t"},
+		{[]string{"%d", "'A"}, "65"},
+		{[]string{"%s"}, ""},
+	}
+
+	for _, test := range tests {
+		args := append([]string{"printf", "-v", "out"}, test.args...)
+		if status := Run(args); status != StatusSuccess {
+			t.Errorf("%v: expected success, got status %d", test.args, status)
+		}
+
+		if out, _ := variables.Get("out"); out != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.args, test.expected, out)
+		}
+	}
+}
+
+func TestPrintf_Errors(t *testing.T) {
+	t.Cleanup(func() { variables.Unset("out") })
+
+	tests := []struct {
+		args     []string
+		status   int
+		expected string
+	}{
+		{[]string{"printf", "-v", "out", "%d|", "12", "abc"}, StatusFailure, "12|0|"},
+		{[]string{"printf", "-v", "out", "a%zb"}, StatusFailure, "a"},
+		{[]string{"printf", "-v", "out", "%"}, StatusFailure, ""},
+		{[]string{"printf", "-v", "1out", "x"}, StatusFailure, ""},
+		{[]string{"printf", "-v"}, StatusUsage, ""},
+		{[]string{"printf"}, StatusUsage, ""},
+	}
+
+	for _, test := range tests {
+		variables.Unset("out")
+
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+		if out, _ := variables.Get("out"); out != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.args, test.expected, out)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"a b", `a\ b`},
+		{"it's", `it\'s`},
+		{"~/x#y", `\~/x#y`},
+		{"line\nbreak", `$'line\nbreak'`},
+	}
+
+	for _, test := range tests {
+		if quoted := shellQuote(test.text); quoted != test.expected {
+			t.Errorf("shellQuote(%q) = %q, want %q", test.text, quoted, test.expected)
+		}
+	}
+}
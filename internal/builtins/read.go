@@ -0,0 +1,452 @@
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mattn/go-isatty"
+
+	"dsh/internal/lexer"
+	"dsh/internal/variables"
+)
+
+// readTimeoutStatus is the status of read when its timeout expires: 128
+// plus SIGALRM, as in bash.
+const readTimeoutStatus = 128 + 14
+
+// defaultIFS splits the input of read when IFS is unset.
+const defaultIFS = " \t\n"
+
+// errReadTimeout indicates that read -t ran out of time.
+var errReadTimeout = errors.New("timed out")
+
+// errEditedInput indicates -e given with a flag that needs the input read
+// as it is typed, which the line editor cannot do.
+var errEditedInput = errors.New("-e cannot be used with -s, -t, -n or -d")
+
+// readOptions are the flags of read.
+type readOptions struct {
+	raw     bool
+	prompt  string
+	timeout time.Duration
+	timed   bool
+	delim   byte
+	// limit is the number of characters to read, or -1 for a whole line.
+	limit  int
+	silent bool
+	array  string
+	edit   bool
+}
+
+// handleRead implements read, which reads a line from standard input and
+// splits it on IFS into the named variables, the last taking the rest of
+// the line. Without names the line goes to REPLY, and with -a the fields
+// go to an array. Backslashes escape the next character and join lines
+// unless -r is given. -p shows a prompt on a terminal, -t gives up after
+// a timeout, -d ends the input at another character, -n stops after N
+// characters, -s hides what is typed and -e reads with the line editor,
+// which none of those four flags can be used with.
+func handleRead(args []string) int {
+	opts, i, ok := parseReadOptions(args)
+	if !ok {
+		return StatusUsage
+	}
+
+	if opts.edit && (opts.silent || opts.timed || opts.limit >= 0 || opts.delim != '\n') {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: read: %v\n", errEditedInput)
+
+		return StatusUsage
+	}
+
+	names := args[i:]
+	for _, name := range append([]string{opts.array}, names...) {
+		if name != "" && !lexer.IsName(name) {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: read: `%s': %v\n", name, ErrNotIdentifier)
+
+			return StatusFailure
+		}
+	}
+
+	// -t 0 only reports whether there is input to read
+	if opts.timed && opts.timeout == 0 {
+		if ready, _ := waitForInput(0); ready {
+			return StatusSuccess
+		}
+
+		return StatusFailure
+	}
+
+	var text string
+	var err error
+	if opts.edited() {
+		text, err = lineEditor.ReadLine(opts.prompt)
+	} else {
+		text, err = readInput(opts)
+	}
+
+	if errors.Is(err, errReadTimeout) {
+		return readTimeoutStatus
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: read: %v\n", err)
+
+		return StatusFailure
+	}
+
+	assignRead(text, names, opts)
+
+	// Input that ends without its delimiter is assigned but still fails
+	if err != nil {
+		return StatusFailure
+	}
+
+	return StatusSuccess
+}
+
+// edited reports whether read uses the line editor: with -e on a terminal.
+// The editor recalls the earlier answers of the session but never saves
+// them to the history file, since the answers read takes may be secrets.
+func (opts readOptions) edited() bool {
+	return opts.edit && lineEditor != nil && isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// parseReadOptions reads the flags of read and returns the index of the
+// first name.
+func parseReadOptions(args []string) (readOptions, int, bool) {
+	opts := readOptions{delim: '\n', limit: -1}
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		flags := args[i][1:]
+		for j := 0; j < len(flags); j++ {
+			flag := flags[j]
+
+			switch flag {
+			case 'r':
+				opts.raw = true
+			case 's':
+				opts.silent = true
+			case 'e':
+				opts.edit = true
+			case 'p', 't', 'd', 'n', 'a':
+				// The value is the rest of this word or the next argument
+				value := flags[j+1:]
+				if value == "" {
+					if i+1 >= len(args) {
+						_, _ = fmt.Fprintf(os.Stderr, "dsh: read: -%c: option requires an argument\n", flag)
+
+						return opts, i, false
+					}
+					i++
+					value = args[i]
+				}
+				j = len(flags)
+
+				if !setReadOption(&opts, flag, value) {
+					return opts, i, false
+				}
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: read: -%c: %v\n", flag, ErrInvalidOption)
+
+				return opts, i, false
+			}
+		}
+	}
+
+	return opts, i, true
+}
+
+// setReadOption applies a flag of read that takes a value.
+func setReadOption(opts *readOptions, flag byte, value string) bool {
+	switch flag {
+	case 'p':
+		opts.prompt = value
+	case 'a':
+		opts.array = value
+	case 'd':
+		// An empty delimiter ends the input at a NUL byte
+		opts.delim = 0
+		if value != "" {
+			opts.delim = value[0]
+		}
+	case 't':
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: read: %s: invalid timeout specification\n", value)
+
+			return false
+		}
+		opts.timeout = time.Duration(seconds * float64(time.Second))
+		opts.timed = true
+	case 'n':
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: read: %s: invalid number\n", value)
+
+			return false
+		}
+		opts.limit = limit
+	}
+
+	return true
+}
+
+// readInput reads from standard input one byte at a time, so that what
+// follows the delimiter is left for the next command. Unless raw, an
+// escaped character is kept with its backslash for assignRead, and an
+// escaped newline is dropped.
+func readInput(opts readOptions) (string, error) {
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		if opts.prompt != "" {
+			_, _ = fmt.Fprint(os.Stderr, opts.prompt)
+		}
+
+		// Hide the input, or return after -n characters without Enter
+		if (opts.silent || opts.limit >= 0) && lineEditor != nil {
+			if restore, err := lineEditor.SetInputMode(!opts.silent, opts.limit < 0); err == nil {
+				defer restore()
+			}
+		}
+	}
+
+	var deadline time.Time
+	if opts.timed {
+		deadline = time.Now().Add(opts.timeout)
+	}
+
+	var text strings.Builder
+
+	for count := 0; opts.limit < 0 || count < opts.limit; count++ {
+		b, err := readByte(deadline)
+		if err != nil {
+			return text.String(), err
+		}
+
+		if b == opts.delim {
+			return text.String(), nil
+		}
+
+		if b == '\\' && !opts.raw {
+			next, err := readByte(deadline)
+			if err != nil {
+				return text.String(), err
+			}
+
+			if next == '\n' {
+				count--
+
+				continue
+			}
+
+			text.WriteByte('\\')
+			b = next
+		}
+
+		// The rest of a multibyte character belongs to the same count
+		text.WriteByte(b)
+		for range sequenceLength(b) - 1 {
+			next, err := readByte(deadline)
+			if err != nil {
+				return text.String(), err
+			}
+			text.WriteByte(next)
+		}
+	}
+
+	return text.String(), nil
+}
+
+// readByte reads one byte of standard input, waiting no later than
+// deadline unless it is zero.
+func readByte(deadline time.Time) (byte, error) {
+	if !deadline.IsZero() {
+		ready, err := waitForInput(time.Until(deadline))
+		if err != nil {
+			return 0, err
+		}
+		if !ready {
+			return 0, errReadTimeout
+		}
+	}
+
+	var buf [1]byte
+
+	n, err := os.Stdin.Read(buf[:])
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+// waitForInput reports whether standard input has something to read
+// within timeout.
+func waitForInput(timeout time.Duration) (bool, error) {
+	timeout = max(timeout, 0)
+
+	for {
+		var fds syscall.FdSet
+		fds.Bits[0] = 1 // Descriptor 0
+
+		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+
+		n, err := syscall.Select(1, &fds, nil, nil, &tv)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("select: %w", err)
+		}
+
+		return n > 0, nil
+	}
+}
+
+// sequenceLength returns the length of the UTF-8 sequence that b starts.
+func sequenceLength(b byte) int {
+	switch {
+	case b&0xE0 == 0xC0:
+		return 2
+	case b&0xF0 == 0xE0:
+		return 3
+	case b&0xF8 == 0xF0:
+		return 4
+	}
+
+	return 1
+}
+
+// assignRead assigns the text read to the variables: split into the
+// names, the last taking the rest, split into an array with -a, or
+// whole to REPLY.
+func assignRead(text string, names []string, opts readOptions) {
+	switch {
+	case opts.array != "":
+		variables.SetArray(opts.array, splitRead(text, 0, opts.raw))
+	case len(names) == 0:
+		if !opts.raw {
+			text = unescapeRead(text)
+		}
+		variables.Set("REPLY", text)
+	default:
+		fields := splitRead(text, len(names), opts.raw)
+		for i, name := range names {
+			value := ""
+			if i < len(fields) {
+				value = fields[i]
+			}
+			variables.Set(name, value)
+		}
+	}
+}
+
+// readUnit is a character of the input, with whether a backslash escaped
+// it from splitting.
+type readUnit struct {
+	ch      rune
+	escaped bool
+}
+
+// splitRead splits text on IFS into at most count fields, or any number
+// if count is zero, the last field taking the rest of the text. Runs of
+// IFS whitespace separate fields and are trimmed from both ends; every
+// other IFS character ends a field.
+func splitRead(text string, count int, raw bool) []string {
+	ifs, ok := variables.Get("IFS")
+	if !ok {
+		ifs = defaultIFS
+	}
+
+	units := readUnits(text, raw)
+	isSpace := func(u readUnit) bool {
+		return !u.escaped && strings.ContainsRune(ifs, u.ch) && strings.ContainsRune(defaultIFS, u.ch)
+	}
+	isDelimiter := func(u readUnit) bool {
+		return !u.escaped && strings.ContainsRune(ifs, u.ch) && !strings.ContainsRune(defaultIFS, u.ch)
+	}
+	skipSpace := func(i int) int {
+		for i < len(units) && isSpace(units[i]) {
+			i++
+		}
+
+		return i
+	}
+
+	var fields []string
+
+	i := skipSpace(0)
+	for i < len(units) {
+		if count > 0 && len(fields) == count-1 {
+			end := len(units)
+			for end > i && isSpace(units[end-1]) {
+				end--
+			}
+
+			return append(fields, unitText(units[i:end]))
+		}
+
+		start := i
+		for i < len(units) && !isSpace(units[i]) && !isDelimiter(units[i]) {
+			i++
+		}
+		fields = append(fields, unitText(units[start:i]))
+
+		// One delimiter ends the field, with any whitespace around it
+		i = skipSpace(i)
+		if i < len(units) && isDelimiter(units[i]) {
+			i = skipSpace(i + 1)
+		}
+	}
+
+	return fields
+}
+
+// readUnits breaks text into characters, marking those a backslash
+// escaped unless raw.
+func readUnits(text string, raw bool) []readUnit {
+	var units []readUnit
+
+	escaped := false
+	for _, ch := range text {
+		if ch == '\\' && !raw && !escaped {
+			escaped = true
+
+			continue
+		}
+		units = append(units, readUnit{ch: ch, escaped: escaped})
+		escaped = false
+	}
+
+	return units
+}
+
+// unitText joins characters back into a string.
+func unitText(units []readUnit) string {
+	var text strings.Builder
+	for _, unit := range units {
+		text.WriteRune(unit.ch)
+	}
+
+	return text.String()
+}
+
+// unescapeRead removes the backslashes that escape characters.
+func unescapeRead(text string) string {
+	return unitText(readUnits(text, false))
+}
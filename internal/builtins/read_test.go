@@ -0,0 +1,133 @@
+package builtins
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"dsh/internal/variables"
+)
+
+// setStdin makes standard input read text for the rest of the test.
+func setStdin(t *testing.T, text string) {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+	_ = writer.Close()
+
+	stdin := os.Stdin
+	os.Stdin = reader
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		_ = reader.Close()
+	})
+}
+
+func TestRead(t *testing.T) {
+	t.Cleanup(func() {
+		for _, name := range []string{"a", "b", "c", "REPLY", "parts"} {
+			variables.Unset(name)
+		}
+	})
+
+	setStdin(t, "  one two  three  \nback\\\nslash \\ x\nraw\\ line\nabcdef,rest")
+
+	if status := Run([]string{"read", "a", "b"}); status != StatusSuccess {
+		t.Fatalf("read failed with status %d", status)
+	}
+	if a, _ := variables.Get("a"); a != "one" {
+		t.Errorf("Expected a=one, got %q", a)
+	}
+	if b, _ := variables.Get("b"); b != "two  three" {
+		t.Errorf("Expected b to take the rest of the line, got %q", b)
+	}
+
+	Run([]string{"read", "a", "b", "c"})
+	if a, _ := variables.Get("a"); a != "backslash" {
+		t.Errorf("Expected an escaped newline to join the lines, got %q", a)
+	}
+	if b, _ := variables.Get("b"); b != " x" {
+		t.Errorf("Expected an escaped space to stay in the field, got %q", b)
+	}
+	if c, _ := variables.Get("c"); c != "" {
+		t.Errorf("Expected c to be empty, got %q", c)
+	}
+
+	Run([]string{"read", "-r"})
+	if reply, _ := variables.Get("REPLY"); reply != `raw\ line` {
+		t.Errorf("Expected REPLY to keep backslashes under -r, got %q", reply)
+	}
+
+	Run([]string{"read", "-n", "3", "a"})
+	if a, _ := variables.Get("a"); a != "abc" {
+		t.Errorf("Expected -n 3 to read three characters, got %q", a)
+	}
+
+	if status := Run([]string{"read", "-d", ",", "a"}); status != StatusSuccess {
+		t.Errorf("read -d failed with status %d", status)
+	}
+	if a, _ := variables.Get("a"); a != "def" {
+		t.Errorf("Expected -d to end at the comma, got %q", a)
+	}
+
+	if status := Run([]string{"read", "a"}); status != StatusFailure {
+		t.Errorf("Expected input without a newline to fail, got status %d", status)
+	}
+	if a, _ := variables.Get("a"); a != "rest" {
+		t.Errorf("Expected the last partial line to be assigned, got %q", a)
+	}
+}
+
+func TestRead_Options(t *testing.T) {
+	setStdin(t, "")
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"read", "-q"}, StatusUsage},
+		{[]string{"read", "-t", "soon"}, StatusUsage},
+		{[]string{"read", "-n"}, StatusUsage},
+		{[]string{"read", "-e", "-s", "name"}, StatusUsage},
+		{[]string{"read", "-et", "1", "name"}, StatusUsage},
+		{[]string{"read", "-e", "-n", "3", "name"}, StatusUsage},
+		{[]string{"read", "-e", "-d", ",", "name"}, StatusUsage},
+		{[]string{"read", "-e", "-d", "\n", "name"}, StatusFailure},
+		{[]string{"read", "1name"}, StatusFailure},
+		{[]string{"read", "name"}, StatusFailure},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
+
+func TestSplitRead(t *testing.T) {
+	t.Setenv("IFS", ": ")
+
+	tests := []struct {
+		text     string
+		count    int
+		expected []string
+	}{
+		{"a:b::c", 0, []string{"a", "b", "", "c"}},
+		{" a : b ", 0, []string{"a", "b"}},
+		{"a:b:c d", 2, []string{"a", "b:c d"}},
+		{`a\:b:c`, 0, []string{"a:b", "c"}},
+		{"", 2, nil},
+	}
+
+	for _, test := range tests {
+		if fields := splitRead(test.text, test.count, false); !slices.Equal(fields, test.expected) {
+			t.Errorf("splitRead(%q, %d) = %q, want %q", test.text, test.count, fields, test.expected)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"dsh/internal/options"
+	"dsh/internal/variables"
+)
+
+// ErrInvalidShellOption indicates an option name shopt does not know.
+var ErrInvalidShellOption = errors.New("invalid shell option name")
+
+// handleSet implements set: -e, -u, -x, -f and -C turn options on and +e
+// and so on turn them off, -o name and +o name do the same by name, and
+// set -o and set +o list the options. The operands after the options, or
+// after --, become the positional parameters, and set -- alone clears
+// them. Without arguments the variables are listed.
+func handleSet(args []string) int {
+	if len(args) == 1 {
+		for _, name := range variables.Names() {
+			_, _ = fmt.Fprintf(os.Stdout, "%s=%s\n", name, declarationValue(name))
+		}
+
+		return StatusSuccess
+	}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			variables.SetPositional(args[i+1:])
+
+			break
+		}
+
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			variables.SetPositional(args[i:])
+
+			break
+		}
+
+		on := arg[0] == '-'
+		for _, letter := range []byte(arg[1:]) {
+			if letter != 'o' {
+				option, ok := options.ByLetter(letter)
+				if !ok {
+					_, _ = fmt.Fprintf(os.Stderr, "dsh: set: %c%c: %v\n", arg[0], letter, ErrInvalidOption)
+
+					return StatusUsage
+				}
+				_ = options.Set(option.Name, on)
+
+				continue
+			}
+
+			// -o takes the next argument as an option name
+			if i+1 >= len(args) {
+				printSetOptions(on)
+
+				continue
+			}
+			i++
+
+			option, ok := options.Lookup(args[i])
+			if !ok || option.Extended {
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: set: %s: %v\n", args[i], options.ErrInvalidOption)
+
+				return StatusUsage
+			}
+			_ = options.Set(option.Name, on)
+		}
+	}
+
+	return StatusSuccess
+}
+
+// printSetOptions lists the set options, as a table for set -o and as
+// commands that restore them for set +o.
+func printSetOptions(table bool) {
+	for _, option := range options.List(false) {
+		printOption(option, table, "set", "-o", "+o")
+	}
+}
+
+func printOption(option options.Option, table bool, command, onFlag, offFlag string) {
+	on := options.Enabled(option.Name)
+
+	switch {
+	case table:
+		state := "off"
+		if on {
+			state = "on"
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "%-15s\t%s\n", option.Name, state)
+	case on:
+		_, _ = fmt.Fprintf(os.Stdout, "%s %s %s\n", command, onFlag, option.Name)
+	default:
+		_, _ = fmt.Fprintf(os.Stdout, "%s %s %s\n", command, offFlag, option.Name)
+	}
+}
+
+// handleShopt implements shopt for the extended options: -s and -u turn
+// them on and off, -q only reports through the status, -p prints them as
+// commands and -o works on the set -o options instead.
+func handleShopt(args []string) int {
+	var setting, unsetting, quiet, reusable, setOptions bool
+
+	i := 1
+	for ; i < len(args) && strings.HasPrefix(args[i], "-") && len(args[i]) > 1; i++ {
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 's':
+				setting = true
+			case 'u':
+				unsetting = true
+			case 'q':
+				quiet = true
+			case 'p':
+				reusable = true
+			case 'o':
+				setOptions = true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: shopt: -%c: %v\n", flag, ErrInvalidOption)
+
+				return StatusUsage
+			}
+		}
+	}
+
+	if setting && unsetting {
+		_, _ = fmt.Fprintln(os.Stderr, "dsh: shopt: cannot set and unset shell options simultaneously")
+
+		return StatusFailure
+	}
+
+	names := args[i:]
+	if len(names) == 0 {
+		for _, option := range options.List(!setOptions) {
+			on := options.Enabled(option.Name)
+			if (setting && !on) || (unsetting && on) || quiet {
+				continue
+			}
+			printShoptOption(option, reusable, setOptions)
+		}
+
+		return StatusSuccess
+	}
+
+	status := StatusSuccess
+
+	for _, name := range names {
+		option, ok := options.Lookup(name)
+		if !ok || option.Extended == setOptions {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: shopt: %s: %v\n", name, ErrInvalidShellOption)
+			status = StatusFailure
+
+			continue
+		}
+
+		switch {
+		case setting || unsetting:
+			_ = options.Set(option.Name, setting)
+		case !options.Enabled(option.Name):
+			status = StatusFailure
+
+			fallthrough
+		default:
+			if !quiet {
+				printShoptOption(option, reusable, setOptions)
+			}
+		}
+	}
+
+	return status
+}
+
+func printShoptOption(option options.Option, reusable, setOptions bool) {
+	if setOptions {
+		printOption(option, !reusable, "set", "-o", "+o")
+	} else {
+		printOption(option, !reusable, "shopt", "-s", "-u")
+	}
+}
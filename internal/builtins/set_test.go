@@ -0,0 +1,113 @@
+package builtins
+
+import (
+	"testing"
+
+	"dsh/internal/options"
+	"dsh/internal/variables"
+)
+
+func TestSet_Options(t *testing.T) {
+	t.Cleanup(func() {
+		for _, name := range []string{options.Errexit, options.Nounset, options.Pipefail, options.Xtrace} {
+			_ = options.Set(name, false)
+		}
+	})
+
+	if status := Run([]string{"set", "-eu", "-o", "pipefail"}); status != StatusSuccess {
+		t.Fatalf("set failed with status %d", status)
+	}
+	if options.Flags() != "eu" || !options.Enabled(options.Pipefail) {
+		t.Errorf("Expected errexit, nounset and pipefail on, got flags %q", options.Flags())
+	}
+
+	if status := Run([]string{"set", "+e", "-x"}); status != StatusSuccess {
+		t.Fatalf("set failed with status %d", status)
+	}
+	if options.Flags() != "ux" {
+		t.Errorf("Expected flags %q, got %q", "ux", options.Flags())
+	}
+
+	if status := Run([]string{"test", "-o", "nounset"}); status != StatusSuccess {
+		t.Errorf("Expected test -o nounset to succeed, got status %d", status)
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"set", "-q"}, StatusUsage},
+		{[]string{"set", "-o", "nosuchoption"}, StatusUsage},
+		{[]string{"set", "-o", "nullglob"}, StatusUsage},
+		{[]string{"set", "-o"}, StatusSuccess},
+		{[]string{"set", "+o"}, StatusSuccess},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
+
+func TestSet_Positional(t *testing.T) {
+	t.Cleanup(func() {
+		variables.SetPositional(nil)
+		_ = options.Set(options.Xtrace, false)
+	})
+
+	if status := Run([]string{"set", "a", "-b"}); status != StatusSuccess {
+		t.Fatalf("set failed with status %d", status)
+	}
+	if args := variables.Positional(); len(args) != 2 || args[1] != "-b" {
+		t.Errorf("Expected the operands as positional parameters, got %q", args)
+	}
+
+	if status := Run([]string{"set", "-x", "--", "-c"}); status != StatusSuccess {
+		t.Fatalf("set failed with status %d", status)
+	}
+	if args := variables.Positional(); len(args) != 1 || args[0] != "-c" || !options.Enabled(options.Xtrace) {
+		t.Errorf("Expected -x on and the parameter after --, got %q", args)
+	}
+
+	if status := Run([]string{"set", "--"}); status != StatusSuccess || len(variables.Positional()) != 0 {
+		t.Errorf("Expected set -- to clear the positional parameters, got %q", variables.Positional())
+	}
+}
+
+func TestShopt(t *testing.T) {
+	t.Cleanup(func() {
+		_ = options.Set(options.Nullglob, false)
+		_ = options.Set(options.Noglob, false)
+	})
+
+	if status := Run([]string{"shopt", "-s", "nullglob"}); status != StatusSuccess {
+		t.Fatalf("shopt -s failed with status %d", status)
+	}
+	if !options.Enabled(options.Nullglob) {
+		t.Error("Expected nullglob to be on")
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"shopt", "-q", "nullglob"}, StatusSuccess},
+		{[]string{"shopt", "-q", "dotglob"}, StatusFailure},
+		{[]string{"shopt", "-q", "nullglob", "dotglob"}, StatusFailure},
+		{[]string{"shopt", "-s", "noglob"}, StatusFailure},
+		{[]string{"shopt", "-so", "noglob"}, StatusSuccess},
+		{[]string{"shopt", "-s", "-u", "nullglob"}, StatusFailure},
+		{[]string{"shopt", "-x"}, StatusUsage},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+
+	if !options.Enabled(options.Noglob) {
+		t.Error("Expected shopt -o to set noglob")
+	}
+}
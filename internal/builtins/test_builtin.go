@@ -0,0 +1,443 @@
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/mattn/go-isatty"
+
+	"dsh/internal/options"
+	"dsh/internal/variables"
+)
+
+var (
+	// ErrMissingBracket indicates a [ command without a closing ].
+	ErrMissingBracket = errors.New("missing ']'")
+	// ErrIntegerExpected indicates a non-numeric operand to -eq and friends.
+	ErrIntegerExpected = errors.New("integer expression expected")
+	// ErrUnaryOperatorExpected indicates two arguments without a unary operator.
+	ErrUnaryOperatorExpected = errors.New("unary operator expected")
+	// ErrBinaryOperatorExpected indicates three words without a binary operator.
+	ErrBinaryOperatorExpected = errors.New("binary operator expected")
+	// ErrUnknownTestOperator indicates an operator test does not implement.
+	ErrUnknownTestOperator = errors.New("unknown operator")
+	// ErrTooManyArguments indicates trailing arguments after an expression.
+	ErrTooManyArguments = errors.New("too many arguments")
+	// ErrMissingCloseParen indicates a ( without a matching ).
+	ErrMissingCloseParen = errors.New("')' expected")
+	// ErrMissingArgument indicates an operator at the end of the arguments.
+	ErrMissingArgument = errors.New("argument expected")
+)
+
+// accessExecute, accessWrite and accessRead are the access(2) mode bits.
+const (
+	accessExecute = 1
+	accessWrite   = 2
+	accessRead    = 4
+)
+
+func handleTest(args []string) int {
+	return runTest("test", args[1:])
+}
+
+func handleBracket(args []string) int {
+	if len(args) < 2 || args[len(args)-1] != "]" {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: [: %v\n", ErrMissingBracket)
+
+		return StatusUsage
+	}
+
+	return runTest("[", args[1:len(args)-1])
+}
+
+func runTest(name string, args []string) int {
+	result, err := evalTestArgs(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %s: %v\n", name, err)
+
+		return StatusUsage
+	}
+
+	if result {
+		return StatusSuccess
+	}
+
+	return StatusFailure
+}
+
+// evalTestArgs applies the POSIX rules that decide the meaning of up to
+// four arguments by their count, then falls back to a full expression
+// parser with -a, -o, ! and parentheses.
+func evalTestArgs(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		return evalTwoTestArgs(args)
+	case 3:
+		if isBinaryTestOperator(args[1]) || args[1] == "-a" || args[1] == "-o" {
+			return evalBinaryTestArgs(args[0], args[1], args[2])
+		}
+	case 4:
+		if args[0] == "(" && args[3] == ")" {
+			return evalTestArgs(args[1:3])
+		}
+	}
+
+	if args[0] == "!" && len(args) <= 4 {
+		result, err := evalTestArgs(args[1:])
+
+		return !result, err
+	}
+
+	if len(args) == 3 && args[0] == "(" && args[2] == ")" {
+		return args[1] != "", nil
+	}
+
+	parser := &testParser{args: args}
+
+	return parser.parse()
+}
+
+func evalTwoTestArgs(args []string) (bool, error) {
+	if args[0] == "!" {
+		return args[1] == "", nil
+	}
+
+	if !IsUnaryTestOperator(args[0]) {
+		return false, fmt.Errorf("%s: %w", args[0], ErrUnaryOperatorExpected)
+	}
+
+	return UnaryTest(args[0], args[1])
+}
+
+func evalBinaryTestArgs(left, op, right string) (bool, error) {
+	switch op {
+	case "-a":
+		return left != "" && right != "", nil
+	case "-o":
+		return left != "" || right != "", nil
+	default:
+		return BinaryTest(left, op, right)
+	}
+}
+
+// testParser evaluates longer test expressions by recursive descent.
+// Precedence from lowest to highest is -o, -a, !, then primaries.
+type testParser struct {
+	args []string
+	pos  int
+}
+
+func (p *testParser) parse() (bool, error) {
+	result, err := p.parseOr()
+	if err != nil {
+		return false, err
+	}
+
+	if p.pos < len(p.args) {
+		return false, fmt.Errorf("%s: %w", p.args[p.pos], ErrTooManyArguments)
+	}
+
+	return result, nil
+}
+
+func (p *testParser) parseOr() (bool, error) {
+	result, err := p.parseAnd()
+	if err != nil {
+		return false, err
+	}
+
+	for p.accept("-o") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return false, err
+		}
+		result = result || right
+	}
+
+	return result, nil
+}
+
+func (p *testParser) parseAnd() (bool, error) {
+	result, err := p.parseNot()
+	if err != nil {
+		return false, err
+	}
+
+	for p.accept("-a") {
+		right, err := p.parseNot()
+		if err != nil {
+			return false, err
+		}
+		result = result && right
+	}
+
+	return result, nil
+}
+
+func (p *testParser) parseNot() (bool, error) {
+	if p.remaining() > 1 && p.accept("!") {
+		result, err := p.parseNot()
+
+		return !result, err
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *testParser) parsePrimary() (bool, error) {
+	if p.remaining() == 0 {
+		return false, ErrMissingArgument
+	}
+
+	if p.remaining() > 1 && p.accept("(") {
+		result, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if !p.accept(")") {
+			return false, ErrMissingCloseParen
+		}
+
+		return result, nil
+	}
+
+	if p.remaining() >= 3 && isBinaryTestOperator(p.args[p.pos+1]) {
+		left, op, right := p.args[p.pos], p.args[p.pos+1], p.args[p.pos+2]
+		p.pos += 3
+
+		return BinaryTest(left, op, right)
+	}
+
+	if p.remaining() >= 2 && IsUnaryTestOperator(p.args[p.pos]) {
+		op, operand := p.args[p.pos], p.args[p.pos+1]
+		p.pos += 2
+
+		return UnaryTest(op, operand)
+	}
+
+	word := p.args[p.pos]
+	p.pos++
+
+	return word != "", nil
+}
+
+func (p *testParser) accept(word string) bool {
+	if p.pos < len(p.args) && p.args[p.pos] == word {
+		p.pos++
+
+		return true
+	}
+
+	return false
+}
+
+func (p *testParser) remaining() int {
+	return len(p.args) - p.pos
+}
+
+// IsUnaryTestOperator reports whether op is a unary test operator.
+func IsUnaryTestOperator(op string) bool {
+	switch op {
+	case "-a", "-b", "-c", "-d", "-e", "-f", "-g", "-h", "-k", "-n", "-o",
+		"-p", "-r", "-s", "-t", "-u", "-v", "-w", "-x", "-z",
+		"-G", "-L", "-N", "-O", "-R", "-S":
+		return true
+	default:
+		return false
+	}
+}
+
+func isBinaryTestOperator(op string) bool {
+	switch op {
+	case "=", "==", "!=", "<", ">",
+		"-eq", "-ne", "-lt", "-le", "-gt", "-ge",
+		"-nt", "-ot", "-ef":
+		return true
+	default:
+		return false
+	}
+}
+
+// UnaryTest evaluates a unary test operator such as -f or -z.
+func UnaryTest(op, operand string) (bool, error) {
+	switch op {
+	case "-n":
+		return operand != "", nil
+	case "-z":
+		return operand == "", nil
+	case "-v":
+		return variables.IsSet(operand), nil
+	case "-o":
+		return options.Enabled(operand), nil
+	case "-R":
+		return false, nil
+	case "-t":
+		fd, err := parseTestInteger(operand)
+		if err != nil {
+			return false, err
+		}
+
+		return isatty.IsTerminal(uintptr(fd)), nil //nolint:gosec // Descriptor numbers are small non-negative integers
+	case "-r":
+		return syscall.Access(operand, accessRead) == nil, nil
+	case "-w":
+		return syscall.Access(operand, accessWrite) == nil, nil
+	case "-x":
+		return syscall.Access(operand, accessExecute) == nil, nil
+	case "-h", "-L":
+		info, err := os.Lstat(operand)
+
+		return err == nil && info.Mode()&os.ModeSymlink != 0, nil
+	}
+
+	if !IsUnaryTestOperator(op) {
+		return false, fmt.Errorf("%s: %w", op, ErrUnknownTestOperator)
+	}
+
+	info, err := os.Stat(operand)
+	if err != nil {
+		return false, nil //nolint:nilerr // A missing file makes every file test false
+	}
+
+	return testFileInfo(op, info), nil
+}
+
+// testFileInfo evaluates the file operators that only need stat(2) data.
+func testFileInfo(op string, info os.FileInfo) bool {
+	mode := info.Mode()
+
+	switch op {
+	case "-a", "-e":
+		return true
+	case "-f":
+		return mode.IsRegular()
+	case "-d":
+		return mode.IsDir()
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+	case "-c":
+		return mode&os.ModeCharDevice != 0
+	case "-p":
+		return mode&os.ModeNamedPipe != 0
+	case "-S":
+		return mode&os.ModeSocket != 0
+	case "-s":
+		return info.Size() > 0
+	case "-g":
+		return mode&os.ModeSetgid != 0
+	case "-u":
+		return mode&os.ModeSetuid != 0
+	case "-k":
+		return mode&os.ModeSticky != 0
+	default:
+		return testFileOwnership(op, info)
+	}
+}
+
+// testFileOwnership evaluates -O, -G and -N, which need the raw stat data.
+func testFileOwnership(op string, info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	switch op {
+	case "-O":
+		return int(stat.Uid) == os.Geteuid()
+	case "-G":
+		return int(stat.Gid) == os.Getegid()
+	case "-N":
+		return stat.Mtim.Nano() > stat.Atim.Nano()
+	default:
+		return false
+	}
+}
+
+// BinaryTest evaluates a binary test operator. = and == compare strings
+// exactly; [[ ]] handles pattern matching before calling this.
+func BinaryTest(left, op, right string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		return compareTestIntegers(left, op, right)
+	case "-nt", "-ot", "-ef":
+		return compareTestFiles(left, op, right), nil
+	default:
+		return false, fmt.Errorf("%s: %w", op, ErrBinaryOperatorExpected)
+	}
+}
+
+func compareTestIntegers(left, op, right string) (bool, error) {
+	a, err := parseTestInteger(left)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := parseTestInteger(right)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case "-eq":
+		return a == b, nil
+	case "-ne":
+		return a != b, nil
+	case "-lt":
+		return a < b, nil
+	case "-le":
+		return a <= b, nil
+	case "-gt":
+		return a > b, nil
+	default:
+		return a >= b, nil
+	}
+}
+
+func parseTestInteger(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", s, ErrIntegerExpected)
+	}
+
+	return n, nil
+}
+
+// compareTestFiles evaluates -nt, -ot and -ef. A file that exists is
+// newer than one that does not.
+func compareTestFiles(left, op, right string) bool {
+	leftInfo, leftErr := os.Stat(left)
+	rightInfo, rightErr := os.Stat(right)
+
+	switch op {
+	case "-nt":
+		if leftErr != nil {
+			return false
+		}
+
+		return rightErr != nil || leftInfo.ModTime().After(rightInfo.ModTime())
+	case "-ot":
+		if rightErr != nil {
+			return false
+		}
+
+		return leftErr != nil || leftInfo.ModTime().Before(rightInfo.ModTime())
+	default:
+		return leftErr == nil && rightErr == nil && os.SameFile(leftInfo, rightInfo)
+	}
+}
@@ -0,0 +1,166 @@
+package builtins
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTestBuiltin_Strings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"test"}, StatusFailure},
+		{[]string{"test", "word"}, StatusSuccess},
+		{[]string{"test", ""}, StatusFailure},
+		{[]string{"test", "-n", "x"}, StatusSuccess},
+		{[]string{"test", "-z", "x"}, StatusFailure},
+		{[]string{"test", "a", "=", "a"}, StatusSuccess},
+		{[]string{"test", "a", "!=", "a"}, StatusFailure},
+		{[]string{"test", "a", "<", "b"}, StatusSuccess},
+		{[]string{"test", "!", "a", "=", "b"}, StatusSuccess},
+		{[]string{"test", "!", "-z", "x"}, StatusSuccess},
+		{[]string{"test", "(", "x", ")"}, StatusSuccess},
+		{[]string{"test", "-z", "", "-a", "a", "=", "a"}, StatusSuccess},
+		{[]string{"test", "-z", "x", "-o", "(", "a", "!=", "b", ")"}, StatusSuccess},
+		{[]string{"test", "-n", "x", "-a", "!", "-n", "x"}, StatusFailure},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
+
+func TestTestBuiltin_Integers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"test", "1", "-eq", "1"}, StatusSuccess},
+		{[]string{"test", "1", "-ne", "1"}, StatusFailure},
+		{[]string{"test", "-3", "-lt", "2"}, StatusSuccess},
+		{[]string{"test", "2", "-le", "2"}, StatusSuccess},
+		{[]string{"test", "10", "-gt", "9"}, StatusSuccess},
+		{[]string{"test", "9", "-ge", "10"}, StatusFailure},
+		{[]string{"test", "x", "-eq", "1"}, StatusUsage},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
+
+func TestTestBuiltin_Files(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	empty := filepath.Join(dir, "empty")
+	link := filepath.Join(dir, "link")
+	missing := filepath.Join(dir, "missing")
+
+	if err := os.WriteFile(file, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"test", "-e", file}, StatusSuccess},
+		{[]string{"test", "-e", missing}, StatusFailure},
+		{[]string{"test", "-f", file}, StatusSuccess},
+		{[]string{"test", "-f", dir}, StatusFailure},
+		{[]string{"test", "-d", dir}, StatusSuccess},
+		{[]string{"test", "-s", file}, StatusSuccess},
+		{[]string{"test", "-s", empty}, StatusFailure},
+		{[]string{"test", "-L", link}, StatusSuccess},
+		{[]string{"test", "-h", file}, StatusFailure},
+		{[]string{"test", "-r", file}, StatusSuccess},
+		{[]string{"test", "-x", file}, StatusFailure},
+		{[]string{"test", "-O", file}, StatusSuccess},
+		{[]string{"test", link, "-ef", file}, StatusSuccess},
+		{[]string{"test", file, "-ef", empty}, StatusFailure},
+		{[]string{"test", file, "-nt", missing}, StatusSuccess},
+		{[]string{"test", missing, "-ot", file}, StatusSuccess},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
+
+func TestTestBuiltin_ModificationTimes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	older := filepath.Join(dir, "older")
+	newer := filepath.Join(dir, "newer")
+
+	for _, name := range []string{older, newer} {
+		if err := os.WriteFile(name, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(older, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := Run([]string{"test", newer, "-nt", older}); status != StatusSuccess {
+		t.Errorf("Expected newer -nt older to succeed, got %d", status)
+	}
+	if status := Run([]string{"test", newer, "-ot", older}); status != StatusFailure {
+		t.Errorf("Expected newer -ot older to fail, got %d", status)
+	}
+}
+
+func TestBracketBuiltin(t *testing.T) {
+	t.Parallel()
+
+	if status := Run([]string{"[", "a", "=", "a", "]"}); status != StatusSuccess {
+		t.Errorf("Expected [ a = a ] to succeed, got %d", status)
+	}
+	if status := Run([]string{"[", "a", "=", "b", "]"}); status != StatusFailure {
+		t.Errorf("Expected [ a = b ] to fail, got %d", status)
+	}
+	if status := Run([]string{"[", "a", "=", "a"}); status != StatusUsage {
+		t.Errorf("Expected [ without ] to be a usage error, got %d", status)
+	}
+}
+
+func TestTestBuiltin_SyntaxErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := [][]string{
+		{"test", "a", "b"},
+		{"test", "a", "b", "c"},
+		{"test", "(", "a"},
+		{"test", "a", "=", "b", "c", "d"},
+	}
+
+	for _, args := range tests {
+		if status := Run(args); status != StatusUsage {
+			t.Errorf("%v: expected usage status, got %d", args, status)
+		}
+	}
+}
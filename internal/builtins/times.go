@@ -0,0 +1,36 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// handleTimes implements times, which prints the user and system time
+// used by the shell, then by the commands it has waited for.
+func handleTimes(_ []string) int {
+	var self, children syscall.Rusage
+
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &self); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: times: %v\n", err)
+
+		return StatusFailure
+	}
+
+	if err := syscall.Getrusage(syscall.RUSAGE_CHILDREN, &children); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: times: %v\n", err)
+
+		return StatusFailure
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%s %s\n%s %s\n",
+		formatTimeval(self.Utime), formatTimeval(self.Stime),
+		formatTimeval(children.Utime), formatTimeval(children.Stime))
+
+	return StatusSuccess
+}
+
+// formatTimeval writes a time as minutes and seconds, as in 0m1.250s.
+func formatTimeval(tv syscall.Timeval) string {
+	return fmt.Sprintf("%dm%d.%03ds", tv.Sec/60, tv.Sec%60, tv.Usec/1000)
+}
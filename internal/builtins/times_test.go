@@ -0,0 +1,37 @@
+package builtins
+
+import (
+	"regexp"
+	"syscall"
+	"testing"
+)
+
+func TestTimes(t *testing.T) {
+	var status int
+
+	output := captureStdout(t, func() { status = handleTimes([]string{"times"}) })
+	if status != StatusSuccess {
+		t.Errorf("Expected status %d, got %d", StatusSuccess, status)
+	}
+
+	expected := regexp.MustCompile(`^\d+m\d+\.\d{3}s \d+m\d+\.\d{3}s\n\d+m\d+\.\d{3}s \d+m\d+\.\d{3}s\n$`)
+	if !expected.MatchString(output) {
+		t.Errorf("Unexpected output %q", output)
+	}
+}
+
+func TestFormatTimeval(t *testing.T) {
+	t.Parallel()
+
+	tests := map[syscall.Timeval]string{
+		{Sec: 0, Usec: 0}:        "0m0.000s",
+		{Sec: 1, Usec: 250000}:   "0m1.250s",
+		{Sec: 125, Usec: 999999}: "2m5.999s",
+	}
+
+	for tv, expected := range tests {
+		if got := formatTimeval(tv); got != expected {
+			t.Errorf("formatTimeval(%v) = %q, expected %q", tv, got, expected)
+		}
+	}
+}
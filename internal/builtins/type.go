@@ -0,0 +1,147 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+
+	"dsh/internal/commands"
+)
+
+// handleType implements type, which tells what each name would run. -t
+// prints only the kind, -p only the path of a file, -P searches PATH even
+// for keywords and builtins, and -a lists every match.
+func handleType(args []string) int {
+	var kindOnly, pathOnly, forcePath, all bool
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 't':
+				kindOnly = true
+			case 'p':
+				pathOnly = true
+			case 'P':
+				forcePath = true
+			case 'a':
+				all = true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: type: -%c: %v\n", flag, ErrInvalidOption)
+
+				return StatusUsage
+			}
+		}
+	}
+
+	status := StatusSuccess
+
+	for _, name := range args[i:] {
+		var found bool
+		if forcePath {
+			found = printPaths(name, all)
+		} else {
+			found = printType(name, all, kindOnly, pathOnly)
+		}
+
+		if !found {
+			if !kindOnly && !pathOnly && !forcePath {
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: type: %s: not found\n", name)
+			}
+			status = StatusFailure
+		}
+	}
+
+	return status
+}
+
+// printType prints what name is for type, and with all also the files
+// in PATH that a keyword, builtin or hashed path hides.
+func printType(name string, all, kindOnly, pathOnly bool) bool {
+	kind, path, ok := resolve(name)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case kindOnly:
+		_, _ = fmt.Fprintln(os.Stdout, kind)
+	case pathOnly:
+		if kind == kindFile {
+			_, _ = fmt.Fprintln(os.Stdout, path)
+		}
+	default:
+		_, _ = fmt.Fprintln(os.Stdout, describe(name, kind, path))
+	}
+
+	if !all {
+		return true
+	}
+
+	for _, other := range commands.FindAll(name) {
+		if kind == kindFile && other == path {
+			continue
+		}
+
+		switch {
+		case kindOnly:
+			_, _ = fmt.Fprintln(os.Stdout, kindFile)
+		case pathOnly:
+			_, _ = fmt.Fprintln(os.Stdout, other)
+		default:
+			_, _ = fmt.Fprintf(os.Stdout, "%s is %s\n", name, other)
+		}
+	}
+
+	return true
+}
+
+// printPaths prints the path name has in PATH, or with all every one.
+func printPaths(name string, all bool) bool {
+	paths := commands.FindAll(name)
+	if len(paths) == 0 {
+		return false
+	}
+
+	if path, ok := commands.Find(name); ok && !all {
+		paths = []string{path}
+	}
+
+	for _, path := range paths {
+		_, _ = fmt.Fprintln(os.Stdout, path)
+	}
+
+	return true
+}
+
+// handleWhich implements which, printing the path of each name found in
+// PATH, or with -a every path. Keywords and builtins are not files and are
+// not reported.
+func handleWhich(args []string) int {
+	all := false
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] != "-a" {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: which: %s: %v\n", args[i], ErrInvalidOption)
+
+			return StatusUsage
+		}
+		all = true
+	}
+
+	status := StatusSuccess
+
+	for _, name := range args[i:] {
+		if !printPaths(name, all) {
+			status = StatusFailure
+		}
+	}
+
+	return status
+}
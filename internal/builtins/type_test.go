@@ -0,0 +1,76 @@
+package builtins
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dsh/internal/commands"
+)
+
+func TestType(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tool"), nil, 0o755); err != nil { //nolint:gosec // Test executable
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"type", "if", "cd", "eval", "tool"}, StatusSuccess},
+		{[]string{"type", "-t", "for", "!", "hash"}, StatusSuccess},
+		{[]string{"type", "-t", "while"}, StatusFailure},
+		{[]string{"type", "-a", "tool"}, StatusSuccess},
+		{[]string{"type", "-p", "cd"}, StatusSuccess},
+		{[]string{"type", "-P", "cd"}, StatusFailure},
+		{[]string{"type", "tool", "dsh_no_such_command"}, StatusFailure},
+		{[]string{"type", "-z", "tool"}, StatusUsage},
+		{[]string{"which", "tool"}, StatusSuccess},
+		{[]string{"which", "-a", "tool", "cd"}, StatusFailure},
+	}
+
+	for _, test := range tests {
+		if status := Run(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tool"), nil, 0o755); err != nil { //nolint:gosec // Test executable
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	if status := Run([]string{"hash", "tool", "cd"}); status != StatusSuccess {
+		t.Fatalf("hash failed with status %d", status)
+	}
+	if path, ok := commands.Hashed("tool"); !ok || path != filepath.Join(dir, "tool") {
+		t.Errorf("Expected tool to be hashed, got %q, %v", path, ok)
+	}
+	if _, ok := commands.Hashed("cd"); ok {
+		t.Error("Builtins should not be hashed")
+	}
+
+	if status := Run([]string{"hash"}); status != StatusSuccess {
+		t.Errorf("Listing the table failed with status %d", status)
+	}
+	if status := Run([]string{"hash", "-d", "tool"}); status != StatusSuccess {
+		t.Errorf("hash -d failed with status %d", status)
+	}
+	if status := Run([]string{"hash", "-d", "tool"}); status != StatusFailure {
+		t.Errorf("hash -d of a forgotten name should fail, got status %d", status)
+	}
+
+	Run([]string{"hash", "tool"})
+	if status := Run([]string{"hash", "-r"}); status != StatusSuccess || len(commands.Entries()) != 0 {
+		t.Errorf("hash -r should empty the table, got status %d and %+v", status, commands.Entries())
+	}
+
+	if status := Run([]string{"hash", "dsh_no_such_command"}); status != StatusFailure {
+		t.Errorf("Hashing a missing command should fail, got status %d", status)
+	}
+}
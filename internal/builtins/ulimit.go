@@ -0,0 +1,237 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// rlimitNproc is RLIMIT_NPROC, which the syscall package leaves out.
+const rlimitNproc = 6
+
+// unlimited is the value of a limit that is not set, RLIM_INFINITY.
+const unlimited = ^uint64(0)
+
+// fileLimitSet is set once ulimit has set the limit on open files, after
+// which the commands the shell starts inherit the shell's own.
+var fileLimitSet bool //nolint:gochecknoglobals // Shell-wide resource state
+
+// resourceLimit is a limit that ulimit shows and sets.
+type resourceLimit struct {
+	flag     rune
+	resource int
+	name     string
+	// unit names what the limit counts in the output of -a, and scale is
+	// how many of the kernel's units that is.
+	unit  string
+	scale uint64
+}
+
+// resourceLimits returns the limits of ulimit in the order -a lists them.
+// Sizes are counted in kilobytes and CPU time in seconds.
+func resourceLimits() []resourceLimit {
+	return []resourceLimit{
+		{'c', syscall.RLIMIT_CORE, "core file size", "blocks", 1024},
+		{'d', syscall.RLIMIT_DATA, "data seg size", "kbytes", 1024},
+		{'f', syscall.RLIMIT_FSIZE, "file size", "blocks", 1024},
+		{'n', syscall.RLIMIT_NOFILE, "open files", "", 1},
+		{'s', syscall.RLIMIT_STACK, "stack size", "kbytes", 1024},
+		{'t', syscall.RLIMIT_CPU, "cpu time", "seconds", 1},
+		{'u', rlimitNproc, "max user processes", "", 1},
+		{'v', syscall.RLIMIT_AS, "virtual memory", "kbytes", 1024},
+	}
+}
+
+// handleUlimit implements ulimit, which shows or sets the resource limits
+// of the shell, which the commands it starts inherit. -H selects the hard
+// limit and -S the soft one; a limit is set as both unless one is given,
+// and shown as the soft one. Without a resource flag it acts on -f, and
+// -a shows every limit. A limit is a number, unlimited, hard or soft.
+func handleUlimit(args []string) int {
+	var hard, soft, all bool
+	var selected []resourceLimit
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'H':
+				hard = true
+			case 'S':
+				soft = true
+			case 'a':
+				all = true
+			default:
+				limit, ok := findLimit(flag)
+				if !ok {
+					_, _ = fmt.Fprintf(os.Stderr, "dsh: ulimit: -%c: %v\n", flag, ErrInvalidOption)
+
+					return StatusUsage
+				}
+				selected = append(selected, limit)
+			}
+		}
+	}
+
+	operands := args[i:]
+	if all {
+		selected = resourceLimits()
+	}
+	if len(selected) == 0 {
+		limit, _ := findLimit('f')
+		selected = []resourceLimit{limit}
+	}
+
+	if len(operands) > 1 || (len(operands) == 1 && len(selected) > 1) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: ulimit: %v\n", ErrTooManyArguments)
+
+		return StatusUsage
+	}
+
+	if len(operands) == 1 {
+		return setLimit(selected[0], operands[0], hard, soft)
+	}
+
+	status := StatusSuccess
+	for _, limit := range selected {
+		rlimit, err := getLimit(limit)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: ulimit: %s: cannot get limit: %v\n", limit.name, err)
+			status = StatusFailure
+
+			continue
+		}
+
+		value := formatLimit(rlimit.Cur, limit)
+		if hard {
+			value = formatLimit(rlimit.Max, limit)
+		}
+
+		if len(selected) == 1 {
+			_, _ = fmt.Fprintln(os.Stdout, value)
+
+			continue
+		}
+
+		label := "(-" + string(limit.flag) + ")"
+		if limit.unit != "" {
+			label = "(" + limit.unit + ", -" + string(limit.flag) + ")"
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "%-20s %17s %s\n", limit.name, label, value)
+	}
+
+	return status
+}
+
+// findLimit returns the limit that flag selects.
+func findLimit(flag rune) (resourceLimit, bool) {
+	for _, limit := range resourceLimits() {
+		if limit.flag == flag {
+			return limit, true
+		}
+	}
+
+	return resourceLimit{}, false
+}
+
+// setLimit sets the hard or soft limit, or both if neither is chosen, to
+// value in the units of limit.
+func setLimit(limit resourceLimit, value string, hard, soft bool) int {
+	rlimit, err := getLimit(limit)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: ulimit: %s: cannot get limit: %v\n", limit.name, err)
+
+		return StatusFailure
+	}
+
+	var amount uint64
+
+	switch value {
+	case "unlimited":
+		amount = unlimited
+	case "hard":
+		amount = rlimit.Max
+	case "soft":
+		amount = rlimit.Cur
+	default:
+		number, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || number > unlimited/limit.scale {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: ulimit: %s: invalid number\n", value)
+
+			return StatusFailure
+		}
+		amount = number * limit.scale
+	}
+
+	if !hard && !soft {
+		hard, soft = true, true
+	}
+	if hard {
+		rlimit.Max = amount
+	}
+	if soft {
+		rlimit.Cur = amount
+	}
+
+	if err := syscall.Setrlimit(limit.resource, &rlimit); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: ulimit: %s: cannot modify limit: %v\n", limit.name, err)
+
+		return StatusFailure
+	}
+	if limit.resource == syscall.RLIMIT_NOFILE {
+		fileLimitSet = true
+	}
+
+	return StatusSuccess
+}
+
+// getLimit returns the limits of resource as the commands the shell starts
+// inherit them. The Go runtime raises the shell's own soft limit on open
+// files at startup, to one below the hard limit, and puts the original
+// back in each command it starts until the limit is set again; sh, as
+// one of those commands, tells what the original was.
+func getLimit(limit resourceLimit) (syscall.Rlimit, error) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(limit.resource, &rlimit); err != nil {
+		return rlimit, err
+	}
+
+	if limit.resource != syscall.RLIMIT_NOFILE || fileLimitSet || rlimit.Max == 0 || rlimit.Cur != rlimit.Max-1 {
+		return rlimit, nil
+	}
+
+	output, err := exec.CommandContext(context.Background(), "/bin/sh", "-c", "ulimit -Sn").Output()
+	if err != nil {
+		return rlimit, nil //nolint:nilerr // The shell's own limit is the best left
+	}
+
+	switch value := strings.TrimSpace(string(output)); value {
+	case "unlimited":
+		rlimit.Cur = unlimited
+	default:
+		if soft, err := strconv.ParseUint(value, 10, 64); err == nil {
+			rlimit.Cur = soft
+		}
+	}
+
+	return rlimit, nil
+}
+
+// formatLimit writes a limit in the units of ulimit.
+func formatLimit(amount uint64, limit resourceLimit) string {
+	if amount == unlimited {
+		return "unlimited"
+	}
+
+	return strconv.FormatUint(amount/limit.scale, 10)
+}
@@ -0,0 +1,80 @@
+package builtins
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestUlimit(t *testing.T) {
+	var saved syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &saved); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = syscall.Setrlimit(syscall.RLIMIT_CORE, &saved) })
+
+	if status := handleUlimit([]string{"ulimit", "-S", "-c", "8"}); status != StatusSuccess {
+		t.Fatalf("Expected status %d, got %d", StatusSuccess, status)
+	}
+
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &rlimit); err != nil {
+		t.Fatal(err)
+	}
+	if rlimit.Cur != 8*1024 || rlimit.Max != saved.Max {
+		t.Errorf("Expected soft limit %d and hard limit %d, got %d and %d", 8*1024, saved.Max, rlimit.Cur, rlimit.Max)
+	}
+
+	if output := captureStdout(t, func() { handleUlimit([]string{"ulimit", "-c"}) }); output != "8\n" {
+		t.Errorf("Expected %q, got %q", "8\n", output)
+	}
+
+	expected := formatLimit(saved.Max, resourceLimit{scale: 1024}) + "\n"
+	if output := captureStdout(t, func() { handleUlimit([]string{"ulimit", "-Hc"}) }); output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	if status := handleUlimit([]string{"ulimit", "-Sc", "hard"}); status != StatusSuccess {
+		t.Errorf("Expected status %d, got %d", StatusSuccess, status)
+	}
+	if output := captureStdout(t, func() { handleUlimit([]string{"ulimit", "-c"}) }); output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	output := captureStdout(t, func() { handleUlimit([]string{"ulimit", "-a"}) })
+	if !strings.Contains(output, "(blocks, -c) ") || !strings.Contains(output, "open files") {
+		t.Errorf("Unexpected output of -a: %q", output)
+	}
+}
+
+func TestUlimit_Errors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"ulimit", "-x"}, StatusUsage},
+		{[]string{"ulimit", "-c", "1", "2"}, StatusUsage},
+		{[]string{"ulimit", "-c", "-n", "1"}, StatusUsage},
+		{[]string{"ulimit", "-c", "lots"}, StatusFailure},
+	}
+
+	for _, test := range tests {
+		if status := handleUlimit(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+}
+
+func TestUlimit_InheritedFileLimit(t *testing.T) {
+	output, err := exec.CommandContext(context.Background(), "/bin/sh", "-c", "ulimit -Sn").Output()
+	if err != nil {
+		t.Skipf("sh unavailable: %v", err)
+	}
+
+	// The test binary's own limit has been raised by the Go runtime
+	if got := captureStdout(t, func() { handleUlimit([]string{"ulimit", "-n"}) }); got != string(output) {
+		t.Errorf("Expected the limit commands inherit, %q, got %q", output, got)
+	}
+}
@@ -0,0 +1,177 @@
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// handleUmask implements umask, which prints the file creation mask in
+// octal, or with -S as the permissions it leaves, as in u=rwx,g=rx,o=rx.
+// Given a mode it sets the mask: an octal number, or a symbolic mode as
+// chmod takes, which changes the permissions left.
+func handleUmask(args []string) int {
+	symbolic := false
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'S':
+				symbolic = true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: umask: -%c: %v\n", flag, ErrInvalidOption)
+
+				return StatusUsage
+			}
+		}
+	}
+
+	if i < len(args)-1 {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: umask: %v\n", ErrTooManyArguments)
+
+		return StatusUsage
+	}
+
+	if i == len(args) {
+		printUmask(currentUmask(), symbolic)
+
+		return StatusSuccess
+	}
+
+	mode := args[i]
+
+	var mask int
+	if mode[0] >= '0' && mode[0] <= '9' {
+		value, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || value > 0o777 {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: umask: %s: octal number out of range\n", mode)
+
+			return StatusFailure
+		}
+		mask = int(value)
+	} else {
+		perm, ok := applySymbolicMode(mode, 0o777&^currentUmask())
+		if !ok {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: umask: %s: invalid symbolic mode\n", mode)
+
+			return StatusFailure
+		}
+		mask = 0o777 &^ perm
+	}
+
+	syscall.Umask(mask)
+
+	if symbolic {
+		printUmask(mask, true)
+	}
+
+	return StatusSuccess
+}
+
+// currentUmask returns the file creation mask, which can only be read by
+// setting it, so it is put straight back.
+func currentUmask() int {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+
+	return mask
+}
+
+// printUmask prints mask in octal, or symbolically as the permissions it
+// leaves.
+func printUmask(mask int, symbolic bool) {
+	if !symbolic {
+		_, _ = fmt.Fprintf(os.Stdout, "%04o\n", mask)
+
+		return
+	}
+
+	perm := 0o777 &^ mask
+	classes := make([]string, 0, 3)
+
+	for j, class := range []string{"u", "g", "o"} {
+		bits := perm >> (6 - 3*j) & 0o7
+
+		var text strings.Builder
+		text.WriteString(class + "=")
+		for k, letter := range "rwx" {
+			if bits&(0o4>>k) != 0 {
+				text.WriteRune(letter)
+			}
+		}
+		classes = append(classes, text.String())
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, strings.Join(classes, ","))
+}
+
+// applySymbolicMode applies a symbolic mode such as u=rwx,go-w to the
+// permissions perm. Each clause names the classes it changes, all if
+// none, then one or more of +, - or = with the permissions.
+func applySymbolicMode(mode string, perm int) (int, bool) {
+	for clause := range strings.SplitSeq(mode, ",") {
+		who := 0
+
+		i := 0
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			switch clause[i] {
+			case 'u':
+				who |= 0o700
+			case 'g':
+				who |= 0o070
+			case 'o':
+				who |= 0o007
+			case 'a':
+				who |= 0o777
+			}
+		}
+		if who == 0 {
+			who = 0o777
+		}
+
+		if i == len(clause) {
+			return 0, false
+		}
+
+		for i < len(clause) {
+			op := clause[i]
+			if strings.IndexByte("+-=", op) < 0 {
+				return 0, false
+			}
+			i++
+
+			bits := 0
+			for ; i < len(clause) && strings.IndexByte("rwx", clause[i]) >= 0; i++ {
+				switch clause[i] {
+				case 'r':
+					bits |= 0o444
+				case 'w':
+					bits |= 0o222
+				case 'x':
+					bits |= 0o111
+				}
+			}
+			bits &= who
+
+			switch op {
+			case '+':
+				perm |= bits
+			case '-':
+				perm &^= bits
+			case '=':
+				perm = perm&^who | bits
+			}
+		}
+	}
+
+	return perm, true
+}
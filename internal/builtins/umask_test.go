@@ -0,0 +1,68 @@
+package builtins
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestUmask(t *testing.T) {
+	saved := syscall.Umask(0o022)
+	t.Cleanup(func() { syscall.Umask(saved) })
+
+	tests := []struct {
+		args     []string
+		expected string
+		mask     int
+	}{
+		{[]string{"umask"}, "0022\n", 0o022},
+		{[]string{"umask", "-S"}, "u=rwx,g=rx,o=rx\n", 0o022},
+		{[]string{"umask", "077"}, "", 0o077},
+		{[]string{"umask", "g+rx,o+r"}, "", 0o023},
+		{[]string{"umask", "-S", "a=rx,u+w"}, "u=rwx,g=rx,o=rx\n", 0o022},
+		{[]string{"umask", "go-w"}, "", 0o022},
+		{[]string{"umask", "+w-x"}, "", 0o111},
+	}
+
+	for _, test := range tests {
+		var status int
+
+		output := captureStdout(t, func() { status = handleUmask(test.args) })
+		if status != StatusSuccess {
+			t.Errorf("%v: expected status %d, got %d", test.args, StatusSuccess, status)
+		}
+		if output != test.expected {
+			t.Errorf("%v: expected output %q, got %q", test.args, test.expected, output)
+		}
+		if mask := currentUmask(); mask != test.mask {
+			t.Errorf("%v: expected mask %04o, got %04o", test.args, test.mask, mask)
+		}
+	}
+}
+
+func TestUmask_Errors(t *testing.T) {
+	saved := syscall.Umask(0o022)
+	t.Cleanup(func() { syscall.Umask(saved) })
+
+	tests := []struct {
+		args   []string
+		status int
+	}{
+		{[]string{"umask", "-p"}, StatusUsage},
+		{[]string{"umask", "022", "077"}, StatusUsage},
+		{[]string{"umask", "1000"}, StatusFailure},
+		{[]string{"umask", "8"}, StatusFailure},
+		{[]string{"umask", "u"}, StatusFailure},
+		{[]string{"umask", "u+q"}, StatusFailure},
+		{[]string{"umask", "z=r"}, StatusFailure},
+	}
+
+	for _, test := range tests {
+		if status := handleUmask(test.args); status != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.args, test.status, status)
+		}
+	}
+
+	if mask := currentUmask(); mask != 0o022 {
+		t.Errorf("Expected the mask to stay 0022, got %04o", mask)
+	}
+}
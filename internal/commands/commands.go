@@ -0,0 +1,273 @@
+// Package commands keeps the shell's table of commands found in PATH.
+// Running a command, the hash, type and which builtins and tab completion
+// all look names up here, so they agree on what is runnable. The table is
+// dropped whenever PATH changes.
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"dsh/internal/variables"
+)
+
+// Entry is a remembered command: the path a name was found at and how
+// many times the shell has run it from there.
+type Entry struct {
+	Name string
+	Path string
+	Hits int
+}
+
+var (
+	tablePath string            //nolint:gochecknoglobals // PATH the table was built for
+	hashed    map[string]*Entry //nolint:gochecknoglobals // Names looked up so far, as hash lists them
+	names     []string          //nolint:gochecknoglobals // Every command in PATH, nil until scanned
+	tableMu   sync.Mutex        //nolint:gochecknoglobals // Guards the table
+)
+
+// refresh drops the table if PATH has changed since it was built. The
+// caller must hold tableMu.
+func refresh() {
+	current, _ := variables.Get("PATH")
+	if hashed != nil && current == tablePath {
+		return
+	}
+
+	tablePath = current
+	hashed = map[string]*Entry{}
+	names = nil
+}
+
+// Lookup returns the path of the command that name runs and counts a hit
+// for it, as running the command does. Names containing a slash are paths
+// already and are not remembered.
+func Lookup(name string) (string, bool) {
+	if strings.Contains(name, "/") {
+		return name, isExecutable(name)
+	}
+
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	entry, ok := remember(name)
+	if !ok {
+		return "", false
+	}
+	entry.Hits++
+
+	return entry.Path, true
+}
+
+// Find returns the path of the command that name runs without adding it
+// to the table, as type and command -v do.
+func Find(name string) (string, bool) {
+	if strings.Contains(name, "/") {
+		return name, isExecutable(name)
+	}
+
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	refresh()
+	if entry, ok := hashed[name]; ok && isExecutable(entry.Path) {
+		return entry.Path, true
+	}
+
+	return search(name)
+}
+
+// FindAll returns every path in PATH at which name is a command, in order.
+func FindAll(name string) []string {
+	if strings.Contains(name, "/") {
+		if isExecutable(name) {
+			return []string{name}
+		}
+
+		return nil
+	}
+
+	var paths []string
+
+	for _, dir := range pathDirs() {
+		if candidate := join(dir, name); isExecutable(candidate) {
+			paths = append(paths, candidate)
+		}
+	}
+
+	return paths
+}
+
+// Hash adds name to the table without counting a hit, as hash name does.
+func Hash(name string) bool {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	_, ok := remember(name)
+
+	return ok
+}
+
+// Hashed returns the path remembered for name, if any.
+func Hashed(name string) (string, bool) {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	refresh()
+	if entry, ok := hashed[name]; ok {
+		return entry.Path, true
+	}
+
+	return "", false
+}
+
+// Forget removes name from the table and reports whether it was there.
+func Forget(name string) bool {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	refresh()
+	_, ok := hashed[name]
+	delete(hashed, name)
+
+	return ok
+}
+
+// Reset empties the table, so that every name is searched for again.
+func Reset() {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	hashed = map[string]*Entry{}
+	names = nil
+}
+
+// Entries returns the remembered commands sorted by name.
+func Entries() []Entry {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	refresh()
+
+	entries := make([]Entry, 0, len(hashed))
+	for _, entry := range hashed {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return entries
+}
+
+// Names returns the names of every command in PATH, sorted. The
+// directories are scanned once for each value of PATH, and the slice is
+// shared, so callers must not modify it.
+func Names() []string {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	refresh()
+	if names == nil {
+		names = scan()
+	}
+
+	return names
+}
+
+// remember returns the table entry for name, searching PATH if it is
+// missing or its file is gone. The caller must hold tableMu.
+func remember(name string) (*Entry, bool) {
+	refresh()
+	if entry, ok := hashed[name]; ok && isExecutable(entry.Path) {
+		return entry, true
+	}
+
+	path, ok := search(name)
+	if !ok {
+		delete(hashed, name)
+
+		return nil, false
+	}
+
+	entry := &Entry{Name: name, Path: path}
+	hashed[name] = entry
+
+	return entry, true
+}
+
+// search returns the first path in PATH at which name is a command.
+func search(name string) (string, bool) {
+	for _, dir := range pathDirs() {
+		if candidate := join(dir, name); isExecutable(candidate) {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+// scan lists the executable files in the PATH directories.
+func scan() []string {
+	seen := map[string]bool{}
+	list := []string{}
+
+	for _, dir := range pathDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || seen[entry.Name()] {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err == nil && info.Mode()&0o111 != 0 {
+				seen[entry.Name()] = true
+				list = append(list, entry.Name())
+			}
+		}
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// pathDirs returns the directories of PATH. An empty entry means the
+// current directory.
+func pathDirs() []string {
+	path, _ := variables.Get("PATH")
+	if path == "" {
+		return nil
+	}
+
+	dirs := strings.Split(path, ":")
+	for i, dir := range dirs {
+		if dir == "" {
+			dirs[i] = "."
+		}
+	}
+
+	return dirs
+}
+
+// join builds the path of name in dir, keeping a slash in it so that it
+// is never looked up in PATH again.
+func join(dir, name string) string {
+	path := filepath.Join(dir, name)
+	if !strings.Contains(path, "/") {
+		path = "./" + path
+	}
+
+	return path
+}
+
+// isExecutable reports whether path is a file the shell could run.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && !info.IsDir() && info.Mode()&0o111 != 0
+}
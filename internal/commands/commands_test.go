@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// setupPath points PATH at a new directory holding the named executables
+// and a file that is not executable, and returns the directory.
+func setupPath(t *testing.T, names ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil { //nolint:gosec // Test executables
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plain"), nil, 0o644); err != nil { //nolint:gosec // Test file
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir)
+
+	return dir
+}
+
+func TestLookup(t *testing.T) {
+	dir := setupPath(t, "alpha", "beta")
+
+	for range 2 {
+		path, ok := Lookup("alpha")
+		if !ok || path != filepath.Join(dir, "alpha") {
+			t.Fatalf("Lookup(alpha): expected %s, got %q, %v", filepath.Join(dir, "alpha"), path, ok)
+		}
+	}
+
+	if _, ok := Lookup("plain"); ok {
+		t.Error("Lookup(plain): a file that is not executable should not be found")
+	}
+	if _, ok := Find("beta"); !ok {
+		t.Error("Find(beta): expected to be found")
+	}
+
+	entries := Entries()
+	if len(entries) != 1 || entries[0].Name != "alpha" || entries[0].Hits != 2 {
+		t.Errorf("Expected only alpha with 2 hits, got %+v", entries)
+	}
+
+	if path, ok := Lookup("/bin/sh"); !ok || path != "/bin/sh" {
+		t.Errorf("Lookup(/bin/sh): expected the path itself, got %q, %v", path, ok)
+	}
+}
+
+func TestTable_Invalidation(t *testing.T) {
+	setupPath(t, "alpha")
+
+	if !Hash("alpha") {
+		t.Fatal("Hash(alpha): expected to be found")
+	}
+	if _, ok := Hashed("alpha"); !ok {
+		t.Fatal("Hashed(alpha): expected to be remembered")
+	}
+
+	other := setupPath(t, "gamma")
+	if _, ok := Hashed("alpha"); ok {
+		t.Error("Changing PATH should empty the table")
+	}
+	if !slices.Equal(Names(), []string{"gamma"}) {
+		t.Errorf("Expected the commands of the new PATH, got %v", Names())
+	}
+
+	if err := os.Remove(filepath.Join(other, "gamma")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Lookup("gamma"); ok {
+		t.Error("A remembered command whose file is gone should not be found")
+	}
+}
+
+func TestForgetAndReset(t *testing.T) {
+	setupPath(t, "alpha", "beta")
+
+	Hash("alpha")
+	Hash("beta")
+
+	if !Forget("alpha") || Forget("alpha") {
+		t.Error("Forget should report whether the name was remembered")
+	}
+
+	Reset()
+	if entries := Entries(); len(entries) != 0 {
+		t.Errorf("Expected an empty table after Reset, got %+v", entries)
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	first := setupPath(t, "alpha")
+	second := t.TempDir()
+	if err := os.WriteFile(filepath.Join(second, "alpha"), nil, 0o755); err != nil { //nolint:gosec // Test executable
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", first+":"+second)
+
+	expected := []string{filepath.Join(first, "alpha"), filepath.Join(second, "alpha")}
+	if paths := FindAll("alpha"); !slices.Equal(paths, expected) {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+}
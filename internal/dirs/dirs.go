@@ -0,0 +1,155 @@
+// Package dirs tracks the shell's working directory: the logical path in
+// PWD, which keeps the symbolic links it was reached through, the
+// previous one in OLDPWD, and the directory stack of pushd and popd.
+package dirs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"dsh/internal/variables"
+)
+
+var (
+	saved   []string   //nolint:gochecknoglobals // Directory stack below the current directory, top first
+	savedMu sync.Mutex //nolint:gochecknoglobals // Guards saved
+)
+
+// Initialize exports PWD as the logical current directory, so that the
+// commands the shell starts see a correct value even if the one it
+// inherited was stale.
+func Initialize() {
+	if dir := Current(); dir != "" {
+		export("PWD", dir)
+	}
+}
+
+// Current returns the logical current directory: PWD when it names the
+// current directory, and the physical path otherwise.
+func Current() string {
+	if pwd, ok := variables.Get("PWD"); ok && isCurrent(pwd) {
+		return pwd
+	}
+
+	return Physical()
+}
+
+// Abbreviate writes HOME at the start of dir as ~, as dirs and prompts
+// show it.
+func Abbreviate(dir string) string {
+	home := os.Getenv("HOME")
+	switch {
+	case home == "" || home == "/":
+		return dir
+	case dir == home:
+		return "~"
+	case strings.HasPrefix(dir, home+"/"):
+		return "~" + dir[len(home):]
+	}
+
+	return dir
+}
+
+// Physical returns the current directory with every symbolic link
+// resolved, or "" if it cannot be determined.
+func Physical() string {
+	dir, err := syscall.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	return dir
+}
+
+// Change makes dir the current directory and updates PWD and OLDPWD. A
+// relative dir is taken from the logical current directory, so that ..
+// undoes the last step, unless physical is set or that path does not
+// exist; then links are resolved as the kernel does.
+func Change(dir string, physical bool) error {
+	previous := Current()
+
+	target := ""
+	if !physical {
+		target = dir
+		if !filepath.IsAbs(dir) {
+			target = filepath.Join(previous, dir)
+		}
+		target = filepath.Clean(target)
+
+		if os.Chdir(target) != nil {
+			target = ""
+		}
+	}
+
+	if target == "" {
+		if err := os.Chdir(dir); err != nil {
+			return err
+		}
+		target = Physical()
+	}
+
+	if previous != "" {
+		export("OLDPWD", previous)
+	}
+	export("PWD", target)
+
+	return nil
+}
+
+// Stack returns the directory stack, the current directory first.
+func Stack() []string {
+	savedMu.Lock()
+	defer savedMu.Unlock()
+
+	return append([]string{Current()}, saved...)
+}
+
+// SetStack replaces the directories of the stack below the current one.
+func SetStack(entries []string) {
+	savedMu.Lock()
+	defer savedMu.Unlock()
+
+	saved = slices.Clone(entries)
+}
+
+// Entry returns entry n of the stack, counted from the top, or with
+// fromBottom from the bottom, as dirs +n and dirs -n show it.
+func Entry(n int, fromBottom bool) (string, bool) {
+	stack := Stack()
+	if n < 0 || n >= len(stack) {
+		return "", false
+	}
+
+	if fromBottom {
+		n = len(stack) - 1 - n
+	}
+
+	return stack[n], true
+}
+
+// isCurrent reports whether path is an absolute, clean path naming the
+// current directory.
+func isCurrent(path string) bool {
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+		return false
+	}
+
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	dotInfo, err := os.Stat(".")
+
+	return err == nil && os.SameFile(pathInfo, dotInfo)
+}
+
+// export sets a variable in the environment, as other shells export PWD
+// and OLDPWD to the commands they start.
+func export(name, value string) {
+	variables.Unset(name)
+	_ = os.Setenv(name, value)
+}
@@ -0,0 +1,90 @@
+package dirs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// setupLink creates a directory with a subdirectory reached through a
+// symbolic link, changes to the link's parent and returns it.
+func setupLink(t *testing.T) string {
+	t.Helper()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "real", "sub"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(root)
+	t.Setenv("OLDPWD", "")
+
+	return root
+}
+
+func TestChange_Logical(t *testing.T) {
+	root := setupLink(t)
+
+	if err := Change("link/sub", false); err != nil {
+		t.Fatal(err)
+	}
+	if dir := Current(); dir != filepath.Join(root, "link", "sub") {
+		t.Errorf("Expected the logical path through the link, got %s", dir)
+	}
+	if dir := Physical(); dir != filepath.Join(root, "real", "sub") {
+		t.Errorf("Expected the physical path, got %s", dir)
+	}
+	if os.Getenv("OLDPWD") != root {
+		t.Errorf("Expected OLDPWD %s, got %s", root, os.Getenv("OLDPWD"))
+	}
+
+	if err := Change("..", false); err != nil {
+		t.Fatal(err)
+	}
+	if dir := os.Getenv("PWD"); dir != filepath.Join(root, "link") {
+		t.Errorf("Expected .. to return to the link, got PWD %s", dir)
+	}
+}
+
+func TestChange_Physical(t *testing.T) {
+	root := setupLink(t)
+
+	if err := Change("link", true); err != nil {
+		t.Fatal(err)
+	}
+	if dir := Current(); dir != filepath.Join(root, "real") {
+		t.Errorf("Expected links resolved, got %s", dir)
+	}
+
+	if err := Change("missing", false); err == nil {
+		t.Error("Expected an error changing to a missing directory")
+	}
+}
+
+func TestStack(t *testing.T) {
+	setupLink(t)
+	t.Cleanup(func() { SetStack(nil) })
+
+	SetStack([]string{"/a", "/b"})
+
+	if stack := Stack(); !slices.Equal(stack[1:], []string{"/a", "/b"}) || stack[0] != Current() {
+		t.Errorf("Expected the current directory above the saved ones, got %v", stack)
+	}
+
+	if dir, ok := Entry(1, false); !ok || dir != "/a" {
+		t.Errorf("Entry(1): expected /a, got %q, %v", dir, ok)
+	}
+	if dir, ok := Entry(0, true); !ok || dir != "/b" {
+		t.Errorf("Entry(0) from the bottom: expected /b, got %q, %v", dir, ok)
+	}
+	if _, ok := Entry(3, false); ok {
+		t.Error("Entry(3): expected out of range")
+	}
+}
@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"dsh/internal/builtins"
+	"dsh/internal/commands"
+	"dsh/internal/lexer"
+	"dsh/internal/parser"
+	"dsh/internal/variables"
+)
+
+// Exit statuses of commands that could not be run, as in other shells.
+const (
+	statusNotExecutable = 126
+	statusNotFound      = 127
+)
+
+// executeEval implements eval: its arguments are joined with spaces and
+// run as a command line in the current shell, with eval's redirections
+// applied to all of it.
+func executeEval(cmd *parser.Command, args []string) bool {
+	restore := saveVariables(cmd.Assignments)
+	defer restore()
+
+	if assignAll(cmd.Assignments) != nil {
+		variables.SetLastStatus(builtins.StatusFailure)
+		return true
+	}
+
+	restoreFiles, err := redirectBuiltin(cmd)
+	defer restoreFiles()
+	if err != nil {
+		reportRedirectError(err)
+
+		return true
+	}
+
+	text := strings.Join(args[1:], " ")
+
+	pipelines, err := parser.New(lexer.New(text)).ParseCommandLine()
+	if err != nil && !errors.Is(err, parser.ErrEmptyPipeline) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: eval: %v\n", err)
+		variables.SetLastStatus(builtins.StatusUsage)
+
+		return true
+	}
+
+	variables.SetLastStatus(builtins.StatusSuccess)
+
+	return ExecuteList(pipelines)
+}
+
+// executeExec implements exec. Without a command its redirections apply
+// to the shell itself and stay in effect, as in exec 3>file. With one the
+// command replaces the shell, keeping the shell's descriptors and
+// environment. A command that cannot be run ends a shell that is not
+// interactive, as in bash.
+func executeExec(cmd *parser.Command, args []string) bool {
+	if len(args) > 1 && args[1] == "--" {
+		args = args[1:]
+	}
+
+	if err := redirectShell(cmd); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: exec: %v\n", err)
+		variables.SetLastStatus(builtins.StatusFailure)
+
+		return true
+	}
+
+	if len(args) < 2 {
+		variables.SetLastStatus(builtins.StatusSuccess)
+
+		return true
+	}
+
+	path, ok := commands.Lookup(args[1])
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: exec: %s: not found\n", args[1])
+		variables.SetLastStatus(statusNotFound)
+
+		return interactive
+	}
+
+	env, err := commandEnv(cmd.Assignments)
+	if err != nil {
+		reportExpansionError(err)
+
+		return true
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+
+	if err := exposeShellFiles(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: exec: %v\n", err)
+		variables.SetLastStatus(builtins.StatusFailure)
+
+		return true
+	}
+
+	// Exec only returns if the command could not replace the shell
+	err = syscall.Exec(path, args[1:], env) //nolint:gosec // Running user commands is the shell's job
+	_, _ = fmt.Fprintf(os.Stderr, "dsh: exec: %s: %v\n", args[1], err)
+	variables.SetLastStatus(statusNotExecutable)
+
+	return interactive
+}
+
+// executeCommandBuiltin implements command. With -v or -V it describes how
+// each name resolves; otherwise it runs its arguments as a command,
+// bypassing shell functions and aliases.
+func executeCommandBuiltin(cmd *parser.Command, args []string) bool {
+	var describe, verbose bool
+
+	i := 1
+	for ; i < len(args) && strings.HasPrefix(args[i], "-") && len(args[i]) > 1; i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		for _, flag := range args[i][1:] {
+			switch flag {
+			case 'v':
+				describe = true
+			case 'V':
+				describe, verbose = true, true
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: command: -%c: %v\n", flag, builtins.ErrInvalidOption)
+				variables.SetLastStatus(builtins.StatusUsage)
+
+				return true
+			}
+		}
+	}
+
+	if !describe {
+		return runCommand(cmd, args[i:])
+	}
+
+	status := builtins.StatusSuccess
+	for _, name := range args[i:] {
+		if !builtins.DescribeCommand(name, verbose) {
+			status = builtins.StatusFailure
+		}
+	}
+	variables.SetLastStatus(status)
+
+	return true
+}
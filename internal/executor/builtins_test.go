@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dsh/internal/lexer"
+	"dsh/internal/parser"
+	"dsh/internal/variables"
+)
+
+func TestExecutor_Eval(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("DSH_TEST_EVAL")
+		variables.Unset("DSH_TEST_NAME")
+	})
+
+	runList(t, `DSH_TEST_NAME=DSH_TEST_EVAL; eval "$DSH_TEST_NAME=set; false"`)
+	if value, _ := variables.Get("DSH_TEST_EVAL"); value != "set" {
+		t.Errorf("Expected eval to assign in the shell, got %q", value)
+	}
+	if GetLastExitStatus() != 1 {
+		t.Errorf("Expected the status of the last evaluated command, got %d", GetLastExitStatus())
+	}
+
+	runList(t, "false; eval")
+	if GetLastExitStatus() != 0 {
+		t.Errorf("Expected an empty eval to succeed, got %d", GetLastExitStatus())
+	}
+
+	runList(t, "eval 'if true'")
+	if GetLastExitStatus() != 2 {
+		t.Errorf("Expected status 2 after a syntax error, got %d", GetLastExitStatus())
+	}
+
+	pipelines, err := parser.New(lexer.New("eval 'exit 4'")).ParseCommandLine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ExecuteList(pipelines) {
+		t.Error("Expected exit inside eval to stop the shell")
+	}
+}
+
+func TestExecutor_Command(t *testing.T) {
+	tests := []struct {
+		input  string
+		status int
+	}{
+		{"command -v cd ls if", 0},
+		{"command -v dsh_no_such_command", 1},
+		{"command -V dsh_no_such_command", 1},
+		{"command test 1 = 2", 1},
+		{"command true", 0},
+		{"command -q", 2},
+		{"command", 0},
+	}
+
+	for _, test := range tests {
+		runList(t, test.input)
+		if status := GetLastExitStatus(); status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.input, test.status, status)
+		}
+	}
+}
+
+func TestExecutor_DescriptorRedirections(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	log := filepath.Join(dir, "log")
+
+	runList(t, "ls "+filepath.Join(dir, "missing")+" > "+out+" 2>&1")
+	if data, _ := os.ReadFile(out); len(data) == 0 {
+		t.Error("Expected 2>&1 to send errors to the output file")
+	}
+
+	runList(t, "exec 9>"+log+"; echo one >&9; sh -c 'echo two >&9'; exec 9>&-")
+	if data, _ := os.ReadFile(log); string(data) != "one\ntwo\n" {
+		t.Errorf("Expected both commands to write to descriptor 9, got %q", data)
+	}
+	if _, ok := shellFiles[9]; ok {
+		t.Error("Expected exec 9>&- to close descriptor 9")
+	}
+
+	runList(t, "echo three >&9")
+	if GetLastExitStatus() != 1 {
+		t.Errorf("Expected writing to a closed descriptor to fail, got %d", GetLastExitStatus())
+	}
+
+	runList(t, "exec 8<"+log+"; exec 7<&8; cat <&7 >"+out+"; exec 7<&- 8<&-")
+	if data, _ := os.ReadFile(out); string(data) != "one\ntwo\n" {
+		t.Errorf("Expected cat to read the copied descriptor, got %q", data)
+	}
+
+	// A missing command ends only a shell that is not interactive
+	pipelines, _ := parser.New(lexer.New("exec dsh_no_such_command")).ParseCommandLine()
+	if ExecuteList(pipelines) || GetLastExitStatus() != 127 {
+		t.Errorf("Expected the shell to end with 127 for a missing command, got %d", GetLastExitStatus())
+	}
+
+	SetInteractive(true)
+	t.Cleanup(func() { SetInteractive(false) })
+	runList(t, "exec dsh_no_such_command")
+	if GetLastExitStatus() != 127 {
+		t.Errorf("Expected status 127 for a missing command, got %d", GetLastExitStatus())
+	}
+}
+
+func TestExecutor_BuiltinRedirections(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("DSH_TEST_FIRST")
+		variables.Unset("DSH_TEST_REST")
+	})
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	in := filepath.Join(dir, "in")
+	t.Chdir(dir)
+	t.Setenv("OLDPWD", "")
+	if err := os.WriteFile(in, []byte("alpha beta gamma\nsecond line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	runList(t, "cd "+dir+" > "+out+"; pwd >> "+out)
+	if data, _ := os.ReadFile(out); string(data) != dir+"\n" {
+		t.Errorf("Expected pwd to write to the file, got %q", data)
+	}
+
+	runList(t, "read DSH_TEST_FIRST DSH_TEST_REST < "+in)
+	first, _ := variables.Get("DSH_TEST_FIRST")
+	rest, _ := variables.Get("DSH_TEST_REST")
+	if first != "alpha" || rest != "beta gamma" {
+		t.Errorf("Expected read to split the first line, got %q and %q", first, rest)
+	}
+
+	runList(t, "read -r DSH_TEST_FIRST <<EOF\n  here \\doc\nEOF")
+	if first, _ := variables.Get("DSH_TEST_FIRST"); first != `here \doc` {
+		t.Errorf("Expected read to take the here document, got %q", first)
+	}
+
+	runList(t, "cd /nonexistent 2> "+out)
+	if data, _ := os.ReadFile(out); len(data) == 0 {
+		t.Error("Expected the error of cd in the redirected stderr")
+	}
+
+	runList(t, "pwd > "+filepath.Join(dir, "missing", "out"))
+	if GetLastExitStatus() != 1 {
+		t.Errorf("Expected a failed redirection to fail the builtin, got %d", GetLastExitStatus())
+	}
+}
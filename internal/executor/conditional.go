@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"dsh/internal/builtins"
+	"dsh/internal/expand"
+	"dsh/internal/parser"
+	"dsh/internal/variables"
+)
+
+// rematchVariable receives the match and capture groups of =~.
+const rematchVariable = "DSH_REMATCH"
+
+// ErrInvalidRegex indicates an =~ pattern that does not compile.
+var ErrInvalidRegex = errors.New("invalid regular expression")
+
+// evaluateConditional runs a [[ ... ]] expression and returns its status:
+// 0 when true, 1 when false, and 2 when the expression is invalid.
+func evaluateConditional(expr *parser.CondExpr) int {
+	result, err := evalCondExpr(expr)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: [[: %v\n", err)
+
+		return builtins.StatusUsage
+	}
+
+	if result {
+		return builtins.StatusSuccess
+	}
+
+	return builtins.StatusFailure
+}
+
+func evalCondExpr(expr *parser.CondExpr) (bool, error) {
+	switch expr.Op {
+	case "&&":
+		left, err := evalCondExpr(expr.Left)
+		if err != nil || !left {
+			return false, err
+		}
+
+		return evalCondExpr(expr.Right)
+	case "||":
+		left, err := evalCondExpr(expr.Left)
+		if err != nil || left {
+			return left, err
+		}
+
+		return evalCondExpr(expr.Right)
+	case "!":
+		result, err := evalCondExpr(expr.Left)
+
+		return !result, err
+	}
+
+	return evalCondLeaf(expr)
+}
+
+// evalCondLeaf expands the operands of a leaf without field splitting and
+// evaluates it. The right side of ==, != and =~ is a pattern, in which
+// quoted parts match literally.
+func evalCondLeaf(expr *parser.CondExpr) (bool, error) {
+	switch len(expr.Operands) {
+	case 1:
+		operand, err := expand.String(expr.Operands[0])
+		if err != nil {
+			return false, err
+		}
+
+		if expr.Op == "" {
+			return operand != "", nil
+		}
+
+		return builtins.UnaryTest(expr.Op, operand)
+	case 2:
+		left, err := expand.String(expr.Operands[0])
+		if err != nil {
+			return false, err
+		}
+
+		right, err := expandCondRight(expr.Op, expr.Operands[1])
+		if err != nil {
+			return false, err
+		}
+
+		switch expr.Op {
+		case "=", "==":
+			return expand.MatchPattern(right, left), nil
+		case "!=":
+			return !expand.MatchPattern(right, left), nil
+		case "=~":
+			return matchRegex(right, left)
+		default:
+			return builtins.BinaryTest(left, expr.Op, right)
+		}
+	default:
+		return false, nil
+	}
+}
+
+func expandCondRight(op, raw string) (string, error) {
+	switch op {
+	case "=", "==", "!=":
+		return expand.Pattern(raw)
+	case "=~":
+		return expand.Regex(raw)
+	default:
+		return expand.String(raw)
+	}
+}
+
+// matchRegex matches value against an extended regular expression and
+// stores the match followed by its capture groups in DSH_REMATCH.
+func matchRegex(pattern, value string) (bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", pattern, ErrInvalidRegex)
+	}
+
+	groups := re.FindStringSubmatch(value)
+	if groups == nil {
+		variables.SetArray(rematchVariable, nil)
+
+		return false, nil
+	}
+
+	variables.SetArray(rematchVariable, groups)
+
+	return true, nil
+}
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"testing"
+
+	"dsh/internal/lexer"
+	"dsh/internal/parser"
+	"dsh/internal/variables"
+)
+
+func conditionalStatus(t *testing.T, input string) int {
+	t.Helper()
+
+	pipelines, err := parser.New(lexer.New(input)).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("%q: parse error: %v", input, err)
+	}
+
+	return evaluateConditional(pipelines[0].Commands[0].Conditional)
+}
+
+func TestConditional_Expressions(t *testing.T) {
+	tests := []struct {
+		input  string
+		status int
+	}{
+		{"[[ abc ]]", 0},
+		{`[[ "" ]]`, 1},
+		{"[[ -d / ]]", 0},
+		{"[[ -f / ]]", 1},
+		{"[[ abc == a* ]]", 0},
+		{"[[ abc == a?c ]]", 0},
+		{"[[ abc == [ab]bc ]]", 0},
+		{"[[ abc != [!a]* ]]", 0},
+		{"[[ a/b == a*b ]]", 0},
+		{"[[ x9 == x[[:digit:]] ]]", 0},
+		{"[[ a < b ]]", 0},
+		{"[[ a > b ]]", 1},
+		{"[[ 2 -gt 10 ]]", 1},
+		{"[[ x -eq 1 ]]", 2},
+		{"[[ ! -e /nonexistent && ( a == a || b == c ) ]]", 0},
+		{"[[ a == b || c == c ]]", 0},
+		{"[[ a == a && c == d ]]", 1},
+	}
+
+	for _, test := range tests {
+		if status := conditionalStatus(t, test.input); status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.input, test.status, status)
+		}
+	}
+}
+
+func TestConditional_RegexSetsRematch(t *testing.T) {
+	if status := conditionalStatus(t, "[[ release-1.24 =~ ^([a-z]+)-([0-9.]+)$ ]]"); status != 0 {
+		t.Fatalf("Expected regex to match, got status %d", status)
+	}
+
+	expected := []string{"release-1.24", "release", "1.24"}
+	got := variables.GetArray(rematchVariable)
+	if len(got) != len(expected) {
+		t.Fatalf("Expected DSH_REMATCH %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("DSH_REMATCH[%d]: expected %q, got %q", i, expected[i], got[i])
+		}
+	}
+
+	if status := conditionalStatus(t, "[[ abc =~ ^[0-9]+$ ]]"); status != 1 {
+		t.Errorf("Expected regex not to match, got status %d", status)
+	}
+	if got := variables.GetArray(rematchVariable); len(got) != 0 {
+		t.Errorf("Expected DSH_REMATCH to be cleared, got %v", got)
+	}
+
+	if status := conditionalStatus(t, "[[ a =~ *a ]]"); status != 2 {
+		t.Errorf("Expected invalid regex to return status 2, got %d", status)
+	}
+}
+
+func TestConditional_QuotedRegexIsLiteral(t *testing.T) {
+	if status := conditionalStatus(t, `[[ a.b =~ "a.b" ]]`); status != 0 {
+		t.Errorf("Expected quoted regex to match literally, got %d", status)
+	}
+	if status := conditionalStatus(t, `[[ axb =~ "a.b" ]]`); status != 1 {
+		t.Errorf("Expected quoted dot not to match any character, got %d", status)
+	}
+}
@@ -2,120 +2,566 @@
 package executor
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"sync"
+	"slices"
+	"strings"
 	"syscall"
 
 	"dsh/internal/builtins"
+	"dsh/internal/dirs"
+	"dsh/internal/expand"
+	"dsh/internal/options"
 	"dsh/internal/parser"
+	"dsh/internal/variables"
 )
 
-var (
-	lastExitStatus int
-	exitStatusMu   sync.RWMutex
-)
+// outputFileMode is the mode of files created by redirections, from
+// which the kernel takes away the bits of the umask.
+const outputFileMode = 0o666
+
+// ErrNoClobber indicates a > redirection to an existing file under noclobber.
+var ErrNoClobber = errors.New("cannot overwrite existing file")
 
 // GetLastExitStatus returns the exit status of the last executed command.
 func GetLastExitStatus() int {
-	exitStatusMu.RLock()
-	defer exitStatusMu.RUnlock()
-	return lastExitStatus
+	return variables.LastStatus()
 }
 
 // setExitStatus sets the exit status from a command execution.
 func setExitStatus(err error) {
-	exitStatusMu.Lock()
-	defer exitStatusMu.Unlock()
+	variables.SetLastStatus(exitStatus(err))
+}
 
+// exitStatus converts the error from running a command to its status.
+func exitStatus(err error) int {
 	if err == nil {
-		lastExitStatus = 0
-		return
+		return 0
 	}
 
 	var exitError *exec.ExitError
 	if errors.As(err, &exitError) {
 		if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
-			lastExitStatus = status.ExitStatus()
-			return
+			return status.ExitStatus()
 		}
 	}
 
+	var builtinError builtinStatus
+	if errors.As(err, &builtinError) {
+		return int(builtinError)
+	}
+
 	// Default to 1 for other errors
-	lastExitStatus = 1
+	return 1
 }
 
-// ExecuteCommand executes a single command.
+// ExecuteCommand executes a single command. It returns false only when
+// the shell should stop, as after exit.
 func ExecuteCommand(cmd *parser.Command) bool {
-	if len(cmd.Args) == 0 {
-		setExitStatus(nil) // Empty command succeeds
+	if cmd.Conditional != nil {
+		variables.SetLastStatus(evaluateConditional(cmd.Conditional))
 		return true
 	}
 
-	// Handle built-in commands
-	if builtins.IsBuiltin(cmd.Args[0]) {
-		success := builtins.ExecuteBuiltin(cmd.Args)
-		if success {
-			setExitStatus(nil) // Builtin succeeded
-		} else {
-			lastExitStatus = 1 // Builtin failed (or exit command)
+	if cmd.Compound != nil {
+		return executeCompound(cmd.Compound)
+	}
+
+	// Process substitutions in the words live until the command finishes
+	mark := substitutionMark()
+	defer func() { reapSubstitutions(mark, !cmd.Background) }()
+
+	args, err := commandArgs(cmd)
+	if err != nil {
+		reportExpansionError(err)
+		return true
+	}
+	traceCommand(cmd.Assignments, args)
+
+	return runCommand(cmd, args)
+}
+
+// runCommand runs a simple command whose words have been expanded to args.
+func runCommand(cmd *parser.Command, args []string) bool {
+	if len(args) == 0 {
+		// Assignments without a command persist in the shell
+		status := builtins.StatusSuccess
+		if assignAll(cmd.Assignments) != nil {
+			status = builtins.StatusFailure
 		}
-		return success
+		variables.SetLastStatus(status)
+
+		return true
+	}
+
+	// eval, exec, command and fc need the executor itself
+	switch args[0] {
+	case "eval":
+		return executeEval(cmd, args)
+	case "exec":
+		return executeExec(cmd, args)
+	case "command":
+		return executeCommandBuiltin(cmd, args)
+	case "fc":
+		return executeFc(cmd, args)
+	}
+
+	// Handle built-in commands
+	if builtins.IsBuiltin(args[0]) {
+		return executeBuiltin(cmd, args)
 	}
 
 	// Execute external command
-	return executeExternal(cmd)
+	return executeExternal(cmd, args)
 }
 
-// ExecutePipeline executes a pipeline of commands.
-func ExecutePipeline(pipeline *parser.Pipeline) bool {
-	if len(pipeline.Commands) == 1 {
-		return ExecuteCommand(pipeline.Commands[0])
+// commandArgs expands the words of a command. Commands built without
+// source text use their Args as they are. The arguments of declaration
+// builtins such as declare keep assignments unexpanded, so that array
+// literals reach the builtin intact.
+func commandArgs(cmd *parser.Command) ([]string, error) {
+	if len(cmd.Words) == 0 {
+		return cmd.Args, nil
 	}
 
-	return executeMultiCommandPipeline()
+	name, err := expand.Fields(cmd.Words[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(name) != 1 || !builtins.IsDeclaration(name[0]) {
+		return expand.Words(cmd.Words)
+	}
+
+	args := name
+	for _, raw := range cmd.Words[1:] {
+		if expand.IsAssignment(raw) {
+			args = append(args, raw)
+
+			continue
+		}
+
+		fields, err := expand.Fields(raw)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, fields...)
+	}
+
+	return args, nil
 }
 
-func executeExternal(cmd *parser.Command) bool {
-	ctx := context.Background()
-	execCmd := exec.CommandContext(ctx, cmd.Args[0], cmd.Args[1:]...) //nolint:gosec
+// executeBuiltin runs a builtin with any prefix assignments and
+// redirections in effect for its duration only.
+func executeBuiltin(cmd *parser.Command, args []string) bool {
+	restore := saveVariables(cmd.Assignments)
+	defer restore()
 
-	// Handle I/O redirection
-	if cmd.InputFile != "" {
-		file, err := os.Open(cmd.InputFile)
+	if assignAll(cmd.Assignments) != nil {
+		variables.SetLastStatus(builtins.StatusFailure)
+		return true
+	}
+
+	restoreFiles, err := redirectBuiltin(cmd)
+	defer restoreFiles()
+	if err != nil {
+		reportRedirectError(err)
+
+		return true
+	}
+
+	variables.SetLastStatus(builtins.Run(args))
+	return !builtins.EndsShell(args[0])
+}
+
+// assignAll performs assignment words in order, reporting the first error.
+func assignAll(assignments []string) error {
+	for _, raw := range assignments {
+		err := expand.Assign(raw)
 		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+			reportExpansionError(err)
+			return err
+		}
+	}
+
+	return nil
+}
 
-			return true
+// saveVariables records the variables named by assignments and returns a
+// function that puts them back.
+func saveVariables(assignments []string) func() {
+	type saved struct {
+		name  string
+		value string
+		isSet bool
+	}
+
+	var state []saved
+
+	for _, raw := range assignments {
+		name, _, err := expand.Assignment(raw)
+		if err != nil {
+			continue
+		}
+		value, isSet := variables.Get(name)
+		state = append(state, saved{name: name, value: value, isSet: isSet})
+	}
+
+	return func() {
+		for i := len(state) - 1; i >= 0; i-- {
+			if state[i].isSet {
+				variables.Set(state[i].name, state[i].value)
+			} else {
+				variables.Unset(state[i].name)
+			}
 		}
-		defer func() { _ = file.Close() }()
+	}
+}
 
-		execCmd.Stdin = file
-	} else {
-		execCmd.Stdin = os.Stdin
+// commandEnv returns the environment for an external command: the shell's
+// environment plus any prefix assignments.
+func commandEnv(assignments []string) ([]string, error) {
+	if len(assignments) == 0 {
+		return nil, nil
 	}
 
-	if cmd.OutputFile != "" {
-		file, err := openOutputFile(cmd.OutputFile, cmd.AppendMode)
+	env := os.Environ()
+
+	for _, raw := range assignments {
+		name, value, err := expand.Assignment(raw)
 		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+			return nil, err
+		}
+		env = append(env, name+"="+value)
+	}
+
+	return env, nil
+}
+
+// executeCompound runs a compound command such as a for loop.
+func executeCompound(compound parser.CompoundCommand) bool {
+	switch command := compound.(type) {
+	case *parser.ForClause:
+		return executeFor(command)
+	case *parser.IfClause:
+		return executeIf(command)
+	case *parser.BraceGroup:
+		return ExecuteList(command.Body)
+	case *parser.Subshell:
+		return executeSubshell(command)
+	default:
+		return true
+	}
+}
+
+// executeIf runs the body of the first branch whose condition succeeds,
+// or the else branch. Without either, the status is zero.
+func executeIf(clause *parser.IfClause) bool {
+	for _, branch := range clause.Branches {
+		errexitIgnored++
+		ok := ExecuteList(branch.Condition)
+		errexitIgnored--
+		if !ok {
+			return false
+		}
+
+		if GetLastExitStatus() == 0 {
+			return ExecuteList(branch.Body)
+		}
+	}
+
+	if clause.Else != nil {
+		return ExecuteList(clause.Else)
+	}
+
+	setExitStatus(nil)
+
+	return true
+}
+
+// executeSubshell runs a list and then undoes its changes to variables,
+// options, the umask and the working directory. exit ends only the
+// subshell. A list that may change what cannot be undone, such as the
+// shell's descriptors or its resource limits, runs in a child shell
+// instead, as in ksh.
+func executeSubshell(subshell *parser.Subshell) bool {
+	if changesProcess(subshell.Body) {
+		return executeShellProcess(subshell.Source)
+	}
+
+	snapshot := variables.TakeSnapshot()
+	settings := options.TakeSnapshot()
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	stack := dirs.Stack()
+	dir, dirErr := os.Getwd()
+
+	ExecuteList(subshell.Body)
+
+	variables.RestoreSnapshot(snapshot)
+	options.RestoreSnapshot(settings)
+	syscall.Umask(mask)
+	dirs.SetStack(stack[1:])
+	if dirErr == nil {
+		_ = os.Chdir(dir)
+	}
+
+	return true
+}
+
+// processBuiltins are the builtins whose effects a subshell run in the
+// shell could not undo: exec replaces the shell or redirects its own
+// descriptors, lowered limits cannot be raised again and bind changes the
+// line editor. command and eval may run any of them.
+var processBuiltins = []string{"bind", "command", "eval", "exec", "fc", "ulimit"} //nolint:gochecknoglobals // Fixed list of builtins
+
+// changesProcess reports whether list may run one of processBuiltins. A
+// command name that is expanded may be any of them, so it counts too.
+// Subshells within list decide for themselves when they run.
+func changesProcess(list []*parser.Pipeline) bool {
+	for _, pipeline := range list {
+		for _, cmd := range pipeline.Commands {
+			if commandChangesProcess(cmd) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// commandChangesProcess reports whether cmd may run one of processBuiltins.
+func commandChangesProcess(cmd *parser.Command) bool {
+	switch compound := cmd.Compound.(type) {
+	case *parser.ForClause:
+		return changesProcess(compound.Body)
+	case *parser.IfClause:
+		for _, branch := range compound.Branches {
+			if changesProcess(branch.Condition) || changesProcess(branch.Body) {
+				return true
+			}
+		}
+
+		return changesProcess(compound.Else)
+	case *parser.BraceGroup:
+		return changesProcess(compound.Body)
+	case *parser.Subshell:
+		return false
+	}
+
+	var name string
+	switch {
+	case len(cmd.Words) > 0:
+		name = cmd.Words[0]
+	case len(cmd.Args) > 0:
+		name = cmd.Args[0]
+	default:
+		return false
+	}
+
+	return !isLiteral(name) || slices.Contains(processBuiltins, name)
+}
+
+// isLiteral reports whether word stands for itself, with nothing in it to
+// expand or quote.
+func isLiteral(word string) bool {
+	return !strings.ContainsAny(word, "$`\\'\"{*?[~")
+}
+
+// executeShellProcess runs text in a child shell and waits for it.
+func executeShellProcess(text string) bool {
+	child, err := shellCommand(text)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+		variables.SetLastStatus(builtins.StatusFailure)
+
+		return true
+	}
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	child.ExtraFiles = childFiles()
+
+	runForegroundProcess(child)
+
+	return true
+}
+
+// executeFor runs the body once for each word of the expanded list. The
+// status is that of the last command run, or zero if the list is empty.
+func executeFor(clause *parser.ForClause) bool {
+	words, err := expand.Words(clause.Words)
+	if err != nil {
+		reportExpansionError(err)
+		return true
+	}
+
+	setExitStatus(nil)
+
+	for _, word := range words {
+		variables.Set(clause.Name, word)
+
+		if !ExecuteList(clause.Body) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reportExpansionError reports err and fails. An unbound variable under
+// nounset also ends a shell that is not interactive, once the pipeline
+// being run has stopped.
+func reportExpansionError(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+	variables.SetLastStatus(builtins.StatusFailure)
+
+	if errors.Is(err, expand.ErrUnboundVariable) && !interactive {
+		unboundExit = true
+	}
+}
+
+var (
+	// interactive is set in a shell reading commands from a terminal,
+	// which an unbound variable does not end.
+	interactive bool //nolint:gochecknoglobals // Set once at startup
+	// unboundExit is set by an unbound variable that ends the shell, for
+	// ExecuteList to stop at.
+	unboundExit bool //nolint:gochecknoglobals // Execution state shared by nested lists
+)
 
-			return true
+// SetInteractive records whether the shell reads commands from a terminal.
+func SetInteractive(on bool) {
+	interactive = on
+}
+
+// endsOnUnbound reports, and forgets, an unbound variable that ends the
+// shell.
+func endsOnUnbound() bool {
+	ends := unboundExit
+	unboundExit = false
+
+	return ends
+}
+
+// errexitIgnored counts the enclosing contexts in which a failure does not
+// end the shell under errexit: if conditions and the commands before the
+// last && or || of a list.
+var errexitIgnored int //nolint:gochecknoglobals // Execution context shared by nested lists
+
+// ExecuteList executes pipelines joined by ;, && and ||. A pipeline after
+// && runs only if the previous status is zero, one after || only if it is
+// non-zero; skipped pipelines leave the status unchanged. Under errexit a
+// failing pipeline ends the shell unless its status is being tested.
+func ExecuteList(pipelines []*parser.Pipeline) bool {
+	run := true
+
+	for _, pipeline := range pipelines {
+		if run {
+			tested := pipeline.Operator != parser.OpSequence
+			if tested {
+				errexitIgnored++
+			}
+			ok := ExecutePipeline(pipeline)
+			if tested {
+				errexitIgnored--
+			}
+
+			if !ok || endsOnUnbound() || (!tested && exitsOnError(pipeline)) {
+				return false
+			}
+		}
+
+		switch pipeline.Operator {
+		case parser.OpAnd:
+			run = GetLastExitStatus() == 0
+		case parser.OpOr:
+			run = GetLastExitStatus() != 0
+		case parser.OpSequence:
+			run = true
 		}
-		defer func() { _ = file.Close() }()
+	}
+
+	return true
+}
+
+// exitsOnError reports whether errexit ends the shell after pipeline.
+// Brace groups, if and for report the status of a command inside them,
+// which was already checked where it ran, and the status of a pipeline
+// after ! is being tested.
+func exitsOnError(pipeline *parser.Pipeline) bool {
+	if GetLastExitStatus() == 0 || errexitIgnored > 0 || pipeline.Negated || !options.Enabled(options.Errexit) {
+		return false
+	}
+
+	if len(pipeline.Commands) == 1 {
+		switch pipeline.Commands[0].Compound.(type) {
+		case *parser.BraceGroup, *parser.IfClause, *parser.ForClause:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ExecutePipeline executes a pipeline of commands, timing it after the
+// time keyword and inverting its status after !.
+func ExecutePipeline(pipeline *parser.Pipeline) bool {
+	if pipeline.Negated {
+		return executeNegated(pipeline)
+	}
+
+	if pipeline.Time != parser.TimeNone {
+		return executeTimed(pipeline)
+	}
+
+	return executePipeline(pipeline)
+}
 
-		execCmd.Stdout = file
-	} else {
-		execCmd.Stdout = os.Stdout
+// executeNegated runs a pipeline after !, whose status is 1 if the
+// pipeline succeeds and 0 if it fails. As with a tested command, no
+// failure within it ends the shell under errexit.
+func executeNegated(pipeline *parser.Pipeline) bool {
+	inner := *pipeline
+	inner.Negated = false
+
+	errexitIgnored++
+	ok := ExecutePipeline(&inner)
+	errexitIgnored--
+
+	status := builtins.StatusSuccess
+	if GetLastExitStatus() == 0 {
+		status = builtins.StatusFailure
+	}
+	variables.SetLastStatus(status)
+
+	return ok
+}
+
+// executePipeline executes a pipeline of commands.
+func executePipeline(pipeline *parser.Pipeline) bool {
+	if len(pipeline.Commands) == 0 {
+		variables.SetLastStatus(0)
+
+		return true
 	}
 
-	execCmd.Stderr = os.Stderr
+	if len(pipeline.Commands) == 1 {
+		return ExecuteCommand(pipeline.Commands[0])
+	}
+
+	return executeMultiCommandPipeline(pipeline)
+}
+
+func executeExternal(cmd *parser.Command, args []string) bool {
+	execCmd, cleanup, err := externalCommand(cmd, args, os.Stdin, os.Stdout)
+	if err != nil {
+		return true
+	}
+	defer cleanup()
 
 	if cmd.Background {
-		startBackgroundProcess(execCmd)
+		startBackgroundProcess(execCmd, jobCommand([]*parser.Command{cmd}))
 
 		return true
 	}
@@ -124,9 +570,50 @@ func executeExternal(cmd *parser.Command) bool {
 	return true
 }
 
-func openOutputFile(filename string, appendMode bool) (*os.File, error) {
+// externalCommand prepares an external command with its environment and
+// redirections, reading stdin and writing stdout unless they are
+// redirected. Errors have already been reported and the status set;
+// otherwise cleanup closes the redirected files once the command is done.
+func externalCommand(cmd *parser.Command, args []string, stdin, stdout *os.File) (*exec.Cmd, func(), error) {
+	execCmd := commandProcess(args)
+
+	env, err := commandEnv(cmd.Assignments)
+	if err != nil {
+		reportExpansionError(err)
+		return nil, nil, err
+	}
+	execCmd.Env = env
+	execCmd.ExtraFiles = childFiles()
+
+	execCmd.Stdin, execCmd.Stdout, execCmd.Stderr = stdin, stdout, os.Stderr
+
+	files, err := redirectCommand(execCmd, cmd.Redirections)
+	cleanup := func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}
+	if err != nil {
+		cleanup()
+		reportRedirectError(err)
+
+		return nil, nil, err
+	}
+
+	return execCmd, cleanup, nil
+}
+
+// reportRedirectError reports a redirection that failed, which may be
+// because its target could not be expanded.
+func reportRedirectError(err error) {
+	reportExpansionError(err)
+}
+
+// openOutputFile opens a redirection target for writing. Under noclobber
+// an existing regular file is not truncated unless clobber is set by >|.
+func openOutputFile(filename string, appendMode, clobber bool) (*os.File, error) {
 	if appendMode {
-		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec
+		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, outputFileMode) //nolint:gosec
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file for append %s: %w", filename, err)
 		}
@@ -134,7 +621,11 @@ func openOutputFile(filename string, appendMode bool) (*os.File, error) {
 		return file, nil
 	}
 
-	file, err := os.Create(filename) //nolint:gosec
+	if options.Enabled(options.Noclobber) && !clobber {
+		return openNoClobber(filename)
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, outputFileMode) //nolint:gosec
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file %s: %w", filename, err)
 	}
@@ -142,19 +633,45 @@ func openOutputFile(filename string, appendMode bool) (*os.File, error) {
 	return file, nil
 }
 
-func startBackgroundProcess(execCmd *exec.Cmd) {
-	err := execCmd.Start()
+// openNoClobber creates filename, refusing to overwrite a regular file.
+// Other existing files, such as /dev/null, are opened for writing.
+func openNoClobber(filename string) (*os.File, error) {
+	info, err := os.Stat(filename)
+	if err == nil && info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: %w", filename, ErrNoClobber)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if err == nil {
+		flags = os.O_WRONLY
+	}
+
+	file, err := os.OpenFile(filename, flags, outputFileMode) //nolint:gosec
 	if err != nil {
+		return nil, fmt.Errorf("failed to create file %s: %w", filename, err)
+	}
+
+	return file, nil
+}
+
+// startBackgroundProcess starts a command as a job of its own.
+func startBackgroundProcess(execCmd *exec.Cmd, command string) {
+	setProcessGroup(execCmd, 0)
+
+	if err := execCmd.Start(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
 
 		return
 	}
 
-	_, _ = fmt.Fprintf(os.Stdout, "[%d]\n", execCmd.Process.Pid)
+	startJob(command, []process{execCmd})
 }
 
 func runForegroundProcess(execCmd *exec.Cmd) {
-	err := execCmd.Run()
+	err := execCmd.Start()
+	if err == nil {
+		err = waitProcess(execCmd)
+	}
 	setExitStatus(err)
 
 	if err != nil {
@@ -166,9 +683,3 @@ func runForegroundProcess(execCmd *exec.Cmd) {
 		// Command failed, but continue processing (don't exit shell)
 	}
 }
-
-func executeMultiCommandPipeline() bool {
-	_, _ = fmt.Fprintf(os.Stderr, "dsh: multi-command pipelines not yet implemented\n")
-
-	return true
-}
@@ -1,8 +1,15 @@
 package executor
 
 import (
-	"dsh/internal/parser"
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+
+	"dsh/internal/lexer"
+	"dsh/internal/options"
+	"dsh/internal/parser"
+	"dsh/internal/variables"
 )
 
 func TestExecutor_SimpleCommand(t *testing.T) {
@@ -52,3 +59,403 @@ func TestExecutor_BuiltinCommand(t *testing.T) {
 		t.Error("Expected builtin command to succeed")
 	}
 }
+
+func TestExecutor_ListOperators(t *testing.T) {
+	tests := []struct {
+		input  string
+		status int
+	}{
+		{"true && false", 1},
+		{"false && true", 1},
+		{"false || true", 0},
+		{"true || false", 0},
+		{"false && true || true", 0},
+		{"true || false && false", 1},
+		{"false; true", 0},
+	}
+
+	for _, test := range tests {
+		pipelines, err := parser.New(lexer.New(test.input)).ParseCommandLine()
+		if err != nil {
+			t.Fatalf("%q: parse error: %v", test.input, err)
+		}
+
+		if !ExecuteList(pipelines) {
+			t.Errorf("%q: expected the shell to keep running", test.input)
+		}
+		if status := GetLastExitStatus(); status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.input, test.status, status)
+		}
+	}
+}
+
+func TestExecutor_FailingBuiltinKeepsRunning(t *testing.T) {
+	cmd := &parser.Command{
+		Args: []string{"test", "a", "=", "b"},
+	}
+
+	if !ExecuteCommand(cmd) {
+		t.Error("Expected a failing builtin not to stop the shell")
+	}
+	if GetLastExitStatus() != 1 {
+		t.Errorf("Expected status 1, got %d", GetLastExitStatus())
+	}
+
+	if ExecuteCommand(&parser.Command{Args: []string{"exit"}}) {
+		t.Error("Expected exit to stop the shell")
+	}
+}
+
+func runList(t *testing.T, input string) {
+	t.Helper()
+
+	pipelines, err := parser.New(lexer.New(input)).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("%q: parse error: %v", input, err)
+	}
+
+	if !ExecuteList(pipelines) {
+		t.Fatalf("%q: expected the shell to keep running", input)
+	}
+}
+
+func TestExecutor_Assignments(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("DSH_TEST_A")
+		variables.Unset("DSH_TEST_TEMP")
+	})
+
+	runList(t, `DSH_TEST_A="one two"; DSH_TEST_A+=" three"`)
+	if value, _ := variables.Get("DSH_TEST_A"); value != "one two three" {
+		t.Errorf("Expected assignment to persist, got %q", value)
+	}
+
+	runList(t, "DSH_TEST_TEMP=1 test -v DSH_TEST_TEMP")
+	if GetLastExitStatus() != 0 {
+		t.Error("Expected a prefix assignment to be visible to the builtin")
+	}
+	if variables.IsSet("DSH_TEST_TEMP") {
+		t.Error("Expected a prefix assignment not to outlive the command")
+	}
+
+	runList(t, "echo ${DSH_TEST_A/x/y}")
+	if GetLastExitStatus() != 1 {
+		t.Errorf("Expected status 1 after a bad substitution, got %d", GetLastExitStatus())
+	}
+}
+
+func TestExecutor_ForLoop(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("DSH_TEST_LIST")
+		variables.Unset("DSH_TEST_SEEN")
+		variables.Unset("item")
+	})
+
+	runList(t, `DSH_TEST_LIST=(a "b c" d); for item in "${DSH_TEST_LIST[@]}" e; do DSH_TEST_SEEN+="<$item>"; done`)
+
+	if value, _ := variables.Get("DSH_TEST_SEEN"); value != "<a><b c><d><e>" {
+		t.Errorf("Unexpected iterations %q", value)
+	}
+
+	runList(t, "false; for item in; do false; done")
+	if GetLastExitStatus() != 0 {
+		t.Errorf("Expected an empty loop to succeed, got %d", GetLastExitStatus())
+	}
+}
+
+func TestExecutor_ChangesProcess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"cd /; umask 077; x=1", false},
+		{"echo a | ulimit -n 64", true},
+		{"if true; then exec 3>file; fi", true},
+		{`"${u}mit" -n 64`, true},
+		{"for x in a; do { command ulimit -n 64; }; done", true},
+		{"(exec echo hi)", false},
+	}
+
+	for _, test := range tests {
+		pipelines, err := parser.New(lexer.New(test.input)).ParseCommandLine()
+		if err != nil {
+			t.Fatalf("%q: parse error: %v", test.input, err)
+		}
+		if changes := changesProcess(pipelines); changes != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expected, changes)
+		}
+	}
+}
+
+func TestExecutor_CompoundCommands(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("DSH_TEST_BRANCH")
+		variables.Unset("DSH_TEST_SUB")
+	})
+
+	runList(t, "if false; then DSH_TEST_BRANCH=a\nelif true; then DSH_TEST_BRANCH=b\nelse DSH_TEST_BRANCH=c\nfi")
+	if value, _ := variables.Get("DSH_TEST_BRANCH"); value != "b" {
+		t.Errorf("Expected the elif branch to run, got %q", value)
+	}
+
+	runList(t, "if false; then true; fi")
+	if GetLastExitStatus() != 0 {
+		t.Errorf("Expected status 0 when no branch runs, got %d", GetLastExitStatus())
+	}
+
+	runList(t, "{ DSH_TEST_BRANCH=group; false; }")
+	if value, _ := variables.Get("DSH_TEST_BRANCH"); value != "group" || GetLastExitStatus() != 1 {
+		t.Errorf("Expected the group to run in the shell, got %q and status %d", value, GetLastExitStatus())
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mask := syscall.Umask(0o022)
+	t.Cleanup(func() { syscall.Umask(mask) })
+
+	runList(t, "( DSH_TEST_SUB=inner; set -f; umask 077; cd /; exit 3 )")
+	if variables.IsSet("DSH_TEST_SUB") {
+		t.Error("Expected subshell assignments to be undone")
+	}
+	if options.Enabled(options.Noglob) {
+		t.Error("Expected subshell options to be undone")
+	}
+	if current := syscall.Umask(0o022); current != 0o022 {
+		t.Errorf("Expected the umask to be restored, got %03o", current)
+	}
+	if cwd, _ := os.Getwd(); cwd != dir {
+		t.Errorf("Expected the working directory to be restored, got %q", cwd)
+	}
+	if GetLastExitStatus() != 3 {
+		t.Errorf("Expected the subshell's exit status 3, got %d", GetLastExitStatus())
+	}
+}
+
+func TestExecutor_HereDoc(t *testing.T) {
+	variables.Set("DSH_TEST_WHO", "world")
+	t.Cleanup(func() { variables.Unset("DSH_TEST_WHO") })
+
+	out := filepath.Join(t.TempDir(), "out")
+
+	runList(t, "cat <<EOF > "+out+"\nhello $DSH_TEST_WHO \\$HOME\nEOF\ncat <<'EOF' >> "+out+"\nraw $DSH_TEST_WHO\nEOF")
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world $HOME\nraw $DSH_TEST_WHO\n" {
+		t.Errorf("Unexpected here-document output %q", data)
+	}
+}
+
+func TestExecutor_Errexit(t *testing.T) {
+	_ = options.Set(options.Errexit, true)
+	t.Cleanup(func() {
+		_ = options.Set(options.Errexit, false)
+		variables.Unset("DSH_TEST_RAN")
+	})
+
+	tests := []struct {
+		input string
+		exits bool
+	}{
+		{"false", true},
+		{"true; false; true", true},
+		{"false || true", false},
+		{"false && true", false},
+		{"true && false", true},
+		{"if false; then true; fi", false},
+		{"if true; then false; fi", true},
+		{"{ false; true; }", true},
+		{"{ false || true; }", false},
+		{"( false; true )", true},
+		{"! true", false},
+		{"! { false; true; }", false},
+		{"! false; false", true},
+	}
+
+	for _, test := range tests {
+		pipelines, err := parser.New(lexer.New(test.input)).ParseCommandLine()
+		if err != nil {
+			t.Errorf("%q: parse error: %v", test.input, err)
+
+			continue
+		}
+
+		if exits := !ExecuteList(pipelines); exits != test.exits {
+			t.Errorf("%q: expected exit %v, got %v", test.input, test.exits, exits)
+		}
+	}
+
+	_ = options.Set(options.Errexit, false)
+	runList(t, "false; DSH_TEST_RAN=1")
+	if !variables.IsSet("DSH_TEST_RAN") {
+		t.Error("Expected the list to continue without errexit")
+	}
+}
+
+func TestExecutor_Negation(t *testing.T) {
+	tests := []struct {
+		input  string
+		status int
+	}{
+		{"! true", 1},
+		{"! false", 0},
+		{"! false | true", 1},
+		{"! true | false", 0},
+		{"! ! false", 1},
+		{"false; !", 1},
+		{"! false && ! true", 1},
+	}
+
+	for _, test := range tests {
+		runList(t, test.input)
+		if status := GetLastExitStatus(); status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.input, test.status, status)
+		}
+	}
+}
+
+func TestExecutor_Nounset(t *testing.T) {
+	_ = options.Set(options.Nounset, true)
+	t.Cleanup(func() {
+		_ = options.Set(options.Nounset, false)
+		SetInteractive(false)
+		variables.Unset("DSH_TEST_RAN")
+	})
+
+	tests := []struct {
+		input string
+		exits bool
+	}{
+		{"echo $DSH_TEST_UNSET; DSH_TEST_RAN=1", true},
+		{"for i in 1; do x=$DSH_TEST_UNSET; done; DSH_TEST_RAN=1", true},
+		{"if true; then echo $DSH_TEST_UNSET; fi", true},
+		{"( echo $DSH_TEST_UNSET ); DSH_TEST_RAN=1", false},
+	}
+
+	for _, test := range tests {
+		pipelines, err := parser.New(lexer.New(test.input)).ParseCommandLine()
+		if err != nil {
+			t.Errorf("%q: parse error: %v", test.input, err)
+
+			continue
+		}
+
+		if exits := !ExecuteList(pipelines); exits != test.exits {
+			t.Errorf("%q: expected exit %v, got %v", test.input, test.exits, exits)
+		}
+		if variables.IsSet("DSH_TEST_RAN") == test.exits {
+			t.Errorf("%q: expected the rest of the list to run only if the shell goes on", test.input)
+		}
+		variables.Unset("DSH_TEST_RAN")
+	}
+
+	// An interactive shell goes on
+	SetInteractive(true)
+	runList(t, "echo $DSH_TEST_UNSET")
+	if GetLastExitStatus() != 1 {
+		t.Errorf("Expected status 1, got %d", GetLastExitStatus())
+	}
+}
+
+func TestExecutor_Noclobber(t *testing.T) {
+	_ = options.Set(options.Noclobber, true)
+	t.Cleanup(func() { _ = options.Set(options.Noclobber, false) })
+
+	out := filepath.Join(t.TempDir(), "out")
+
+	runList(t, "echo first > "+out)
+	if GetLastExitStatus() != 0 {
+		t.Fatalf("Expected creating a new file to succeed, got %d", GetLastExitStatus())
+	}
+
+	runList(t, "echo second > "+out)
+	if GetLastExitStatus() != 1 {
+		t.Errorf("Expected overwriting to fail, got %d", GetLastExitStatus())
+	}
+
+	runList(t, "echo third >> "+out+"; echo /dev/null > /dev/null")
+	if GetLastExitStatus() != 0 {
+		t.Errorf("Expected appending and writing to a device to succeed, got %d", GetLastExitStatus())
+	}
+
+	data, _ := os.ReadFile(out)
+	if string(data) != "first\nthird\n" {
+		t.Errorf("Unexpected contents %q", data)
+	}
+
+	runList(t, "echo forced >| "+out)
+	if data, _ := os.ReadFile(out); string(data) != "forced\n" {
+		t.Errorf("Expected >| to overwrite, got %q", data)
+	}
+}
+
+func TestExecutor_OutputFileMode(t *testing.T) {
+	saved := syscall.Umask(0o027)
+	t.Cleanup(func() { syscall.Umask(saved) })
+
+	dir := t.TempDir()
+	truncated := filepath.Join(dir, "truncated")
+	appended := filepath.Join(dir, "appended")
+
+	runList(t, "echo one > "+truncated+"; echo two >> "+appended)
+
+	for _, name := range []string{truncated, appended} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0o640 {
+			t.Errorf("%s: expected mode 0640, got %04o", filepath.Base(name), mode)
+		}
+	}
+}
+
+func TestExecutor_Pipelines(t *testing.T) {
+	t.Cleanup(func() { _ = options.Set(options.Pipefail, false) })
+
+	out := filepath.Join(t.TempDir(), "out")
+
+	runList(t, "echo hello | tr a-z A-Z | cat > "+out)
+	if data, _ := os.ReadFile(out); string(data) != "HELLO\n" {
+		t.Errorf("Unexpected pipeline output %q", data)
+	}
+
+	t.Cleanup(func() { variables.Unset("DSH_TEST_WORD") })
+
+	runList(t, "DSH_TEST_WORD=unexported; echo $DSH_TEST_WORD | tr a-z A-Z > "+out)
+	if data, _ := os.ReadFile(out); string(data) != "UNEXPORTED\n" {
+		t.Errorf("Expected echo to run in the shell with its variables, got %q", data)
+	}
+
+	runList(t, "printf '%s\\n' b a | sort > "+out)
+	if data, _ := os.ReadFile(out); string(data) != "a\nb\n" {
+		t.Errorf("Unexpected printf pipeline output %q", data)
+	}
+
+	tests := []struct {
+		input    string
+		status   int
+		pipefail bool
+	}{
+		{"false | true", 0, false},
+		{"true | false", 1, false},
+		{"false | true", 1, true},
+		{"sh -c 'exit 3' | sh -c 'exit 4' | true", 4, true},
+		{"true | true", 0, true},
+		{"printf %d x | true", 1, true},
+	}
+
+	for _, test := range tests {
+		_ = options.Set(options.Pipefail, test.pipefail)
+
+		runList(t, test.input)
+		if status := GetLastExitStatus(); status != test.status {
+			t.Errorf("%q (pipefail %v): expected status %d, got %d", test.input, test.pipefail, test.status, status)
+		}
+	}
+}
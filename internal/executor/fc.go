@@ -0,0 +1,334 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"dsh/internal/builtins"
+	"dsh/internal/lexer"
+	"dsh/internal/parser"
+	"dsh/internal/readline"
+	"dsh/internal/variables"
+)
+
+var (
+	// ErrNoHistory indicates fc in a shell that keeps no history.
+	ErrNoHistory = errors.New("no command history")
+	// ErrHistoryNotFound indicates an fc argument that names no command.
+	ErrHistoryNotFound = errors.New("no command found")
+)
+
+// fcListLength is the number of commands fc -l lists by default.
+const fcListLength = 16
+
+// history is the history of the interactive shell, which fc lists and
+// runs commands from. Other shells keep none.
+var history *readline.History //nolint:gochecknoglobals // Hook installed once at startup
+
+// SetHistory installs the history that fc works on.
+func SetHistory(h *readline.History) {
+	history = h
+}
+
+// fcOptions are the flags of fc.
+type fcOptions struct {
+	list, numbers, reverse, substitute bool
+	editor                             string
+}
+
+// executeFc implements fc. With -l it lists commands from history, with
+// -n leaving out their numbers and with -r the newest first. With -s it
+// runs a command again, after replacing old with new for each old=new
+// given. Otherwise it edits commands in an editor, -e or FCEDIT, and runs
+// the result. Commands are named by number, by a negative offset from the
+// last, or by the start of their text; the command line running fc is not
+// one of them.
+func executeFc(cmd *parser.Command, args []string) bool {
+	restore := saveVariables(cmd.Assignments)
+	defer restore()
+
+	if assignAll(cmd.Assignments) != nil {
+		variables.SetLastStatus(builtins.StatusFailure)
+
+		return true
+	}
+
+	restoreFiles, err := redirectBuiltin(cmd)
+	defer restoreFiles()
+	if err != nil {
+		reportRedirectError(err)
+
+		return true
+	}
+
+	opts, i, ok := parseFcOptions(args)
+	if !ok {
+		variables.SetLastStatus(builtins.StatusUsage)
+
+		return true
+	}
+
+	if history == nil {
+		return fcFailed(ErrNoHistory)
+	}
+
+	// The last entry is the command line running fc
+	entries := history.Entries()
+	if len(entries) > 0 {
+		entries = entries[:len(entries)-1]
+	}
+
+	switch {
+	case opts.list:
+		return fcList(entries, args[i:], opts)
+	case opts.substitute:
+		return fcSubstitute(entries, args[i:])
+	default:
+		return fcEdit(entries, args[i:], opts)
+	}
+}
+
+// parseFcOptions reads the flags of fc and returns the index of the first
+// operand. Negative numbers are operands.
+func parseFcOptions(args []string) (fcOptions, int, bool) {
+	var opts fcOptions
+
+	i := 1
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-' && !isDigit(args[i][1]); i++ {
+		if args[i] == "--" {
+			i++
+
+			break
+		}
+
+		flags := args[i][1:]
+		for j := 0; j < len(flags); j++ {
+			switch flags[j] {
+			case 'l':
+				opts.list = true
+			case 'n':
+				opts.numbers = true
+			case 'r':
+				opts.reverse = true
+			case 's':
+				opts.substitute = true
+			case 'e':
+				// The editor is the rest of this word or the next argument
+				opts.editor = flags[j+1:]
+				if opts.editor == "" {
+					if i+1 >= len(args) {
+						_, _ = fmt.Fprintf(os.Stderr, "dsh: fc: -e: option requires an argument\n")
+
+						return opts, i, false
+					}
+					i++
+					opts.editor = args[i]
+				}
+				j = len(flags)
+			default:
+				_, _ = fmt.Fprintf(os.Stderr, "dsh: fc: -%c: %v\n", flags[j], builtins.ErrInvalidOption)
+
+				return opts, i, false
+			}
+		}
+	}
+
+	// -e - runs commands again without editing them
+	if opts.editor == "-" {
+		opts.substitute = true
+	}
+
+	return opts, i, true
+}
+
+// fcList lists the commands from first to last, by default the last 16.
+func fcList(entries, operands []string, opts fcOptions) bool {
+	if len(entries) == 0 {
+		variables.SetLastStatus(builtins.StatusSuccess)
+
+		return true
+	}
+
+	first, last, err := fcRange(entries, operands, -fcListLength, -1)
+	if err != nil {
+		return fcFailed(err)
+	}
+
+	reverse := opts.reverse != (first > last)
+	if first > last {
+		first, last = last, first
+	}
+
+	for n := range last - first + 1 {
+		index := first + n
+		if reverse {
+			index = last - n
+		}
+
+		text := strings.ReplaceAll(entries[index], "\n", "\n\t")
+		if opts.numbers {
+			_, _ = fmt.Fprintf(os.Stdout, "\t%s\n", text)
+		} else {
+			_, _ = fmt.Fprintf(os.Stdout, "%d\t%s\n", index+1, text)
+		}
+	}
+
+	variables.SetLastStatus(builtins.StatusSuccess)
+
+	return true
+}
+
+// fcSubstitute runs a command again, by default the last, with each
+// old=new operand before it applied.
+func fcSubstitute(entries, operands []string) bool {
+	var replacements []string
+	for len(operands) > 0 && strings.Contains(operands[0], "=") {
+		old, replacement, _ := strings.Cut(operands[0], "=")
+		replacements = append(replacements, old, replacement)
+		operands = operands[1:]
+	}
+
+	spec := "-1"
+	if len(operands) > 0 {
+		spec = operands[0]
+	}
+
+	index, err := fcFind(entries, spec)
+	if err != nil {
+		return fcFailed(err)
+	}
+
+	text := entries[index]
+	for i := 0; i < len(replacements); i += 2 {
+		if replacements[i] != "" {
+			text = strings.ReplaceAll(text, replacements[i], replacements[i+1])
+		}
+	}
+
+	return fcRun(text)
+}
+
+// fcEdit edits the commands from first to last, by default the last one,
+// and runs what the editor leaves.
+func fcEdit(entries, operands []string, opts fcOptions) bool {
+	first, last, err := fcRange(entries, operands, -1, 0)
+	if err != nil {
+		return fcFailed(err)
+	}
+
+	lines := entries[min(first, last) : max(first, last)+1]
+	if first > last {
+		lines = append([]string(nil), lines...)
+		for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+			lines[i], lines[j] = lines[j], lines[i]
+		}
+	}
+
+	editor := opts.editor
+	if editor == "" {
+		editor, _ = variables.Get("FCEDIT")
+	}
+	if strings.TrimSpace(editor) == "" {
+		editor = readline.Editor()
+	}
+
+	text, err := readline.EditText(editor, strings.Join(lines, "\n"))
+	if err != nil {
+		return fcFailed(err)
+	}
+
+	return fcRun(text)
+}
+
+// fcRange returns the indices of the first and last operands, with
+// defaultFirst for a missing first one. A missing last one is the last
+// command, or with defaultLast 0 the same as the first.
+func fcRange(entries, operands []string, defaultFirst, defaultLast int) (int, int, error) {
+	if len(operands) > 2 {
+		return 0, 0, builtins.ErrTooManyArguments
+	}
+
+	specs := []string{strconv.Itoa(defaultFirst), strconv.Itoa(defaultLast)}
+	copy(specs, operands)
+	if defaultLast == 0 && len(operands) < 2 {
+		specs[1] = specs[0]
+	}
+
+	first, err := fcFind(entries, specs[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	last, err := fcFind(entries, specs[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return first, last, nil
+}
+
+// fcFind returns the index of the command spec names: by number, by a
+// negative offset from the end, or by the start of its text. Numbers out
+// of range stand for the nearest command.
+func fcFind(entries []string, spec string) (int, error) {
+	if len(entries) == 0 {
+		return 0, fmt.Errorf("%s: %w", spec, ErrHistoryNotFound)
+	}
+
+	if number, err := strconv.Atoi(spec); err == nil {
+		index := number - 1
+		if number < 0 {
+			index = len(entries) + number
+		}
+
+		return min(max(index, 0), len(entries)-1), nil
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if strings.HasPrefix(entries[i], spec) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s: %w", spec, ErrHistoryNotFound)
+}
+
+// fcRun shows text, adds it to history and runs it as a command line.
+func fcRun(text string) bool {
+	if strings.TrimSpace(text) == "" {
+		variables.SetLastStatus(builtins.StatusSuccess)
+
+		return true
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, text)
+	history.Add(text)
+
+	pipelines, err := parser.New(lexer.New(text)).ParseCommandLine()
+	if err != nil && !errors.Is(err, parser.ErrEmptyPipeline) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: fc: %v\n", err)
+		variables.SetLastStatus(builtins.StatusUsage)
+
+		return true
+	}
+
+	variables.SetLastStatus(builtins.StatusSuccess)
+
+	return ExecuteList(pipelines)
+}
+
+// fcFailed reports err and fails.
+func fcFailed(err error) bool {
+	_, _ = fmt.Fprintf(os.Stderr, "dsh: fc: %v\n", err)
+	variables.SetLastStatus(builtins.StatusFailure)
+
+	return true
+}
+
+// isDigit reports whether b is a decimal digit.
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dsh/internal/readline"
+	"dsh/internal/variables"
+)
+
+// runFc runs input as the command line just entered, with the commands in
+// entries before it in history, and returns what it wrote to stdout.
+func runFc(t *testing.T, entries []string, input string) string {
+	t.Helper()
+
+	h := readline.NewEmptyHistory()
+	for _, entry := range append(entries, input) {
+		h.Add(entry)
+	}
+	SetHistory(h)
+
+	out := filepath.Join(t.TempDir(), "out")
+	runList(t, input+" > "+out)
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestExecutor_Fc(t *testing.T) {
+	t.Cleanup(func() {
+		SetHistory(nil)
+		variables.Unset("DSH_TEST_FC")
+		variables.Unset("FCEDIT")
+	})
+
+	entries := []string{"echo one", "echo two", "true", "echo three"}
+
+	tests := []struct {
+		input  string
+		want   string
+		status int
+	}{
+		{"fc -l", "1\techo one\n2\techo two\n3\ttrue\n4\techo three\n", 0},
+		{"fc -l -2", "3\ttrue\n4\techo three\n", 0},
+		{"fc -l 2 3", "2\techo two\n3\ttrue\n", 0},
+		{"fc -lr 2 3", "3\ttrue\n2\techo two\n", 0},
+		{"fc -l 3 2", "3\ttrue\n2\techo two\n", 0},
+		{"fc -ln echo", "\techo three\n", 0},
+		{"fc -l nosuch", "", 1},
+		{"fc -l 1 2 3", "", 1},
+		{"fc -s", "echo three\nthree\n", 0},
+		{"fc -s two=2 echo\\ tw", "echo 2\n2\n", 0},
+		{"fc -s one=1 1=2 1", "echo 2\n2\n", 0},
+		{"fc -e - 1", "echo one\none\n", 0},
+		{"fc -q", "", 2},
+		{"fc -e", "", 2},
+	}
+
+	for _, test := range tests {
+		output := runFc(t, entries, test.input)
+		if output != test.want {
+			t.Errorf("%q: expected %q, got %q", test.input, test.want, output)
+		}
+		if status := GetLastExitStatus(); status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.input, test.status, status)
+		}
+	}
+
+	// The command run again is added to history
+	h := readline.NewEmptyHistory()
+	for _, entry := range []string{"echo one", "fc -s one=1"} {
+		h.Add(entry)
+	}
+	SetHistory(h)
+	runList(t, "fc -s one=1 > /dev/null")
+	if got := h.Entries(); got[len(got)-1] != "echo 1" {
+		t.Errorf("Expected the command run added to history, got %q", got)
+	}
+
+	// Editing runs what the editor leaves
+	output := runFc(t, entries, "fc -e 'sed -i s/echo./DSH_TEST_FC=/' 2")
+	if value, _ := variables.Get("DSH_TEST_FC"); value != "two" || output != "DSH_TEST_FC=two\n" {
+		t.Errorf("Expected the edited command run, got %q and output %q", value, output)
+	}
+
+	variables.Set("FCEDIT", "sed -i s/one/1/")
+	if output := runFc(t, entries, "fc 1 2"); output != "echo 1\necho two\n1\ntwo\n" {
+		t.Errorf("Expected FCEDIT to edit both commands, got %q", output)
+	}
+
+	SetHistory(nil)
+	runList(t, "fc -l")
+	if status := GetLastExitStatus(); status != 1 {
+		t.Errorf("Expected fc without history to fail, got %d", status)
+	}
+}
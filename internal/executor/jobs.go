@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"dsh/internal/jobs"
+	"dsh/internal/parser"
+)
+
+// setProcessGroup makes a command of a background job join the job's
+// process group when it starts, or lead a new one if pgid is 0. Commands
+// that run in the shell itself have no group to join.
+func setProcessGroup(cmd process, pgid int) {
+	if execCmd, ok := cmd.(*exec.Cmd); ok {
+		execCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: pgid}
+	}
+}
+
+// startJob records commands started in the background as a job, reports
+// its number and the ID of its last process, and reaps the commands
+// without blocking the shell, taking the job off the table once they
+// have all finished.
+func startJob(command string, started []process) {
+	var pids []int
+	for _, cmd := range started {
+		if execCmd, ok := cmd.(*exec.Cmd); ok {
+			pids = append(pids, execCmd.Process.Pid)
+		}
+	}
+
+	var job *jobs.Job
+	if len(pids) > 0 {
+		job = jobs.Add(command, pids)
+		_, _ = fmt.Fprintf(os.Stdout, "[%d] %d\n", job.ID, pids[len(pids)-1])
+	}
+
+	go func() {
+		for _, cmd := range started {
+			_ = cmd.Wait()
+		}
+		if job != nil {
+			jobs.Finish(job)
+		}
+	}()
+}
+
+// jobCommand returns the text of a background pipeline as a job, without
+// the & that ends it.
+func jobCommand(cmds []*parser.Command) string {
+	sources := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		sources = append(sources, cmd.Source)
+	}
+	command := strings.Join(sources, " | ")
+
+	return strings.TrimSpace(strings.TrimSuffix(command, "&"))
+}
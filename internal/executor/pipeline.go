@@ -0,0 +1,277 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"dsh/internal/builtins"
+	"dsh/internal/expand"
+	"dsh/internal/options"
+	"dsh/internal/parser"
+	"dsh/internal/variables"
+)
+
+// errNoSource indicates a builtin or compound command in a pipeline that
+// was built without source text, so it cannot run in a child shell.
+var errNoSource = errors.New("pipeline command has no source text")
+
+// pipelineStage is one command of a pipeline, running in its own process
+// or, for an output builtin, in the shell.
+type pipelineStage struct {
+	cmd     process
+	cleanup func()
+}
+
+// executeMultiCommandPipeline runs the commands of a pipeline at the same
+// time, each reading the output of the one before. External commands run
+// directly and output builtins such as echo in the shell; other builtins
+// and compound commands run in a child dsh, as in a subshell. The status
+// is that of the last command or, under pipefail, that of the last command
+// to fail.
+func executeMultiCommandPipeline(pipeline *parser.Pipeline) bool {
+	background := pipeline.Commands[len(pipeline.Commands)-1].Background
+
+	mark := substitutionMark()
+	defer func() { reapSubstitutions(mark, !background) }()
+
+	pipes, err := createPipes(len(pipeline.Commands) - 1)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+		variables.SetLastStatus(builtins.StatusFailure)
+
+		return true
+	}
+
+	// Each command reads the pipe before it and writes the one after it,
+	// before its own redirections apply
+	stages := make([]*pipelineStage, len(pipeline.Commands))
+	for i, cmd := range pipeline.Commands {
+		stdin, stdout := os.Stdin, os.Stdout
+		if i > 0 {
+			stdin = pipes[2*i-2]
+		}
+		if i < len(stages)-1 {
+			stdout = pipes[2*i+1]
+		}
+
+		stages[i], _ = pipelineCommand(cmd, stdin, stdout)
+	}
+	defer func() {
+		for _, stage := range stages {
+			if stage != nil {
+				stage.cleanup()
+			}
+		}
+	}()
+
+	// The commands of a background pipeline share the process group of
+	// the first one to start
+	pgid := 0
+
+	statuses := make([]int, len(stages))
+	for i, stage := range stages {
+		if stage == nil {
+			statuses[i] = builtins.StatusFailure
+
+			continue
+		}
+
+		if background {
+			setProcessGroup(stage.cmd, pgid)
+		}
+
+		if err := stage.cmd.Start(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+			statuses[i] = exitStatus(err)
+			stage.cleanup()
+			stages[i] = nil
+
+			continue
+		}
+
+		if execCmd, ok := stage.cmd.(*exec.Cmd); ok && background && pgid == 0 {
+			pgid = execCmd.Process.Pid
+		}
+	}
+
+	// The commands hold their own copies of the pipe ends now
+	for _, pipe := range pipes {
+		_ = pipe.Close()
+	}
+
+	if background {
+		startBackgroundPipeline(stages, jobCommand(pipeline.Commands))
+
+		return true
+	}
+
+	for i, stage := range stages {
+		if stage != nil {
+			statuses[i] = exitStatus(waitProcess(stage.cmd))
+		}
+	}
+	variables.SetLastStatus(pipelineStatus(statuses))
+
+	return true
+}
+
+// pipelineCommand prepares one command of a pipeline with the given input
+// and output. Errors have already been reported.
+func pipelineCommand(cmd *parser.Command, stdin, stdout *os.File) (*pipelineStage, error) {
+	if cmd.Conditional == nil && cmd.Compound == nil && runsExternally(cmd) {
+		args, err := commandArgs(cmd)
+		if err != nil {
+			reportExpansionError(err)
+
+			return nil, err
+		}
+
+		if len(args) > 0 {
+			traceCommand(cmd.Assignments, args)
+
+			execCmd, cleanup, err := externalCommand(cmd, args, stdin, stdout)
+			if err != nil {
+				return nil, err
+			}
+
+			return &pipelineStage{cmd: execCmd, cleanup: cleanup}, nil
+		}
+	}
+
+	if runsInShell(cmd) {
+		args, err := commandArgs(cmd)
+		if err != nil {
+			reportExpansionError(err)
+
+			return nil, err
+		}
+		traceCommand(cmd.Assignments, args)
+
+		builtin, err := newBuiltinProcess(args, stdout)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+
+			return nil, err
+		}
+
+		return &pipelineStage{cmd: builtin, cleanup: func() {}}, nil
+	}
+
+	if cmd.Source == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", errNoSource)
+
+		return nil, errNoSource
+	}
+
+	child, err := shellCommand(cmd.Source)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+
+		return nil, err
+	}
+
+	child.Stdin = stdin
+	child.Stdout = stdout
+	child.Stderr = os.Stderr
+	child.ExtraFiles = childFiles()
+
+	return &pipelineStage{cmd: child, cleanup: func() {}}, nil
+}
+
+// runsExternally reports whether a simple command names an external
+// command, judging by its first word alone so that the words of commands
+// run in a child shell are not expanded twice.
+func runsExternally(cmd *parser.Command) bool {
+	if len(cmd.Words) == 0 {
+		return len(cmd.Args) > 0 && !builtins.IsBuiltin(cmd.Args[0])
+	}
+
+	name, err := expand.Fields(cmd.Words[0])
+
+	return err != nil || (len(name) > 0 && !builtins.IsBuiltin(name[0]))
+}
+
+// runsInShell reports whether a simple command is an output builtin, or a
+// builtin listing the shell's own state, that the shell can run itself,
+// which it does only without assignments or redirections, since those
+// would change the shell's own state. A listing is recognised only in
+// words that need no expanding, since otherwise they would be expanded
+// twice when it turns out not to be one.
+func runsInShell(cmd *parser.Command) bool {
+	if cmd.Conditional != nil || cmd.Compound != nil || len(cmd.Assignments) > 0 || len(cmd.Redirections) > 0 {
+		return false
+	}
+
+	if len(cmd.Words) == 0 {
+		return len(cmd.Args) > 0 && (builtins.IsOutputBuiltin(cmd.Args[0]) || builtins.IsListing(cmd.Args))
+	}
+
+	name, err := expand.Fields(cmd.Words[0])
+	if err != nil || len(name) == 0 {
+		return false
+	}
+
+	if builtins.IsOutputBuiltin(name[0]) {
+		return true
+	}
+
+	for _, word := range cmd.Words {
+		if !isLiteral(word) {
+			return false
+		}
+	}
+
+	return builtins.IsListing(cmd.Args)
+}
+
+// createPipes returns count pipes as reader and writer pairs, for the
+// shell to close once the commands using them have started.
+func createPipes(count int) ([]*os.File, error) {
+	var pipes []*os.File
+
+	for range count {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			for _, pipe := range pipes {
+				_ = pipe.Close()
+			}
+
+			return nil, fmt.Errorf("pipe: %w", err)
+		}
+		pipes = append(pipes, reader, writer)
+	}
+
+	return pipes, nil
+}
+
+// startBackgroundPipeline starts the job of a background pipeline whose
+// commands are running.
+func startBackgroundPipeline(stages []*pipelineStage, command string) {
+	var started []process
+	for _, stage := range stages {
+		if stage != nil {
+			started = append(started, stage.cmd)
+		}
+	}
+
+	startJob(command, started)
+}
+
+// pipelineStatus returns the status of a pipeline from those of its
+// commands: the last one's, or under pipefail the last non-zero one.
+func pipelineStatus(statuses []int) int {
+	status := statuses[len(statuses)-1]
+	if !options.Enabled(options.Pipefail) {
+		return status
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if statuses[i] != 0 {
+			return statuses[i]
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,310 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"dsh/internal/builtins"
+	"dsh/internal/commands"
+	"dsh/internal/lexer"
+	"dsh/internal/options"
+	"dsh/internal/parser"
+	"dsh/internal/variables"
+)
+
+// firstExtraFd is the descriptor number of exec.Cmd.ExtraFiles[0].
+const firstExtraFd = 3
+
+// process is a command started for a pipeline or a process substitution:
+// an exec.Cmd or a builtinProcess.
+type process interface {
+	Start() error
+	Wait() error
+}
+
+// processSubstitution is the inner command of a running <(...) or >(...).
+type processSubstitution struct {
+	cmd process
+	// file is the shell's end of the pipe, which /dev/fd/N names.
+	file *os.File
+}
+
+// builtinStatus is the error Wait returns for a builtinProcess that
+// failed, holding its exit status.
+type builtinStatus int
+
+func (status builtinStatus) Error() string {
+	return "exit status " + strconv.Itoa(int(status))
+}
+
+// builtinProcess runs an output builtin such as echo in the shell itself,
+// writing to a pipe, where other commands would run in a process. Its
+// words are expanded once, with all of the shell's variables.
+type builtinProcess struct {
+	args   []string
+	stdout *os.File
+	done   chan int
+}
+
+// newBuiltinProcess prepares args to write to its own copy of stdout,
+// which it closes when done so that the reader sees the end of the output.
+func newBuiltinProcess(args []string, stdout *os.File) (*builtinProcess, error) {
+	fd, err := syscall.Dup(int(stdout.Fd())) //nolint:gosec // Descriptor numbers are small
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", args[0], err)
+	}
+	syscall.CloseOnExec(fd)
+
+	return &builtinProcess{
+		args:   args,
+		stdout: os.NewFile(uintptr(fd), stdout.Name()), //nolint:gosec // dup returns a valid descriptor
+		done:   make(chan int, 1),
+	}, nil
+}
+
+// Start runs the builtin without waiting for it.
+func (b *builtinProcess) Start() error {
+	go func() {
+		status := builtins.RunWithOutput(b.args, b.stdout)
+		_ = b.stdout.Close()
+		b.done <- status
+	}()
+
+	return nil
+}
+
+// Wait waits for the builtin and reports its status as exec.Cmd does.
+func (b *builtinProcess) Wait() error {
+	if status := <-b.done; status != 0 {
+		return builtinStatus(status)
+	}
+
+	return nil
+}
+
+var (
+	// substitutions are started while a command's words are expanded and
+	// reaped when that command finishes.
+	substitutions   []*processSubstitution //nolint:gochecknoglobals // Shell-wide list of running substitutions
+	substitutionsMu sync.Mutex             //nolint:gochecknoglobals // Guards substitutions
+)
+
+// StartProcessSubstitution runs command with its stdout, or with output
+// set its stdin, connected to a pipe, and returns the /dev/fd path of the
+// shell's end of that pipe. It is installed as the expand package's
+// process substituter.
+func StartProcessSubstitution(command string, output bool) (string, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", fmt.Errorf("process substitution: %w", err)
+	}
+
+	shellEnd, childEnd := reader, writer
+	stdin, stdout := os.Stdin, writer
+	if output {
+		shellEnd, childEnd = writer, reader
+		stdin, stdout = reader, os.Stdout
+	}
+
+	child, err := substitutionCommand(command, stdin, stdout)
+	if err == nil {
+		err = child.Start()
+	}
+	_ = childEnd.Close()
+	if err != nil {
+		_ = shellEnd.Close()
+
+		return "", fmt.Errorf("process substitution: %w", err)
+	}
+
+	substitutionsMu.Lock()
+	substitutions = append(substitutions, &processSubstitution{cmd: child, file: shellEnd})
+	substitutionsMu.Unlock()
+
+	return fmt.Sprintf("/dev/fd/%d", shellEnd.Fd()), nil
+}
+
+// CommandOutput runs command, as a process substitution would, and
+// returns what it writes to stdout without the trailing newlines. It is
+// installed as the prompt package's command runner for $(...) in PS1.
+func CommandOutput(command string) (string, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", fmt.Errorf("command substitution: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	child, err := substitutionCommand(command, os.Stdin, writer)
+	if err == nil {
+		err = child.Start()
+	}
+	_ = writer.Close()
+	if err != nil {
+		return "", fmt.Errorf("command substitution: %w", err)
+	}
+
+	output, err := io.ReadAll(reader)
+	_ = child.Wait()
+	if err != nil {
+		return "", fmt.Errorf("command substitution: %w", err)
+	}
+
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// substitutionCommand prepares the inner command with the given input and
+// output. A single simple external command runs directly, and an output
+// or listing builtin in the shell; anything else runs in a child dsh, which is given
+// the shell's variables.
+func substitutionCommand(command string, stdin, stdout *os.File) (process, error) {
+	if cmd := simpleCommand(command); cmd != nil {
+		args, err := commandArgs(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(args) == 0:
+		case (builtins.IsOutputBuiltin(args[0]) || builtins.IsListing(args)) && len(cmd.Assignments) == 0:
+			return newBuiltinProcess(args, stdout)
+		case !builtins.IsBuiltin(args[0]):
+			env, err := commandEnv(cmd.Assignments)
+			if err != nil {
+				return nil, err
+			}
+
+			child := commandProcess(args)
+			child.Env = env
+			child.Stdin, child.Stdout, child.Stderr = stdin, stdout, os.Stderr
+
+			return child, nil
+		}
+	}
+
+	child, err := shellCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	child.Stdin, child.Stdout, child.Stderr = stdin, stdout, os.Stderr
+
+	return child, nil
+}
+
+// commandProcess prepares an external command, finding its file through
+// the shell's command table. A name that is not found is left for exec to
+// report when the command starts.
+func commandProcess(args []string) *exec.Cmd {
+	path, ok := commands.Lookup(args[0])
+	if !ok {
+		path = args[0]
+	}
+
+	execCmd := exec.CommandContext(context.Background(), path, args[1:]...) //nolint:gosec // Running user commands is the shell's job
+	execCmd.Args[0] = args[0]
+
+	return execCmd
+}
+
+// shellCommand prepares a child dsh that runs text with the shell's
+// current options and variables, unexported ones and arrays included.
+func shellCommand(text string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := variables.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	args := append(options.Arguments(), "-c", text)
+	child := exec.CommandContext(context.Background(), self, args...) //nolint:gosec // Re-running the shell itself
+	child.Env = append(os.Environ(), variables.StateEnv+"="+state)
+
+	return child, nil
+}
+
+// simpleCommand returns the command when text is one command without
+// redirections, pipes or lists, and nil otherwise.
+func simpleCommand(text string) *parser.Command {
+	pipelines, err := parser.New(lexer.New(text)).ParseCommandLine()
+	if err != nil || len(pipelines) != 1 || len(pipelines[0].Commands) != 1 || pipelines[0].Time != parser.TimeNone {
+		return nil
+	}
+
+	cmd := pipelines[0].Commands[0]
+	if cmd.Conditional != nil || cmd.Compound != nil || cmd.Background || len(cmd.Redirections) > 0 {
+		return nil
+	}
+
+	return cmd
+}
+
+// substitutionMark returns a marker for the substitutions started so far.
+func substitutionMark() int {
+	substitutionsMu.Lock()
+	defer substitutionsMu.Unlock()
+
+	return len(substitutions)
+}
+
+// substitutionFiles returns the pipe ends of the running substitutions
+// arranged as exec.Cmd.ExtraFiles, so that each keeps its descriptor
+// number N in the child and /dev/fd/N refers to the same pipe there.
+func substitutionFiles() []*os.File {
+	substitutionsMu.Lock()
+	defer substitutionsMu.Unlock()
+
+	var files []*os.File
+
+	for _, substitution := range substitutions {
+		index := int(substitution.file.Fd()) - firstExtraFd //nolint:gosec // Descriptor numbers are small
+		for len(files) <= index {
+			files = append(files, nil)
+		}
+		files[index] = substitution.file
+	}
+
+	return files
+}
+
+// reapSubstitutions closes the pipes of the substitutions started after
+// mark and waits for their commands. With wait unset, as after starting a
+// background job, they are reaped without blocking the shell.
+func reapSubstitutions(mark int, wait bool) {
+	substitutionsMu.Lock()
+	if mark >= len(substitutions) {
+		substitutionsMu.Unlock()
+
+		return
+	}
+	finished := append([]*processSubstitution(nil), substitutions[mark:]...)
+	substitutions = substitutions[:mark]
+	substitutionsMu.Unlock()
+
+	for _, substitution := range finished {
+		_ = substitution.file.Close()
+	}
+
+	if !wait {
+		go func() {
+			for _, substitution := range finished {
+				_ = substitution.cmd.Wait()
+			}
+		}()
+
+		return
+	}
+
+	for _, substitution := range finished {
+		_ = waitProcess(substitution.cmd)
+	}
+}
@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dsh/internal/expand"
+)
+
+func TestProcessSubstitution(t *testing.T) {
+	expand.SetProcessSubstituter(StartProcessSubstitution)
+	t.Cleanup(func() { expand.SetProcessSubstituter(nil) })
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	output := filepath.Join(dir, "output")
+	written := filepath.Join(dir, "written")
+
+	runList(t, "printf 'b\\na\\n' > "+input+"; cat <(sort "+input+") <(printf done) > "+output)
+
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if string(content) != "a\nb\ndone" {
+		t.Errorf("Unexpected output %q", content)
+	}
+
+	runList(t, "printf hello > >(dd of="+written+" status=none)")
+
+	content, err = os.ReadFile(written)
+	if err != nil {
+		t.Fatalf("Failed to read written file: %v", err)
+	}
+	if string(content) != "hello" {
+		t.Errorf("Expected the inner command to have finished writing, got %q", content)
+	}
+
+	if mark := substitutionMark(); mark != 0 {
+		t.Errorf("Expected all substitutions to be reaped, %d remain", mark)
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	output, err := CommandOutput("printf 'main\\n\\n'")
+	if err != nil {
+		t.Fatalf("CommandOutput failed: %v", err)
+	}
+	if output != "main" {
+		t.Errorf("Expected the output without trailing newlines, got %q", output)
+	}
+
+	output, err = CommandOutput("echo a b")
+	if err != nil || output != "a b" {
+		t.Errorf("Expected an output builtin to run, got %q (%v)", output, err)
+	}
+}
@@ -0,0 +1,406 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"dsh/internal/expand"
+	"dsh/internal/lexer"
+	"dsh/internal/parser"
+)
+
+// ErrBadDescriptor indicates a redirection from a descriptor that is not
+// open, or to a target that is not a descriptor number.
+var ErrBadDescriptor = errors.New("bad file descriptor")
+
+var (
+	// shellFiles are the shell's descriptors from 3 up: those opened by
+	// exec and those inherited from the shell's parent. Every command the
+	// shell starts gets them at the same numbers.
+	shellFiles   = map[int]*os.File{} //nolint:gochecknoglobals // The shell's descriptor table
+	shellFilesMu sync.Mutex           //nolint:gochecknoglobals // Guards shellFiles
+)
+
+// childFiles returns the descriptors from 3 up for a command to start
+// with, arranged as exec.Cmd.ExtraFiles: the pipes of running process
+// substitutions, whose /dev/fd paths name their numbers, and the shell's
+// own descriptors.
+func childFiles() []*os.File {
+	files := substitutionFiles()
+
+	shellFilesMu.Lock()
+	defer shellFilesMu.Unlock()
+
+	for fd, file := range shellFiles {
+		index := fd - firstExtraFd
+		for len(files) <= index {
+			files = append(files, nil)
+		}
+		if files[index] == nil {
+			files[index] = file
+		}
+	}
+
+	return files
+}
+
+// shellFile returns the shell's descriptor fd, from 3 up. A descriptor the
+// shell inherited is adopted the first time it is used; descriptors the
+// Go runtime opened are close-on-exec and never match.
+func shellFile(fd int) (*os.File, bool) {
+	shellFilesMu.Lock()
+	defer shellFilesMu.Unlock()
+
+	if file, ok := shellFiles[fd]; ok {
+		return file, true
+	}
+
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFD, 0)
+	if fd < firstExtraFd || errno != 0 || flags&syscall.FD_CLOEXEC != 0 {
+		return nil, false
+	}
+
+	file := os.NewFile(uintptr(fd), "/dev/fd/"+strconv.Itoa(fd))
+	shellFiles[fd] = file
+
+	return file, true
+}
+
+// openRedirection opens the file of a redirection to a file.
+func openRedirection(redirection parser.Redirection, target string) (*os.File, error) {
+	switch redirection.Op {
+	case lexer.RedirectIn:
+		return os.Open(target) //nolint:gosec // Opening user files is the shell's job
+	case lexer.RedirectAppend:
+		return openOutputFile(target, true, false)
+	default:
+		return openOutputFile(target, false, redirection.Op == lexer.RedirectClobber)
+	}
+}
+
+// isDuplication reports whether redirection copies or closes a descriptor
+// rather than opening a file.
+func isDuplication(redirection parser.Redirection) bool {
+	return redirection.Op == lexer.RedirectDupOut || redirection.Op == lexer.RedirectDupIn
+}
+
+// sourceDescriptor parses the target of >& or <&.
+func sourceDescriptor(target string) (int, error) {
+	fd, err := strconv.Atoi(target)
+	if err != nil || fd < 0 {
+		return 0, fmt.Errorf("%s: %w", target, ErrBadDescriptor)
+	}
+
+	return fd, nil
+}
+
+// redirectCommand applies redirections, left to right, to a command about
+// to start, whose standard streams are already set. It returns the files
+// it opened, which the caller closes once the command has finished.
+func redirectCommand(execCmd *exec.Cmd, redirections []parser.Redirection) ([]*os.File, error) {
+	var opened []*os.File
+
+	for _, redirection := range redirections {
+		if redirection.Op == lexer.HereDoc {
+			body, err := expand.HereDoc(redirection.HereDoc)
+			if err != nil {
+				return opened, err
+			}
+			execCmd.Stdin = strings.NewReader(body)
+
+			continue
+		}
+
+		target, err := expand.String(redirection.Target)
+		if err != nil {
+			return opened, err
+		}
+
+		var stream any
+
+		switch {
+		case isDuplication(redirection) && target == "-":
+			stream = nil
+		case isDuplication(redirection):
+			fd, err := sourceDescriptor(target)
+			if err != nil {
+				return opened, err
+			}
+
+			stream = commandStream(execCmd, fd)
+			if stream == nil {
+				return opened, fmt.Errorf("%d: %w", fd, ErrBadDescriptor)
+			}
+		default:
+			file, err := openRedirection(redirection, target)
+			if err != nil {
+				return opened, err
+			}
+			opened = append(opened, file)
+			stream = file
+		}
+
+		if err := setCommandStream(execCmd, redirection.Fd, stream); err != nil {
+			return opened, err
+		}
+	}
+
+	return opened, nil
+}
+
+// commandStream returns what descriptor fd of a command about to start
+// refers to, or nil if it is closed. Descriptors the command was not
+// given are looked up among the shell's own.
+func commandStream(execCmd *exec.Cmd, fd int) any {
+	switch fd {
+	case 0:
+		return execCmd.Stdin
+	case 1:
+		return execCmd.Stdout
+	case 2:
+		return execCmd.Stderr
+	}
+
+	index := fd - firstExtraFd
+	if index < len(execCmd.ExtraFiles) && execCmd.ExtraFiles[index] != nil {
+		return execCmd.ExtraFiles[index]
+	}
+
+	if file, ok := shellFile(fd); ok {
+		return file
+	}
+
+	return nil
+}
+
+// setCommandStream points descriptor fd of a command about to start at
+// stream; nil closes it. Descriptors from 3 up need a file, while here
+// documents, which are not files, can only be copied among 0, 1 and 2.
+func setCommandStream(execCmd *exec.Cmd, fd int, stream any) error {
+	badDescriptor := fmt.Errorf("%d: %w", fd, ErrBadDescriptor)
+
+	switch fd {
+	case 0:
+		reader, ok := stream.(io.Reader)
+		if !ok && stream != nil {
+			return badDescriptor
+		}
+		execCmd.Stdin = reader
+	case 1, 2:
+		writer, ok := stream.(io.Writer)
+		if !ok && stream != nil {
+			return badDescriptor
+		}
+		if fd == 1 {
+			execCmd.Stdout = writer
+		} else {
+			execCmd.Stderr = writer
+		}
+	default:
+		file, ok := stream.(*os.File)
+		if !ok && stream != nil {
+			return badDescriptor
+		}
+
+		index := fd - firstExtraFd
+		for len(execCmd.ExtraFiles) <= index {
+			execCmd.ExtraFiles = append(execCmd.ExtraFiles, nil)
+		}
+		execCmd.ExtraFiles[index] = file
+	}
+
+	return nil
+}
+
+// redirectShell applies the redirections of exec to the shell itself, left
+// to right, so that they stay in effect for the commands that follow.
+func redirectShell(cmd *parser.Command) error {
+	for _, redirection := range cmd.Redirections {
+		if redirection.Op == lexer.HereDoc {
+			if err := redirectHereDoc(redirection.HereDoc); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		target, err := expand.String(redirection.Target)
+		if err != nil {
+			return err
+		}
+
+		if isDuplication(redirection) && target == "-" {
+			if err := closeShellDescriptor(redirection.Fd); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		var file *os.File
+		if isDuplication(redirection) {
+			fd, err := sourceDescriptor(target)
+			if err != nil {
+				return err
+			}
+			file, err = duplicateShellDescriptor(fd)
+			if err != nil {
+				return err
+			}
+		} else {
+			file, err = openRedirection(redirection, target)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := setShellDescriptor(redirection.Fd, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// redirectBuiltin applies the redirections of a builtin to the shell for
+// as long as it runs, since builtins read and write the shell's own
+// descriptors. The returned function puts the descriptors back, and must
+// be called even after an error.
+func redirectBuiltin(cmd *parser.Command) (func(), error) {
+	saved := map[int]*os.File{}
+	restore := func() {
+		for fd, file := range saved {
+			if file == nil {
+				_ = closeShellDescriptor(fd)
+			} else {
+				_ = setShellDescriptor(fd, file)
+			}
+		}
+	}
+
+	for _, redirection := range cmd.Redirections {
+		if _, ok := saved[redirection.Fd]; !ok {
+			// A descriptor that was not open is closed again afterwards
+			saved[redirection.Fd], _ = duplicateShellDescriptor(redirection.Fd)
+		}
+	}
+
+	return restore, redirectShell(cmd)
+}
+
+// redirectHereDoc makes the shell's standard input read the body of a
+// here document through a pipe.
+func redirectHereDoc(hereDoc *lexer.HereDocument) error {
+	body, err := expand.HereDoc(hereDoc)
+	if err != nil {
+		return err
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("here document: %w", err)
+	}
+
+	// A body larger than the pipe's buffer is written while it is read
+	go func() {
+		_, _ = io.WriteString(writer, body)
+		_ = writer.Close()
+	}()
+
+	return setShellDescriptor(0, reader)
+}
+
+// duplicateShellDescriptor returns a new file for the shell's descriptor fd.
+func duplicateShellDescriptor(fd int) (*os.File, error) {
+	source := fd
+	if fd >= firstExtraFd {
+		file, ok := shellFile(fd)
+		if !ok {
+			return nil, fmt.Errorf("%d: %w", fd, ErrBadDescriptor)
+		}
+		source = int(file.Fd()) //nolint:gosec // Descriptor numbers are small
+	}
+
+	duplicate, err := syscall.Dup(source)
+	if err != nil {
+		return nil, fmt.Errorf("%d: %w", fd, ErrBadDescriptor)
+	}
+	syscall.CloseOnExec(duplicate)
+
+	return os.NewFile(uintptr(duplicate), "/dev/fd/"+strconv.Itoa(fd)), nil //nolint:gosec // dup returns a valid descriptor
+}
+
+// setShellDescriptor makes the shell's descriptor fd refer to file. The
+// standard descriptors are replaced in place, so the shell's own input
+// and output follow; the others go in shellFiles.
+func setShellDescriptor(fd int, file *os.File) error {
+	if fd < firstExtraFd {
+		defer func() { _ = file.Close() }()
+
+		if err := syscall.Dup3(int(file.Fd()), fd, 0); err != nil { //nolint:gosec // Descriptor numbers are small
+			return fmt.Errorf("%d: %w", fd, err)
+		}
+
+		return nil
+	}
+
+	shellFilesMu.Lock()
+	defer shellFilesMu.Unlock()
+
+	if previous, ok := shellFiles[fd]; ok {
+		_ = previous.Close()
+	}
+	shellFiles[fd] = file
+
+	return nil
+}
+
+// closeShellDescriptor closes the shell's descriptor fd. A standard
+// descriptor is pointed at /dev/null instead, since the next file the
+// shell opened would otherwise take its number.
+func closeShellDescriptor(fd int) error {
+	if fd < firstExtraFd {
+		file, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+
+		return setShellDescriptor(fd, file)
+	}
+
+	if file, ok := shellFile(fd); ok {
+		shellFilesMu.Lock()
+		delete(shellFiles, fd)
+		shellFilesMu.Unlock()
+
+		_ = file.Close()
+	}
+
+	return nil
+}
+
+// exposeShellFiles moves the shell's descriptors to their own numbers, so
+// that a command replacing the shell with exec keeps them.
+func exposeShellFiles() error {
+	shellFilesMu.Lock()
+	defer shellFilesMu.Unlock()
+
+	for fd, file := range shellFiles {
+		if int(file.Fd()) == fd { //nolint:gosec // Descriptor numbers are small
+			continue
+		}
+
+		if err := syscall.Dup3(int(file.Fd()), fd, 0); err != nil { //nolint:gosec // Descriptor numbers are small
+			return fmt.Errorf("%d: %w", fd, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"dsh/internal/parser"
+	"dsh/internal/variables"
+)
+
+// Report formats of the time keyword, in the escapes of TIMEFORMAT.
+const (
+	defaultTimeFormat = "\nreal\t%3lR\nuser\t%3lU\nsys\t%3lS"
+	posixTimeFormat   = "real %2R\nuser %2U\nsys %2S"
+)
+
+// resourceUsage adds up the resources used while a pipeline is timed.
+type resourceUsage struct {
+	user, sys time.Duration
+	// maxRSS is the largest resident set of a command, in kilobytes.
+	maxRSS      int64
+	voluntary   int64
+	involuntary int64
+}
+
+// add adds the usage of a process or of the shell itself.
+func (usage *resourceUsage) add(rusage *syscall.Rusage) {
+	usage.user += time.Duration(rusage.Utime.Nano())
+	usage.sys += time.Duration(rusage.Stime.Nano())
+	usage.maxRSS = max(usage.maxRSS, rusage.Maxrss)
+	usage.voluntary += rusage.Nvcsw
+	usage.involuntary += rusage.Nivcsw
+}
+
+var (
+	// timers are the usages of the pipelines being timed, innermost last.
+	// Every command they wait for counts towards all of them.
+	timers   []*resourceUsage //nolint:gochecknoglobals // Shell-wide stack of timed pipelines
+	timersMu sync.Mutex       //nolint:gochecknoglobals // Guards timers
+)
+
+// waitProcess waits for a command the shell runs in the foreground,
+// adding what it used to the pipelines being timed.
+func waitProcess(cmd process) error {
+	err := cmd.Wait()
+	if execCmd, ok := cmd.(*exec.Cmd); ok {
+		recordUsage(execCmd.ProcessState)
+	}
+
+	return err
+}
+
+// recordUsage adds the rusage of a finished process, which includes the
+// children it waited for, to the pipelines being timed.
+func recordUsage(state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return
+	}
+
+	timersMu.Lock()
+	defer timersMu.Unlock()
+
+	for _, usage := range timers {
+		usage.add(rusage)
+	}
+}
+
+// executeTimed runs a pipeline after the time keyword and reports to
+// stderr how long it took and the CPU time used by its commands and by
+// the shell while it ran. Shell constructs are timed as a whole.
+func executeTimed(pipeline *parser.Pipeline) bool {
+	usage := &resourceUsage{}
+
+	timersMu.Lock()
+	timers = append(timers, usage)
+	timersMu.Unlock()
+
+	var before, after syscall.Rusage
+	_ = syscall.Getrusage(syscall.RUSAGE_SELF, &before)
+	start := time.Now()
+
+	ok := true
+	if len(pipeline.Commands) > 0 {
+		ok = executePipeline(pipeline)
+	} else {
+		variables.SetLastStatus(0)
+	}
+
+	elapsed := time.Since(start)
+	_ = syscall.Getrusage(syscall.RUSAGE_SELF, &after)
+
+	timersMu.Lock()
+	timers = timers[:len(timers)-1]
+	timersMu.Unlock()
+
+	// The shell's own share, without its lifetime's peak memory
+	usage.user += time.Duration(after.Utime.Nano() - before.Utime.Nano())
+	usage.sys += time.Duration(after.Stime.Nano() - before.Stime.Nano())
+	usage.voluntary += after.Nvcsw - before.Nvcsw
+	usage.involuntary += after.Nivcsw - before.Nivcsw
+
+	if ok {
+		reportTime(pipeline.Time, elapsed, usage)
+	}
+
+	return ok
+}
+
+// reportTime prints the times of a pipeline in the format of TIMEFORMAT,
+// or the POSIX format for time -p. An empty TIMEFORMAT prints nothing.
+func reportTime(mode parser.TimeMode, elapsed time.Duration, usage *resourceUsage) {
+	format, ok := variables.Get("TIMEFORMAT")
+	if !ok {
+		format = defaultTimeFormat
+	}
+	if mode == parser.TimePosix {
+		format = posixTimeFormat
+	}
+
+	var report strings.Builder
+	if format != "" {
+		report.WriteString(formatTime(format, elapsed, usage.user, usage.sys))
+		report.WriteByte('\n')
+	}
+
+	if mode == parser.TimeVerbose {
+		_, _ = fmt.Fprintf(&report, "maxrss\t%dk\n", usage.maxRSS)
+		_, _ = fmt.Fprintf(&report, "ctxsw\t%d voluntary, %d involuntary\n", usage.voluntary, usage.involuntary)
+	}
+
+	_, _ = fmt.Fprint(os.Stderr, report.String())
+}
+
+// formatTime expands the escapes of TIMEFORMAT: %R, %U and %S for the
+// real, user and system time, %P for the CPU percentage and %% for a %.
+// A digit after % gives the number of decimals, at most 3, and l the
+// long form MmS.FFFs.
+func formatTime(format string, elapsed, user, sys time.Duration) string {
+	var out strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			out.WriteByte(format[i])
+
+			continue
+		}
+
+		start := i
+		i++
+
+		precision, long := 3, false
+		if format[i] >= '0' && format[i] <= '9' {
+			precision = min(int(format[i]-'0'), 3)
+			i++
+		}
+		if i < len(format) && format[i] == 'l' {
+			long = true
+			i++
+		}
+		if i == len(format) {
+			out.WriteString(format[start:])
+
+			break
+		}
+
+		switch format[i] {
+		case 'R':
+			out.WriteString(formatSeconds(elapsed, precision, long))
+		case 'U':
+			out.WriteString(formatSeconds(user, precision, long))
+		case 'S':
+			out.WriteString(formatSeconds(sys, precision, long))
+		case 'P':
+			percent := 0.0
+			if elapsed > 0 {
+				percent = float64(user+sys) * 100 / float64(elapsed)
+			}
+			out.WriteString(strconv.FormatFloat(percent, 'f', 2, 64))
+		case '%':
+			out.WriteByte('%')
+		default:
+			out.WriteString(format[start : i+1])
+		}
+	}
+
+	return out.String()
+}
+
+// formatSeconds writes d in seconds with precision decimals, truncated
+// rather than rounded, or in minutes and seconds if long.
+func formatSeconds(d time.Duration, precision int, long bool) string {
+	unit := time.Second
+	for range precision {
+		unit /= 10
+	}
+	d = d.Truncate(unit)
+
+	if !long {
+		return strconv.FormatFloat(d.Seconds(), 'f', precision, 64)
+	}
+
+	minutes := d / time.Minute
+	seconds := (d - minutes*time.Minute).Seconds()
+
+	return strconv.FormatInt(int64(minutes), 10) + "m" + strconv.FormatFloat(seconds, 'f', precision, 64) + "s"
+}
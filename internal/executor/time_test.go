@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"dsh/internal/variables"
+)
+
+func TestFormatTime(t *testing.T) {
+	t.Parallel()
+
+	elapsed := 83*time.Second + 456789*time.Microsecond
+	user := 1500 * time.Millisecond
+	sys := 250 * time.Millisecond
+
+	tests := map[string]string{
+		defaultTimeFormat:  "\nreal\t1m23.456s\nuser\t0m1.500s\nsys\t0m0.250s",
+		posixTimeFormat:    "real 83.45\nuser 1.50\nsys 0.25",
+		"%0R %1U %5S":      "83 1.5 0.250",
+		"%0lR":             "1m23s",
+		"%P%%":             "2.10%",
+		"%x %":             "%x %",
+		"total %3l":        "total %3l",
+		"no escapes\there": "no escapes\there",
+	}
+
+	for format, expected := range tests {
+		if got := formatTime(format, elapsed, user, sys); got != expected {
+			t.Errorf("formatTime(%q) = %q, expected %q", format, got, expected)
+		}
+	}
+}
+
+func TestExecutor_Time(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report")
+
+	file, err := os.Create(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := os.Stderr
+	os.Stderr = file
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		_ = file.Close()
+		variables.Unset("TIMEFORMAT")
+		variables.Unset("DSH_TEST_TIMED")
+	})
+
+	variables.Set("TIMEFORMAT", "[%3R %U %S]")
+	runList(t, "time { DSH_TEST_TIMED=yes; false; }; time -v; TIMEFORMAT=; time true")
+
+	if value, _ := variables.Get("DSH_TEST_TIMED"); value != "yes" {
+		t.Errorf("Expected the timed group to run, got %q", value)
+	}
+	if status := GetLastExitStatus(); status != 0 {
+		t.Errorf("Expected status 0, got %d", status)
+	}
+
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := regexp.MustCompile(`^\[\d+\.\d{3} \d+\.\d{3} \d+\.\d{3}\]\n` +
+		`\[\d+\.\d{3} \d+\.\d{3} \d+\.\d{3}\]\nmaxrss\t\d+k\nctxsw\t\d+ voluntary, \d+ involuntary\n$`)
+	if !expected.Match(data) {
+		t.Errorf("Unexpected time report %q", data)
+	}
+}
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"dsh/internal/expand"
+	"dsh/internal/options"
+	"dsh/internal/variables"
+)
+
+// defaultPS4 prefixes traced commands when PS4 is unset.
+const defaultPS4 = "+ "
+
+// traceCommand prints a command about to run to stderr under xtrace: the
+// expanded PS4, then its assignments and arguments quoted for reuse.
+func traceCommand(assignments, args []string) {
+	if !options.Enabled(options.Xtrace) {
+		return
+	}
+
+	words := make([]string, 0, len(assignments)+len(args))
+
+	for _, raw := range assignments {
+		name, value, err := expand.Assignment(raw)
+		if err != nil {
+			continue
+		}
+		words = append(words, name+"="+traceQuote(value))
+	}
+
+	for _, arg := range args {
+		words = append(words, traceQuote(arg))
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s%s\n", tracePrefix(), strings.Join(words, " "))
+}
+
+// tracePrefix expands PS4, falling back to its text if expansion fails.
+func tracePrefix() string {
+	ps4, ok := variables.Get("PS4")
+	if !ok {
+		return defaultPS4
+	}
+
+	prefix, err := expand.String(ps4)
+	if err != nil {
+		return ps4
+	}
+
+	return prefix
+}
+
+// traceQuote single-quotes word if the shell would otherwise split or
+// expand it.
+func traceQuote(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\n'\"\\$`|&;<>()*?[]{}~#!") {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
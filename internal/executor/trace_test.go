@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dsh/internal/options"
+	"dsh/internal/variables"
+)
+
+func TestTraceCommand(t *testing.T) {
+	trace := filepath.Join(t.TempDir(), "trace")
+
+	file, err := os.Create(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := os.Stderr
+	os.Stderr = file
+	variables.Set("PS4", "[$DSH_TEST_DEPTH] ")
+	variables.Set("DSH_TEST_DEPTH", "1")
+	_ = options.Set(options.Xtrace, true)
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		_ = file.Close()
+		_ = options.Set(options.Xtrace, false)
+		variables.Unset("PS4")
+		variables.Unset("DSH_TEST_DEPTH")
+	})
+
+	runList(t, `DSH_TEST_X="a b" test "it's" = ''; DSH_TEST_Y=1`)
+
+	data, err := os.ReadFile(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[1] DSH_TEST_X='a b' test 'it'\\''s' = ''\n[1] DSH_TEST_Y=1\n"
+	if string(data) != expected {
+		t.Errorf("Expected trace %q, got %q", expected, data)
+	}
+	variables.Unset("DSH_TEST_Y")
+}
+
+func TestTraceQuote(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"plain":  "plain",
+		"":       "''",
+		"a b":    "'a b'",
+		"$HOME":  "'$HOME'",
+		"it's":   `'it'\''s'`,
+		"*.go":   "'*.go'",
+		"a=b,c/": "a=b,c/",
+	}
+
+	for word, expected := range tests {
+		if got := traceQuote(word); got != expected {
+			t.Errorf("traceQuote(%q): expected %s, got %s", word, expected, got)
+		}
+	}
+}
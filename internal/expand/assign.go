@@ -0,0 +1,194 @@
+package expand
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"dsh/internal/lexer"
+	"dsh/internal/variables"
+)
+
+// ErrSubscriptRequired indicates an associative array literal element
+// without a [key]= prefix.
+var ErrSubscriptRequired = errors.New("must use subscript when assigning associative array")
+
+// assignment is a parsed name=value, name+=value or name[sub]=value word.
+type assignment struct {
+	name      string
+	subscript string
+	hasIndex  bool
+	appending bool
+	value     string
+}
+
+// IsAssignment reports whether raw has the form of a variable assignment.
+func IsAssignment(raw string) bool {
+	_, ok := parseAssignment(raw)
+
+	return ok
+}
+
+// Assignment returns the name and expanded scalar value of an assignment
+// word, as needed for the environment of a single command.
+func Assignment(raw string) (string, string, error) {
+	a, ok := parseAssignment(raw)
+	if !ok {
+		return "", "", fmt.Errorf("%s: %w", raw, ErrBadSubstitution)
+	}
+
+	value, err := String(a.value)
+	if err != nil {
+		return "", "", err
+	}
+
+	if a.appending {
+		current, _ := variables.Get(a.name)
+		value = current + value
+	}
+
+	return a.name, value, nil
+}
+
+// Assign performs an assignment word: name=value, name+=value,
+// name[sub]=value, or an array literal name=(a b [k]=v).
+func Assign(raw string) error {
+	a, ok := parseAssignment(raw)
+	if !ok {
+		return fmt.Errorf("%s: %w", raw, ErrBadSubstitution)
+	}
+
+	if !a.hasIndex && strings.HasPrefix(a.value, "(") && strings.HasSuffix(a.value, ")") {
+		return assignArrayLiteral(a.name, a.value[1:len(a.value)-1], a.appending)
+	}
+
+	value, err := String(a.value)
+	if err != nil {
+		return err
+	}
+
+	if !a.hasIndex {
+		if a.appending {
+			current, _ := variables.Get(a.name)
+			value = current + value
+		}
+		variables.Set(a.name, value)
+
+		return nil
+	}
+
+	key, err := Subscript(a.name, a.subscript)
+	if err != nil {
+		return err
+	}
+
+	if a.appending {
+		current, _ := variables.GetElement(a.name, key)
+		value = current + value
+	}
+
+	return variables.SetElement(a.name, key, value)
+}
+
+// assignArrayLiteral assigns the elements of (...). Elements written as
+// [key]=value set that key; the others follow the highest index so far.
+// Without +=, the array is emptied first but keeps its kind.
+func assignArrayLiteral(name, literal string, appending bool) error {
+	if !appending {
+		kind := variables.KindOf(name)
+		if kind == variables.Scalar {
+			kind = variables.Indexed
+		}
+		variables.Unset(name)
+
+		err := variables.Declare(name, kind)
+		if err != nil {
+			return err
+		}
+	} else if variables.KindOf(name) == variables.Scalar {
+		variables.AppendArray(name, nil)
+	}
+
+	for _, element := range literalElements(literal) {
+		err := assignElement(name, element)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func assignElement(name, element string) error {
+	if strings.HasPrefix(element, "[") {
+		if closing := strings.Index(element, "]="); closing > 0 {
+			key, err := Subscript(name, element[1:closing])
+			if err != nil {
+				return err
+			}
+
+			value, err := String(element[closing+2:])
+			if err != nil {
+				return err
+			}
+
+			return variables.SetElement(name, key, value)
+		}
+	}
+
+	if variables.KindOf(name) == variables.Associative {
+		return fmt.Errorf("%s: %s: %w", name, element, ErrSubscriptRequired)
+	}
+
+	values, err := Fields(element)
+	if err != nil {
+		return err
+	}
+	variables.AppendArray(name, values)
+
+	return nil
+}
+
+// literalElements splits the inside of an array literal into raw words.
+func literalElements(literal string) []string {
+	var elements []string
+
+	l := lexer.New(literal)
+	for token := l.NextToken(); token.Type != lexer.EOF; token = l.NextToken() {
+		if token.Type == lexer.Word {
+			elements = append(elements, token.Raw)
+		}
+	}
+
+	return elements
+}
+
+// parseAssignment splits an assignment word into its parts. The name and
+// subscript must be unquoted.
+func parseAssignment(raw string) (assignment, bool) {
+	equals := strings.IndexByte(raw, '=')
+	if equals <= 0 {
+		return assignment{}, false
+	}
+
+	a := assignment{value: raw[equals+1:]}
+	target := raw[:equals]
+
+	if strings.HasSuffix(target, "+") {
+		a.appending = true
+		target = target[:len(target)-1]
+	}
+
+	if open := strings.IndexByte(target, '['); open > 0 && strings.HasSuffix(target, "]") {
+		a.subscript = target[open+1 : len(target)-1]
+		a.hasIndex = true
+		target = target[:open]
+	}
+
+	if !lexer.IsName(target) {
+		return assignment{}, false
+	}
+	a.name = target
+
+	return a, true
+}
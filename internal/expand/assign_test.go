@@ -0,0 +1,93 @@
+package expand
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"dsh/internal/variables"
+)
+
+func TestIsAssignment(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected bool
+	}{
+		{"a=1", true},
+		{"a+=1", true},
+		{"arr[3]=x", true},
+		{"arr=(a b)", true},
+		{"_x1=", true},
+		{"=a", false},
+		{"1a=b", false},
+		{`"a"=b`, false},
+		{"echo", false},
+		{"a-b=c", false},
+	}
+
+	for _, test := range tests {
+		if got := IsAssignment(test.raw); got != test.expected {
+			t.Errorf("IsAssignment(%q) = %v, want %v", test.raw, got, test.expected)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("scalar")
+		variables.Unset("arr")
+		variables.Unset("assoc")
+	})
+
+	steps := []string{
+		`scalar="a b"`,
+		"scalar+=c",
+		`arr=(one "two three" [5]=six)`,
+		"arr+=(seven)",
+		"arr[1]=TWO",
+		"arr[-1]+=!",
+	}
+	for _, raw := range steps {
+		err := Assign(raw)
+		if err != nil {
+			t.Fatalf("Assign(%q) failed: %v", raw, err)
+		}
+	}
+
+	if value, _ := variables.Get("scalar"); value != "a bc" {
+		t.Errorf("Expected scalar 'a bc', got %q", value)
+	}
+
+	expected := []string{"one", "TWO", "six", "seven!"}
+	if got := variables.GetArray("arr"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if keys := variables.Keys("arr"); !reflect.DeepEqual(keys, []string{"0", "1", "5", "6"}) {
+		t.Errorf("Unexpected indices %v", keys)
+	}
+}
+
+func TestAssign_AssociativeLiteral(t *testing.T) {
+	err := variables.Declare("assoc", variables.Associative)
+	if err != nil {
+		t.Fatalf("Declare failed: %v", err)
+	}
+	t.Cleanup(func() { variables.Unset("assoc") })
+
+	err = Assign(`assoc=([b]=1 ["key with space"]=2)`)
+	if err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+
+	if variables.KindOf("assoc") != variables.Associative {
+		t.Error("Expected array literal to keep the associative kind")
+	}
+	if value, _ := variables.GetElement("assoc", "key with space"); value != "2" {
+		t.Errorf("Expected 2, got %q", value)
+	}
+
+	err = Assign("assoc=(novalue)")
+	if !errors.Is(err, ErrSubscriptRequired) {
+		t.Errorf("Expected ErrSubscriptRequired, got %v", err)
+	}
+}
@@ -0,0 +1,397 @@
+// Package expand performs the word expansions of the shell when a command
+// runs: tilde expansion, parameter expansion, process substitution, field
+// splitting, pathname expansion and quote removal. Words are expanded from
+// the raw text recorded by the lexer.
+package expand
+
+import (
+	"regexp"
+	"strings"
+
+	"dsh/internal/lexer"
+	"dsh/internal/options"
+)
+
+// mode selects what an expansion produces.
+type mode int
+
+const (
+	// modeFields splits unquoted expansions into separate fields.
+	modeFields mode = iota
+	// modeString produces a single string without splitting.
+	modeString
+	// modePattern is modeString with quoted glob characters escaped.
+	modePattern
+	// modeRegex is modeString with quoted regex metacharacters escaped.
+	modeRegex
+)
+
+// globSpecial lists the characters escaped in quoted parts of patterns.
+const globSpecial = `*?[]\`
+
+// expander walks one raw word and accumulates its fields.
+type expander struct {
+	raw     string
+	pos     int
+	mode    mode
+	fields  []string
+	current strings.Builder
+	// inField is set once the current field has content, even empty
+	// quoted content such as "".
+	inField bool
+	// sawArray is set by "${name[@]}", which produces no field at all
+	// when the array is empty.
+	sawArray bool
+	// pattern is the current field with quoted glob characters escaped,
+	// and globbing is set once it holds an unquoted one.
+	pattern  strings.Builder
+	globbing bool
+	// err is a pathname expansion failure under failglob.
+	err error
+	// defaulted is set while expanding the word of ${name:-word}, whose
+	// unquoted text is split into fields like the value of a parameter.
+	defaulted bool
+}
+
+// Fields expands raw into zero or more fields: tilde and parameter
+// expansion, splitting of unquoted results on IFS, pathname expansion of
+// fields with unquoted glob characters, and quote removal.
+func Fields(raw string) ([]string, error) {
+	e := &expander{raw: raw, mode: modeFields}
+
+	err := e.run()
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return e.fields, nil
+}
+
+// Words expands each raw word with Fields and concatenates the results.
+func Words(raws []string) ([]string, error) {
+	var result []string
+
+	for _, raw := range raws {
+		fields, err := Fields(raw)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, fields...)
+	}
+
+	return result, nil
+}
+
+// String expands raw into a single string without field splitting, as for
+// assignment values, redirection targets and [[ ]] operands.
+func String(raw string) (string, error) {
+	return expandJoined(raw, modeString)
+}
+
+// Pattern expands raw like String but escapes glob characters that were
+// quoted, so that only unquoted *, ? and [ act as wildcards.
+func Pattern(raw string) (string, error) {
+	return expandJoined(raw, modePattern)
+}
+
+// Regex expands raw like String but escapes regular expression
+// metacharacters that were quoted, so quoted text matches literally.
+func Regex(raw string) (string, error) {
+	return expandJoined(raw, modeRegex)
+}
+
+func expandJoined(raw string, m mode) (string, error) {
+	e := &expander{raw: raw, mode: m}
+
+	err := e.run()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(e.fields, " "), nil
+}
+
+func (e *expander) run() error {
+	e.expandLeadingTilde()
+
+	if err := e.unquoted(); err != nil {
+		return err
+	}
+
+	e.endField()
+
+	return nil
+}
+
+// unquoted expands the rest of raw outside any quotes.
+func (e *expander) unquoted() error {
+	for e.pos < len(e.raw) {
+		var err error
+
+		switch e.raw[e.pos] {
+		case '\'':
+			e.singleQuoted()
+		case '"':
+			err = e.doubleQuoted()
+		case '\\':
+			e.escaped()
+		case '$':
+			err = e.dollar(false)
+		case '<', '>':
+			if e.pos+1 < len(e.raw) && e.raw[e.pos+1] == '(' {
+				err = e.processSubstitution()
+			} else {
+				e.literal(e.raw[e.pos:e.pos+1], false)
+				e.pos++
+			}
+		default:
+			if e.defaulted {
+				e.value(e.raw[e.pos:e.pos+1], false)
+			} else {
+				e.literal(e.raw[e.pos:e.pos+1], false)
+			}
+			e.pos++
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandLeadingTilde replaces an unquoted ~ or ~user prefix.
+func (e *expander) expandLeadingTilde() {
+	if !strings.HasPrefix(e.raw, "~") {
+		return
+	}
+
+	end := strings.IndexByte(e.raw, '/')
+	if end < 0 {
+		end = len(e.raw)
+	}
+
+	prefix := e.raw[:end]
+	if strings.ContainsAny(prefix, `'"\$`) {
+		return
+	}
+
+	if expanded := Tilde(prefix); expanded != prefix {
+		e.literal(expanded, true)
+		e.pos = end
+	}
+}
+
+func (e *expander) singleQuoted() {
+	end := strings.IndexByte(e.raw[e.pos+1:], '\'')
+	if end < 0 {
+		// Unterminated quote: keep the rest literally
+		e.literal(e.raw[e.pos+1:], true)
+		e.pos = len(e.raw)
+
+		return
+	}
+
+	e.literal(e.raw[e.pos+1:e.pos+1+end], true)
+	e.pos += end + 2
+}
+
+// doubleQuoted expands the inside of "...": parameters are expanded and
+// backslash only escapes $, `, ", \ and newline, where it joins lines.
+func (e *expander) doubleQuoted() error {
+	e.pos++ // skip opening quote
+	sawArray := e.sawArray
+	e.sawArray = false
+	start := e.current.Len()
+	hadField := e.inField
+
+	for e.pos < len(e.raw) && e.raw[e.pos] != '"' {
+		if err := e.quotedChar(); err != nil {
+			return err
+		}
+	}
+	e.pos++ // skip closing quote
+
+	// "" is an empty field, but "${empty[@]}" is no field at all
+	if !e.sawArray && !hadField && e.current.Len() == start {
+		e.inField = true
+	}
+	e.sawArray = e.sawArray || sawArray
+
+	return nil
+}
+
+// quotedChar expands the character at the current position as inside
+// "...".
+func (e *expander) quotedChar() error {
+	switch {
+	case e.raw[e.pos] == '\\' && e.pos+1 < len(e.raw) && e.raw[e.pos+1] == '\n':
+		// Line continuation
+		e.pos += 2
+	case e.raw[e.pos] == '\\' && e.pos+1 < len(e.raw) && strings.IndexByte("$`\"\\", e.raw[e.pos+1]) >= 0:
+		e.literal(e.raw[e.pos+1:e.pos+2], true)
+		e.pos += 2
+	case e.raw[e.pos] == '$':
+		return e.dollar(true)
+	default:
+		e.literal(e.raw[e.pos:e.pos+1], true)
+		e.pos++
+	}
+
+	return nil
+}
+
+func (e *expander) escaped() {
+	e.pos++
+	if e.pos >= len(e.raw) {
+		e.literal(`\`, false)
+
+		return
+	}
+
+	if e.raw[e.pos] == '\n' {
+		// Line continuation
+		e.pos++
+
+		return
+	}
+
+	e.literal(e.raw[e.pos:e.pos+1], true)
+	e.pos++
+}
+
+// literal adds text to the current field. Quoted text is escaped in
+// pattern and regex modes so it matches literally.
+func (e *expander) literal(text string, quoted bool) {
+	if quoted {
+		text = e.quote(text)
+	}
+
+	e.current.WriteString(text)
+	e.inField = true
+
+	if e.mode == modeFields {
+		if quoted {
+			e.pattern.WriteString(escapeGlob(text))
+		} else {
+			e.pattern.WriteString(text)
+			e.globbing = e.globbing || strings.ContainsAny(text, "*?[")
+		}
+	}
+}
+
+func (e *expander) quote(text string) string {
+	switch e.mode {
+	case modePattern:
+		return escapeGlob(text)
+	case modeRegex:
+		return regexp.QuoteMeta(text)
+	case modeFields, modeString:
+		return text
+	}
+
+	return text
+}
+
+// value adds the result of an expansion. Unquoted results are split into
+// fields on IFS when splitting is enabled.
+func (e *expander) value(text string, quoted bool) {
+	switch {
+	case quoted:
+		e.literal(text, true)
+	case e.mode == modeFields:
+		e.split(text)
+	case text != "":
+		e.literal(text, false)
+	}
+}
+
+// values adds the elements of "${name[@]}": each element is a separate
+// field, the first joined to any text before it.
+func (e *expander) values(list []string, quoted bool) {
+	e.sawArray = true
+
+	if e.mode != modeFields {
+		e.value(strings.Join(list, " "), quoted)
+
+		return
+	}
+
+	for i, item := range list {
+		if i > 0 {
+			e.endField()
+		}
+		e.value(item, quoted)
+	}
+}
+
+// escapeGlob escapes the glob characters in text so that they match
+// literally.
+func escapeGlob(text string) string {
+	var result strings.Builder
+	for _, ch := range text {
+		if strings.ContainsRune(globSpecial, ch) {
+			result.WriteRune('\\')
+		}
+		result.WriteRune(ch)
+	}
+
+	return result.String()
+}
+
+// endField finishes the current field if it has any content, replacing
+// it with the matching pathnames if it is a pattern.
+func (e *expander) endField() {
+	if !e.inField {
+		return
+	}
+
+	if e.globbing && !options.Enabled(options.Noglob) {
+		err := e.globField(e.current.String(), e.pattern.String())
+		if err != nil && e.err == nil {
+			e.err = err
+		}
+	} else {
+		e.fields = append(e.fields, e.current.String())
+	}
+
+	e.current.Reset()
+	e.pattern.Reset()
+	e.inField = false
+	e.globbing = false
+}
+
+// HereDoc returns the body of a here-document. Unless its delimiter was
+// quoted, parameters are expanded and backslash escapes only $, `, \ and
+// newline; quotes have no special meaning.
+func HereDoc(doc *lexer.HereDocument) (string, error) {
+	if doc.Quoted {
+		return doc.Body, nil
+	}
+
+	e := &expander{raw: doc.Body, mode: modeString}
+
+	for e.pos < len(e.raw) {
+		switch {
+		case e.raw[e.pos] == '\\' && e.pos+1 < len(e.raw) && e.raw[e.pos+1] == '\n':
+			e.pos += 2
+		case e.raw[e.pos] == '\\' && e.pos+1 < len(e.raw) && strings.IndexByte("$`\\", e.raw[e.pos+1]) >= 0:
+			e.literal(e.raw[e.pos+1:e.pos+2], true)
+			e.pos += 2
+		case e.raw[e.pos] == '$':
+			err := e.dollar(true)
+			if err != nil {
+				return "", err
+			}
+		default:
+			e.literal(e.raw[e.pos:e.pos+1], true)
+			e.pos++
+		}
+	}
+
+	return e.current.String(), nil
+}
@@ -0,0 +1,239 @@
+package expand
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"dsh/internal/options"
+	"dsh/internal/variables"
+)
+
+func setupVariables(t *testing.T) {
+	t.Helper()
+
+	variables.Set("name", "world")
+	variables.Set("spaced", "  a  b ")
+	variables.SetArray("list", []string{"x", "y z", "w"})
+	variables.SetArray("empty", nil)
+
+	t.Cleanup(func() {
+		for _, name := range []string{"name", "spaced", "list", "empty"} {
+			variables.Unset(name)
+		}
+	})
+}
+
+func TestFields(t *testing.T) {
+	setupVariables(t)
+
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{"plain", []string{"plain"}},
+		{"hello-$name", []string{"hello-world"}},
+		{"${name}s", []string{"worlds"}},
+		{`'$name'`, []string{"$name"}},
+		{`"$name and more"`, []string{"world and more"}},
+		{`\$name`, []string{"$name"}},
+		{`"a\"b\\c\d"`, []string{`a"b\c\d`}},
+		{`""`, []string{""}},
+		{"$unset_variable", nil},
+		{"$spaced", []string{"a", "b"}},
+		{`"$spaced"`, []string{"  a  b "}},
+		{"${list[@]}", []string{"x", "y", "z", "w"}},
+		{`"${list[@]}"`, []string{"x", "y z", "w"}},
+		{`pre"${list[@]}"post`, []string{"prex", "y z", "wpost"}},
+		{`"${list[*]}"`, []string{"x y z w"}},
+		{`"${empty[@]}"`, nil},
+		{"${list[1]}", []string{"y", "z"}},
+		{"${list[-1]}", []string{"w"}},
+		{"${#list[@]}", []string{"3"}},
+		{"${#name}", []string{"5"}},
+		{`"${!list[@]}"`, []string{"0", "1", "2"}},
+		{"${name:1:3}", []string{"orl"}},
+		{"${name: -2}", []string{"ld"}},
+		{`"${list[@]:1}"`, []string{"y z", "w"}},
+		{"$", []string{"$"}},
+	}
+
+	for _, test := range tests {
+		got, err := Fields(test.raw)
+		if err != nil {
+			t.Errorf("Fields(%q) failed: %v", test.raw, err)
+
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Fields(%q) = %q, want %q", test.raw, got, test.expected)
+		}
+	}
+}
+
+func TestFields_IFS(t *testing.T) {
+	variables.Set("IFS", ":")
+	variables.Set("path", "a::b:")
+	t.Cleanup(func() {
+		variables.Unset("IFS")
+		variables.Unset("path")
+	})
+
+	got, err := Fields("$path")
+	if err != nil {
+		t.Fatalf("Fields failed: %v", err)
+	}
+
+	expected := []string{"a", "", "b"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestFields_Positional(t *testing.T) {
+	variables.SetPositional([]string{"a b", "c", "", "d", "e", "f", "g", "h", "i", "j"})
+	t.Cleanup(func() { variables.SetPositional(nil) })
+
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{"$0", []string{"dsh"}},
+		{"$1", []string{"a", "b"}},
+		{`"$1"`, []string{"a b"}},
+		{"${10}", []string{"j"}},
+		{`"$10"`, []string{"a b0"}},
+		{"$#", []string{"10"}},
+		{"${#}", []string{"10"}},
+		{"${#1}", []string{"3"}},
+		{`"$@"`, []string{"a b", "c", "", "d", "e", "f", "g", "h", "i", "j"}},
+		{`"${@}"`, []string{"a b", "c", "", "d", "e", "f", "g", "h", "i", "j"}},
+		{"$*", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+		{`"$*"`, []string{"a b c  d e f g h i j"}},
+		{"${2:0:1}", []string{"c"}},
+	}
+
+	for _, test := range tests {
+		got, err := Fields(test.raw)
+		if err != nil {
+			t.Errorf("Fields(%q) failed: %v", test.raw, err)
+
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Fields(%q) = %q, want %q", test.raw, got, test.expected)
+		}
+	}
+
+	variables.SetPositional(nil)
+	if got, _ := Fields(`"$@"`); got != nil {
+		t.Errorf("Expected no fields for \"$@\" without parameters, got %q", got)
+	}
+}
+
+func TestFields_Default(t *testing.T) {
+	setupVariables(t)
+	variables.Set("blank", "")
+	t.Cleanup(func() {
+		variables.Unset("blank")
+		_ = options.Set(options.Nounset, false)
+	})
+
+	// The default takes the place of an unset parameter even under set -u
+	if err := options.Set(options.Nounset, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{"${name:-other}", []string{"world"}},
+		{"${unset_variable:-other}", []string{"other"}},
+		{"${blank:-other}", []string{"other"}},
+		{"${blank-other}", nil},
+		{"${unset_variable-other}", []string{"other"}},
+		{"${unset_variable:-}", nil},
+		{"${unset_variable:-a  b}", []string{"a", "b"}},
+		{`"${unset_variable:-a  b}"`, []string{"a  b"}},
+		{`${unset_variable:-"a  b"}`, []string{"a  b"}},
+		{"${unset_variable:-$name}", []string{"world"}},
+		{"${unset_variable:-${blank:-in}ner}", []string{"inner"}},
+		{"${list[1]:-other}", []string{"y", "z"}},
+		{"${list[7]:-other}", []string{"other"}},
+		{`"${empty[@]:-none}"`, []string{"none"}},
+		{"${9:-other}", []string{"other"}},
+	}
+
+	for _, test := range tests {
+		got, err := Fields(test.raw)
+		if err != nil {
+			t.Errorf("Fields(%q) failed: %v", test.raw, err)
+
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Fields(%q) = %q, want %q", test.raw, got, test.expected)
+		}
+	}
+}
+
+func TestFields_BadSubstitution(t *testing.T) {
+	for _, raw := range []string{"${", "${name", "${1abc}", "${name/x/y}"} {
+		_, err := Fields(raw)
+		if !errors.Is(err, ErrBadSubstitution) {
+			t.Errorf("Fields(%q): expected ErrBadSubstitution, got %v", raw, err)
+		}
+	}
+}
+
+func TestPatternAndRegex(t *testing.T) {
+	setupVariables(t)
+
+	pattern, err := Pattern(`"*"$name*`)
+	if err != nil {
+		t.Fatalf("Pattern failed: %v", err)
+	}
+	if pattern != `\*world*` {
+		t.Errorf("Expected quoted glob characters to be escaped, got %q", pattern)
+	}
+
+	regex, err := Regex(`"a.b"(c|d)`)
+	if err != nil {
+		t.Fatalf("Regex failed: %v", err)
+	}
+	if regex != `a\.b(c|d)` {
+		t.Errorf("Expected quoted regex characters to be escaped, got %q", regex)
+	}
+}
+
+func TestProcessSubstitution(t *testing.T) {
+	t.Cleanup(func() { SetProcessSubstituter(nil) })
+
+	if got, _ := Fields("<(ls)"); !reflect.DeepEqual(got, []string{"<(ls)"}) {
+		t.Errorf("Expected the word unchanged without a substituter, got %q", got)
+	}
+
+	var commands []string
+	SetProcessSubstituter(func(command string, output bool) (string, error) {
+		commands = append(commands, command)
+		if output {
+			return "/dev/fd/61", nil
+		}
+
+		return "/dev/fd/60", nil
+	})
+
+	got, err := Words([]string{"<(sort 'a b' | uniq)", ">(cat)", `"<(quoted)"`})
+	if err != nil {
+		t.Fatalf("Words failed: %v", err)
+	}
+
+	expected := []string{"/dev/fd/60", "/dev/fd/61", "<(quoted)"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if len(commands) != 2 || commands[0] != "sort 'a b' | uniq" {
+		t.Errorf("Unexpected commands %q", commands)
+	}
+}
@@ -0,0 +1,165 @@
+package expand
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dsh/internal/options"
+)
+
+// ErrNoMatch indicates a pattern that matched no files under failglob.
+var ErrNoMatch = errors.New("no match")
+
+// globField replaces a field holding unquoted glob characters with the
+// paths it matches. Without a match the field is kept as it is, dropped
+// under nullglob, or an error under failglob.
+func (e *expander) globField(field, pattern string) error {
+	matches := pathnames(pattern)
+
+	switch {
+	case len(matches) > 0:
+		e.fields = append(e.fields, matches...)
+	case options.Enabled(options.Failglob):
+		return fmt.Errorf("%w: %s", ErrNoMatch, field)
+	case !options.Enabled(options.Nullglob):
+		e.fields = append(e.fields, field)
+	}
+
+	return nil
+}
+
+// pathnames returns the existing paths matching pattern, sorted. Each
+// component is matched against directory entries; a leading dot must be
+// matched explicitly unless dotglob is set.
+func pathnames(pattern string) []string {
+	candidates := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		candidates = []string{"/"}
+	}
+
+	components := strings.Split(strings.TrimLeft(pattern, "/"), "/")
+	for i, component := range components {
+		if component == "" {
+			if i == len(components)-1 {
+				// A trailing slash matches directories only
+				candidates = directories(candidates)
+			}
+
+			continue
+		}
+
+		if !hasGlobSpecial(component) {
+			name := removeEscapes(component)
+			for j, candidate := range candidates {
+				candidates[j] = joinPath(candidate, name)
+			}
+
+			continue
+		}
+
+		re, err := regexp.Compile("^" + globToRegex(component) + "$")
+		if err != nil {
+			return nil
+		}
+
+		candidates = matchEntries(candidates, component, re)
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	var matches []string
+
+	for _, candidate := range candidates {
+		if _, err := os.Lstat(candidate); err == nil {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+
+	return matches
+}
+
+// matchEntries returns the entries of each candidate directory whose
+// names match one pattern component.
+func matchEntries(candidates []string, component string, re *regexp.Regexp) []string {
+	showHidden := strings.HasPrefix(component, ".") || strings.HasPrefix(component, `\.`) ||
+		options.Enabled(options.Dotglob)
+
+	var matched []string
+
+	for _, candidate := range candidates {
+		dir := candidate
+		if dir == "" {
+			dir = "."
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") && !showHidden {
+				continue
+			}
+			if re.MatchString(name) {
+				matched = append(matched, joinPath(candidate, name))
+			}
+		}
+	}
+
+	return matched
+}
+
+func directories(candidates []string) []string {
+	var dirs []string
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			dirs = append(dirs, strings.TrimSuffix(candidate, "/")+"/")
+		}
+	}
+
+	return dirs
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+
+	return dir + "/" + name
+}
+
+// hasGlobSpecial reports whether pattern has an unescaped *, ? or [.
+func hasGlobSpecial(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeEscapes(pattern string) string {
+	var result strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		result.WriteByte(pattern[i])
+	}
+
+	return result.String()
+}
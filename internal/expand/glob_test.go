@@ -0,0 +1,113 @@
+package expand
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"dsh/internal/options"
+)
+
+func setupGlobDir(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.go", ".hidden.txt", "sub/d.txt", "sub/e.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+}
+
+func TestFields_Pathnames(t *testing.T) {
+	setupGlobDir(t)
+
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{"*.txt", []string{"a.txt", "b.txt"}},
+		{"[ab].*", []string{"a.txt", "b.txt"}},
+		{"?.go", []string{"c.go"}},
+		{".*.txt", []string{".hidden.txt"}},
+		{"*/*.txt", []string{"sub/d.txt"}},
+		{"*/", []string{"sub/"}},
+		{"sub/*", []string{"sub/d.txt", "sub/e.go"}},
+		{"'*'.txt", []string{"*.txt"}},
+		{`\*.txt`, []string{"*.txt"}},
+		{"*.none", []string{"*.none"}},
+		{"x=*", []string{"x=*"}},
+	}
+
+	for _, test := range tests {
+		got, err := Fields(test.raw)
+		if err != nil {
+			t.Errorf("Fields(%q): unexpected error %v", test.raw, err)
+
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Fields(%q): expected %q, got %q", test.raw, test.expected, got)
+		}
+	}
+}
+
+func TestFields_GlobOptions(t *testing.T) {
+	setupGlobDir(t)
+	t.Cleanup(func() {
+		for _, name := range []string{options.Noglob, options.Dotglob, options.Nullglob, options.Failglob} {
+			_ = options.Set(name, false)
+		}
+	})
+
+	_ = options.Set(options.Dotglob, true)
+	if got, _ := Fields("*.txt"); !reflect.DeepEqual(got, []string{".hidden.txt", "a.txt", "b.txt"}) {
+		t.Errorf("dotglob: unexpected matches %q", got)
+	}
+
+	_ = options.Set(options.Noglob, true)
+	if got, _ := Fields("*.txt"); !reflect.DeepEqual(got, []string{"*.txt"}) {
+		t.Errorf("noglob: expected the pattern unchanged, got %q", got)
+	}
+	_ = options.Set(options.Noglob, false)
+
+	_ = options.Set(options.Nullglob, true)
+	if got, _ := Fields("*.none"); len(got) != 0 {
+		t.Errorf("nullglob: expected no fields, got %q", got)
+	}
+
+	_ = options.Set(options.Failglob, true)
+	if _, err := Fields("*.none"); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("failglob: expected ErrNoMatch, got %v", err)
+	}
+}
+
+func TestFields_Nounset(t *testing.T) {
+	setupVariables(t)
+	t.Cleanup(func() { _ = options.Set(options.Nounset, false) })
+
+	_ = options.Set(options.Nounset, true)
+
+	for _, raw := range []string{"$dsh_unset_name", "${dsh_unset_name}", "${list[7]}"} {
+		if _, err := Fields(raw); !errors.Is(err, ErrUnboundVariable) {
+			t.Errorf("Fields(%q): expected ErrUnboundVariable, got %v", raw, err)
+		}
+	}
+
+	for _, raw := range []string{"$name", "${list[1]}", "${list[@]}", "${#dsh_unset_name}", "$-"} {
+		if _, err := Fields(raw); err != nil {
+			t.Errorf("Fields(%q): unexpected error %v", raw, err)
+		}
+	}
+
+	if got, _ := Fields("$-"); !reflect.DeepEqual(got, []string{"u"}) {
+		t.Errorf("Expected $- to be %q, got %q", "u", got)
+	}
+}
@@ -0,0 +1,116 @@
+package expand
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchPattern reports whether value matches the shell glob pattern. Unlike
+// pathname expansion, * and ? also match slashes.
+func MatchPattern(pattern, value string) bool {
+	re, err := regexp.Compile("^" + globToRegex(pattern) + "$")
+	if err != nil {
+		return pattern == value
+	}
+
+	return re.MatchString(value)
+}
+
+// globToRegex translates *, ? and [...] into regular expression syntax and
+// escapes everything else.
+func globToRegex(pattern string) string {
+	var result strings.Builder
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			result.WriteString(".*")
+		case '?':
+			result.WriteString(".")
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			result.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			end := bracketEnd(runes, i)
+			if end < 0 {
+				result.WriteString(`\[`)
+
+				continue
+			}
+			result.WriteString(bracketToRegex(runes[i+1 : end]))
+			i = end
+		default:
+			result.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+
+	return result.String()
+}
+
+// bracketEnd returns the index of the ] closing the bracket expression that
+// starts at start, or -1 if there is none.
+func bracketEnd(runes []rune, start int) int {
+	i := start + 1
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		i++
+	}
+	if i < len(runes) && runes[i] == ']' {
+		i++
+	}
+
+	for ; i < len(runes); i++ {
+		if runes[i] == ']' {
+			return i
+		}
+		if class := characterClassEnd(runes, i); class > 0 {
+			i = class
+		}
+	}
+
+	return -1
+}
+
+// characterClassEnd returns the index of the ] ending a [:name:] class
+// that starts at start, or -1 if there is none.
+func characterClassEnd(runes []rune, start int) int {
+	if start+1 >= len(runes) || runes[start] != '[' || runes[start+1] != ':' {
+		return -1
+	}
+
+	for i := start + 2; i+1 < len(runes); i++ {
+		if runes[i] == ':' && runes[i+1] == ']' {
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+func bracketToRegex(body []rune) string {
+	var result strings.Builder
+	result.WriteString("[")
+
+	if len(body) > 0 && (body[0] == '!' || body[0] == '^') {
+		result.WriteString("^")
+		body = body[1:]
+	}
+
+	for i := 0; i < len(body); i++ {
+		if class := characterClassEnd(body, i); class > 0 {
+			result.WriteString(string(body[i : class+1]))
+			i = class
+
+			continue
+		}
+		if body[i] == '\\' || body[i] == ']' || body[i] == '[' {
+			result.WriteRune('\\')
+		}
+		result.WriteRune(body[i])
+	}
+	result.WriteString("]")
+
+	return result.String()
+}
@@ -0,0 +1,537 @@
+package expand
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"dsh/internal/jobs"
+	"dsh/internal/lexer"
+	"dsh/internal/options"
+	"dsh/internal/variables"
+)
+
+var (
+	// ErrBadSubstitution indicates a ${...} expansion the shell does not support.
+	ErrBadSubstitution = errors.New("bad substitution")
+	// ErrUnboundVariable indicates a reference to an unset variable under nounset.
+	ErrUnboundVariable = errors.New("unbound variable")
+)
+
+// dollar expands the parameter starting at the current $. A $ that does
+// not start an expansion is kept literally.
+func (e *expander) dollar(quoted bool) error {
+	next := byte(0)
+	if e.pos+1 < len(e.raw) {
+		next = e.raw[e.pos+1]
+	}
+
+	switch {
+	case next == '{':
+		end := matchingBrace(e.raw, e.pos+1)
+		if end < 0 {
+			return fmt.Errorf("%s: %w", e.raw[e.pos:], ErrBadSubstitution)
+		}
+		body := e.raw[e.pos+2 : end]
+		e.pos = end + 1
+
+		return e.braced(body, quoted)
+	case next == '?':
+		e.pos += 2
+		e.value(strconv.Itoa(variables.LastStatus()), quoted)
+	case next == '$':
+		e.pos += 2
+		e.value(strconv.Itoa(os.Getpid()), quoted)
+	case next == '!':
+		e.pos += 2
+		if pid := jobs.LastPid(); pid != 0 {
+			e.value(strconv.Itoa(pid), quoted)
+		} else {
+			e.value("", quoted)
+		}
+	case isDigit(next):
+		value, err := lookup(string(next))
+		if err != nil {
+			return err
+		}
+		e.pos += 2
+		e.value(value, quoted)
+	case next == '#':
+		e.pos += 2
+		e.value(strconv.Itoa(len(variables.Positional())), quoted)
+	case next == '@' || next == '*':
+		e.pos += 2
+		e.positional(next == '*', quoted)
+	case next == '-':
+		e.pos += 2
+		e.value(options.Flags(), quoted)
+	case next == '_' || isLetter(next):
+		end := e.pos + 1
+		for end < len(e.raw) && (e.raw[end] == '_' || isLetter(e.raw[end]) || isDigit(e.raw[end])) {
+			end++
+		}
+		value, err := lookup(e.raw[e.pos+1 : end])
+		if err != nil {
+			return err
+		}
+		e.pos = end
+		e.value(value, quoted)
+	default:
+		e.literal("$", quoted)
+		e.pos++
+	}
+
+	return nil
+}
+
+// braced expands the body of ${...}.
+func (e *expander) braced(body string, quoted bool) error {
+	badSubstitution := fmt.Errorf("${%s}: %w", body, ErrBadSubstitution)
+
+	switch {
+	case body == "#":
+		return e.count(len(variables.Positional()), quoted)
+	case body == "@" || body == "*":
+		e.positional(body == "*", quoted)
+
+		return nil
+	case strings.HasPrefix(body, "#"):
+		return e.length(body[1:], quoted, badSubstitution)
+	case strings.HasPrefix(body, "!"):
+		return e.indirect(body[1:], quoted, badSubstitution)
+	}
+
+	name, subscript, rest, ok := splitParameter(body)
+	if !ok {
+		return badSubstitution
+	}
+
+	switch {
+	case rest == "":
+		return e.parameter(name, subscript, quoted)
+	case strings.HasPrefix(rest, ":-"):
+		return e.orDefault(name, subscript, rest[2:], true, quoted)
+	case strings.HasPrefix(rest, "-"):
+		return e.orDefault(name, subscript, rest[1:], false, quoted)
+	case !strings.HasPrefix(rest, ":"):
+		return badSubstitution
+	}
+
+	return e.slice(name, subscript, rest[1:], quoted, badSubstitution)
+}
+
+// parameter expands ${name}, ${name[sub]}, ${name[@]} and ${name[*]}.
+func (e *expander) parameter(name, subscript string, quoted bool) error {
+	switch subscript {
+	case "":
+		value, err := lookup(name)
+		if err != nil {
+			return err
+		}
+		e.value(value, quoted)
+	case "@":
+		e.values(variables.GetArray(name), quoted)
+	case "*":
+		e.value(strings.Join(variables.GetArray(name), ifsJoiner()), quoted)
+	default:
+		key, err := Subscript(name, subscript)
+		if err != nil {
+			return err
+		}
+		value, ok := variables.GetElement(name, key)
+		if !ok && options.Enabled(options.Nounset) {
+			return fmt.Errorf("%s[%s]: %w", name, subscript, ErrUnboundVariable)
+		}
+		e.value(value, quoted)
+	}
+
+	return nil
+}
+
+// orDefault expands ${name-word}, which is word when name is unset, and
+// ${name:-word}, which is also word when name is empty. The parameter is
+// not looked up under nounset when word takes its place.
+func (e *expander) orDefault(name, subscript, word string, orEmpty, quoted bool) error {
+	var value string
+	var set bool
+
+	switch subscript {
+	case "":
+		value, set = parameterValue(name)
+	case "@", "*":
+		elements := variables.GetArray(name)
+		value, set = strings.Join(elements, ""), len(elements) > 0
+	default:
+		key, err := Subscript(name, subscript)
+		if err != nil {
+			return err
+		}
+		value, set = variables.GetElement(name, key)
+	}
+
+	if set && (!orEmpty || value != "") {
+		return e.parameter(name, subscript, quoted)
+	}
+
+	return e.word(word, quoted)
+}
+
+// word expands the word of ${name:-word} in place of the parameter, as if
+// it were part of the word around it, so inside "..." it is quoted.
+func (e *expander) word(word string, quoted bool) error {
+	raw, pos, defaulted := e.raw, e.pos, e.defaulted
+	e.raw, e.pos, e.defaulted = word, 0, true
+	defer func() { e.raw, e.pos, e.defaulted = raw, pos, defaulted }()
+
+	if !quoted {
+		e.expandLeadingTilde()
+
+		return e.unquoted()
+	}
+
+	for e.pos < len(e.raw) {
+		if err := e.quotedChar(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// positional expands $@ and $*. Each positional parameter is a separate
+// field, except in "$*", which joins them with the first character of IFS.
+func (e *expander) positional(joined, quoted bool) {
+	args := variables.Positional()
+	if joined && quoted {
+		e.value(strings.Join(args, ifsJoiner()), quoted)
+
+		return
+	}
+
+	e.values(args, quoted)
+}
+
+// lookup returns the value of a variable or positional parameter, which
+// under nounset must be set.
+func lookup(name string) (string, error) {
+	value, ok := parameterValue(name)
+	if !ok && options.Enabled(options.Nounset) {
+		return "", fmt.Errorf("%s: %w", name, ErrUnboundVariable)
+	}
+
+	return value, nil
+}
+
+// parameterValue returns the value of a variable or, for a name of digits,
+// of $0 or a positional parameter, and whether it is set.
+func parameterValue(name string) (string, bool) {
+	if name == "" || !isDigit(name[0]) {
+		return variables.Get(name)
+	}
+
+	n, err := strconv.Atoi(name)
+	if err != nil {
+		return "", false
+	}
+	if n == 0 {
+		return variables.ScriptName(), true
+	}
+
+	args := variables.Positional()
+	if n > len(args) {
+		return "", false
+	}
+
+	return args[n-1], true
+}
+
+// length expands ${#name}, ${#name[sub]} and the element count ${#name[@]}.
+func (e *expander) length(body string, quoted bool, badSubstitution error) error {
+	name, subscript, rest, ok := splitParameter(body)
+	if !ok || rest != "" {
+		return badSubstitution
+	}
+
+	var value string
+
+	switch subscript {
+	case "@", "*":
+		return e.count(len(variables.GetArray(name)), quoted)
+	case "":
+		value, _ = parameterValue(name)
+	default:
+		key, err := Subscript(name, subscript)
+		if err != nil {
+			return err
+		}
+		value, _ = variables.GetElement(name, key)
+	}
+
+	return e.count(utf8.RuneCountInString(value), quoted)
+}
+
+func (e *expander) count(n int, quoted bool) error {
+	e.value(strconv.Itoa(n), quoted)
+
+	return nil
+}
+
+// indirect expands the key list ${!name[@]} and the indirection ${!name}.
+func (e *expander) indirect(body string, quoted bool, badSubstitution error) error {
+	name, subscript, rest, ok := splitParameter(body)
+	if !ok || rest != "" {
+		return badSubstitution
+	}
+
+	switch subscript {
+	case "@":
+		e.values(variables.Keys(name), quoted)
+	case "*":
+		e.value(strings.Join(variables.Keys(name), ifsJoiner()), quoted)
+	case "":
+		target, _ := variables.Get(name)
+		if target == "" {
+			e.value("", quoted)
+
+			return nil
+		}
+
+		targetName, targetSubscript, targetRest, valid := splitParameter(target)
+		if !valid || targetRest != "" {
+			return fmt.Errorf("%s: %w", target, ErrBadSubstitution)
+		}
+
+		return e.parameter(targetName, targetSubscript, quoted)
+	default:
+		return badSubstitution
+	}
+
+	return nil
+}
+
+// slice expands ${name:offset:length} on a string and
+// ${name[@]:offset:length} on the elements of an array. For indexed arrays
+// the offset is an index, so unset elements are skipped.
+func (e *expander) slice(name, subscript, spec string, quoted bool, badSubstitution error) error {
+	offsetText, lengthText, hasLength := strings.Cut(spec, ":")
+
+	offset, err := arithmeticValue(offsetText)
+	if err != nil {
+		return badSubstitution
+	}
+
+	length := -1
+	if hasLength {
+		length, err = arithmeticValue(lengthText)
+		if err != nil || length < 0 {
+			return badSubstitution
+		}
+	}
+
+	if subscript == "@" || subscript == "*" {
+		elements := arrayFrom(name, offset)
+		if length >= 0 && length < len(elements) {
+			elements = elements[:length]
+		}
+
+		if subscript == "*" {
+			e.value(strings.Join(elements, ifsJoiner()), quoted)
+		} else {
+			e.values(elements, quoted)
+		}
+
+		return nil
+	}
+
+	var value string
+	if subscript == "" {
+		value, _ = parameterValue(name)
+	} else {
+		key, err := Subscript(name, subscript)
+		if err != nil {
+			return err
+		}
+		value, _ = variables.GetElement(name, key)
+	}
+
+	e.value(substring(value, offset, length), quoted)
+
+	return nil
+}
+
+// arrayFrom returns the elements of name starting at offset. Indexed
+// arrays are sliced by index, everything else by position.
+func arrayFrom(name string, offset int) []string {
+	keys := variables.Keys(name)
+	values := variables.GetArray(name)
+
+	if variables.KindOf(name) != variables.Indexed {
+		if offset < 0 {
+			offset += len(values)
+		}
+
+		return values[min(max(offset, 0), len(values)):]
+	}
+
+	if offset < 0 && len(keys) > 0 {
+		last, _ := strconv.Atoi(keys[len(keys)-1])
+		offset += last + 1
+	}
+
+	for i, key := range keys {
+		index, _ := strconv.Atoi(key)
+		if index >= offset {
+			return values[i:]
+		}
+	}
+
+	return nil
+}
+
+// substring returns length runes of value starting at offset. A negative
+// offset counts back from the end, and a negative length means the rest.
+func substring(value string, offset, length int) string {
+	runes := []rune(value)
+	if offset < 0 {
+		offset += len(runes)
+	}
+	offset = min(max(offset, 0), len(runes))
+
+	end := len(runes)
+	if length >= 0 {
+		end = min(offset+length, len(runes))
+	}
+
+	return string(runes[offset:end])
+}
+
+// Subscript expands an array subscript. For indexed arrays a bare
+// variable name stands for its value, so ${arr[i]} works as in bash.
+func Subscript(name, subscript string) (string, error) {
+	key, err := String(subscript)
+	if err != nil {
+		return "", err
+	}
+
+	if variables.KindOf(name) == variables.Associative {
+		return key, nil
+	}
+
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "$") {
+		key = key[1:]
+	}
+
+	if lexer.IsName(key) {
+		value, _ := variables.Get(key)
+		if value == "" {
+			return "0", nil
+		}
+
+		return value, nil
+	}
+
+	return key, nil
+}
+
+// arithmeticValue parses a slice offset or length. Only integers and
+// variable names are supported until the shell gains arithmetic.
+func arithmeticValue(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if lexer.IsName(text) {
+		text, _ = variables.Get(text)
+		if text == "" {
+			return 0, nil
+		}
+	}
+
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", text, ErrBadSubstitution)
+	}
+
+	return n, nil
+}
+
+// splitParameter splits "name", "name[sub]" and "name[sub]rest" into their
+// parts. The rest is whatever follows the name and subscript. A number,
+// naming a positional parameter, has no subscript.
+func splitParameter(body string) (string, string, string, bool) {
+	if body != "" && isDigit(body[0]) {
+		end := 1
+		for end < len(body) && isDigit(body[end]) {
+			end++
+		}
+
+		return body[:end], "", body[end:], !strings.HasPrefix(body[end:], "[")
+	}
+
+	end := 0
+	for end < len(body) && (body[end] == '_' || isLetter(body[end]) || (end > 0 && isDigit(body[end]))) {
+		end++
+	}
+
+	name := body[:end]
+	if !lexer.IsName(name) {
+		return "", "", "", false
+	}
+
+	rest := body[end:]
+	if !strings.HasPrefix(rest, "[") {
+		return name, "", rest, true
+	}
+
+	closing := strings.IndexByte(rest, ']')
+	if closing < 0 {
+		return "", "", "", false
+	}
+
+	return name, rest[1:closing], rest[closing+1:], true
+}
+
+// matchingBrace returns the index of the } closing the { at start, or -1.
+func matchingBrace(raw string, start int) int {
+	depth := 0
+
+	for i := start; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// ifsJoiner returns the separator for ${name[*]}: the first character of
+// IFS, a space when IFS is unset.
+func ifsJoiner() string {
+	ifs, ok := variables.Get("IFS")
+	if !ok {
+		return " "
+	}
+
+	if ifs == "" {
+		return ""
+	}
+
+	return ifs[:1]
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,85 @@
+package expand
+
+// ProcessSubstituter starts the command of a process substitution and
+// returns the path that names its pipe. With output set, as for >(cmd),
+// the command reads what is written to the path; otherwise, as for
+// <(cmd), reading the path yields the command's output.
+type ProcessSubstituter func(command string, output bool) (string, error)
+
+// processSubstituter is installed by the shell, since running commands
+// belongs to the executor, which depends on this package.
+var processSubstituter ProcessSubstituter //nolint:gochecknoglobals // Hook installed once at startup
+
+// SetProcessSubstituter installs the function that runs <(...) and >(...).
+// Until one is installed, process substitutions are left as they are.
+func SetProcessSubstituter(fn ProcessSubstituter) {
+	processSubstituter = fn
+}
+
+// processSubstitution replaces an unquoted <(command) or >(command) with
+// the path returned by the installed substituter.
+func (e *expander) processSubstitution() error {
+	end := matchingParen(e.raw, e.pos+1)
+	if processSubstituter == nil || end < 0 {
+		e.literal(e.raw[e.pos:e.pos+1], false)
+		e.pos++
+
+		return nil
+	}
+
+	output := e.raw[e.pos] == '>'
+	command := e.raw[e.pos+2 : end]
+	e.pos = end + 1
+
+	path, err := processSubstituter(command, output)
+	if err != nil {
+		return err
+	}
+	e.literal(path, true)
+
+	return nil
+}
+
+// matchingParen returns the index of the ) closing the ( at start, or -1.
+// Parentheses inside quotes do not count.
+func matchingParen(raw string, start int) int {
+	depth := 0
+
+	for i := start; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := indexFrom(raw, i+1, '\'')
+			if end < 0 {
+				return -1
+			}
+			i = end
+		case '"':
+			for i++; i < len(raw) && raw[i] != '"'; i++ {
+				if raw[i] == '\\' {
+					i++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func indexFrom(s string, start int, ch byte) int {
+	for i := start; i < len(s); i++ {
+		if s[i] == ch {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,51 @@
+package expand
+
+import (
+	"strings"
+
+	"dsh/internal/variables"
+)
+
+// defaultIFS is used for field splitting when IFS is unset.
+const defaultIFS = " \t\n"
+
+// split adds an unquoted expansion to the fields, splitting it on the
+// characters of IFS. Runs of IFS whitespace separate fields; every other
+// IFS character ends a field, even an empty one.
+func (e *expander) split(text string) {
+	ifs, ok := variables.Get("IFS")
+	if !ok {
+		ifs = defaultIFS
+	}
+
+	if ifs == "" {
+		if text != "" {
+			e.literal(text, false)
+		}
+
+		return
+	}
+
+	// delimited is set when whitespace has just ended a field, so that a
+	// non-whitespace delimiter right after it does not add an empty field
+	delimited := false
+
+	for _, ch := range text {
+		switch {
+		case !strings.ContainsRune(ifs, ch):
+			e.literal(string(ch), false)
+			delimited = false
+		case strings.ContainsRune(defaultIFS, ch):
+			if e.inField {
+				e.endField()
+				delimited = true
+			}
+		default:
+			if !delimited {
+				e.inField = true
+				e.endField()
+			}
+			delimited = false
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package expand
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"dsh/internal/dirs"
+	"dsh/internal/variables"
+)
+
+// Tilde expands a leading tilde (~ or ~user) in a path according to POSIX
+// rules, and ~+, ~- and ~N to PWD, OLDPWD and entries of the directory
+// stack. Paths whose user or entry is unknown are returned unchanged.
+func Tilde(path string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+
+	// Handle ~ alone or ~/...
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return path // Return unchanged if HOME not set
+		}
+		return strings.Replace(path, "~", home, 1)
+	}
+
+	// Handle ~username or ~username/...
+	slashIndex := strings.Index(path, "/")
+	var username string
+	if slashIndex == -1 {
+		username = path[1:] // Everything after ~
+	} else {
+		username = path[1:slashIndex] // Between ~ and /
+	}
+
+	if dir, ok := stackTilde(username); ok {
+		return dir + path[1+len(username):]
+	}
+
+	u, err := user.Lookup(username)
+	if err != nil {
+		return path // Return unchanged if user not found
+	}
+
+	return strings.Replace(path, "~"+username, u.HomeDir, 1)
+}
+
+// stackTilde expands the part after ~ when it names a directory: + for
+// PWD, - for OLDPWD, and N, +N or -N for an entry of the directory stack
+// as dirs +N and dirs -N number them.
+func stackTilde(name string) (string, bool) {
+	switch name {
+	case "+":
+		return variables.Get("PWD")
+	case "-":
+		return variables.Get("OLDPWD")
+	case "":
+		return "", false
+	}
+
+	fromBottom := name[0] == '-'
+	if name[0] == '+' || name[0] == '-' {
+		name = name[1:]
+	}
+
+	if name == "" || strings.Trim(name, "0123456789") != "" {
+		return "", false
+	}
+
+	n, err := strconv.Atoi(name)
+	if err != nil {
+		return "", false
+	}
+
+	return dirs.Entry(n, fromBottom)
+}
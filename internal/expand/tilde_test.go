@@ -1,8 +1,10 @@
-package parser
+package expand
 
 import (
 	"os/user"
 	"testing"
+
+	"dsh/internal/dirs"
 )
 
 func TestExpandTilde(t *testing.T) {
@@ -23,9 +25,9 @@ func TestExpandTilde(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		result := expandTilde(test.input)
+		result := Tilde(test.input)
 		if result != test.expected {
-			t.Errorf("expandTilde(%q) = %q, want %q", test.input, result, test.expected)
+			t.Errorf("Tilde(%q) = %q, want %q", test.input, result, test.expected)
 		}
 	}
 }
@@ -50,9 +52,38 @@ func TestExpandTildeUser(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		result := expandTilde(test.input)
+		result := Tilde(test.input)
 		if result != test.expected {
-			t.Errorf("expandTilde(%q) = %q, want %q", test.input, result, test.expected)
+			t.Errorf("Tilde(%q) = %q, want %q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestExpandTildeDirectoryStack(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("OLDPWD", "/previous")
+	t.Cleanup(func() { dirs.SetStack(nil) })
+
+	current := dirs.Current()
+	dirs.SetStack([]string{"/first", "/second"})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"~+", current},
+		{"~-/file", "/previous/file"},
+		{"~0", current},
+		{"~1/dir", "/first/dir"},
+		{"~+2", "/second"},
+		{"~-0", "/second"},
+		{"~-2", current},
+		{"~3", "~3"},
+	}
+
+	for _, test := range tests {
+		if result := Tilde(test.input); result != test.expected {
+			t.Errorf("Tilde(%q) = %q, want %q", test.input, result, test.expected)
 		}
 	}
 }
@@ -0,0 +1,454 @@
+// Package highlight colours the command line as it is typed. Commands
+// that exist are green and unknown ones red, builtins, keywords, strings,
+// variables, redirections, operators and comments each have a colour of
+// their own, and paths that exist are underlined. DSH_HIGHLIGHT changes
+// the styles, as in DSH_HIGHLIGHT='command=brightgreen,bold:comment=none'.
+package highlight
+
+import (
+	"os"
+	"strings"
+
+	"dsh/internal/builtins"
+	"dsh/internal/commands"
+	"dsh/internal/expand"
+	"dsh/internal/lexer"
+	"dsh/internal/parser"
+	"dsh/internal/terminal"
+	"dsh/internal/variables"
+)
+
+// Kind is what a part of the command line is, which decides its style.
+type Kind int
+
+// The kinds of text the highlighter tells apart.
+const (
+	Plain Kind = iota
+	Command
+	Unknown
+	Builtin
+	Keyword
+	String
+	Variable
+	Redirection
+	Operator
+	Comment
+	Path
+)
+
+// kindNames returns the names DSH_HIGHLIGHT gives the kinds, indexed by
+// kind.
+func kindNames() []string {
+	return []string{
+		"plain", "command", "unknown", "builtin", "keyword", "string",
+		"variable", "redirection", "operator", "comment", "path",
+	}
+}
+
+// defaultStyle returns the style of kind unless DSH_HIGHLIGHT changes it.
+func defaultStyle(kind Kind) terminal.Style {
+	switch kind {
+	case Command:
+		return terminal.Style{Foreground: terminal.ColorGreen}
+	case Unknown:
+		return terminal.Style{Foreground: terminal.ColorRed}
+	case Builtin:
+		return terminal.Style{Foreground: terminal.ColorCyan}
+	case Keyword:
+		return terminal.Style{Foreground: terminal.ColorMagenta}
+	case String:
+		return terminal.Style{Foreground: terminal.ColorYellow}
+	case Variable:
+		return terminal.Style{Foreground: terminal.ColorBlue}
+	case Redirection:
+		return terminal.Style{Foreground: terminal.ColorBrightMagenta}
+	case Operator:
+		return terminal.Style{Foreground: terminal.ColorBrightCyan}
+	case Comment:
+		return terminal.Style{Foreground: terminal.ColorBrightBlack}
+	case Path:
+		return terminal.Style{Underline: true}
+	}
+
+	return terminal.Style{}
+}
+
+// span is a part of the line, from byte offset start to end, of one kind.
+type span struct {
+	start int
+	end   int
+	kind  Kind
+}
+
+// Highlighter colours command lines. The line editor redraws the whole
+// line on each key, so it remembers which commands and files it has
+// looked up, until Reset, and the last line it coloured; lexing the line
+// again is cheap next to searching PATH.
+type Highlighter struct {
+	colors *terminal.ColorManager
+	found  map[string]Kind
+	exists map[string]bool
+	// spec is the DSH_HIGHLIGHT that styles were read from.
+	spec   string
+	styles []terminal.Style
+	// lastText was coloured as lastLine.
+	lastText string
+	lastLine string
+}
+
+// New returns a highlighter with the default styles.
+func New() *Highlighter {
+	h := &Highlighter{colors: terminal.NewColorManager()}
+	h.Reset()
+	h.styles = parseStyles("")
+
+	return h
+}
+
+// Reset forgets the commands and files looked up, which running a command
+// may have changed.
+func (h *Highlighter) Reset() {
+	h.found = map[string]Kind{}
+	h.exists = map[string]bool{}
+	h.lastText, h.lastLine = "", ""
+}
+
+// Line returns text with the escape sequences that colour it.
+func (h *Highlighter) Line(text string) string {
+	spec, _ := variables.Get("DSH_HIGHLIGHT")
+	if spec != h.spec {
+		h.spec, h.styles = spec, parseStyles(spec)
+		h.lastText, h.lastLine = "", ""
+	}
+
+	if text == h.lastText && h.lastLine != "" {
+		return h.lastLine
+	}
+
+	var line strings.Builder
+	done := 0
+	for _, part := range h.spans(text) {
+		if part.start == part.end {
+			continue
+		}
+		line.WriteString(text[done:part.start])
+		line.WriteString(h.colors.StyleText(text[part.start:part.end], h.styles[part.kind]))
+		done = part.end
+	}
+	line.WriteString(text[done:])
+
+	h.lastText, h.lastLine = text, line.String()
+
+	return h.lastLine
+}
+
+// spans splits text into the parts to colour, in order. Words are sorted
+// by where they stand: the first word of a command is a keyword, an
+// assignment or the command itself, and the rest are its arguments.
+func (h *Highlighter) spans(text string) []span {
+	var spans []span
+
+	lx := lexer.New(text)
+	commandPosition := true
+	// target is set when the next word is the file of a redirection, and
+	// conditional between [[ and ]]. heading counts down the words after
+	// for or case: the name or subject, then in.
+	target, conditional, heading := false, false, 0
+	previous := 0
+
+	for {
+		token := lx.NextToken()
+		start, end := token.Pos.Offset, lx.Pos().Offset
+		spans = append(spans, comments(text, previous, start)...)
+		previous = end
+
+		if token.Type == lexer.EOF || end <= start {
+			break
+		}
+
+		switch token.Type {
+		case lexer.Word:
+			switch {
+			case target:
+				spans = append(spans, h.argument(token, start, end)...)
+				target = false
+			case heading == 2:
+				spans = append(spans, words(token.Raw, start)...)
+				heading--
+			case heading == 1 && token.Raw == "in":
+				spans = append(spans, span{start, end, Keyword})
+				heading--
+			case conditional && token.Raw == "]]":
+				spans = append(spans, span{start, end, Keyword})
+				conditional = false
+			case commandPosition && parser.IsReservedWord(token.Raw):
+				spans = append(spans, span{start, end, Keyword})
+				switch token.Raw {
+				case "for":
+					heading, commandPosition = 2, false
+				case "[[":
+					conditional, commandPosition = true, false
+				case "]]", "fi", "done", "}":
+					commandPosition = false
+				}
+			case commandPosition && expand.IsAssignment(token.Raw):
+				name := strings.IndexByte(token.Raw, '=')
+				spans = append(spans, span{start, start + name, Variable})
+				spans = append(spans, words(token.Raw[name+1:], start+name+1)...)
+			case commandPosition:
+				spans = append(spans, h.command(token, start, end)...)
+				commandPosition = false
+			default:
+				heading = 0
+				spans = append(spans, h.argument(token, start, end)...)
+			}
+		case lexer.HereDoc, lexer.RedirectOut, lexer.RedirectIn, lexer.RedirectAppend,
+			lexer.RedirectClobber, lexer.RedirectDupOut, lexer.RedirectDupIn:
+			spans = append(spans, span{start, end, Redirection})
+			target = true
+		case lexer.Newline:
+			// Any here-document bodies follow the newline
+			spans = append(spans, span{start + 1, end, String})
+			commandPosition, heading = true, 0
+		default:
+			spans = append(spans, span{start, end, Operator})
+			commandPosition, heading = true, 0
+		}
+	}
+
+	return spans
+}
+
+// command returns the spans of the command word token: all of it in the
+// colour of a builtin, a command or an unknown name. A word that is quoted
+// or expanded is only known when it runs, so its parts are coloured as in
+// an argument.
+func (h *Highlighter) command(token lexer.Token, start, end int) []span {
+	if strings.ContainsAny(token.Raw, "$`'\"\\") {
+		return words(token.Raw, start)
+	}
+
+	kind, ok := h.found[token.Value]
+	if !ok {
+		kind = Unknown
+		if builtins.IsBuiltin(token.Value) {
+			kind = Builtin
+		} else if _, found := commands.Find(token.Value); found {
+			kind = Command
+		}
+		h.found[token.Value] = kind
+	}
+
+	return []span{{start, end, kind}}
+}
+
+// argument returns the spans of an argument word: the whole of it if it
+// names a file that exists, or else its strings and variables.
+func (h *Highlighter) argument(token lexer.Token, start, end int) []span {
+	if h.isPath(token) {
+		return []span{{start, end, Path}}
+	}
+
+	return words(token.Raw, start)
+}
+
+// isPath reports whether the argument token names a file that exists.
+// Words with expansions or patterns are left alone.
+func (h *Highlighter) isPath(token lexer.Token) bool {
+	if token.Value == "" || strings.ContainsAny(token.Raw, "$`*?[") {
+		return false
+	}
+
+	path := expand.Tilde(token.Value)
+	if exists, ok := h.exists[path]; ok {
+		return exists
+	}
+
+	_, err := os.Stat(path)
+	h.exists[path] = err == nil
+
+	return err == nil
+}
+
+// words returns the strings and variables of raw, a word of the command
+// line starting at offset.
+func words(raw string, offset int) []span {
+	var spans []span
+
+	for i := 0; i < len(raw); {
+		switch raw[i] {
+		case '\\':
+			i += 2
+		case '\'':
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end < 0 {
+				end = len(raw)
+			} else {
+				end += i + 2
+			}
+			spans = append(spans, span{offset + i, offset + end, String})
+			i = end
+		case '"':
+			end := i + 1
+			for end < len(raw) && raw[end] != '"' {
+				if raw[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(raw))
+			spans = append(spans, quoted(raw[i:end], offset+i)...)
+			i = end
+		case '$':
+			length := parameterLength(raw[i:])
+			if length > 1 {
+				spans = append(spans, span{offset + i, offset + i + length, Variable})
+			}
+			i += length
+		default:
+			i++
+		}
+	}
+
+	return spans
+}
+
+// quoted returns the spans of a double-quoted string starting at offset:
+// the string itself, broken around the variables in it.
+func quoted(text string, offset int) []span {
+	var spans []span
+
+	done := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '$':
+			length := parameterLength(text[i:])
+			if length < 2 {
+				continue
+			}
+			spans = append(spans,
+				span{offset + done, offset + i, String},
+				span{offset + i, offset + i + length, Variable})
+			i += length - 1
+			done = i + 1
+		}
+	}
+
+	if done < len(text) {
+		spans = append(spans, span{offset + done, offset + len(text), String})
+	}
+
+	return spans
+}
+
+// parameterLength returns the length of the expansion at the start of
+// text, which begins with $, or 1 if the $ starts none.
+func parameterLength(text string) int {
+	if len(text) < 2 {
+		return 1
+	}
+
+	switch text[1] {
+	case '(', '{':
+		closing := byte(')')
+		if text[1] == '{' {
+			closing = '}'
+		}
+
+		depth := 0
+		for i := 1; i < len(text); i++ {
+			switch text[i] {
+			case text[1]:
+				depth++
+			case closing:
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+
+		return len(text)
+	}
+
+	if strings.IndexByte("?$!#@*-0123456789", text[1]) >= 0 {
+		return 2
+	}
+
+	length := 1
+	for length < len(text) && lexer.IsName(text[1:length+1]) {
+		length++
+	}
+
+	return length
+}
+
+// comments returns a span for the comment in the text between tokens
+// from start to end, which holds nothing else but blanks.
+func comments(text string, start, end int) []span {
+	if start >= end {
+		return nil
+	}
+
+	hash := strings.IndexByte(text[start:end], '#')
+	if hash < 0 {
+		return nil
+	}
+	hash += start
+
+	stop := strings.IndexByte(text[hash:end], '\n')
+	if stop < 0 {
+		return []span{{hash, end, Comment}}
+	}
+
+	return []span{{hash, hash + stop, Comment}}
+}
+
+// parseStyles returns the style of each kind: the default, unless spec,
+// the value of DSH_HIGHLIGHT, changes it. Spec holds kind=attributes
+// pairs separated by colons, and the attributes are colour names, bold,
+// underline or none, separated by commas.
+func parseStyles(spec string) []terminal.Style {
+	names := kindNames()
+
+	styles := make([]terminal.Style, len(names))
+	for kind := range styles {
+		styles[kind] = defaultStyle(Kind(kind))
+	}
+
+	for entry := range strings.SplitSeq(spec, ":") {
+		name, attributes, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+
+		kind := -1
+		for i, known := range names {
+			if known == strings.TrimSpace(name) {
+				kind = i
+			}
+		}
+		if kind < 0 {
+			continue
+		}
+
+		var style terminal.Style
+		for attribute := range strings.SplitSeq(attributes, ",") {
+			switch attribute = strings.TrimSpace(attribute); attribute {
+			case "bold":
+				style.Bold = true
+			case "underline":
+				style.Underline = true
+			default:
+				if color, ok := terminal.ParseColor(attribute); ok {
+					style.Foreground = color
+				}
+			}
+		}
+		styles[kind] = style
+	}
+
+	return styles
+}
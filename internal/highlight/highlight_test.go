@@ -0,0 +1,169 @@
+package highlight
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dsh/internal/terminal"
+	"dsh/internal/variables"
+)
+
+// part is a piece of the line and its kind, as tests describe them.
+type part struct {
+	text string
+	kind Kind
+}
+
+// kinds returns the coloured parts of text.
+func kinds(h *Highlighter, text string) []part {
+	var parts []part
+	for _, s := range h.spans(text) {
+		if s.start < s.end {
+			parts = append(parts, part{text[s.start:s.end], s.kind})
+		}
+	}
+
+	return parts
+}
+
+// setupPath makes PATH hold only a directory with an executable named tool.
+func setupPath(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0o700); err != nil { //nolint:gosec // Test executable
+		t.Fatal(err)
+	}
+
+	previous, _ := variables.Get("PATH")
+	variables.Set("PATH", dir)
+	t.Cleanup(func() { variables.Set("PATH", previous) })
+}
+
+func TestHighlighter_Commands(t *testing.T) {
+	setupPath(t)
+	h := New()
+
+	tests := []struct {
+		text string
+		want []part
+	}{
+		{"tool -x", []part{{"tool", Command}}},
+		{"nosuchtool", []part{{"nosuchtool", Unknown}}},
+		{"cd /nonexistent", []part{{"cd", Builtin}}},
+		{"tool | tool && nosuch; cd", []part{
+			{"tool", Command}, {"|", Operator}, {"tool", Command}, {"&&", Operator},
+			{"nosuch", Unknown}, {";", Operator}, {"cd", Builtin},
+		}},
+		{"if tool; then cd; fi", []part{
+			{"if", Keyword}, {"tool", Command}, {";", Operator}, {"then", Keyword},
+			{"cd", Builtin}, {";", Operator}, {"fi", Keyword},
+		}},
+		{"for i in a; do tool; done", []part{
+			{"for", Keyword}, {"in", Keyword}, {";", Operator}, {"do", Keyword},
+			{"tool", Command}, {";", Operator}, {"done", Keyword},
+		}},
+		{"X=1 tool", []part{{"X", Variable}, {"tool", Command}}},
+		{"echo if", []part{{"echo", Builtin}}},
+	}
+
+	for _, tt := range tests {
+		got := kinds(h, tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.text, got, tt.want)
+
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: part %d is %v, want %v", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestHighlighter_Words(t *testing.T) {
+	setupPath(t)
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("notes.txt", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New()
+
+	tests := []struct {
+		text string
+		want []part
+	}{
+		{`echo 'a b' "x $HOME y" $1 ${#name}`, []part{
+			{"echo", Builtin}, {"'a b'", String}, {`"x `, String}, {"$HOME", Variable},
+			{` y"`, String}, {"$1", Variable}, {"${#name}", Variable},
+		}},
+		{"cat notes.txt missing.txt", []part{{"cat", Unknown}, {"notes.txt", Path}}},
+		{"echo hi > out 2>&1 # done", []part{
+			{"echo", Builtin}, {">", Redirection}, {"2>&", Redirection}, {"# done", Comment},
+		}},
+		{`echo "open`, []part{{"echo", Builtin}, {`"open`, String}}},
+	}
+
+	for _, tt := range tests {
+		got := kinds(h, tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.text, got, tt.want)
+
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: part %d is %v, want %v", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestHighlighter_Line(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	setupPath(t)
+	h := New()
+
+	if line := h.Line("tool # hi"); line != "\033[32mtool\033[0m \033[90m# hi\033[0m" {
+		t.Errorf("Unexpected line %q", line)
+	}
+
+	variables.Set("DSH_HIGHLIGHT", "command=blue,bold:comment=none:bogus=red")
+	t.Cleanup(func() { variables.Unset("DSH_HIGHLIGHT") })
+
+	if line := h.Line("tool # hi"); line != "\033[1;34mtool\033[0m # hi" {
+		t.Errorf("Expected the configured styles, got %q", line)
+	}
+
+	if width := terminal.DisplayWidth(h.Line("tool 'x' $y")); width != len("tool 'x' $y") {
+		t.Errorf("Expected the colours to take no columns, got width %d", width)
+	}
+}
+
+func TestHighlighter_Reset(t *testing.T) {
+	setupPath(t)
+	h := New()
+
+	if got := kinds(h, "later"); got[0].kind != Unknown {
+		t.Fatalf("Expected an unknown command, got %v", got)
+	}
+
+	path, _ := variables.Get("PATH")
+	if err := os.WriteFile(filepath.Join(strings.Split(path, ":")[0], "later"), nil, 0o700); err != nil { //nolint:gosec // Test executable
+		t.Fatal(err)
+	}
+
+	if got := kinds(h, "later"); got[0].kind != Unknown {
+		t.Errorf("Expected the lookup to be remembered, got %v", got)
+	}
+
+	h.Reset()
+	if got := kinds(h, "later"); got[0].kind != Command {
+		t.Errorf("Expected the new command after Reset, got %v", got)
+	}
+}
@@ -0,0 +1,147 @@
+// Package jobs keeps the table of commands the shell runs in the
+// background. Each job runs in a process group of its own, so that kill
+// can signal all of its processes at once through a job spec such as %1.
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNoSuchJob indicates a job spec that matches no running job.
+	ErrNoSuchJob = errors.New("no such job")
+	// ErrAmbiguousJob indicates a job spec that matches several jobs.
+	ErrAmbiguousJob = errors.New("ambiguous job spec")
+)
+
+// Job is a pipeline started in the background.
+type Job struct {
+	ID int
+	// Pgid is the job's process group, led by its first process.
+	Pgid    int
+	Pids    []int
+	Command string
+}
+
+var (
+	table   []*Job     //nolint:gochecknoglobals // Running jobs, oldest first
+	lastPid int        //nolint:gochecknoglobals // Special parameter $!
+	tableMu sync.Mutex //nolint:gochecknoglobals // Guards table and lastPid
+)
+
+// Add records a job whose processes have started, the first leading its
+// process group, and returns it. It takes the number after the highest
+// in use, so numbers are reused once the jobs holding them finish.
+func Add(command string, pids []int) *Job {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	id := 1
+	if len(table) > 0 {
+		id = table[len(table)-1].ID + 1
+	}
+
+	job := &Job{ID: id, Pgid: pids[0], Pids: pids, Command: command}
+	table = append(table, job)
+	lastPid = pids[len(pids)-1]
+
+	return job
+}
+
+// Finish removes a job once all its processes have been waited for.
+func Finish(job *Job) {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	for i, running := range table {
+		if running == job {
+			table = append(table[:i], table[i+1:]...)
+
+			return
+		}
+	}
+}
+
+// List returns the running jobs, oldest first.
+func List() []*Job {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	return append([]*Job(nil), table...)
+}
+
+// LastPid returns the process ID of the last command started in the
+// background, or 0 if there has been none.
+func LastPid() int {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	return lastPid
+}
+
+// Find returns the job that spec names: %n the job numbered n, %%, %+ or
+// % alone the current job, which is the newest, %- the one before it,
+// %name the job whose command starts with name and %?text the job whose
+// command contains text.
+func Find(spec string) (*Job, error) {
+	tableMu.Lock()
+	defer tableMu.Unlock()
+
+	text := strings.TrimPrefix(spec, "%")
+	noSuchJob := fmt.Errorf("%s: %w", spec, ErrNoSuchJob)
+
+	switch {
+	case text == "" || text == "%" || text == "+":
+		if len(table) == 0 {
+			return nil, noSuchJob
+		}
+
+		return table[len(table)-1], nil
+	case text == "-":
+		if len(table) < 2 {
+			return nil, noSuchJob
+		}
+
+		return table[len(table)-2], nil
+	case isNumber(text):
+		id, _ := strconv.Atoi(text)
+		for _, job := range table {
+			if job.ID == id {
+				return job, nil
+			}
+		}
+
+		return nil, noSuchJob
+	}
+
+	match := strings.HasPrefix
+	if rest, ok := strings.CutPrefix(text, "?"); ok {
+		text, match = rest, strings.Contains
+	}
+
+	var found *Job
+	for _, job := range table {
+		if !match(job.Command, text) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s: %w", spec, ErrAmbiguousJob)
+		}
+		found = job
+	}
+
+	if found == nil {
+		return nil, noSuchJob
+	}
+
+	return found, nil
+}
+
+// isNumber reports whether text is a non-empty run of digits.
+func isNumber(text string) bool {
+	return text != "" && strings.Trim(text, "0123456789") == ""
+}
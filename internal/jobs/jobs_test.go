@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	build := Add("make build", []int{100, 101})
+	tests := Add("go test ./...", []int{200})
+	t.Cleanup(func() {
+		Finish(build)
+		Finish(tests)
+	})
+
+	if build.ID != 1 || tests.ID != 2 || build.Pgid != 100 || LastPid() != 200 {
+		t.Fatalf("Unexpected jobs %+v and %+v with $! %d", build, tests, LastPid())
+	}
+
+	found := map[string]*Job{
+		"%1":     build,
+		"%2":     tests,
+		"%%":     tests,
+		"%+":     tests,
+		"%":      tests,
+		"%-":     build,
+		"%make":  build,
+		"%?test": tests,
+	}
+
+	for spec, expected := range found {
+		job, err := Find(spec)
+		if err != nil || job != expected {
+			t.Errorf("%s: expected job %d, got %v (%v)", spec, expected.ID, job, err)
+		}
+	}
+
+	failures := map[string]error{
+		"%3":     ErrNoSuchJob,
+		"%vim":   ErrNoSuchJob,
+		"%?e":    ErrAmbiguousJob,
+		"%?nope": ErrNoSuchJob,
+	}
+
+	for spec, expected := range failures {
+		if _, err := Find(spec); !errors.Is(err, expected) {
+			t.Errorf("%s: expected error %v, got %v", spec, expected, err)
+		}
+	}
+}
+
+func TestFinish(t *testing.T) {
+	first := Add("sleep 1", []int{300})
+	second := Add("sleep 2", []int{400})
+	Finish(second)
+
+	// The number of a finished job is free again
+	third := Add("sleep 3", []int{500})
+	t.Cleanup(func() {
+		Finish(first)
+		Finish(third)
+	})
+
+	if third.ID != 2 {
+		t.Errorf("Expected the new job to take number 2, got %d", third.ID)
+	}
+
+	if list := List(); len(list) != 2 || list[0] != first || list[1] != third {
+		t.Errorf("Unexpected job list %v", list)
+	}
+
+	if _, err := Find("%-"); err != nil {
+		t.Errorf("Expected a previous job, got %v", err)
+	}
+}
@@ -3,7 +3,9 @@ package lexer
 
 import (
 	"errors"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 // TokenType represents different types of shell tokens.
@@ -20,18 +22,67 @@ const (
 	RedirectIn
 	// RedirectAppend represents the append redirection operator >>.
 	RedirectAppend
+	// RedirectClobber represents >|, which truncates even under noclobber.
+	RedirectClobber
+	// RedirectDupOut represents >&, which copies an output descriptor.
+	RedirectDupOut
+	// RedirectDupIn represents <&, which copies an input descriptor.
+	RedirectDupIn
 	// Background represents the background operator &.
 	Background
 	// Semicolon represents the command separator ;.
 	Semicolon
+	// And represents the AND-list operator &&.
+	And
+	// Or represents the OR-list operator ||.
+	Or
+	// LParen represents an opening parenthesis (.
+	LParen
+	// RParen represents a closing parenthesis ).
+	RParen
+	// Newline represents the end of a line, which separates commands like ;.
+	Newline
+	// HereDoc represents the here-document operators << and <<-.
+	HereDoc
 	// EOF represents end of file.
 	EOF
 )
 
-// Token represents a lexical token with its type and value.
+// Token represents a lexical token with its type and value. For words,
+// Value has quotes and backslashes removed while Raw keeps the source text
+// so that expansions can be performed when the command runs. HereDoc
+// tokens carry the document that the lexer fills in at the next newline.
+// Redirection operators preceded by a descriptor number, as in 2>file,
+// carry it in IONumber. Pos is where the token starts in the input.
 type Token struct {
-	Type  TokenType
-	Value string
+	Type     TokenType
+	Value    string
+	Raw      string
+	HereDoc  *HereDocument
+	IONumber string
+	Pos      Position
+}
+
+// Position is a location in the input. Line and Column start at 1, and
+// Column counts characters rather than bytes.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// HereDocument is the body of a << or <<- redirection. The lexer reads it
+// from the lines following the one that holds the operator.
+type HereDocument struct {
+	Delimiter string
+	// Quoted is set when any part of the delimiter was quoted, in which
+	// case the body is used without expansion.
+	Quoted bool
+	// StripTabs is set for <<-, which removes leading tabs from each line.
+	StripTabs bool
+	Body      string
+	// pos is where the operator starts, for reporting a missing delimiter.
+	pos Position
 }
 
 // Lexer tokenizes shell input.
@@ -39,6 +90,23 @@ type Lexer struct {
 	input    string
 	position int
 	current  rune
+	// inConditional is set between [[ and ]], where the word after =~ is
+	// read as a regular expression.
+	inConditional bool
+	afterRegexOp  bool
+	// delimiterFor is the here-document whose delimiter is the next word;
+	// pendingHereDocs wait for the end of the line to read their bodies.
+	delimiterFor    *HereDocument
+	pendingHereDocs []*HereDocument
+	// line and column track the position of the current character, column
+	// counting the characters before it on its line, so that finding it
+	// takes no time on long lines.
+	line   int
+	column int
+	// err records the first reason the input ended too early, and errPos
+	// where the unfinished construct starts.
+	err    error
+	errPos Position
 }
 
 var (
@@ -46,6 +114,12 @@ var (
 	ErrUnexpectedEOF = errors.New("unexpected EOF in quoted string")
 	// ErrUnterminatedString indicates an unterminated quoted string.
 	ErrUnterminatedString = errors.New("unterminated quoted string")
+	// ErrTrailingBackslash indicates a backslash at the very end of input.
+	ErrTrailingBackslash = errors.New("unexpected EOF after backslash")
+	// ErrUnbalancedExpansion indicates ${, $(, <( or >( without its closing bracket.
+	ErrUnbalancedExpansion = errors.New("unexpected EOF while looking for matching bracket")
+	// ErrUnterminatedHereDoc indicates a here-document without its delimiter line.
+	ErrUnterminatedHereDoc = errors.New("here-document not terminated by its delimiter")
 )
 
 // New creates a new lexer for the given input.
@@ -54,24 +128,116 @@ func New(input string) *Lexer {
 		input:    input,
 		position: 0,
 		current:  0,
+		line:     1,
 	}
 	lexer.readChar()
 
 	return lexer
 }
 
+// Err returns the reason the input ended in the middle of a construct,
+// such as an open quote or a here-document without its delimiter, or nil.
+// The parser reports such input as incomplete.
+func (lexer *Lexer) Err() error {
+	return lexer.err
+}
+
+// ErrPos returns where the construct left open by Err starts.
+func (lexer *Lexer) ErrPos() Position {
+	return lexer.errPos
+}
+
+func (lexer *Lexer) setErr(err error, pos Position) {
+	if lexer.err == nil {
+		lexer.err = err
+		lexer.errPos = pos
+	}
+}
+
+// Pos returns the position of the current character.
+func (lexer *Lexer) Pos() Position {
+	offset := lexer.offset()
+
+	return Position{
+		Line:   lexer.line,
+		Column: lexer.column + 1,
+		Offset: offset,
+	}
+}
+
+// SourceLine returns the line of the input that holds pos, without its
+// newline.
+func (lexer *Lexer) SourceLine(pos Position) string {
+	offset := min(max(pos.Offset, 0), len(lexer.input))
+	start := strings.LastIndexByte(lexer.input[:offset], '\n') + 1
+	end := strings.IndexByte(lexer.input[offset:], '\n')
+	if end < 0 {
+		return lexer.input[start:]
+	}
+
+	return lexer.input[start : offset+end]
+}
+
+// Text returns the input between two byte offsets, such as the source of
+// a command from the position of its first token to that of the token
+// after it.
+func (lexer *Lexer) Text(start, end int) string {
+	end = min(max(end, 0), len(lexer.input))
+	start = min(max(start, 0), end)
+
+	return lexer.input[start:end]
+}
+
 // NextToken returns the next token from the input.
 func (lexer *Lexer) NextToken() Token {
 	lexer.skipWhitespace()
+	if lexer.current == '#' {
+		lexer.skipComment()
+	}
+
+	pos := lexer.Pos()
+	token := lexer.nextToken()
+	token.Pos = pos
+
+	return token
+}
+
+func (lexer *Lexer) nextToken() Token {
+	if lexer.afterRegexOp && lexer.current != 0 && lexer.current != '\n' {
+		return lexer.wordToken()
+	}
 
 	switch lexer.current {
 	case 0:
+		if doc := lexer.delimiterFor; doc != nil {
+			lexer.setErr(ErrUnterminatedHereDoc, doc.pos)
+		}
+		if len(lexer.pendingHereDocs) > 0 {
+			lexer.setErr(ErrUnterminatedHereDoc, lexer.pendingHereDocs[0].pos)
+		}
+
 		return Token{Type: EOF, Value: ""}
-	case '#':
-		lexer.skipComment()
+	case '\n':
+		lexer.readChar()
+		lexer.readHereDocBodies()
 
-		return lexer.NextToken()
+		if strings.TrimSpace(lexer.input[lexer.offset():]) == "" {
+			// Trailing blank lines do not separate anything
+			for lexer.current != 0 {
+				lexer.readChar()
+			}
+
+			return Token{Type: EOF, Value: ""}
+		}
+
+		return Token{Type: Newline, Value: "\n"}
 	case '|':
+		if lexer.peekChar() == '|' {
+			lexer.readChar()
+			lexer.readChar()
+
+			return Token{Type: Or, Value: "||"}
+		}
 		lexer.readChar()
 
 		return Token{Type: Pipe, Value: "|"}
@@ -80,31 +246,212 @@ func (lexer *Lexer) NextToken() Token {
 
 		return Token{Type: Semicolon, Value: ";"}
 	case '&':
+		if lexer.peekChar() == '&' {
+			lexer.readChar()
+			lexer.readChar()
+
+			return Token{Type: And, Value: "&&"}
+		}
 		lexer.readChar()
 
 		return Token{Type: Background, Value: "&"}
-	case '>':
-		if lexer.peekChar() == '>' {
+	case '(':
+		lexer.readChar()
+
+		return Token{Type: LParen, Value: "("}
+	case ')':
+		lexer.readChar()
+
+		return Token{Type: RParen, Value: ")"}
+	case '>', '<':
+		if lexer.peekChar() == '(' {
+			return lexer.wordToken()
+		}
+
+		return lexer.redirectToken()
+	default:
+		if number := lexer.ioNumber(); number != "" {
+			token := lexer.redirectToken()
+			token.IONumber = number
+
+			return token
+		}
+
+		return lexer.wordToken()
+	}
+}
+
+// redirectToken reads the redirection operator at the current < or >.
+func (lexer *Lexer) redirectToken() Token {
+	if lexer.current == '<' {
+		switch lexer.peekChar() {
+		case '<':
+			return lexer.hereDocToken()
+		case '&':
 			lexer.readChar()
 			lexer.readChar()
 
-			return Token{Type: RedirectAppend, Value: ">>"}
+			return Token{Type: RedirectDupIn, Value: "<&"}
 		}
 		lexer.readChar()
 
+		return Token{Type: RedirectIn, Value: "<"}
+	}
+
+	var token Token
+
+	switch lexer.peekChar() {
+	case '>':
+		token = Token{Type: RedirectAppend, Value: ">>"}
+	case '|':
+		token = Token{Type: RedirectClobber, Value: ">|"}
+	case '&':
+		token = Token{Type: RedirectDupOut, Value: ">&"}
+	default:
+		lexer.readChar()
+
 		return Token{Type: RedirectOut, Value: ">"}
-	case '<':
+	}
+	lexer.readChar()
+	lexer.readChar()
+
+	return token
+}
+
+// ioNumber reads the digits of a descriptor number directly followed by a
+// redirection operator, as in 2>file, and returns "" for anything else.
+func (lexer *Lexer) ioNumber() string {
+	start := lexer.offset()
+	end := start
+	for end < len(lexer.input) && lexer.input[end] >= '0' && lexer.input[end] <= '9' {
+		end++
+	}
+
+	if end == start || end >= len(lexer.input) || (lexer.input[end] != '<' && lexer.input[end] != '>') {
+		return ""
+	}
+	if end+1 < len(lexer.input) && lexer.input[end+1] == '(' {
+		return ""
+	}
+
+	for lexer.offset() < end {
 		lexer.readChar()
+	}
 
-		return Token{Type: RedirectIn, Value: "<"}
-	default:
-		word := lexer.readWord()
+	return lexer.input[start:end]
+}
+
+// wordToken reads a word and tracks [[ ... ]] so that the operand of =~ can
+// contain parentheses and pipes without being split into operators.
+func (lexer *Lexer) wordToken() Token {
+	start := lexer.offset()
+
+	var word string
+	if lexer.afterRegexOp {
+		word = lexer.readRegexWord()
+	} else {
+		word = lexer.readWord()
+	}
+	lexer.afterRegexOp = false
+
+	raw := lexer.input[start:lexer.offset()]
+
+	if doc := lexer.delimiterFor; doc != nil {
+		doc.Delimiter = word
+		doc.Quoted = strings.ContainsAny(raw, `'"\`)
+		lexer.pendingHereDocs = append(lexer.pendingHereDocs, doc)
+		lexer.delimiterFor = nil
+	}
 
-		return Token{Type: Word, Value: word}
+	switch {
+	case word == "[[":
+		lexer.inConditional = true
+	case word == "]]":
+		lexer.inConditional = false
+	case word == "=~" && lexer.inConditional:
+		lexer.afterRegexOp = true
 	}
+
+	return Token{Type: Word, Value: word, Raw: raw}
+}
+
+// hereDocToken reads << or <<- and arranges for the next word to become
+// the delimiter of a new here-document.
+func (lexer *Lexer) hereDocToken() Token {
+	doc := &HereDocument{pos: lexer.Pos()}
+	lexer.readChar()
+	lexer.readChar()
+
+	value := "<<"
+	if lexer.current == '-' {
+		doc.StripTabs = true
+		value = "<<-"
+		lexer.readChar()
+	}
+	lexer.delimiterFor = doc
+
+	return Token{Type: HereDoc, Value: value, HereDoc: doc}
+}
+
+// readHereDocBodies reads the bodies of the here-documents started on the
+// line just ended, each up to its delimiter line.
+func (lexer *Lexer) readHereDocBodies() {
+	for _, doc := range lexer.pendingHereDocs {
+		var body strings.Builder
+		found := false
+
+		for lexer.current != 0 {
+			line := lexer.readLine()
+			if doc.StripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == doc.Delimiter {
+				found = true
+
+				break
+			}
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+
+		doc.Body = body.String()
+		if !found {
+			lexer.setErr(ErrUnterminatedHereDoc, doc.pos)
+		}
+	}
+
+	lexer.pendingHereDocs = nil
+}
+
+// readLine returns the rest of the current line and moves past its newline.
+func (lexer *Lexer) readLine() string {
+	start := lexer.offset()
+	for lexer.current != 0 && lexer.current != '\n' {
+		lexer.readChar()
+	}
+	line := lexer.input[start:lexer.offset()]
+
+	if lexer.current == '\n' {
+		lexer.readChar()
+	}
+
+	return line
+}
+
+// offset returns the byte offset of the current character.
+func (lexer *Lexer) offset() int {
+	return min(lexer.position-1, len(lexer.input))
 }
 
 func (lexer *Lexer) readChar() {
+	switch previous := lexer.position - 1; {
+	case lexer.current == '\n':
+		lexer.line++
+		lexer.column = 0
+	case previous >= 0 && previous < len(lexer.input) && utf8.RuneStart(lexer.input[previous]):
+		lexer.column++
+	}
+
 	if lexer.position >= len(lexer.input) {
 		lexer.current = 0 // EOF
 	} else {
@@ -121,9 +468,19 @@ func (lexer *Lexer) peekChar() rune {
 	return rune(lexer.input[lexer.position])
 }
 
+// skipWhitespace skips blanks and backslash-newline line continuations.
+// Newlines themselves are tokens.
 func (lexer *Lexer) skipWhitespace() {
-	for lexer.current == ' ' || lexer.current == '\t' || lexer.current == '\n' || lexer.current == '\r' {
-		lexer.readChar()
+	for {
+		switch {
+		case lexer.current == ' ' || lexer.current == '\t' || lexer.current == '\r':
+			lexer.readChar()
+		case lexer.current == '\\' && lexer.peekChar() == '\n':
+			lexer.readChar()
+			lexer.readChar()
+		default:
+			return
+		}
 	}
 }
 
@@ -141,7 +498,7 @@ func (lexer *Lexer) readQuotedString(quote rune) (string, error) {
 		if quote == '"' && lexer.current == '\\' {
 			lexer.readChar()
 			if lexer.current == 0 {
-				return "", ErrUnexpectedEOF
+				return result.String(), ErrUnexpectedEOF
 			}
 
 			result.WriteRune(lexer.handleEscapeSequence())
@@ -152,7 +509,7 @@ func (lexer *Lexer) readQuotedString(quote rune) (string, error) {
 	}
 
 	if lexer.current != quote {
-		return "", ErrUnterminatedString
+		return result.String(), ErrUnterminatedString
 	}
 	lexer.readChar() // skip closing quote
 
@@ -182,19 +539,36 @@ func (lexer *Lexer) handleEscapeSequence() rune {
 func (lexer *Lexer) readWord() string {
 	var result strings.Builder
 
-	for lexer.current != 0 && !isWhitespace(lexer.current) && !isSpecialChar(lexer.current) {
+	for lexer.current != 0 && !isWhitespace(lexer.current) &&
+		(!isSpecialChar(lexer.current) || lexer.atProcessSubstitution()) {
+		if (lexer.current == '$' && (lexer.peekChar() == '{' || lexer.peekChar() == '(')) ||
+			lexer.atProcessSubstitution() {
+			// ${...}, $(...), <(...) and >(...) may contain blanks and operators
+			result.WriteRune(lexer.current)
+			lexer.readChar()
+			lexer.readBalanced(&result)
+
+			continue
+		}
+
 		switch lexer.current {
 		case '\'', '"':
+			pos := lexer.Pos()
 			quoted, err := lexer.readQuotedString(lexer.current)
 			if err != nil {
-				result.WriteRune(lexer.current)
-				lexer.readChar()
-			} else {
-				result.WriteString(quoted)
+				lexer.setErr(err, pos)
 			}
+			result.WriteString(quoted)
 		case '\\':
+			pos := lexer.Pos()
 			lexer.readChar()
-			if lexer.current != 0 {
+			switch lexer.current {
+			case 0:
+				lexer.setErr(ErrTrailingBackslash, pos)
+			case '\n':
+				// Line continuation
+				lexer.readChar()
+			default:
 				result.WriteRune(lexer.current)
 				lexer.readChar()
 			}
@@ -202,6 +576,147 @@ func (lexer *Lexer) readWord() string {
 			result.WriteRune(lexer.current)
 			lexer.readChar()
 		}
+
+		if lexer.current == '(' && isArrayAssignmentPrefix(result.String()) {
+			// name=(a b c) is a single word holding an array literal
+			lexer.readBalanced(&result)
+		}
+	}
+
+	return result.String()
+}
+
+// atProcessSubstitution reports whether the input continues with <( or >(.
+func (lexer *Lexer) atProcessSubstitution() bool {
+	return (lexer.current == '<' || lexer.current == '>') && lexer.peekChar() == '('
+}
+
+// readBalanced copies an opening bracket and everything up to its matching
+// closing bracket into result verbatim, skipping over quoted text.
+func (lexer *Lexer) readBalanced(result *strings.Builder) {
+	pos := lexer.Pos()
+	open := lexer.current
+	closing := map[rune]rune{'(': ')', '{': '}'}[open]
+	depth := 0
+
+	for lexer.current != 0 {
+		switch lexer.current {
+		case open:
+			depth++
+		case closing:
+			depth--
+		case '\\':
+			result.WriteRune(lexer.current)
+			lexer.readChar()
+		case '\'', '"':
+			lexer.copyQuoted(result)
+
+			continue
+		}
+
+		if lexer.current == 0 {
+			break
+		}
+		result.WriteRune(lexer.current)
+		lexer.readChar()
+
+		if depth == 0 {
+			return
+		}
+	}
+
+	lexer.setErr(ErrUnbalancedExpansion, pos)
+}
+
+// copyQuoted copies a quoted string, quotes included, into result.
+func (lexer *Lexer) copyQuoted(result *strings.Builder) {
+	pos := lexer.Pos()
+	quote := lexer.current
+	result.WriteRune(quote)
+	lexer.readChar()
+
+	for lexer.current != 0 && lexer.current != quote {
+		if quote == '"' && lexer.current == '\\' {
+			result.WriteRune(lexer.current)
+			lexer.readChar()
+			if lexer.current == 0 {
+				break
+			}
+		}
+		result.WriteRune(lexer.current)
+		lexer.readChar()
+	}
+
+	if lexer.current != quote {
+		lexer.setErr(ErrUnterminatedString, pos)
+
+		return
+	}
+	result.WriteRune(quote)
+	lexer.readChar()
+}
+
+// isArrayAssignmentPrefix reports whether word is "name=" or "name+=".
+func isArrayAssignmentPrefix(word string) bool {
+	name, found := strings.CutSuffix(word, "=")
+	if !found {
+		return false
+	}
+	name = strings.TrimSuffix(name, "+")
+
+	return IsName(name)
+}
+
+// IsName reports whether s is a valid shell variable name.
+func IsName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, ch := range s {
+		isLetter := ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		if !isLetter && (i == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readRegexWord reads the right-hand side of =~, where parentheses and |
+// belong to the pattern. It stops at whitespace outside any parentheses.
+// Quoted parts match literally, so their metacharacters are escaped.
+func (lexer *Lexer) readRegexWord() string {
+	var result strings.Builder
+	depth := 0
+
+	for lexer.current != 0 && (depth > 0 || !isWhitespace(lexer.current)) {
+		switch lexer.current {
+		case '\'', '"':
+			pos := lexer.Pos()
+			quoted, err := lexer.readQuotedString(lexer.current)
+			if err != nil {
+				lexer.setErr(err, pos)
+
+				return result.String()
+			}
+			result.WriteString(regexp.QuoteMeta(quoted))
+
+			continue
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\\':
+			result.WriteRune(lexer.current)
+			lexer.readChar()
+			if lexer.current == 0 {
+				return result.String()
+			}
+		}
+
+		result.WriteRune(lexer.current)
+		lexer.readChar()
 	}
 
 	return result.String()
@@ -212,5 +727,5 @@ func isWhitespace(ch rune) bool {
 }
 
 func isSpecialChar(ch rune) bool {
-	return ch == '|' || ch == '>' || ch == '<' || ch == ';' || ch == '&'
+	return ch == '|' || ch == '>' || ch == '<' || ch == ';' || ch == '&' || ch == '(' || ch == ')'
 }
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -185,3 +186,236 @@ func TestLexer_AppendRedirection(t *testing.T) {
 		}
 	}
 }
+
+func TestLexer_ClobberRedirection(t *testing.T) {
+	lexer := New("echo hello >| output.txt")
+
+	expected := []TokenType{Word, Word, RedirectClobber, Word, EOF}
+	for i, tokenType := range expected {
+		if token := lexer.NextToken(); token.Type != tokenType {
+			t.Errorf("Token %d: expected %v, got %v", i, tokenType, token.Type)
+		}
+	}
+}
+
+func TestLexer_DescriptorRedirections(t *testing.T) {
+	lexer := New("cmd 2>err 3<in 2>&1 <&3 a2>b 10 >out 4<(x)")
+
+	expected := []struct {
+		tokenType TokenType
+		ioNumber  string
+		value     string
+	}{
+		{Word, "", "cmd"},
+		{RedirectOut, "2", ">"},
+		{Word, "", "err"},
+		{RedirectIn, "3", "<"},
+		{Word, "", "in"},
+		{RedirectDupOut, "2", ">&"},
+		{Word, "", "1"},
+		{RedirectDupIn, "", "<&"},
+		{Word, "", "3"},
+		{Word, "", "a2"},
+		{RedirectOut, "", ">"},
+		{Word, "", "b"},
+		{Word, "", "10"},
+		{RedirectOut, "", ">"},
+		{Word, "", "out"},
+		{Word, "", "4<(x)"},
+		{EOF, "", ""},
+	}
+
+	for i, want := range expected {
+		token := lexer.NextToken()
+		if token.Type != want.tokenType || token.IONumber != want.ioNumber || token.Value != want.value {
+			t.Errorf("Token %d: expected %v %q %q, got %v %q %q",
+				i, want.tokenType, want.ioNumber, want.value, token.Type, token.IONumber, token.Value)
+		}
+	}
+}
+
+func TestLexer_ListOperators(t *testing.T) {
+	input := "true && echo yes || echo no | cat"
+	lexer := New(input)
+
+	expected := []TokenType{Word, And, Word, Word, Or, Word, Word, Pipe, Word, EOF}
+	for i, want := range expected {
+		token := lexer.NextToken()
+		if token.Type != want {
+			t.Errorf("Token %d: expected %v, got %v (%q)", i, want, token.Type, token.Value)
+		}
+	}
+}
+
+func TestLexer_Parentheses(t *testing.T) {
+	input := "[[ ( a == b ) ]]"
+	lexer := New(input)
+
+	expected := []TokenType{Word, LParen, Word, Word, Word, RParen, Word, EOF}
+	for i, want := range expected {
+		token := lexer.NextToken()
+		if token.Type != want {
+			t.Errorf("Token %d: expected %v, got %v (%q)", i, want, token.Type, token.Value)
+		}
+	}
+}
+
+func TestLexer_RegexOperand(t *testing.T) {
+	tests := []struct {
+		input string
+		regex string
+	}{
+		{`[[ $x =~ ^(foo|bar)[0-9]+$ ]]`, `^(foo|bar)[0-9]+$`},
+		{`[[ $x =~ "a.b" ]]`, `a\.b`},
+		{`[[ $x =~ ( a|b ) ]]`, `( a|b )`},
+	}
+
+	for _, test := range tests {
+		lexer := New(test.input)
+
+		var tokens []Token
+		for token := lexer.NextToken(); token.Type != EOF; token = lexer.NextToken() {
+			tokens = append(tokens, token)
+		}
+
+		if len(tokens) != 5 {
+			t.Fatalf("%q: expected 5 tokens, got %d: %v", test.input, len(tokens), tokens)
+		}
+		if tokens[3].Value != test.regex {
+			t.Errorf("%q: expected regex %q, got %q", test.input, test.regex, tokens[3].Value)
+		}
+	}
+}
+
+func TestLexer_RegexModeOnlyInsideConditional(t *testing.T) {
+	lexer := New("echo =~ (a)")
+
+	expected := []TokenType{Word, Word, LParen, Word, RParen, EOF}
+	for i, want := range expected {
+		token := lexer.NextToken()
+		if token.Type != want {
+			t.Errorf("Token %d: expected %v, got %v (%q)", i, want, token.Type, token.Value)
+		}
+	}
+}
+
+func TestLexer_RawWords(t *testing.T) {
+	input := `echo "$a b" 'c'\d ${x[@]:1} arr=(1 "2 3") done`
+	lexer := New(input)
+
+	expected := []string{"echo", `"$a b"`, `'c'\d`, "${x[@]:1}", `arr=(1 "2 3")`, "done"}
+	for i, want := range expected {
+		token := lexer.NextToken()
+		if token.Type != Word || token.Raw != want {
+			t.Errorf("Token %d: expected raw word %q, got %v %q", i, want, token.Type, token.Raw)
+		}
+	}
+
+	if token := lexer.NextToken(); token.Type != EOF {
+		t.Errorf("Expected EOF, got %v (%q)", token.Type, token.Value)
+	}
+}
+
+func TestLexer_ProcessSubstitution(t *testing.T) {
+	lexer := New("diff <(sort a | uniq) >(cat) < in")
+
+	expected := []Token{
+		{Type: Word, Value: "diff", Raw: "diff"},
+		{Type: Word, Value: "<(sort a | uniq)", Raw: "<(sort a | uniq)"},
+		{Type: Word, Value: ">(cat)", Raw: ">(cat)"},
+		{Type: RedirectIn, Value: "<"},
+		{Type: Word, Value: "in", Raw: "in"},
+		{Type: EOF},
+	}
+	for i, want := range expected {
+		token := lexer.NextToken()
+		token.Pos = Position{}
+		if token != want {
+			t.Errorf("Token %d: expected %+v, got %+v", i, want, token)
+		}
+	}
+}
+
+func TestLexer_NewlinesAndHereDocs(t *testing.T) {
+	lexer := New("cat <<EOF; echo x\nbody $y\nEOF\necho done")
+
+	expected := []TokenType{Word, HereDoc, Word, Semicolon, Word, Word, Newline, Word, Word, EOF}
+	var heredoc Token
+	for i, want := range expected {
+		token := lexer.NextToken()
+		if token.Type != want {
+			t.Errorf("Token %d: expected %v, got %v (%q)", i, want, token.Type, token.Value)
+		}
+		if token.Type == HereDoc {
+			heredoc = token
+		}
+	}
+
+	if heredoc.HereDoc == nil || heredoc.HereDoc.Delimiter != "EOF" || heredoc.HereDoc.Body != "body $y\n" {
+		t.Errorf("Unexpected here-document %+v", heredoc.HereDoc)
+	}
+	if lexer.Err() != nil {
+		t.Errorf("Unexpected error %v", lexer.Err())
+	}
+}
+
+func TestLexer_IncompleteInput(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{`echo "open`, ErrUnterminatedString},
+		{`echo trailing\`, ErrTrailingBackslash},
+		{"echo $(date", ErrUnbalancedExpansion},
+		{"cat <<EOF\nno end", ErrUnterminatedHereDoc},
+	}
+
+	for _, test := range tests {
+		lexer := New(test.input)
+		for lexer.NextToken().Type != EOF {
+		}
+
+		if !errors.Is(lexer.Err(), test.err) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.err, lexer.Err())
+		}
+	}
+
+	lexer := New("echo a\\\nb")
+	if token := lexer.NextToken(); token.Value != "echo" {
+		t.Fatalf("Unexpected token %q", token.Value)
+	}
+	if token := lexer.NextToken(); token.Value != "ab" {
+		t.Errorf("Expected a backslash-newline to join the word, got %q", token.Value)
+	}
+}
+
+func TestLexer_Positions(t *testing.T) {
+	lexer := New("echo héllo > out\n  cat # comment\n\tls")
+
+	expected := []Position{
+		{Line: 1, Column: 1, Offset: 0},
+		{Line: 1, Column: 6, Offset: 5},
+		{Line: 1, Column: 12, Offset: 12},
+		{Line: 1, Column: 14, Offset: 14},
+		{Line: 1, Column: 17, Offset: 17},
+		{Line: 2, Column: 3, Offset: 20},
+		{Line: 2, Column: 16, Offset: 33},
+		{Line: 3, Column: 2, Offset: 35},
+	}
+	for i, want := range expected {
+		if token := lexer.NextToken(); token.Pos != want {
+			t.Errorf("Token %d (%q): expected %+v, got %+v", i, token.Value, want, token.Pos)
+		}
+	}
+
+	if line := lexer.SourceLine(expected[5]); line != "  cat # comment" {
+		t.Errorf("Unexpected source line %q", line)
+	}
+
+	lexer = New("echo ok\necho 'open")
+	for lexer.NextToken().Type != EOF {
+	}
+	if pos := lexer.ErrPos(); pos.Line != 2 || pos.Column != 6 {
+		t.Errorf("Expected the open quote at 2:6, got %+v", pos)
+	}
+}
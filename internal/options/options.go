@@ -0,0 +1,185 @@
+// Package options holds the shell options changed by set, shopt and the
+// command line, such as errexit and nullglob.
+package options
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidOption indicates an option name or letter the shell does not know.
+var ErrInvalidOption = errors.New("invalid option name")
+
+// Option names used by the shell.
+const (
+	Errexit         = "errexit"
+	Noclobber       = "noclobber"
+	Noglob          = "noglob"
+	Nounset         = "nounset"
+	Pipefail        = "pipefail"
+	Xtrace          = "xtrace"
+	HistExpand      = "histexpand"
+	Dotglob         = "dotglob"
+	Failglob        = "failglob"
+	Nullglob        = "nullglob"
+	TransientPrompt = "transientprompt"
+	Emacs           = "emacs"
+	Vi              = "vi"
+)
+
+// Option describes one option. Options changed with set -o may also have
+// a single-letter form; Extended options belong to shopt instead.
+type Option struct {
+	Name     string
+	Letter   byte
+	Extended bool
+}
+
+// all lists every option in the order set -o and shopt print them.
+var all = []Option{ //nolint:gochecknoglobals // Fixed option table
+	{Name: Errexit, Letter: 'e'},
+	{Name: Noclobber, Letter: 'C'},
+	{Name: Noglob, Letter: 'f'},
+	{Name: Nounset, Letter: 'u'},
+	{Name: Pipefail},
+	{Name: Xtrace, Letter: 'x'},
+	{Name: HistExpand, Letter: 'H'},
+	{Name: Emacs},
+	{Name: Vi},
+	{Name: Dotglob, Extended: true},
+	{Name: Failglob, Extended: true},
+	{Name: Nullglob, Extended: true},
+	{Name: TransientPrompt, Extended: true},
+}
+
+var (
+	enabled   = map[string]bool{} //nolint:gochecknoglobals // Shell-wide option state
+	enabledMu sync.RWMutex        //nolint:gochecknoglobals // Guards enabled
+)
+
+// Enabled reports whether the named option is on.
+func Enabled(name string) bool {
+	enabledMu.RLock()
+	defer enabledMu.RUnlock()
+
+	// emacs is the default editing mode, so it is kept as vi being off
+	if name == Emacs {
+		return !enabled[Vi]
+	}
+
+	return enabled[name]
+}
+
+// Set turns the named option on or off.
+func Set(name string, on bool) error {
+	if _, ok := Lookup(name); !ok {
+		return fmt.Errorf("%s: %w", name, ErrInvalidOption)
+	}
+
+	enabledMu.Lock()
+	defer enabledMu.Unlock()
+
+	// Turning one editing mode on turns the other off
+	if name == Emacs {
+		name, on = Vi, !on
+	}
+	enabled[name] = on
+
+	return nil
+}
+
+// Snapshot is a copy of the options that are on.
+type Snapshot struct {
+	enabled map[string]bool
+}
+
+// TakeSnapshot copies the options so that RestoreSnapshot can undo later
+// changes, as a subshell requires.
+func TakeSnapshot() *Snapshot {
+	enabledMu.RLock()
+	defer enabledMu.RUnlock()
+
+	return &Snapshot{enabled: maps.Clone(enabled)}
+}
+
+// RestoreSnapshot puts back the options of snapshot.
+func RestoreSnapshot(snapshot *Snapshot) {
+	enabledMu.Lock()
+	defer enabledMu.Unlock()
+
+	enabled = maps.Clone(snapshot.enabled)
+}
+
+// Lookup returns the option with the given name.
+func Lookup(name string) (Option, bool) {
+	for _, option := range all {
+		if option.Name == name {
+			return option, true
+		}
+	}
+
+	return Option{}, false
+}
+
+// ByLetter returns the set option with the given single-letter form.
+func ByLetter(letter byte) (Option, bool) {
+	for _, option := range all {
+		if option.Letter == letter && letter != 0 {
+			return option, true
+		}
+	}
+
+	return Option{}, false
+}
+
+// List returns the options of set -o, or with extended those of shopt.
+func List(extended bool) []Option {
+	var list []Option
+
+	for _, option := range all {
+		if option.Extended == extended {
+			list = append(list, option)
+		}
+	}
+
+	return list
+}
+
+// Flags returns the letters of the enabled options, the value of $-.
+func Flags() string {
+	var letters []string
+
+	for _, option := range all {
+		if option.Letter != 0 && Enabled(option.Name) {
+			letters = append(letters, string(option.Letter))
+		}
+	}
+	sort.Strings(letters)
+
+	return strings.Join(letters, "")
+}
+
+// Arguments returns the command line options that enable the current
+// options, so that a child shell starts with the same settings.
+func Arguments() []string {
+	var args []string
+
+	for _, option := range all {
+		// The default editing mode needs no argument
+		if !Enabled(option.Name) || option.Name == Emacs {
+			continue
+		}
+
+		if option.Extended {
+			args = append(args, "-O", option.Name)
+		} else {
+			args = append(args, "-o", option.Name)
+		}
+	}
+
+	return args
+}
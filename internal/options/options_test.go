@@ -0,0 +1,80 @@
+package options
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetAndEnabled(t *testing.T) {
+	t.Cleanup(func() {
+		_ = Set(Xtrace, false)
+		_ = Set(Errexit, false)
+		_ = Set(Nullglob, false)
+	})
+
+	if Enabled(Xtrace) {
+		t.Fatal("Expected xtrace to start off")
+	}
+
+	for _, name := range []string{Xtrace, Errexit, Nullglob} {
+		if err := Set(name, true); err != nil {
+			t.Fatalf("Set(%q) failed: %v", name, err)
+		}
+	}
+
+	if Flags() != "ex" {
+		t.Errorf("Expected flags %q, got %q", "ex", Flags())
+	}
+
+	expected := []string{"-o", Errexit, "-o", Xtrace, "-O", Nullglob}
+	if args := Arguments(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected arguments %q, got %q", expected, args)
+	}
+
+	if err := Set("nosuchoption", true); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if option, ok := ByLetter('C'); !ok || option.Name != Noclobber {
+		t.Errorf("Expected -C to be noclobber, got %+v", option)
+	}
+	if option, ok := ByLetter('H'); !ok || option.Name != HistExpand {
+		t.Errorf("Expected -H to be histexpand, got %+v", option)
+	}
+	if _, ok := ByLetter('z'); ok {
+		t.Error("Expected no option for -z")
+	}
+	if option, ok := Lookup(Pipefail); !ok || option.Letter != 0 || option.Extended {
+		t.Errorf("Unexpected pipefail option %+v", option)
+	}
+
+	for _, option := range List(true) {
+		if !option.Extended {
+			t.Errorf("Expected only shopt options, got %+v", option)
+		}
+	}
+}
+
+func TestEditingMode(t *testing.T) {
+	t.Cleanup(func() { _ = Set(Vi, false) })
+
+	if !Enabled(Emacs) || Enabled(Vi) {
+		t.Fatal("Expected emacs to be the default editing mode")
+	}
+
+	_ = Set(Vi, true)
+	if Enabled(Emacs) || !Enabled(Vi) {
+		t.Error("Expected vi to turn emacs off")
+	}
+	if args := Arguments(); !reflect.DeepEqual(args, []string{"-o", Vi}) {
+		t.Errorf("Expected only vi in the arguments, got %q", args)
+	}
+
+	_ = Set(Emacs, true)
+	if !Enabled(Emacs) || Enabled(Vi) {
+		t.Error("Expected emacs to turn vi off")
+	}
+}
@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"dsh/internal/lexer"
+)
+
+var (
+	// ErrExpectedLoopVariable indicates a for loop without a valid name.
+	ErrExpectedLoopVariable = errors.New("expected variable name after 'for'")
+	// ErrExpectedDo indicates a loop whose body does not start with do.
+	ErrExpectedDo = errors.New("expected 'do'")
+	// ErrExpectedDone indicates a loop body without a closing done.
+	ErrExpectedDone = errors.New("expected 'done'")
+	// ErrExpectedThen indicates an if or elif condition without then.
+	ErrExpectedThen = errors.New("expected 'then'")
+	// ErrExpectedFi indicates an if command without a closing fi.
+	ErrExpectedFi = errors.New("expected 'fi'")
+	// ErrExpectedCloseBrace indicates a { group without a closing }.
+	ErrExpectedCloseBrace = errors.New("expected '}'")
+	// ErrExpectedCloseSubshell indicates a ( subshell without a closing ).
+	ErrExpectedCloseSubshell = errors.New("expected ')' to close subshell")
+)
+
+// reservedWords are the words that start or end compound commands when
+// they appear where a command name is expected.
+var reservedWords = []string{ //nolint:gochecknoglobals // Fixed list of keywords
+	"!", "[[", "]]", "{", "}", "do", "done", "elif", "else", "fi", "for",
+	"if", "in", "then", "time",
+}
+
+// IsReservedWord reports whether word is a shell keyword, such as if.
+func IsReservedWord(word string) bool {
+	return slices.Contains(reservedWords, word)
+}
+
+// CompoundCommand is a command built from other commands, such as a loop.
+// The executor switches on the concrete type.
+type CompoundCommand interface {
+	compound()
+}
+
+// ForClause is "for name in words; do body; done". Words keeps the source
+// text of the list, which is expanded each time the loop starts.
+type ForClause struct {
+	Name  string
+	Words []string
+	Body  []*Pipeline
+}
+
+func (*ForClause) compound() {}
+
+// IfClause is "if list; then list; [elif list; then list;]... [else list;]
+// fi". The first branch whose condition succeeds runs.
+type IfClause struct {
+	Branches []IfBranch
+	Else     []*Pipeline
+}
+
+// IfBranch is one condition of an if command and the list it guards.
+type IfBranch struct {
+	Condition []*Pipeline
+	Body      []*Pipeline
+}
+
+func (*IfClause) compound() {}
+
+// BraceGroup is "{ list; }", which runs the list in the current shell.
+type BraceGroup struct {
+	Body []*Pipeline
+}
+
+func (*BraceGroup) compound() {}
+
+// Subshell is "( list )", which runs the list without affecting the
+// variables and working directory of the shell. Source is the text of the
+// list, for running it in a child shell.
+type Subshell struct {
+	Body   []*Pipeline
+	Source string
+}
+
+func (*Subshell) compound() {}
+
+// parseForCommand parses a for loop. Without "in words" the loop runs over
+// the positional parameters, as if the list were "$@".
+func (parser *Parser) parseForCommand() (*Command, error) {
+	parser.nextToken() // skip for
+
+	if parser.currentToken.Type != lexer.Word || !lexer.IsName(parser.currentToken.Raw) {
+		return nil, ErrExpectedLoopVariable
+	}
+	clause := &ForClause{Name: parser.currentToken.Raw}
+	parser.nextToken()
+
+	if parser.atReservedWord("in") {
+		parser.nextToken()
+
+		for parser.currentToken.Type == lexer.Word {
+			clause.Words = append(clause.Words, parser.currentToken.Raw)
+			parser.nextToken()
+		}
+	} else {
+		clause.Words = []string{`"$@"`}
+	}
+
+	if parser.atSeparator() {
+		parser.nextToken()
+	}
+	parser.skipNewlines()
+
+	body, err := parser.parseLoopBody()
+	if err != nil {
+		return nil, err
+	}
+	clause.Body = body
+
+	return parser.finishCompound(clause)
+}
+
+// parseLoopBody parses "do list done".
+func (parser *Parser) parseLoopBody() ([]*Pipeline, error) {
+	if !parser.atReservedWord("do") {
+		return nil, parser.incompleteAtEOF(ErrExpectedDo)
+	}
+	parser.nextToken()
+
+	return parser.parseListUntil("done", ErrExpectedDone)
+}
+
+// parseIfCommand parses an if command with its elif and else branches.
+func (parser *Parser) parseIfCommand() (*Command, error) {
+	clause := &IfClause{}
+
+	for {
+		parser.nextToken() // skip if or elif
+
+		condition, err := parser.parseListUntil("then", ErrExpectedThen)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := parser.parseList("elif", "else", "fi")
+		if err != nil {
+			return nil, err
+		}
+		clause.Branches = append(clause.Branches, IfBranch{Condition: condition, Body: body})
+
+		if !parser.atReservedWord("elif") {
+			break
+		}
+	}
+
+	if parser.atReservedWord("else") {
+		parser.nextToken()
+
+		body, err := parser.parseList("fi")
+		if err != nil {
+			return nil, err
+		}
+		clause.Else = body
+	}
+
+	if !parser.atReservedWord("fi") {
+		return nil, parser.incompleteAtEOF(ErrExpectedFi)
+	}
+	parser.nextToken()
+
+	return parser.finishCompound(clause)
+}
+
+// parseBraceGroup parses "{ list; }".
+func (parser *Parser) parseBraceGroup() (*Command, error) {
+	parser.nextToken() // skip {
+
+	body, err := parser.parseListUntil("}", ErrExpectedCloseBrace)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.finishCompound(&BraceGroup{Body: body})
+}
+
+// parseSubshell parses "( list )".
+func (parser *Parser) parseSubshell() (*Command, error) {
+	parser.nextToken() // skip (
+	start := parser.currentToken.Pos.Offset
+
+	body, err := parser.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.currentToken.Type != lexer.RParen {
+		return nil, parser.incompleteAtEOF(ErrExpectedCloseSubshell)
+	}
+	source := strings.TrimSpace(parser.lexer.Text(start, parser.currentToken.Pos.Offset))
+	parser.nextToken()
+
+	return parser.finishCompound(&Subshell{Body: body, Source: source})
+}
+
+// parseListUntil parses a list and the reserved word that must end it,
+// failing with missing if the word is absent.
+func (parser *Parser) parseListUntil(word string, missing error) ([]*Pipeline, error) {
+	list, err := parser.parseList(word)
+	if err != nil {
+		return nil, err
+	}
+
+	if !parser.atReservedWord(word) {
+		return nil, parser.incompleteAtEOF(missing)
+	}
+	parser.nextToken()
+
+	return list, nil
+}
+
+// finishCompound wraps a compound command after its closing word, which
+// must not be followed by further words.
+func (parser *Parser) finishCompound(compound CompoundCommand) (*Command, error) {
+	if parser.currentToken.Type == lexer.Word || parser.currentToken.Type == lexer.LParen {
+		return nil, fmt.Errorf("%w '%s'", ErrUnexpectedToken, parser.currentToken.Value)
+	}
+
+	return &Command{Compound: compound}, nil
+}
+
+// atReservedWord reports whether the current token is one of the given
+// reserved words. Quoted words are never reserved.
+func (parser *Parser) atReservedWord(words ...string) bool {
+	if parser.currentToken.Type != lexer.Word {
+		return false
+	}
+
+	for _, word := range words {
+		if parser.currentToken.Raw == word {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,234 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"dsh/internal/lexer"
+)
+
+var (
+	// ErrUnterminatedConditional indicates a [[ without a matching ]].
+	ErrUnterminatedConditional = errors.New("expected ']]' to close conditional expression")
+	// ErrEmptyConditional indicates [[ ]] with no expression inside.
+	ErrEmptyConditional = errors.New("expected expression inside [[ ]]")
+	// ErrExpectedCloseParen indicates a ( without a matching ) in [[ ]].
+	ErrExpectedCloseParen = errors.New("expected ')' in conditional expression")
+	// ErrExpectedOperand indicates an operator with a missing operand in [[ ]].
+	ErrExpectedOperand = errors.New("expected operand in conditional expression")
+)
+
+// CondExpr is a node of a [[ ... ]] conditional expression. Op is "&&",
+// "||" or "!" for the logical nodes, a test operator such as "-f" or "=="
+// for leaves, or empty for a lone word that is true when non-empty.
+// Operands hold the source text of the words, expanded at evaluation.
+type CondExpr struct {
+	Op       string
+	Left     *CondExpr
+	Right    *CondExpr
+	Operands []string
+}
+
+// unaryCondOperators lists the operators that take a single operand.
+var unaryCondOperators = map[string]bool{ //nolint:gochecknoglobals // Operator lookup table
+	"-a": true, "-b": true, "-c": true, "-d": true, "-e": true, "-f": true,
+	"-g": true, "-h": true, "-k": true, "-n": true, "-o": true, "-p": true,
+	"-r": true, "-s": true, "-t": true, "-u": true, "-v": true, "-w": true,
+	"-x": true, "-z": true, "-G": true, "-L": true, "-N": true, "-O": true,
+	"-R": true, "-S": true,
+}
+
+// binaryCondOperators lists the operators that take two operands.
+var binaryCondOperators = map[string]bool{ //nolint:gochecknoglobals // Operator lookup table
+	"=": true, "==": true, "!=": true, "=~": true, "<": true, ">": true,
+	"-eq": true, "-ne": true, "-lt": true, "-le": true, "-gt": true, "-ge": true,
+	"-nt": true, "-ot": true, "-ef": true,
+}
+
+// parseConditionalCommand parses [[ expression ]]. Inside the brackets
+// && and || are logical operators, and < and > compare strings.
+func (parser *Parser) parseConditionalCommand() (*Command, error) {
+	parser.nextToken() // skip [[
+
+	if parser.atConditionalEnd() {
+		if parser.currentToken.Type == lexer.EOF {
+			return nil, parser.incompleteAtEOF(ErrUnterminatedConditional)
+		}
+
+		return nil, ErrEmptyConditional
+	}
+
+	expr, err := parser.parseCondOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !parser.atConditionalEnd() || parser.currentToken.Type == lexer.EOF {
+		return nil, parser.incompleteAtEOF(ErrUnterminatedConditional)
+	}
+	parser.nextToken() // skip ]]
+
+	cmd := &Command{Conditional: expr}
+
+	for parser.isRedirectToken() {
+		err := parser.parseRedirect(cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if parser.currentToken.Type == lexer.Word {
+		return nil, fmt.Errorf("%w '%s'", ErrUnexpectedToken, parser.currentToken.Value)
+	}
+
+	if parser.currentToken.Type == lexer.Background {
+		cmd.Background = true
+		parser.nextToken()
+	}
+
+	return cmd, nil
+}
+
+func (parser *Parser) atConditionalEnd() bool {
+	return parser.currentToken.Type == lexer.EOF ||
+		(parser.currentToken.Type == lexer.Word && parser.currentToken.Value == "]]")
+}
+
+func (parser *Parser) isRedirectToken() bool {
+	return parser.currentToken.Type == lexer.RedirectOut ||
+		parser.currentToken.Type == lexer.RedirectIn ||
+		parser.currentToken.Type == lexer.RedirectAppend ||
+		parser.currentToken.Type == lexer.RedirectClobber ||
+		parser.currentToken.Type == lexer.RedirectDupOut ||
+		parser.currentToken.Type == lexer.RedirectDupIn ||
+		parser.currentToken.Type == lexer.HereDoc
+}
+
+func (parser *Parser) parseCondOr() (*CondExpr, error) {
+	left, err := parser.parseCondAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.currentToken.Type == lexer.Or {
+		parser.nextToken()
+
+		right, err := parser.parseCondAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &CondExpr{Op: "||", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (parser *Parser) parseCondAnd() (*CondExpr, error) {
+	left, err := parser.parseCondNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.currentToken.Type == lexer.And {
+		parser.nextToken()
+
+		right, err := parser.parseCondNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &CondExpr{Op: "&&", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (parser *Parser) parseCondNot() (*CondExpr, error) {
+	if parser.currentToken.Type == lexer.Word && parser.currentToken.Value == "!" {
+		parser.nextToken()
+
+		operand, err := parser.parseCondNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return &CondExpr{Op: "!", Left: operand}, nil
+	}
+
+	return parser.parseCondPrimary()
+}
+
+func (parser *Parser) parseCondPrimary() (*CondExpr, error) {
+	if parser.currentToken.Type == lexer.LParen {
+		parser.nextToken()
+
+		expr, err := parser.parseCondOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if parser.currentToken.Type != lexer.RParen {
+			return nil, ErrExpectedCloseParen
+		}
+		parser.nextToken()
+
+		return expr, nil
+	}
+
+	if !parser.isCondOperand() {
+		return nil, ErrExpectedOperand
+	}
+
+	if unaryCondOperators[parser.currentToken.Value] && parser.peekIsCondOperand() {
+		op := parser.currentToken.Value
+		parser.nextToken()
+		operand := parser.currentToken.Value
+		parser.nextToken()
+
+		return &CondExpr{Op: op, Operands: []string{operand}}, nil
+	}
+
+	return parser.parseCondComparison()
+}
+
+// parseCondComparison parses "word" or "word op word".
+func (parser *Parser) parseCondComparison() (*CondExpr, error) {
+	left := parser.currentToken.Raw
+	parser.nextToken()
+
+	op, isBinary := parser.condBinaryOperator()
+	if !isBinary {
+		return &CondExpr{Operands: []string{left}}, nil
+	}
+	parser.nextToken()
+
+	if !parser.isCondOperand() {
+		return nil, ErrExpectedOperand
+	}
+	right := parser.currentToken.Raw
+	parser.nextToken()
+
+	return &CondExpr{Op: op, Operands: []string{left, right}}, nil
+}
+
+// condBinaryOperator returns the current token as a binary operator. The
+// lexer reports < and > as redirections, which mean comparison here.
+func (parser *Parser) condBinaryOperator() (string, bool) {
+	switch parser.currentToken.Type {
+	case lexer.RedirectIn:
+		return "<", true
+	case lexer.RedirectOut:
+		return ">", true
+	case lexer.Word:
+		return parser.currentToken.Value, binaryCondOperators[parser.currentToken.Value]
+	default:
+		return "", false
+	}
+}
+
+func (parser *Parser) isCondOperand() bool {
+	return parser.currentToken.Type == lexer.Word && parser.currentToken.Value != "]]"
+}
+
+func (parser *Parser) peekIsCondOperand() bool {
+	return parser.peekToken.Type == lexer.Word && parser.peekToken.Value != "]]"
+}
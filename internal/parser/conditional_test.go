@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"dsh/internal/lexer"
+)
+
+func parseConditional(t *testing.T, input string) *CondExpr {
+	t.Helper()
+
+	pipelines, err := New(lexer.New(input)).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("%q: parse error: %v", input, err)
+	}
+
+	cmd := pipelines[0].Commands[0]
+	if cmd.Conditional == nil {
+		t.Fatalf("%q: expected a conditional command", input)
+	}
+
+	return cmd.Conditional
+}
+
+func TestParser_ConditionalLeaves(t *testing.T) {
+	tests := []struct {
+		input    string
+		op       string
+		operands []string
+	}{
+		{"[[ word ]]", "", []string{"word"}},
+		{"[[ -f /etc/passwd ]]", "-f", []string{"/etc/passwd"}},
+		{"[[ a == b* ]]", "==", []string{"a", "b*"}},
+		{"[[ a < b ]]", "<", []string{"a", "b"}},
+		{"[[ a > b ]]", ">", []string{"a", "b"}},
+		{"[[ 1 -lt 2 ]]", "-lt", []string{"1", "2"}},
+		{"[[ -n ]]", "", []string{"-n"}},
+	}
+
+	for _, test := range tests {
+		expr := parseConditional(t, test.input)
+		if expr.Op != test.op {
+			t.Errorf("%q: expected op %q, got %q", test.input, test.op, expr.Op)
+		}
+		if len(expr.Operands) != len(test.operands) {
+			t.Fatalf("%q: expected operands %v, got %v", test.input, test.operands, expr.Operands)
+		}
+		for i := range test.operands {
+			if expr.Operands[i] != test.operands[i] {
+				t.Errorf("%q: operand %d: expected %q, got %q", test.input, i, test.operands[i], expr.Operands[i])
+			}
+		}
+	}
+}
+
+func TestParser_ConditionalPrecedence(t *testing.T) {
+	expr := parseConditional(t, "[[ a || ! b && ( c || d ) ]]")
+
+	if expr.Op != "||" {
+		t.Fatalf("Expected || at the root, got %q", expr.Op)
+	}
+	if expr.Right.Op != "&&" {
+		t.Fatalf("Expected && to bind tighter than ||, got %q", expr.Right.Op)
+	}
+	if expr.Right.Left.Op != "!" {
+		t.Errorf("Expected ! on the left of &&, got %q", expr.Right.Left.Op)
+	}
+	if expr.Right.Right.Op != "||" {
+		t.Errorf("Expected parenthesised ||, got %q", expr.Right.Right.Op)
+	}
+}
+
+func TestParser_ConditionalInList(t *testing.T) {
+	pipelines, err := New(lexer.New("[[ -d /tmp ]] && echo dir")).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if len(pipelines) != 2 {
+		t.Fatalf("Expected 2 pipelines, got %d", len(pipelines))
+	}
+	if pipelines[0].Operator != OpAnd {
+		t.Errorf("Expected && after the conditional, got %v", pipelines[0].Operator)
+	}
+}
+
+func TestParser_ConditionalErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"[[ a", ErrUnterminatedConditional},
+		{"[[ ]]", ErrEmptyConditional},
+		{"[[ ( a ]]", ErrExpectedCloseParen},
+		{"[[ a == ]]", ErrExpectedOperand},
+		{"[[ a ]] b", ErrUnexpectedToken},
+	}
+
+	for _, test := range tests {
+		_, err := New(lexer.New(test.input)).ParseCommandLine()
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected error %v, got %v", test.input, test.err, err)
+		}
+	}
+}
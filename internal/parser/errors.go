@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"dsh/internal/lexer"
+)
+
+// SyntaxError is an error in the input together with where it was found.
+// Err is one of the sentinel errors of this package or, for input that
+// ends too early, the lexer's reason wrapped in ErrIncompleteInput.
+type SyntaxError struct {
+	Pos lexer.Position
+	// Source is the line of input that holds Pos.
+	Source string
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %v", e.Pos.Line, e.Pos.Column, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Excerpt returns the offending line with a caret under the error's
+// column. Tabs are kept in the padding so the caret lines up.
+func (e *SyntaxError) Excerpt() string {
+	var padding strings.Builder
+
+	for i, ch := range []rune(e.Source) {
+		if i >= e.Pos.Column-1 {
+			break
+		}
+		if ch == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	return e.Source + "\n" + padding.String() + "^"
+}
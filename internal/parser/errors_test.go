@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"dsh/internal/lexer"
+)
+
+func TestSyntaxError_Position(t *testing.T) {
+	tests := []struct {
+		input   string
+		err     error
+		line    int
+		column  int
+		message string
+	}{
+		{"echo ok\necho bad >", ErrExpectedFilenameAfterOut, 2, 11, "2:11: expected filename after '>'"},
+		{"cat < ; ls", ErrExpectedFilenameAfterIn, 1, 7, "1:7: expected filename after '<'"},
+		{"true; fi", ErrUnexpectedToken, 1, 7, "1:7: syntax error near unexpected token 'fi'"},
+		{"echo a\necho 'b\nc", lexer.ErrUnterminatedString, 2, 6, "2:6: incomplete input: unterminated quoted string"},
+		{"cat <<EOF\nbody", lexer.ErrUnterminatedHereDoc, 1, 5, "1:5: incomplete input: here-document not terminated by its delimiter"},
+	}
+
+	for _, test := range tests {
+		_, err := New(lexer.New(test.input)).ParseCommandLine()
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected a SyntaxError, got %v", test.input, err)
+
+			continue
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.err, err)
+		}
+		if syntaxErr.Pos.Line != test.line || syntaxErr.Pos.Column != test.column {
+			t.Errorf("%q: expected %d:%d, got %d:%d", test.input, test.line, test.column,
+				syntaxErr.Pos.Line, syntaxErr.Pos.Column)
+		}
+		if err.Error() != test.message {
+			t.Errorf("%q: expected message %q, got %q", test.input, test.message, err.Error())
+		}
+	}
+}
+
+func TestSyntaxError_Excerpt(t *testing.T) {
+	_, err := New(lexer.New("if true; then\n\techo x >\nfi")).ParseCommandLine()
+
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Expected a SyntaxError, got %v", err)
+	}
+
+	expected := "\techo x >\n\t        ^"
+	if syntaxErr.Excerpt() != expected {
+		t.Errorf("Expected excerpt %q, got %q", expected, syntaxErr.Excerpt())
+	}
+}
+
+func TestSyntaxError_EmptyInput(t *testing.T) {
+	_, err := New(lexer.New("   ")).ParseCommandLine()
+
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		t.Errorf("Expected empty input not to be a syntax error, got %v", err)
+	}
+}
@@ -3,39 +3,119 @@ package parser
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
+	"dsh/internal/expand"
 	"dsh/internal/lexer"
 )
 
 var (
 	// ErrExpectedCommandAfterPipe indicates missing command after pipe operator.
-	ErrExpectedCommandAfterPipe = errors.New("expected command after pipe")
+	ErrExpectedCommandAfterPipe = errors.New("expected command after '|'")
 	// ErrExpectedFilenameAfterOut indicates missing filename after > operator.
-	ErrExpectedFilenameAfterOut = errors.New("expected filename after >")
+	ErrExpectedFilenameAfterOut = errors.New("expected filename after '>'")
 	// ErrExpectedFilenameAfterAppend indicates missing filename after >> operator.
-	ErrExpectedFilenameAfterAppend = errors.New("expected filename after >>")
+	ErrExpectedFilenameAfterAppend = errors.New("expected filename after '>>'")
+	// ErrExpectedFilenameAfterClobber indicates missing filename after >| operator.
+	ErrExpectedFilenameAfterClobber = errors.New("expected filename after '>|'")
 	// ErrExpectedFilenameAfterIn indicates missing filename after < operator.
-	ErrExpectedFilenameAfterIn = errors.New("expected filename after <")
+	ErrExpectedFilenameAfterIn = errors.New("expected filename after '<'")
+	// ErrExpectedDescriptorAfterDupOut indicates missing descriptor after >& operator.
+	ErrExpectedDescriptorAfterDupOut = errors.New("expected file descriptor after '>&'")
+	// ErrExpectedDescriptorAfterDupIn indicates missing descriptor after <& operator.
+	ErrExpectedDescriptorAfterDupIn = errors.New("expected file descriptor after '<&'")
+	// ErrBadDescriptor indicates a descriptor number too large to use.
+	ErrBadDescriptor = errors.New("bad file descriptor")
 	// ErrNoCommand indicates no command was found in input.
 	ErrNoCommand = errors.New("no command found")
 	// ErrEmptyPipeline indicates an empty pipeline.
 	ErrEmptyPipeline = errors.New("empty pipeline")
 	// ErrNoTokens indicates no tokens to parse.
 	ErrNoTokens = errors.New("no tokens to parse")
+	// ErrUnexpectedToken indicates an operator where a command was expected.
+	ErrUnexpectedToken = errors.New("syntax error near unexpected token")
+	// ErrExpectedCommandAfterAnd indicates missing command after && operator.
+	ErrExpectedCommandAfterAnd = errors.New("expected command after '&&'")
+	// ErrExpectedCommandAfterOr indicates missing command after || operator.
+	ErrExpectedCommandAfterOr = errors.New("expected command after '||'")
+	// ErrExpectedHereDocDelimiter indicates missing delimiter after << operator.
+	ErrExpectedHereDocDelimiter = errors.New("expected delimiter after '<<'")
+	// ErrIncompleteInput indicates input that ends in the middle of a
+	// command, such as after a pipe or inside quotes. It wraps the specific
+	// error; an interactive shell reads another line and tries again.
+	ErrIncompleteInput = errors.New("incomplete input")
 )
 
 // Command represents a single command with its arguments and redirections.
+// Args holds the words with quotes removed, while Words keeps their source
+// text for expansion when the command runs. Assignments are the name=value
+// words before the command name; a command may consist of those alone.
+// Redirections, here documents included, are kept in the order they were
+// written and apply left to right, so that 2>&1 >file copies the standard
+// output from before the file was opened. A [[ ... ]] command carries its
+// expression in Conditional, and other compound commands such as for loops
+// in Compound; both have no Args. Source is the text of the command, for
+// running it in a child shell.
 type Command struct {
-	Args       []string
-	InputFile  string
-	OutputFile string
-	AppendMode bool
-	Background bool
+	Args         []string
+	Words        []string
+	Assignments  []string
+	Redirections []Redirection
+	Background   bool
+	Conditional  *CondExpr
+	Compound     CompoundCommand
+	Source       string
 }
 
-// Pipeline represents a sequence of commands connected by pipes.
+// Redirection redirects a descriptor: to a file for <, >, 2>file, 3>>file,
+// 2>|file and 3<file, where >| overwrites files even under noclobber, to
+// the body of HereDoc for <<, or to a copy of another descriptor for 2>&1
+// and 0<&3, where a Target of - closes the descriptor instead. Target is
+// source text, expanded when the command runs.
+type Redirection struct {
+	Fd      int
+	Op      lexer.TokenType
+	Target  string
+	HereDoc *lexer.HereDocument
+}
+
+// ListOperator describes how a pipeline is joined to the one after it.
+type ListOperator int
+
+const (
+	// OpSequence runs the next pipeline unconditionally (; or end of input).
+	OpSequence ListOperator = iota
+	// OpAnd runs the next pipeline only if this one succeeded (&&).
+	OpAnd
+	// OpOr runs the next pipeline only if this one failed (||).
+	OpOr
+)
+
+// TimeMode says whether the time keyword precedes a pipeline, and how
+// the time it took is reported.
+type TimeMode int
+
+const (
+	// TimeNone runs the pipeline untimed.
+	TimeNone TimeMode = iota
+	// TimeDefault reports in the format of TIMEFORMAT (time).
+	TimeDefault
+	// TimePosix reports in the POSIX format (time -p).
+	TimePosix
+	// TimeVerbose also reports memory and context switches (time -v).
+	TimeVerbose
+)
+
+// Pipeline represents a sequence of commands connected by pipes. A timed
+// pipeline may have no commands, as time on its own reports nothing run.
 type Pipeline struct {
 	Commands []*Command
+	Operator ListOperator
+	Time     TimeMode
+	// Negated is set by a leading !, which inverts the pipeline's status.
+	Negated bool
 }
 
 // Parser parses tokens into command structures.
@@ -58,18 +138,53 @@ func New(l *lexer.Lexer) *Parser {
 	return parser
 }
 
-// ParseCommandLine parses a complete command line into pipelines.
+// ParseCommandLine parses a complete command line into pipelines. Lines
+// are separated by newlines, which end commands like ;. Errors are
+// returned as a *SyntaxError; input that stops in the middle of a command
+// fails with ErrIncompleteInput.
 func (parser *Parser) ParseCommandLine() ([]*Pipeline, error) {
+	pipelines, err := parser.parseList()
+	if err == nil && parser.currentToken.Type != lexer.EOF {
+		err = fmt.Errorf("%w '%s'", ErrUnexpectedToken, parser.currentToken.Value)
+	}
+
+	// An open quote or here-document can make any error that follows
+	// it at the end of input misleading, so it takes precedence
+	if lexErr := parser.lexer.Err(); lexErr != nil && (err == nil || parser.currentToken.Type == lexer.EOF) {
+		return nil, parser.syntaxError(parser.lexer.ErrPos(), fmt.Errorf("%w: %w", ErrIncompleteInput, lexErr))
+	}
+
+	if errors.Is(err, ErrEmptyPipeline) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, parser.syntaxError(parser.currentToken.Pos, err)
+	}
+
+	return pipelines, nil
+}
+
+// syntaxError locates err at pos. Errors are detected at the token that
+// cannot continue the command, which is where they are reported.
+func (parser *Parser) syntaxError(pos lexer.Position, err error) *SyntaxError {
+	return &SyntaxError{Pos: pos, Source: parser.lexer.SourceLine(pos), Err: err}
+}
+
+// parseList parses pipelines up to the end of input, a closing
+// parenthesis or, inside a compound command, the first of the given
+// reserved words in command position.
+func (parser *Parser) parseList(terminators ...string) ([]*Pipeline, error) {
 	var pipelines []*Pipeline
 
-	for parser.currentToken.Type != lexer.EOF {
+	for !parser.atListEnd(terminators...) {
 		pipeline, err := parser.parsePipeline()
 		if err != nil {
 			if errors.Is(err, ErrEmptyPipeline) {
 				// Skip empty pipelines, continue parsing
-				if parser.currentToken.Type == lexer.Semicolon {
-					parser.nextToken()
+				if !parser.atSeparator() {
+					return nil, fmt.Errorf("%w '%s'", ErrUnexpectedToken, parser.currentToken.Value)
 				}
+				parser.nextToken()
 
 				continue
 			}
@@ -81,14 +196,73 @@ func (parser *Parser) ParseCommandLine() ([]*Pipeline, error) {
 			pipelines = append(pipelines, pipeline)
 		}
 
-		if parser.currentToken.Type == lexer.Semicolon {
-			parser.nextToken()
+		err = parser.parseListOperator(pipeline)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	return pipelines, nil
 }
 
+func (parser *Parser) atListEnd(terminators ...string) bool {
+	return parser.currentToken.Type == lexer.EOF ||
+		parser.currentToken.Type == lexer.RParen ||
+		parser.atReservedWord(terminators...)
+}
+
+func (parser *Parser) atSeparator() bool {
+	return parser.currentToken.Type == lexer.Semicolon || parser.currentToken.Type == lexer.Newline
+}
+
+// skipNewlines skips the line breaks allowed after operators and reserved
+// words.
+func (parser *Parser) skipNewlines() {
+	for parser.currentToken.Type == lexer.Newline {
+		parser.nextToken()
+	}
+}
+
+// parseListOperator consumes the operator after a pipeline. && and || must
+// be followed by another command, possibly on a later line.
+func (parser *Parser) parseListOperator(pipeline *Pipeline) error {
+	switch parser.currentToken.Type {
+	case lexer.Semicolon, lexer.Newline:
+		parser.nextToken()
+	case lexer.And, lexer.Or:
+		pipeline.Operator = OpAnd
+		missing := ErrExpectedCommandAfterAnd
+		if parser.currentToken.Type == lexer.Or {
+			pipeline.Operator = OpOr
+			missing = ErrExpectedCommandAfterOr
+		}
+		parser.nextToken()
+		parser.skipNewlines()
+
+		if !parser.startsCommand() {
+			return parser.incompleteAtEOF(missing)
+		}
+	default:
+	}
+
+	return nil
+}
+
+// incompleteAtEOF marks err as incomplete input when the parser has run
+// out of tokens, since more input could still complete the command.
+func (parser *Parser) incompleteAtEOF(err error) error {
+	if parser.currentToken.Type == lexer.EOF {
+		return fmt.Errorf("%w: %w", ErrIncompleteInput, err)
+	}
+
+	return err
+}
+
+// startsCommand reports whether the current token can begin a command.
+func (parser *Parser) startsCommand() bool {
+	return parser.currentToken.Type == lexer.Word || parser.currentToken.Type == lexer.LParen
+}
+
 func (parser *Parser) nextToken() {
 	parser.currentToken = parser.peekToken
 	parser.peekToken = parser.lexer.NextToken()
@@ -99,7 +273,17 @@ func (parser *Parser) parsePipeline() (*Pipeline, error) {
 		Commands: []*Command{},
 	}
 
-	cmd, err := parser.parseCommand()
+	// ! and time may come in either order
+	parser.parseNegation(pipeline)
+	if parser.atReservedWord("time") {
+		pipeline.Time = parser.parseTimeOptions()
+		parser.parseNegation(pipeline)
+	}
+	if (pipeline.Negated || pipeline.Time != TimeNone) && !parser.startsCommand() {
+		return pipeline, nil
+	}
+
+	cmd, err := parser.parseSourceCommand()
 	if err != nil {
 		if errors.Is(err, ErrNoTokens) {
 			return nil, ErrEmptyPipeline // Use sentinel error instead of nil
@@ -116,11 +300,12 @@ func (parser *Parser) parsePipeline() (*Pipeline, error) {
 
 	for parser.currentToken.Type == lexer.Pipe {
 		parser.nextToken()
+		parser.skipNewlines()
 
-		cmd, err := parser.parseCommand()
+		cmd, err := parser.parseSourceCommand()
 		if err != nil {
 			if errors.Is(err, ErrNoTokens) {
-				return nil, ErrExpectedCommandAfterPipe
+				return nil, parser.incompleteAtEOF(ErrExpectedCommandAfterPipe)
 			}
 
 			return nil, err
@@ -136,16 +321,73 @@ func (parser *Parser) parsePipeline() (*Pipeline, error) {
 	return pipeline, nil
 }
 
+// parseNegation consumes the ! words before a pipeline, each of which
+// inverts its status again.
+func (parser *Parser) parseNegation(pipeline *Pipeline) {
+	for parser.atReservedWord("!") {
+		pipeline.Negated = !pipeline.Negated
+		parser.nextToken()
+	}
+}
+
+// parseTimeOptions consumes the time keyword and its -p and -v options.
+func (parser *Parser) parseTimeOptions() TimeMode {
+	mode := TimeDefault
+
+	parser.nextToken()
+	for parser.atReservedWord("-p", "-v", "--") {
+		switch parser.currentToken.Raw {
+		case "-p":
+			mode = max(mode, TimePosix)
+		case "-v":
+			mode = TimeVerbose
+		default:
+			parser.nextToken()
+
+			return mode
+		}
+		parser.nextToken()
+	}
+
+	return mode
+}
+
+// parseSourceCommand parses a command and records its source text.
+func (parser *Parser) parseSourceCommand() (*Command, error) {
+	start := parser.currentToken.Pos.Offset
+
+	cmd, err := parser.parseCommand()
+	if cmd != nil {
+		cmd.Source = strings.TrimSpace(parser.lexer.Text(start, parser.currentToken.Pos.Offset))
+	}
+
+	return cmd, err
+}
+
 func (parser *Parser) parseCommand() (*Command, error) {
+	if parser.currentToken.Type == lexer.LParen {
+		return parser.parseSubshell()
+	}
+
 	if parser.currentToken.Type != lexer.Word {
 		return nil, ErrNoTokens
 	}
 
+	switch parser.currentToken.Raw {
+	case "[[":
+		return parser.parseConditionalCommand()
+	case "for":
+		return parser.parseForCommand()
+	case "if":
+		return parser.parseIfCommand()
+	case "{":
+		return parser.parseBraceGroup()
+	case "do", "done", "then", "elif", "else", "fi", "}":
+		return nil, fmt.Errorf("%w '%s'", ErrUnexpectedToken, parser.currentToken.Value)
+	}
+
 	cmd := &Command{
 		Args:       []string{},
-		InputFile:  "",
-		OutputFile: "",
-		AppendMode: false,
 		Background: false,
 	}
 
@@ -159,7 +401,7 @@ func (parser *Parser) parseCommand() (*Command, error) {
 		parser.nextToken()
 	}
 
-	if len(cmd.Args) == 0 {
+	if len(cmd.Args) == 0 && len(cmd.Assignments) == 0 {
 		return nil, ErrNoCommand
 	}
 
@@ -168,64 +410,94 @@ func (parser *Parser) parseCommand() (*Command, error) {
 
 func (parser *Parser) processCommandTokens(cmd *Command) error {
 	for parser.isCommandToken() {
-		switch parser.currentToken.Type {
-		case lexer.Word:
-			cmd.Args = append(cmd.Args, expandTilde(parser.currentToken.Value))
-			parser.nextToken()
-		case lexer.RedirectOut:
-			err := parser.handleOutputRedirect(cmd, false)
-			if err != nil {
-				return err
-			}
-		case lexer.RedirectAppend:
-			err := parser.handleOutputRedirect(cmd, true)
-			if err != nil {
-				return err
-			}
-		case lexer.RedirectIn:
-			err := parser.handleInputRedirect(cmd)
-			if err != nil {
-				return err
+		if parser.currentToken.Type == lexer.Word {
+			if len(cmd.Words) == 0 && expand.IsAssignment(parser.currentToken.Raw) {
+				cmd.Assignments = append(cmd.Assignments, parser.currentToken.Raw)
+			} else {
+				cmd.Args = append(cmd.Args, expand.Tilde(parser.currentToken.Value))
+				cmd.Words = append(cmd.Words, parser.currentToken.Raw)
 			}
-		case lexer.Pipe, lexer.Background, lexer.Semicolon, lexer.EOF:
-			return nil
+			parser.nextToken()
+
+			continue
+		}
+
+		err := parser.parseRedirect(cmd)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// parseRedirect parses the redirection operator at the current token and
+// its filename.
+func (parser *Parser) parseRedirect(cmd *Command) error {
+	if parser.currentToken.Type == lexer.HereDoc {
+		return parser.handleHereDoc(cmd)
+	}
+
+	return parser.handleDescriptorRedirect(cmd)
+}
+
 func (parser *Parser) isCommandToken() bool {
-	return parser.currentToken.Type == lexer.Word ||
-		parser.currentToken.Type == lexer.RedirectOut ||
-		parser.currentToken.Type == lexer.RedirectIn ||
-		parser.currentToken.Type == lexer.RedirectAppend
+	return parser.currentToken.Type == lexer.Word || parser.isRedirectToken()
 }
 
-func (parser *Parser) handleOutputRedirect(cmd *Command, appendMode bool) error {
+// handleDescriptorRedirect parses a redirection to a file, or a copy of a
+// descriptor with >& or <&, into Redirections. Without a number before
+// the operator, < and <& redirect the standard input and the others the
+// standard output.
+func (parser *Parser) handleDescriptorRedirect(cmd *Command) error {
+	token := parser.currentToken
+
+	fd := 1
+	if token.Type == lexer.RedirectIn || token.Type == lexer.RedirectDupIn {
+		fd = 0
+	}
+	if token.IONumber != "" {
+		number, err := strconv.Atoi(token.IONumber)
+		if err != nil {
+			return fmt.Errorf("%s: %w", token.IONumber, ErrBadDescriptor)
+		}
+		fd = number
+	}
+
 	parser.nextToken()
 	if parser.currentToken.Type != lexer.Word {
-		if appendMode {
+		switch token.Type {
+		case lexer.RedirectDupOut:
+			return ErrExpectedDescriptorAfterDupOut
+		case lexer.RedirectDupIn:
+			return ErrExpectedDescriptorAfterDupIn
+		case lexer.RedirectIn:
+			return ErrExpectedFilenameAfterIn
+		case lexer.RedirectAppend:
 			return ErrExpectedFilenameAfterAppend
+		case lexer.RedirectClobber:
+			return ErrExpectedFilenameAfterClobber
 		}
 
 		return ErrExpectedFilenameAfterOut
 	}
 
-	cmd.OutputFile = expandTilde(parser.currentToken.Value)
-	cmd.AppendMode = appendMode
+	cmd.Redirections = append(cmd.Redirections, Redirection{Fd: fd, Op: token.Type, Target: parser.currentToken.Raw})
 	parser.nextToken()
 
 	return nil
 }
 
-func (parser *Parser) handleInputRedirect(cmd *Command) error {
+// handleHereDoc parses << or <<- and its delimiter. The lexer reads the
+// document itself once it reaches the end of the line.
+func (parser *Parser) handleHereDoc(cmd *Command) error {
+	doc := parser.currentToken.HereDoc
 	parser.nextToken()
 	if parser.currentToken.Type != lexer.Word {
-		return ErrExpectedFilenameAfterIn
+		return parser.incompleteAtEOF(ErrExpectedHereDocDelimiter)
 	}
 
-	cmd.InputFile = expandTilde(parser.currentToken.Value)
+	cmd.Redirections = append(cmd.Redirections, Redirection{Fd: 0, Op: lexer.HereDoc, HereDoc: doc})
 	parser.nextToken()
 
 	return nil
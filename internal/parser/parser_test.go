@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"dsh/internal/lexer"
@@ -54,11 +56,12 @@ func TestParser_Redirection(t *testing.T) {
 
 	pipeline := commands[0]
 	cmd := pipeline.Commands[0]
-	if cmd.InputFile != "input.txt" {
-		t.Errorf("Expected input file 'input.txt', got '%s'", cmd.InputFile)
+	expected := []Redirection{
+		{Fd: 0, Op: lexer.RedirectIn, Target: "input.txt"},
+		{Fd: 1, Op: lexer.RedirectOut, Target: "output.txt"},
 	}
-	if cmd.OutputFile != "output.txt" {
-		t.Errorf("Expected output file 'output.txt', got '%s'", cmd.OutputFile)
+	if !reflect.DeepEqual(cmd.Redirections, expected) {
+		t.Errorf("Expected redirections %+v, got %+v", expected, cmd.Redirections)
 	}
 }
 
@@ -121,11 +124,64 @@ func TestParser_AppendRedirection(t *testing.T) {
 
 	pipeline := commands[0]
 	cmd := pipeline.Commands[0]
-	if cmd.OutputFile != "output.txt" {
-		t.Errorf("Expected output file 'output.txt', got '%s'", cmd.OutputFile)
+	expected := []Redirection{{Fd: 1, Op: lexer.RedirectAppend, Target: "output.txt"}}
+	if !reflect.DeepEqual(cmd.Redirections, expected) {
+		t.Errorf("Expected an appending redirection, got %+v", cmd.Redirections)
 	}
-	if !cmd.AppendMode {
-		t.Error("Expected append output mode")
+}
+
+func TestParser_ClobberRedirection(t *testing.T) {
+	commands, err := New(lexer.New("echo hello >| output.txt | cat")).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	cmd := commands[0].Commands[0]
+	expected := []Redirection{{Fd: 1, Op: lexer.RedirectClobber, Target: "output.txt"}}
+	if !reflect.DeepEqual(cmd.Redirections, expected) {
+		t.Errorf("Expected a clobbering redirection to output.txt, got %+v", cmd.Redirections)
+	}
+	if cmd.Source != "echo hello >| output.txt" || commands[0].Commands[1].Source != "cat" {
+		t.Errorf("Unexpected command sources %q and %q", cmd.Source, commands[0].Commands[1].Source)
+	}
+
+	_, err = New(lexer.New("echo >|")).ParseCommandLine()
+	if !errors.Is(err, ErrExpectedFilenameAfterClobber) {
+		t.Errorf("Expected ErrExpectedFilenameAfterClobber, got %v", err)
+	}
+}
+
+func TestParser_DescriptorRedirections(t *testing.T) {
+	commands, err := New(lexer.New("cmd 1>out 0<in 2>err 3>>log 2>&1 <&- 4<data")).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	// All of them stay in the order they were written
+	cmd := commands[0].Commands[0]
+	expected := []Redirection{
+		{Fd: 1, Op: lexer.RedirectOut, Target: "out"},
+		{Fd: 0, Op: lexer.RedirectIn, Target: "in"},
+		{Fd: 2, Op: lexer.RedirectOut, Target: "err"},
+		{Fd: 3, Op: lexer.RedirectAppend, Target: "log"},
+		{Fd: 2, Op: lexer.RedirectDupOut, Target: "1"},
+		{Fd: 0, Op: lexer.RedirectDupIn, Target: "-"},
+		{Fd: 4, Op: lexer.RedirectIn, Target: "data"},
+	}
+	if !reflect.DeepEqual(cmd.Redirections, expected) {
+		t.Errorf("Expected redirections %+v, got %+v", expected, cmd.Redirections)
+	}
+
+	errorTests := map[string]error{
+		"cmd 2>":  ErrExpectedFilenameAfterOut,
+		"cmd >&":  ErrExpectedDescriptorAfterDupOut,
+		"cmd 3<&": ErrExpectedDescriptorAfterDupIn,
+		"cmd 3<":  ErrExpectedFilenameAfterIn,
+	}
+	for input, want := range errorTests {
+		if _, err := New(lexer.New(input)).ParseCommandLine(); !errors.Is(err, want) {
+			t.Errorf("%q: expected %v, got %v", input, want, err)
+		}
 	}
 }
 
@@ -188,3 +244,289 @@ func TestParser_ComplexCommand(t *testing.T) {
 		t.Error("Expected at least one command")
 	}
 }
+
+func TestParser_ListOperators(t *testing.T) {
+	input := "true && echo yes || echo no; echo done"
+	l := lexer.New(input)
+	p := New(l)
+
+	pipelines, err := p.ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	expected := []ListOperator{OpAnd, OpOr, OpSequence, OpSequence}
+	if len(pipelines) != len(expected) {
+		t.Fatalf("Expected %d pipelines, got %d", len(expected), len(pipelines))
+	}
+
+	for i, want := range expected {
+		if pipelines[i].Operator != want {
+			t.Errorf("Pipeline %d: expected operator %v, got %v", i, want, pipelines[i].Operator)
+		}
+	}
+}
+
+func TestParser_ListOperatorErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"true &&", ErrExpectedCommandAfterAnd},
+		{"false ||", ErrExpectedCommandAfterOr},
+		{"&& true", ErrUnexpectedToken},
+		{"| cat", ErrUnexpectedToken},
+	}
+
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+
+		_, err := p.ParseCommandLine()
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected error %v, got %v", test.input, test.err, err)
+		}
+	}
+}
+
+func TestParser_Time(t *testing.T) {
+	tests := []struct {
+		input    string
+		mode     TimeMode
+		commands int
+	}{
+		{"ls | wc", TimeNone, 2},
+		{"time ls | wc", TimeDefault, 2},
+		{"time -p { ls; }", TimePosix, 1},
+		{"time -v -- ls", TimeVerbose, 1},
+		{"time", TimeDefault, 0},
+		{"'time' ls", TimeNone, 1},
+	}
+
+	for _, test := range tests {
+		pipelines, err := New(lexer.New(test.input)).ParseCommandLine()
+		if err != nil {
+			t.Fatalf("%q: parse error: %v", test.input, err)
+		}
+
+		if len(pipelines) != 1 {
+			t.Fatalf("%q: expected 1 pipeline, got %d", test.input, len(pipelines))
+		}
+
+		if pipelines[0].Time != test.mode || len(pipelines[0].Commands) != test.commands {
+			t.Errorf("%q: expected mode %v with %d commands, got %v with %d",
+				test.input, test.mode, test.commands, pipelines[0].Time, len(pipelines[0].Commands))
+		}
+	}
+}
+
+func TestParser_Assignments(t *testing.T) {
+	p := New(lexer.New(`A=1 B="x y" cmd C=2 "$A"`))
+
+	pipelines, err := p.ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	cmd := pipelines[0].Commands[0]
+	if len(cmd.Assignments) != 2 || cmd.Assignments[1] != `B="x y"` {
+		t.Errorf("Expected two raw assignments, got %q", cmd.Assignments)
+	}
+
+	expected := []string{"cmd", "C=2", `"$A"`}
+	if len(cmd.Words) != len(expected) {
+		t.Fatalf("Expected words %q, got %q", expected, cmd.Words)
+	}
+	for i, word := range expected {
+		if cmd.Words[i] != word {
+			t.Errorf("Word %d: expected %q, got %q", i, word, cmd.Words[i])
+		}
+	}
+
+	pipelines, err = New(lexer.New("arr=(a 'b c')")).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if got := pipelines[0].Commands[0].Assignments; len(got) != 1 || got[0] != "arr=(a 'b c')" {
+		t.Errorf("Expected array literal assignment, got %q", got)
+	}
+}
+
+func TestParser_Negation(t *testing.T) {
+	tests := []struct {
+		input    string
+		negated  bool
+		mode     TimeMode
+		commands int
+	}{
+		{"! ls | wc", true, TimeNone, 2},
+		{"! ! ls", false, TimeNone, 1},
+		{"time ! ls", true, TimeDefault, 1},
+		{"! time -p ls", true, TimePosix, 1},
+		{"!", true, TimeNone, 0},
+		{"'!' ls", false, TimeNone, 1},
+		{"!ls", false, TimeNone, 1},
+	}
+
+	for _, test := range tests {
+		pipelines, err := New(lexer.New(test.input)).ParseCommandLine()
+		if err != nil {
+			t.Fatalf("%q: parse error: %v", test.input, err)
+		}
+
+		got := pipelines[0]
+		if got.Negated != test.negated || got.Time != test.mode || len(got.Commands) != test.commands {
+			t.Errorf("%q: expected negated %v, mode %v and %d commands, got %v, %v and %d",
+				test.input, test.negated, test.mode, test.commands, got.Negated, got.Time, len(got.Commands))
+		}
+	}
+}
+
+func TestParser_ForLoop(t *testing.T) {
+	p := New(lexer.New(`for item in a "b c" $x; do echo $item; false; done; echo after`))
+
+	pipelines, err := p.ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if len(pipelines) != 2 {
+		t.Fatalf("Expected 2 pipelines, got %d", len(pipelines))
+	}
+
+	clause, ok := pipelines[0].Commands[0].Compound.(*ForClause)
+	if !ok {
+		t.Fatalf("Expected a for clause, got %#v", pipelines[0].Commands[0])
+	}
+	if clause.Name != "item" {
+		t.Errorf("Expected loop variable 'item', got %q", clause.Name)
+	}
+	if len(clause.Words) != 3 || clause.Words[1] != `"b c"` {
+		t.Errorf("Unexpected word list %q", clause.Words)
+	}
+	if len(clause.Body) != 2 {
+		t.Errorf("Expected 2 pipelines in the body, got %d", len(clause.Body))
+	}
+
+	pipelines, err = New(lexer.New("for arg do echo $arg; done")).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if clause, ok := pipelines[0].Commands[0].Compound.(*ForClause); !ok || len(clause.Words) != 1 || clause.Words[0] != `"$@"` {
+		t.Errorf("Expected a loop over the positional parameters, got %#v", pipelines[0].Commands[0].Compound)
+	}
+}
+
+func TestParser_ForLoopErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"for; do true; done", ErrExpectedLoopVariable},
+		{"for 1x in a; do true; done", ErrExpectedLoopVariable},
+		{"for i in a; true; done", ErrExpectedDo},
+		{"for i in a; do true", ErrExpectedDone},
+		{"for i in a; do true; done extra", ErrUnexpectedToken},
+		{"done", ErrUnexpectedToken},
+		{"true; do", ErrUnexpectedToken},
+	}
+
+	for _, test := range tests {
+		_, err := New(lexer.New(test.input)).ParseCommandLine()
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected error %v, got %v", test.input, test.err, err)
+		}
+	}
+}
+
+func TestParser_IncompleteInput(t *testing.T) {
+	inputs := []string{
+		`echo "open`,
+		"echo 'open",
+		"echo one |",
+		"true &&",
+		"false ||\n",
+		`echo \`,
+		"if true; then echo yes",
+		"if true\nthen echo yes\nelse",
+		"{ echo grouped",
+		"( echo sub",
+		"for i in a b\ndo",
+		"cat <<EOF\nline",
+		"[[ -n x",
+		"echo ${x",
+	}
+
+	for _, input := range inputs {
+		_, err := New(lexer.New(input)).ParseCommandLine()
+		if !errors.Is(err, ErrIncompleteInput) {
+			t.Errorf("%q: expected incomplete input, got %v", input, err)
+		}
+	}
+
+	for _, input := range []string{"echo )", "fi", "echo one | | cat", "{ true; } extra"} {
+		_, err := New(lexer.New(input)).ParseCommandLine()
+		if err == nil || errors.Is(err, ErrIncompleteInput) {
+			t.Errorf("%q: expected a syntax error, got %v", input, err)
+		}
+	}
+}
+
+func TestParser_IfClause(t *testing.T) {
+	input := "if false; then echo a\nelif true\nthen echo b; echo c\nelse echo d; fi"
+
+	pipelines, err := New(lexer.New(input)).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	clause, ok := pipelines[0].Commands[0].Compound.(*IfClause)
+	if !ok {
+		t.Fatalf("Expected an if clause, got %#v", pipelines[0].Commands[0])
+	}
+	if len(clause.Branches) != 2 {
+		t.Fatalf("Expected 2 branches, got %d", len(clause.Branches))
+	}
+	if len(clause.Branches[1].Body) != 2 {
+		t.Errorf("Expected 2 pipelines in the elif body, got %d", len(clause.Branches[1].Body))
+	}
+	if len(clause.Else) != 1 {
+		t.Errorf("Expected 1 pipeline in the else branch, got %d", len(clause.Else))
+	}
+}
+
+func TestParser_NewlinesAndGroups(t *testing.T) {
+	input := "echo a\n\n{ echo b\necho c; }\n( cd /; pwd )\necho d"
+
+	pipelines, err := New(lexer.New(input)).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if len(pipelines) != 4 {
+		t.Fatalf("Expected 4 pipelines, got %d", len(pipelines))
+	}
+	if group, ok := pipelines[1].Commands[0].Compound.(*BraceGroup); !ok || len(group.Body) != 2 {
+		t.Errorf("Expected a brace group of 2 pipelines, got %#v", pipelines[1].Commands[0].Compound)
+	}
+	if subshell, ok := pipelines[2].Commands[0].Compound.(*Subshell); !ok || len(subshell.Body) != 2 || subshell.Source != "cd /; pwd" {
+		t.Errorf("Expected a subshell of 2 pipelines, got %#v", pipelines[2].Commands[0].Compound)
+	}
+}
+
+func TestParser_HereDoc(t *testing.T) {
+	pipelines, err := New(lexer.New("cat <<-'END' > out\n\tline $x\n\tEND\necho after")).ParseCommandLine()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	cmd := pipelines[0].Commands[0]
+	if len(cmd.Redirections) != 2 || cmd.Redirections[0].HereDoc == nil {
+		t.Fatalf("Expected a here-document, got %+v", cmd.Redirections)
+	}
+	if doc := cmd.Redirections[0].HereDoc; doc.Body != "line $x\n" || !doc.Quoted {
+		t.Errorf("Unexpected here-document %+v", doc)
+	}
+	if cmd.Redirections[1].Target != "out" || len(pipelines) != 2 {
+		t.Errorf("Expected the rest of the line to parse, got %+v", pipelines)
+	}
+}
@@ -0,0 +1,312 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dsh/internal/dirs"
+)
+
+// gitTimeout bounds how long git status may run, so that a huge
+// repository only costs the dirty flag and the ahead and behind counts.
+const gitTimeout = 2 * time.Second
+
+// gitRepo is the repository the working directory is in.
+type gitRepo struct {
+	workTree string
+	gitDir   string
+}
+
+// gitStatus is what git status reports about the working tree and the
+// branch's upstream.
+type gitStatus struct {
+	dirty  bool
+	ahead  int
+	behind int
+}
+
+// gitCache holds the last status read in the background. The branch and
+// any operation in progress are read from .git for each prompt, which is
+// quick, while git status, which may take a while, runs in a goroutine
+// and the prompt is repainted when it finishes.
+var gitCache struct { //nolint:gochecknoglobals // Shared with the goroutine running git status
+	sync.Mutex
+	// key identifies the repository and the state of its HEAD and index
+	// that status was read for.
+	key    string
+	status gitStatus
+	known  bool
+	// repo and wanted are the repository and key the last prompt asked
+	// about. running is set while git status runs, and again if another
+	// prompt asked about another repository or key meanwhile. Asking for
+	// the same again, as \g in both PS1 and RPS1 does, shares the run.
+	repo    gitRepo
+	wanted  string
+	running bool
+	again   bool
+	// repainting is set while the prompt is drawn again with a result
+	// just read, which must not start another run.
+	repainting bool
+}
+
+// repaint is installed by the shell to draw the prompt again.
+var repaint func() //nolint:gochecknoglobals // Hook installed once at startup
+
+// SetRepaint installs the function called when a result read in the
+// background, such as git status, changes the prompt being shown.
+func SetRepaint(fn func()) {
+	gitCache.Lock()
+	defer gitCache.Unlock()
+
+	repaint = fn
+}
+
+// gitSegment returns the \g escape: the branch, or the commit when HEAD
+// is detached, * when the tree has changes, ↑ and ↓ with the commits
+// ahead of and behind the upstream, and any rebase, merge, cherry-pick,
+// revert or bisect in progress, as in (main*↑1|rebase). It is empty
+// outside a repository.
+func gitSegment() string {
+	repo, ok := findGitRepo(dirs.Current())
+	if !ok {
+		return ""
+	}
+
+	head, err := os.ReadFile(filepath.Join(repo.gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	branch := branchName(repo.gitDir, strings.TrimSpace(string(head)))
+	operation := gitOperation(repo.gitDir)
+
+	key := repo.workTree + "\n" + string(head)
+	if info, err := os.Stat(filepath.Join(repo.gitDir, "index")); err == nil {
+		key += info.ModTime().String()
+	}
+
+	status, known := cachedStatus(repo, key)
+
+	var segment strings.Builder
+	segment.WriteString("(" + branch)
+	if known {
+		if status.dirty {
+			segment.WriteString("*")
+		}
+		if status.ahead > 0 {
+			segment.WriteString("↑" + strconv.Itoa(status.ahead))
+		}
+		if status.behind > 0 {
+			segment.WriteString("↓" + strconv.Itoa(status.behind))
+		}
+	}
+	if operation != "" {
+		segment.WriteString("|" + operation)
+	}
+	segment.WriteString(")")
+
+	return segment.String()
+}
+
+// findGitRepo finds the repository dir is in, looking in each directory
+// up to the root for .git, which is a directory or, in a linked worktree
+// or submodule, a file naming the git directory.
+func findGitRepo(dir string) (gitRepo, bool) {
+	for dir != "" {
+		path := filepath.Join(dir, ".git")
+
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+		case info.IsDir():
+			return gitRepo{workTree: dir, gitDir: path}, true
+		default:
+			content, err := os.ReadFile(path) //nolint:gosec // Reading the repository is the point
+			if err != nil {
+				return gitRepo{}, false
+			}
+
+			gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+			if !ok {
+				return gitRepo{}, false
+			}
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+
+			return gitRepo{workTree: dir, gitDir: gitDir}, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return gitRepo{}, false
+}
+
+// branchName returns the branch that head, the content of HEAD, names,
+// or the short commit when it is detached. During a rebase HEAD is
+// detached and the branch being rebased is recorded beside it.
+func branchName(gitDir, head string) string {
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		if ref, err := os.ReadFile(filepath.Join(gitDir, dir, "head-name")); err == nil { //nolint:gosec // Reading the repository is the point
+			return strings.TrimPrefix(strings.TrimSpace(string(ref)), "refs/heads/")
+		}
+	}
+
+	const shortCommit = 7
+	if len(head) > shortCommit {
+		return head[:shortCommit]
+	}
+
+	return head
+}
+
+// gitOperation returns the operation in progress in the repository, or ""
+// if there is none.
+func gitOperation(gitDir string) string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(gitDir, name))
+
+		return err == nil
+	}
+
+	switch {
+	case exists("rebase-merge"), exists("rebase-apply"):
+		return "rebase"
+	case exists("MERGE_HEAD"):
+		return "merge"
+	case exists("CHERRY_PICK_HEAD"):
+		return "cherry-pick"
+	case exists("REVERT_HEAD"):
+		return "revert"
+	case exists("BISECT_LOG"):
+		return "bisect"
+	}
+
+	return ""
+}
+
+// cachedStatus returns the last status read for key, if any, and starts
+// reading it again in the background, since the working tree may have
+// changed without HEAD or the index changing. A run already reading the
+// same key is left to finish rather than repeated.
+func cachedStatus(repo gitRepo, key string) (gitStatus, bool) {
+	gitCache.Lock()
+	defer gitCache.Unlock()
+
+	if !gitCache.repainting {
+		// The goroutine waits for the lock, so sees repo and key
+		if !gitCache.running {
+			gitCache.running = true
+			go refreshStatus()
+		} else if gitCache.repo != repo || gitCache.wanted != key {
+			gitCache.again = true
+		}
+		gitCache.repo, gitCache.wanted = repo, key
+	}
+
+	if gitCache.key != key {
+		return gitStatus{}, false
+	}
+
+	return gitCache.status, gitCache.known
+}
+
+// refreshStatus runs git status for the repository the last prompt asked
+// about and, if the result changes what the prompt shows, repaints it.
+func refreshStatus() {
+	for {
+		gitCache.Lock()
+		repo, key := gitCache.repo, gitCache.wanted
+		gitCache.again = false
+		gitCache.Unlock()
+
+		status, known := readStatus(repo.workTree)
+
+		gitCache.Lock()
+		changed := gitCache.key != key || gitCache.known != known || gitCache.status != status
+		gitCache.key, gitCache.status, gitCache.known = key, status, known
+
+		again := gitCache.again
+		gitCache.running = again
+
+		draw := repaint
+		if !changed {
+			draw = nil
+		}
+		gitCache.repainting = draw != nil
+		gitCache.Unlock()
+
+		if draw != nil {
+			draw()
+
+			gitCache.Lock()
+			gitCache.repainting = false
+			gitCache.Unlock()
+		}
+
+		if !again {
+			return
+		}
+	}
+}
+
+// readStatus runs git status in workTree, giving up after gitTimeout.
+// It does not take the index lock, so it never gets in the way of git
+// commands the user runs meanwhile.
+func readStatus(workTree string) (gitStatus, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "-C", workTree, "status", "--porcelain=v2", "--branch")
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return gitStatus{}, false
+	}
+
+	return parseStatus(output), true
+}
+
+// parseStatus reads the output of git status --porcelain=v2 --branch, in
+// which a "# branch.ab +1 -2" header gives the commits ahead and behind
+// and every line not starting with # is a changed or untracked file.
+func parseStatus(output []byte) gitStatus {
+	var status gitStatus
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if ab, ok := strings.CutPrefix(line, "# branch.ab "); ok {
+			ahead, behind, _ := strings.Cut(ab, " ")
+			status.ahead, _ = strconv.Atoi(strings.TrimPrefix(ahead, "+"))
+			status.behind, _ = strconv.Atoi(strings.TrimPrefix(behind, "-"))
+
+			continue
+		}
+
+		if line != "" && !strings.HasPrefix(line, "#") {
+			status.dirty = true
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,150 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFiles creates the files named by the keys of files under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGit_ReadsHead(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".git/HEAD":          "ref: refs/heads/feature/x\n",
+		"sub/dir/file":       "",
+		"linked/.git":        "gitdir: ../worktrees/linked\n",
+		"worktrees/linked/x": "",
+	})
+
+	repo, ok := findGitRepo(filepath.Join(root, "sub", "dir"))
+	if !ok || repo.workTree != root || repo.gitDir != filepath.Join(root, ".git") {
+		t.Fatalf("Expected the repository above, got %+v (%v)", repo, ok)
+	}
+	if branch := branchName(repo.gitDir, "ref: refs/heads/feature/x"); branch != "feature/x" {
+		t.Errorf("Expected the branch, got %q", branch)
+	}
+	if branch := branchName(repo.gitDir, "0123456789abcdef"); branch != "0123456" {
+		t.Errorf("Expected the short commit when detached, got %q", branch)
+	}
+
+	repo, ok = findGitRepo(filepath.Join(root, "linked"))
+	if !ok || repo.gitDir != filepath.Join(root, "worktrees", "linked") {
+		t.Errorf("Expected the git directory a .git file names, got %+v (%v)", repo, ok)
+	}
+
+	if _, ok := findGitRepo(t.TempDir()); ok {
+		t.Error("Expected no repository outside one")
+	}
+}
+
+func TestGit_Operation(t *testing.T) {
+	gitDir := t.TempDir()
+
+	if operation := gitOperation(gitDir); operation != "" {
+		t.Errorf("Expected no operation, got %q", operation)
+	}
+
+	writeFiles(t, gitDir, map[string]string{"MERGE_HEAD": ""})
+	if operation := gitOperation(gitDir); operation != "merge" {
+		t.Errorf("Expected a merge, got %q", operation)
+	}
+
+	writeFiles(t, gitDir, map[string]string{"rebase-merge/head-name": "refs/heads/topic\n"})
+	if operation := gitOperation(gitDir); operation != "rebase" {
+		t.Errorf("Expected a rebase, got %q", operation)
+	}
+	if branch := branchName(gitDir, "0123456789abcdef"); branch != "topic" {
+		t.Errorf("Expected the branch being rebased, got %q", branch)
+	}
+}
+
+func TestGit_ParseStatus(t *testing.T) {
+	output := "# branch.oid 0123\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +2 -1\n" +
+		"1 .M N... 100644 100644 100644 0123 0123 main.go\n"
+
+	status := parseStatus([]byte(output))
+	if status != (gitStatus{dirty: true, ahead: 2, behind: 1}) {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	if status := parseStatus([]byte("# branch.head main\n")); status != (gitStatus{}) {
+		t.Errorf("Expected a clean tree, got %+v", status)
+	}
+}
+
+func TestGit_SegmentRefreshesInBackground(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	cmd := exec.CommandContext(context.Background(), "git", "init", "-q", "-b", "main", root)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+	writeFiles(t, root, map[string]string{"new": ""})
+	t.Chdir(root)
+	t.Setenv("PWD", root)
+
+	repainted := make(chan struct{}, 1)
+	SetRepaint(func() { repainted <- struct{}{} })
+	t.Cleanup(func() { SetRepaint(nil) })
+
+	// The status is not known until git status has run
+	if segment := gitSegment(); segment != "(main)" {
+		t.Errorf("Expected the branch alone at first, got %q", segment)
+	}
+
+	select {
+	case <-repainted:
+	case <-time.After(gitTimeout + time.Second):
+		t.Fatal("Expected a repaint once git status finished")
+	}
+
+	if segment := gitSegment(); segment != "(main*)" {
+		t.Errorf("Expected the untracked file to mark the tree dirty, got %q", segment)
+	}
+}
+
+func TestGit_StatusRunsOncePerKey(t *testing.T) {
+	repo := gitRepo{workTree: "/src", gitDir: "/src/.git"}
+
+	// As while a run for the first prompt reads the tree
+	gitCache.Lock()
+	gitCache.running, gitCache.again = true, false
+	gitCache.repo, gitCache.wanted = repo, "key"
+	gitCache.Unlock()
+	t.Cleanup(func() {
+		gitCache.Lock()
+		gitCache.running, gitCache.again = false, false
+		gitCache.Unlock()
+	})
+
+	// \g in both PS1 and RPS1 shares the run
+	cachedStatus(repo, "key")
+	if gitCache.again {
+		t.Error("Expected the same key not to run git status again")
+	}
+
+	cachedStatus(repo, "other")
+	if !gitCache.again {
+		t.Error("Expected another key to run git status again")
+	}
+}
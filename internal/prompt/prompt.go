@@ -0,0 +1,295 @@
+// Package prompt expands PS1 and PS2 into the text the line editor shows.
+// Backslash escapes as bash has them stand for the user, host, working
+// directory, time and the like, and \g for the state of the git
+// repository. \F{colour} colours the text after it, parameters are
+// expanded and $(...) is replaced by a command's output.
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"dsh/internal/dirs"
+	"dsh/internal/expand"
+	"dsh/internal/jobs"
+	"dsh/internal/lexer"
+	"dsh/internal/terminal"
+	"dsh/internal/variables"
+)
+
+// CommandRunner runs command and returns its output without the trailing
+// newlines.
+type CommandRunner func(command string) (string, error)
+
+// commandRunner is installed by the shell, since running commands belongs
+// to the executor.
+var commandRunner CommandRunner //nolint:gochecknoglobals // Hook installed once at startup
+
+// SetCommandRunner installs the function that runs $(...) in a prompt.
+// Until one is installed, command substitutions are left as they are.
+func SetCommandRunner(fn CommandRunner) {
+	commandRunner = fn
+}
+
+// Expand returns the prompt that text describes.
+func Expand(text string) string {
+	return expandAt(text, time.Now())
+}
+
+// expandAt expands text with now as the time the escapes show.
+func expandAt(text string, now time.Time) string {
+	colors := terminal.NewColorManager()
+
+	var out, segment strings.Builder
+	color := terminal.ColorReset
+
+	// Write the text so far in the colour it was given
+	flush := func() {
+		if color == terminal.ColorReset {
+			out.WriteString(segment.String())
+		} else if segment.Len() > 0 {
+			out.WriteString(colors.Colorize(segment.String(), color))
+		}
+		segment.Reset()
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if i+1 == len(text) {
+				segment.WriteByte('\\')
+
+				continue
+			}
+			i++
+
+			if text[i] == 'F' {
+				if name, ok := braced(text[i+1:]); ok {
+					if parsed, known := terminal.ParseColor(name); known {
+						flush()
+						color = parsed
+						i += len(name) + 2
+
+						continue
+					}
+				}
+			}
+
+			if length := octalLength(text[i:]); length > 0 {
+				code, _ := strconv.ParseUint(text[i:i+length], 8, 8)
+				segment.WriteByte(byte(code))
+				i += length - 1
+
+				continue
+			}
+
+			segment.WriteString(escape(text[i], now))
+		case '$':
+			value, length := dollar(text[i:])
+			segment.WriteString(value)
+			i += length - 1
+		default:
+			segment.WriteByte(text[i])
+		}
+	}
+	flush()
+
+	return out.String()
+}
+
+// escape returns what the escape \letter stands for, or the escape itself
+// if it is not one.
+func escape(letter byte, now time.Time) string {
+	switch letter {
+	case 'u':
+		return userName()
+	case 'h':
+		host, _, _ := strings.Cut(hostName(), ".")
+
+		return host
+	case 'H':
+		return hostName()
+	case 'w':
+		return dirs.Abbreviate(dirs.Current())
+	case 'W':
+		dir := dirs.Current()
+		if dirs.Abbreviate(dir) == "~" {
+			return "~"
+		}
+
+		return filepath.Base(dir)
+	case '$':
+		if os.Geteuid() == 0 {
+			return "#"
+		}
+
+		return "$"
+	case 't':
+		return now.Format("15:04:05")
+	case 'T':
+		return now.Format("03:04:05")
+	case '@':
+		return now.Format("03:04 PM")
+	case 'A':
+		return now.Format("15:04")
+	case 'd':
+		return now.Format("Mon Jan 02")
+	case 'j':
+		return strconv.Itoa(len(jobs.List()))
+	case '?':
+		return strconv.Itoa(variables.LastStatus())
+	case 'g':
+		return gitSegment()
+	case 's':
+		return "dsh"
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 'a':
+		return "\a"
+	case 'e':
+		return "\033"
+	case '\\':
+		return "\\"
+	case '[', ']':
+		// Marks around non-printing text, which is measured without them
+		return ""
+	}
+
+	return "\\" + string(letter)
+}
+
+// userName returns the name of the user the shell runs as.
+func userName() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+// hostName returns the name of the machine, or "" if it is not known.
+func hostName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	return host
+}
+
+// braced returns the text between the braces at the start of text.
+func braced(text string) (string, bool) {
+	if !strings.HasPrefix(text, "{") {
+		return "", false
+	}
+
+	end := strings.IndexByte(text, '}')
+	if end < 0 {
+		return "", false
+	}
+
+	return text[1:end], true
+}
+
+// octalLength returns the length of the three octal digits at the start
+// of text, as in \033, or 0 if there are none.
+func octalLength(text string) int {
+	if len(text) < 3 {
+		return 0
+	}
+
+	for i := range 3 {
+		if text[i] < '0' || text[i] > '7' {
+			return 0
+		}
+	}
+
+	return 3
+}
+
+// dollar expands the command substitution or parameter at the start of
+// text, which begins with $, and returns its value and the length of text
+// it took. A $ that starts neither stands for itself.
+func dollar(text string) (string, int) {
+	switch {
+	case strings.HasPrefix(text, "$("):
+		end := closing(text, 1, '(', ')')
+		if end < 0 || commandRunner == nil {
+			return "$", 1
+		}
+
+		output, err := commandRunner(text[2:end])
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+		}
+
+		return output, end + 1
+	case strings.HasPrefix(text, "${"):
+		end := closing(text, 1, '{', '}')
+		if end < 0 {
+			return "$", 1
+		}
+
+		return parameter(text[:end+1]), end + 1
+	}
+
+	length := 1
+	if len(text) > 1 && strings.IndexByte("?$!#@*-0123456789", text[1]) >= 0 {
+		length = 2
+	} else {
+		for length < len(text) && lexer.IsName(text[1:length+1]) {
+			length++
+		}
+	}
+
+	if length == 1 {
+		return "$", 1
+	}
+
+	return parameter(text[:length]), length
+}
+
+// parameter expands a parameter such as $HOME or ${#name}.
+func parameter(text string) string {
+	value, err := expand.String(text)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+	}
+
+	return value
+}
+
+// closing returns the index of the bracket that closes the one at
+// text[start], skipping quoted text, or -1 if it is not closed.
+func closing(text string, start int, open, closeBracket byte) int {
+	depth := 0
+
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '\'', '"':
+			end := strings.IndexByte(text[i+1:], text[i])
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case open:
+			depth++
+		case closeBracket:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
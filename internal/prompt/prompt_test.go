@@ -0,0 +1,122 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dsh/internal/variables"
+)
+
+func TestExpand_Escapes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, "src"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(home, "src"))
+	t.Setenv("PWD", filepath.Join(home, "src"))
+
+	variables.SetLastStatus(3)
+	t.Cleanup(func() { variables.SetLastStatus(0) })
+
+	now := time.Date(2024, time.March, 5, 14, 7, 9, 0, time.UTC)
+
+	dollar := "$"
+	if os.Geteuid() == 0 {
+		dollar = "#"
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`\w`, "~/src"},
+		{`\W`, "src"},
+		{`\t`, "14:07:09"},
+		{`\T`, "02:07:09"},
+		{`\@`, "02:07 PM"},
+		{`\A`, "14:07"},
+		{`\d`, "Tue Mar 05"},
+		{`\j`, "0"},
+		{`\?`, "3"},
+		{`\$ `, dollar + " "},
+		{`\s\\`, `dsh\`},
+		{`a\nb`, "a\nb"},
+		{`\[\033[1m\]x`, "\033[1mx"},
+		{`\q`, `\q`},
+		{`trailing\`, `trailing\`},
+	}
+
+	for _, tt := range tests {
+		if got := expandAt(tt.text, now); got != tt.want {
+			t.Errorf("expandAt(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	t.Chdir(home)
+	t.Setenv("PWD", home)
+	if got := expandAt(`\w \W`, now); got != "~ ~" {
+		t.Errorf("Expected HOME to show as ~, got %q", got)
+	}
+}
+
+func TestExpand_Parameters(t *testing.T) {
+	variables.Set("PROMPT_NAME", "box")
+	t.Cleanup(func() { variables.Unset("PROMPT_NAME") })
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"$PROMPT_NAME> ", "box> "},
+		{"${PROMPT_NAME}1", "box1"},
+		{"${#PROMPT_NAME}", "3"},
+		{"cost: $ 5", "cost: $ 5"},
+		{"$", "$"},
+	}
+
+	for _, tt := range tests {
+		if got := Expand(tt.text); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExpand_CommandSubstitution(t *testing.T) {
+	t.Cleanup(func() { SetCommandRunner(nil) })
+
+	if got := Expand("$(git branch)> "); got != "$(git branch)> " {
+		t.Errorf("Expected the text unchanged without a runner, got %q", got)
+	}
+
+	var commands []string
+	SetCommandRunner(func(command string) (string, error) {
+		commands = append(commands, command)
+
+		return "main", nil
+	})
+
+	if got := Expand("[$(echo \"(x)\" | cut -c1)]$ "); got != "[main]$ " {
+		t.Errorf("Expected the command's output, got %q", got)
+	}
+	if len(commands) != 1 || commands[0] != `echo "(x)" | cut -c1` {
+		t.Errorf("Expected the whole command to run, got %q", commands)
+	}
+}
+
+func TestExpand_Colors(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+
+	got := Expand(`\F{green}ok\F{reset} \F{brightred}$\F{mauve}`)
+	want := "\033[32mok\033[0m \033[91m$\\F{mauve}\033[0m"
+	if got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+
+	t.Setenv("TERM", "dumb")
+	if got := Expand(`\F{green}ok\F{reset}`); got != "ok" {
+		t.Errorf("Expected no colour codes on a dumb terminal, got %q", got)
+	}
+}
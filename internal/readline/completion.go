@@ -7,6 +7,8 @@ import (
 	"sort"
 	"strings"
 
+	"dsh/internal/builtins"
+	"dsh/internal/commands"
 	"dsh/internal/terminal"
 )
 
@@ -15,13 +17,12 @@ type Completion struct {
 	commands []string
 }
 
-// NewCompletion creates a new completion instance.
+// NewCompletion creates a new completion instance. Commands are loaded
+// when a command name is first completed, not at startup.
 func NewCompletion() *Completion {
-	c := &Completion{
+	return &Completion{
 		commands: make([]string, 0),
 	}
-	c.loadCommands()
-	return c
 }
 
 // CompletionItem represents a completion with its type.
@@ -61,62 +62,31 @@ func (c *Completion) Complete(input string, _ int) ([]CompletionItem, string) {
 	return c.completeFile(lastWord)
 }
 
-// loadCommands loads available commands from PATH and builtins.
+// loadCommands loads the available commands from builtins and from the
+// shell's command table, which rescans PATH only when it changes.
 func (c *Completion) loadCommands() {
-	var commands []string
+	var names []string
 
 	// Add builtin commands
-	builtinNames := []string{"cd", "pwd", "help", "exit", "todo"}
-	commands = append(commands, builtinNames...)
+	names = append(names, builtins.Names()...)
 
 	// Add commands from PATH
-	pathCommands := c.getPathCommands()
-	commands = append(commands, pathCommands...)
+	names = append(names, commands.Names()...)
 
 	// Sort and deduplicate
-	sort.Strings(commands)
-	c.commands = c.deduplicate(commands)
-}
-
-// getPathCommands gets executable commands from PATH.
-func (c *Completion) getPathCommands() []string {
-	var commands []string
-	pathEnv := os.Getenv("PATH")
-	if pathEnv == "" {
-		return commands
-	}
-
-	paths := strings.Split(pathEnv, ":")
-	for _, path := range paths {
-		if path == "" {
-			continue
-		}
-
-		entries, err := os.ReadDir(path)
-		if err != nil {
-			continue
-		}
-
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				info, err := entry.Info()
-				if err == nil && info.Mode()&0111 != 0 { // Executable
-					commands = append(commands, entry.Name())
-				}
-			}
-		}
-	}
-
-	return commands
+	sort.Strings(names)
+	c.commands = c.deduplicate(names)
 }
 
 // completeCommand completes command names.
 func (c *Completion) completeCommand(prefix string) ([]CompletionItem, string) {
 	var matches []CompletionItem
-	builtins := []string{"cd", "pwd", "help", "exit", "todo"}
+	builtinNames := builtins.Names()
+
+	c.loadCommands()
 
 	// Add builtin matches
-	for _, cmd := range builtins {
+	for _, cmd := range builtinNames {
 		if strings.HasPrefix(cmd, prefix) {
 			matches = append(matches, CompletionItem{Text: cmd, Type: itemTypeBuiltin})
 		}
@@ -127,7 +97,7 @@ func (c *Completion) completeCommand(prefix string) ([]CompletionItem, string) {
 		if strings.HasPrefix(cmd, prefix) {
 			// Skip if already added as builtin
 			isBuiltin := false
-			for _, builtin := range builtins {
+			for _, builtin := range builtinNames {
 				if cmd == builtin {
 					isBuiltin = true
 					break
@@ -10,19 +10,25 @@ func TestCompletion_Commands(t *testing.T) {
 	c := NewCompletion()
 
 	// Test command completion
-	matches, _ := c.Complete("ec", 0)
+	matches, _ := c.Complete("ca", 0)
 
-	// Should find "echo" command
+	// Should find "cat" command
 	found := false
 	for _, match := range matches {
-		if match.Text == "echo" && match.Type == "command" {
+		if match.Text == "cat" && match.Type == "command" {
 			found = true
 			break
 		}
 	}
 
 	if !found {
-		t.Error("Expected to find 'echo' command in completion")
+		t.Error("Expected to find 'cat' command in completion")
+	}
+
+	// Builtins that are also commands in PATH complete as builtins
+	matches, _ = c.Complete("ec", 0)
+	if len(matches) == 0 || matches[0].Text != "echo" || matches[0].Type != itemTypeBuiltin {
+		t.Errorf("Expected 'echo' as a builtin in completion, got %v", matches)
 	}
 
 	// Test builtin completion
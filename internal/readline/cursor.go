@@ -1,5 +1,7 @@
 package readline
 
+import "dsh/internal/terminal"
+
 // Movement and editing functions.
 func (r *Readline) moveCursorLeft() {
 	if r.cursor > 0 {
@@ -10,12 +12,12 @@ func (r *Readline) moveCursorLeft() {
 	}
 }
 
+// moveCursorRight moves over the next character without writing it again,
+// which would lose its colour.
 func (r *Readline) moveCursorRight() {
 	if r.cursor < len(r.buffer) {
-		if r.terminal != nil {
-			_, _ = r.terminal.WriteString(string(r.buffer[r.cursor]))
-		}
 		r.cursor++
+		r.setCursorPosition()
 	}
 }
 
@@ -35,10 +37,16 @@ func (r *Readline) setCursorPosition() {
 	if r.terminal == nil {
 		return
 	}
-	// Move cursor to correct position using ANSI escape sequences
-	promptLen := len(r.prompt)
-	totalPos := promptLen + r.cursor + 1
-	_, _ = r.terminal.Printf("\033[%dG", totalPos)
+	// Move cursor to correct position using ANSI escape sequences. Only
+	// the last line of the prompt shares the screen line with the input,
+	// and its colour codes take no columns.
+	column := promptWidth(r.shownPrompt()) + terminal.DisplayWidth(displayText(r.buffer[:r.cursor])) + 1
+	_, _ = r.terminal.Printf("\033[%dG", column)
+}
+
+// promptWidth returns the number of columns the last line of prompt takes.
+func promptWidth(prompt string) int {
+	return terminal.DisplayWidth(terminal.LastLine(prompt))
 }
 
 // Word movement.
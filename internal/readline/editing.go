@@ -1,6 +1,10 @@
 package readline
 
-import "dsh/internal/terminal"
+import (
+	"strings"
+
+	"dsh/internal/terminal"
+)
 
 func (r *Readline) insertRune(ch rune) {
 	if r.cursor == len(r.buffer) {
@@ -13,6 +17,15 @@ func (r *Readline) insertRune(ch rune) {
 	r.redraw()
 }
 
+// insertText inserts text at the cursor, as a macro or the output of a
+// bound command does, and leaves the cursor after it.
+func (r *Readline) insertText(text string) {
+	runes := []rune(text)
+	r.buffer = append(r.buffer[:r.cursor], append(runes, r.buffer[r.cursor:]...)...)
+	r.cursor += len(runes)
+	r.redraw()
+}
+
 func (r *Readline) deleteChar() {
 	if r.cursor < len(r.buffer) {
 		r.buffer = append(r.buffer[:r.cursor], r.buffer[r.cursor+1:]...)
@@ -50,16 +63,113 @@ func (r *Readline) redraw() {
 	// Update suggestion before drawing
 	r.updateSuggestion()
 
-	// Clear line and redraw
+	// Clear line and redraw, leaving any earlier lines of the prompt
 	_, _ = r.terminal.WriteString("\r\033[K")
-	r.displayPrompt()
+	_, _ = r.terminal.WriteString(terminal.LastLine(r.shownPrompt()))
 
 	// Print buffer with suggestion
-	_, _ = r.terminal.WriteString(string(r.buffer))
+	text := displayText(r.buffer)
+	_, _ = r.terminal.WriteString(r.styledText())
 	if r.suggestion != "" {
 		_, _ = r.terminal.WriteString(r.terminal.Colorize(r.suggestion, terminal.ColorBrightBlack))
 	}
+	r.drawRightPrompt(promptWidth(r.shownPrompt()) + terminal.DisplayWidth(text) + terminal.DisplayWidth(r.suggestion))
 
 	// Position cursor correctly
 	r.setCursorPosition()
 }
+
+// drawRightPrompt writes the right prompt at the end of the first input
+// line, where used columns are taken, and reports whether it did. It is
+// left out when the input would run into it, and keeps clear of the last
+// column, where some terminals wrap as soon as it is written.
+func (r *Readline) drawRightPrompt(used int) bool {
+	if r.rightPrompt == "" || r.pending != nil {
+		return false
+	}
+
+	width, _ := r.terminal.Size()
+	column := width - terminal.DisplayWidth(r.rightPrompt)
+	if used+1 >= column {
+		return false
+	}
+
+	_, _ = r.terminal.Printf("\033[%dG", column)
+	_, _ = r.terminal.WriteString(r.rightPrompt)
+
+	return true
+}
+
+// newlineGlyph stands in for the newlines of a multi-line command recalled
+// from history, which is edited on a single screen line.
+const newlineGlyph = '↵'
+
+// controlBase is where the control characters of the input are moved, in
+// Unicode's private use area, while the highlighter colours it, so that
+// they cannot be mistaken for the escape sequences it adds. Newlines and
+// tabs stay, since they separate words.
+const controlBase = 0xe000
+
+// styledText returns the input as drawn on the screen, coloured by the
+// highlighter if one is installed.
+func (r *Readline) styledText() string {
+	if r.viEnabled && r.vi.mode == viVisual {
+		return r.visualText()
+	}
+
+	if r.highlight == nil {
+		return displayText(r.buffer)
+	}
+
+	hidden := strings.Map(func(ch rune) rune {
+		if isControl(ch) && ch != '\n' && ch != '\t' {
+			return controlBase + ch
+		}
+
+		return ch
+	}, string(r.buffer))
+
+	var result strings.Builder
+	for _, ch := range r.highlight(hidden) {
+		switch {
+		case ch == '\n' || ch == '\t':
+			result.WriteString(displayRune(ch))
+		case ch >= controlBase && isControl(ch-controlBase):
+			result.WriteString(displayRune(ch - controlBase))
+		default:
+			result.WriteRune(ch)
+		}
+	}
+
+	return result.String()
+}
+
+// displayText returns text as drawn on the screen, where a newline is a
+// glyph and other control characters, such as a pasted tab or escape, are
+// shown as ^I and ^[.
+func displayText(text []rune) string {
+	var result strings.Builder
+
+	for _, ch := range text {
+		result.WriteString(displayRune(ch))
+	}
+
+	return result.String()
+}
+
+// displayRune returns ch as displayText draws it.
+func displayRune(ch rune) string {
+	switch {
+	case ch == '\n':
+		return string(newlineGlyph)
+	case isControl(ch):
+		return "^" + string(ch^0x40)
+	default:
+		return string(ch)
+	}
+}
+
+// isControl reports whether ch is an ASCII control character.
+func isControl(ch rune) bool {
+	return ch < 0x20 || ch == 0x7f
+}
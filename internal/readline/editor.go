@@ -0,0 +1,96 @@
+package readline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"dsh/internal/variables"
+)
+
+// defaultEditor edits commands when neither VISUAL nor EDITOR is set.
+const defaultEditor = "vi"
+
+// ErrNoEditor indicates an editor command with no words.
+var ErrNoEditor = errors.New("no editor given")
+
+// Editor returns the editor that commands are edited with: VISUAL, then
+// EDITOR, then vi.
+func Editor() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor, ok := variables.Get(name); ok && strings.TrimSpace(editor) != "" {
+			return editor
+		}
+	}
+
+	return defaultEditor
+}
+
+// EditText writes text to a temporary file, runs editor on it on the
+// terminal and returns what the file holds afterwards, without its
+// trailing newlines. editor may carry arguments, as in "code --wait".
+func EditText(editor, text string) (string, error) {
+	words := strings.Fields(editor)
+	if len(words) == 0 {
+		return "", ErrNoEditor
+	}
+
+	file, err := os.CreateTemp("", "dsh-edit-*.sh")
+	if err != nil {
+		return "", fmt.Errorf("edit: %w", err)
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	_, err = file.WriteString(text + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("edit: %w", err)
+	}
+
+	cmd := exec.Command(words[0], append(words[1:], file.Name())...) //nolint:gosec // Running the user's editor is the point
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", words[0], err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("edit: %w", err)
+	}
+
+	return strings.TrimRight(string(edited), "\n"), nil
+}
+
+// editCommandLine edits the line in the editor, and reports whether that
+// worked. The line is drawn again afterwards, below what the editor left.
+func (r *Readline) editCommandLine() bool {
+	if r.completionMenu.IsActive() {
+		r.clearTabCompletion()
+	}
+	r.moveCursorToEnd()
+	_, _ = r.terminal.WriteString("\r\n")
+
+	// The editor expects the terminal in the usual mode
+	if r.rawTerminal != nil {
+		_ = r.rawTerminal.Restore()
+	}
+	text, err := EditText(Editor(), string(r.buffer))
+	if r.rawTerminal != nil {
+		_ = r.rawTerminal.SetRawMode()
+	}
+
+	if err != nil {
+		_, _ = r.terminal.Printf("dsh: %v\r\n", err)
+	} else {
+		r.buffer = []rune(text)
+		r.cursor = len(r.buffer)
+	}
+	r.displayPrompt()
+	r.redraw()
+
+	return err == nil
+}
@@ -0,0 +1,62 @@
+package readline
+
+import (
+	"testing"
+
+	"dsh/internal/terminal"
+	"dsh/internal/variables"
+	"dsh/test/rendering"
+)
+
+func TestEditor(t *testing.T) {
+	t.Cleanup(func() {
+		variables.Unset("VISUAL")
+		variables.Unset("EDITOR")
+	})
+
+	variables.Unset("VISUAL")
+	variables.Unset("EDITOR")
+	if editor := Editor(); editor != defaultEditor {
+		t.Errorf("Expected %q without VISUAL or EDITOR, got %q", defaultEditor, editor)
+	}
+
+	variables.Set("EDITOR", "ed")
+	variables.Set("VISUAL", "sed -i s/one/two/")
+	if editor := Editor(); editor != "sed -i s/one/two/" {
+		t.Errorf("Expected VISUAL before EDITOR, got %q", editor)
+	}
+
+	if text, err := EditText(Editor(), "echo one\necho one"); err != nil || text != "echo two\necho two" {
+		t.Errorf("Expected the edited text, got %q (%v)", text, err)
+	}
+	if _, err := EditText("false", "text"); err == nil {
+		t.Error("Expected a failing editor to fail")
+	}
+	if _, err := EditText(" ", "text"); err == nil {
+		t.Error("Expected an empty editor to fail")
+	}
+
+	// The line is loaded back for more editing, or run at once
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	queueViKeys(mockTerm, "echo one")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlX})
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlE})
+	queueViKeys(mockTerm, "!\r")
+
+	if line, _ := rl.ReadLine(); line != "echo two!" {
+		t.Errorf("Expected the edited line loaded back, got %q", line)
+	}
+
+	if err := Bind(`"\C-o": edit-and-execute-command`); err != nil {
+		t.Fatal(err)
+	}
+	resetKeymap(t)
+
+	queueViKeys(mockTerm, "echo one")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlO})
+
+	if line, _ := rl.ReadLine(); line != "echo two" {
+		t.Errorf("Expected the edited line run, got %q", line)
+	}
+}
@@ -0,0 +1,442 @@
+package readline
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrEventNotFound indicates a history reference that names no command.
+	ErrEventNotFound = errors.New("event not found")
+	// ErrBadWordSpecifier indicates a word designator past the last word of
+	// the command.
+	ErrBadWordSpecifier = errors.New("bad word specifier")
+	// ErrBadModifier indicates a history modifier dsh does not know.
+	ErrBadModifier = errors.New("unrecognized history modifier")
+	// ErrSubstitutionFailed indicates a :s modifier whose old text is not in
+	// the command, or one with nothing to repeat.
+	ErrSubstitutionFailed = errors.New("substitution failed")
+)
+
+// historyMetacharacters separate the words of a command, as well as
+// blanks do.
+const historyMetacharacters = "|&;<>()"
+
+// historyExpansion is the state of expanding one line.
+type historyExpansion struct {
+	history *History
+	line    string
+	// output is the expanded line so far, which !# refers to.
+	output strings.Builder
+	// printOnly is set by the :p modifier.
+	printOnly bool
+}
+
+// Expand performs csh-style history expansion on line and returns the
+// result. An event designator such as !!, !n, !-n, !prefix or !?text?
+// picks a command from history, a word designator after it such as :0,
+// :$, :2-4 or :* picks words of the command, and modifiers such as :h,
+// :t, :r, :e and :s/old/new/ edit them. ^old^new at the start of the line
+// runs the last command with old replaced by new. printOnly reports a :p
+// modifier, which asks for the result to be shown but not run.
+func (h *History) Expand(line string) (expanded string, printOnly bool, err error) {
+	x := &historyExpansion{history: h, line: line}
+
+	i := 0
+	if strings.HasPrefix(line, "^") {
+		text, end, err := x.substitute(x.lastCommand(), 1, '^', false)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %w", line[:end], err)
+		}
+		x.output.WriteString(text)
+		i = end
+	}
+
+	var quote byte
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(line):
+			x.output.WriteString(line[i : i+2])
+			i += 2
+
+			continue
+		case c == '\'' && quote == 0, c == '"' && quote == 0:
+			quote = c
+		case c == quote:
+			quote = 0
+		case c == '!' && quote != '\'' && x.startsReference(i, quote == '"'):
+			text, end, err := x.reference(i)
+			if err != nil {
+				return "", false, err
+			}
+			x.output.WriteString(text)
+			i = end
+
+			continue
+		}
+
+		x.output.WriteByte(c)
+		i++
+	}
+
+	return x.output.String(), x.printOnly, nil
+}
+
+// startsReference reports whether the ! at i starts a history reference.
+// It does not before a blank, = or ( or at the end of the line, nor in $!
+// and ${!name}, nor before the closing quote of a double-quoted string.
+func (x *historyExpansion) startsReference(i int, quoted bool) bool {
+	if i+1 >= len(x.line) || strings.IndexByte(" \t\n=(", x.line[i+1]) >= 0 {
+		return false
+	}
+	if quoted && x.line[i+1] == '"' {
+		return false
+	}
+
+	return !strings.HasSuffix(x.line[:i], "$") && !strings.HasSuffix(x.line[:i], "${")
+}
+
+// reference expands the history reference at start and returns its text
+// and the index just past it.
+func (x *historyExpansion) reference(start int) (string, int, error) {
+	text, end, err := x.event(start)
+	if err != nil {
+		return "", end, err
+	}
+
+	if end < len(x.line) && (strings.IndexByte("^$*", x.line[end]) >= 0 ||
+		x.line[end] == ':' && end+1 < len(x.line) && strings.IndexByte("0123456789^$*-", x.line[end+1]) >= 0) {
+		if x.line[end] == ':' {
+			end++
+		}
+		text, end, err = x.words(text, start, end)
+		if err != nil {
+			return "", end, err
+		}
+	}
+
+	for end+1 < len(x.line) && x.line[end] == ':' {
+		text, end, err = x.modify(text, end+1)
+		if err != nil {
+			return "", end, fmt.Errorf("%s: %w", x.line[start:end], err)
+		}
+	}
+
+	return text, end, nil
+}
+
+// event returns the command the event designator at start names, and the
+// index just past the designator. A reference with only a word designator
+// or modifiers, such as !$, names the last command.
+func (x *historyExpansion) event(start int) (string, int, error) {
+	end := start + 1
+	entries := x.history.items
+	found := -1
+
+	switch c := x.line[end]; {
+	case c == '!':
+		end++
+		found = len(entries) - 1
+	case c == '#':
+		return x.output.String(), end + 1, nil
+	case strings.IndexByte(":^$*", c) >= 0:
+		found = len(entries) - 1
+	case c == '?':
+		text, rest, closed := strings.Cut(x.line[end+1:], "?")
+		end = len(x.line) - len(rest)
+		if !closed {
+			end = len(x.line)
+		}
+		for i := len(entries) - 1; i >= 0 && text != ""; i-- {
+			if strings.Contains(entries[i], text) {
+				found = i
+
+				break
+			}
+		}
+	case isHistoryDigit(c) || c == '-' && end+1 < len(x.line) && isHistoryDigit(x.line[end+1]):
+		for end++; end < len(x.line) && isHistoryDigit(x.line[end]); end++ {
+		}
+		number, _ := strconv.Atoi(x.line[start+1 : end])
+		if number < 0 {
+			number += len(entries) + 1
+		}
+		if number >= 1 && number <= len(entries) {
+			found = number - 1
+		}
+	default:
+		for end < len(x.line) && !isHistoryWordEnd(x.line[end]) {
+			end++
+		}
+		prefix := x.line[start+1 : end]
+		for i := len(entries) - 1; i >= 0 && prefix != ""; i-- {
+			if strings.HasPrefix(entries[i], prefix) {
+				found = i
+
+				break
+			}
+		}
+	}
+
+	if found < 0 {
+		return "", end, fmt.Errorf("%s: %w", x.line[start:end], ErrEventNotFound)
+	}
+
+	return entries[found], end, nil
+}
+
+// lastCommand returns the last command in history, or "" if there is
+// none.
+func (x *historyExpansion) lastCommand() string {
+	if len(x.history.items) == 0 {
+		return ""
+	}
+
+	return x.history.items[len(x.history.items)-1]
+}
+
+// words returns the words of command that the word designator at i picks,
+// joined by spaces, and the index just past the designator. Words are
+// numbered from 0, the command name; ^ is word 1 and $ the last word. x-y
+// is a range of words, -y starts at 0, x* runs to the last word, x- to
+// the one before it and * on its own is every argument.
+func (x *historyExpansion) words(command string, start, i int) (string, int, error) {
+	words := historyWords(command)
+	last := len(words) - 1
+
+	if x.line[i] == '*' {
+		return strings.Join(words[min(1, len(words)):], " "), i + 1, nil
+	}
+
+	first := 0
+	if x.line[i] != '-' {
+		first, i = x.wordIndex(i, last)
+	}
+	end := first
+
+	switch {
+	case i < len(x.line) && x.line[i] == '*':
+		i++
+		end = last
+	case i < len(x.line) && x.line[i] == '-':
+		i++
+		end = last - 1
+		if i < len(x.line) && (isHistoryDigit(x.line[i]) || x.line[i] == '^' || x.line[i] == '$') {
+			end, i = x.wordIndex(i, last)
+		}
+	}
+
+	if first < 0 || first > last || end > last || end < first {
+		return "", i, fmt.Errorf("%s: %w", x.line[start:i], ErrBadWordSpecifier)
+	}
+
+	return strings.Join(words[first:end+1], " "), i, nil
+}
+
+// wordIndex reads the word number at i, where ^ is 1 and $ is last, and
+// returns it and the index just past it, or -1 if there is none.
+func (x *historyExpansion) wordIndex(i, last int) (int, int) {
+	switch {
+	case i >= len(x.line):
+		return -1, i
+	case x.line[i] == '^':
+		return 1, i + 1
+	case x.line[i] == '$':
+		return last, i + 1
+	}
+
+	end := i
+	for end < len(x.line) && isHistoryDigit(x.line[end]) {
+		end++
+	}
+	if end == i {
+		return -1, i
+	}
+	number, _ := strconv.Atoi(x.line[i:end])
+
+	return number, end
+}
+
+// modify applies the modifier at i, just past its colon, to text and
+// returns the result and the index just past the modifier.
+func (x *historyExpansion) modify(text string, i int) (string, int, error) {
+	global := false
+	if c := x.line[i]; (c == 'g' || c == 'a') && i+1 < len(x.line) {
+		global = true
+		i++
+	}
+
+	switch x.line[i] {
+	case 'h':
+		if slash := strings.LastIndexByte(text, '/'); slash >= 0 {
+			text = text[:slash]
+		}
+	case 't':
+		text = text[strings.LastIndexByte(text, '/')+1:]
+	case 'r':
+		if dot := strings.LastIndexByte(text, '.'); dot > strings.LastIndexByte(text, '/') {
+			text = text[:dot]
+		}
+	case 'e':
+		dot := strings.LastIndexByte(text, '.')
+		if dot <= strings.LastIndexByte(text, '/') {
+			dot = len(text)
+		}
+		text = text[dot:]
+	case 'p':
+		x.printOnly = true
+	case 's':
+		if i+1 >= len(x.line) {
+			return "", i + 1, ErrSubstitutionFailed
+		}
+
+		return x.substitute(text, i+2, x.line[i+1], global)
+	case '&':
+		return x.replace(text, i+1, global)
+	default:
+		return "", i + 1, ErrBadModifier
+	}
+
+	return text, i + 1, nil
+}
+
+// substitute reads old and new from the line at i, up to delimiters, as
+// in :s/old/new/, and replaces old with new in text. The last delimiter
+// may be left out at the end of the line; a backslash quotes a delimiter,
+// an & in new stands for old and an empty old is the one last used.
+func (x *historyExpansion) substitute(text string, i int, delimiter byte, global bool) (string, int, error) {
+	old, i := x.delimited(i, delimiter)
+	replacement, i := x.delimited(i, delimiter)
+
+	if old != "" {
+		x.history.lastOld = old
+	}
+	x.history.lastNew = replacement
+
+	return x.replace(text, i, global)
+}
+
+// delimited returns the text at i up to delimiter, without the backslashes
+// that quote a delimiter, and the index just past the delimiter.
+func (x *historyExpansion) delimited(i int, delimiter byte) (string, int) {
+	var text strings.Builder
+
+	for ; i < len(x.line); i++ {
+		switch {
+		case x.line[i] == delimiter:
+			return text.String(), i + 1
+		case x.line[i] == '\\' && i+1 < len(x.line) && x.line[i+1] == delimiter:
+			i++
+		}
+		text.WriteByte(x.line[i])
+	}
+
+	return text.String(), i
+}
+
+// replace makes the last substitution in text, or with global every
+// occurrence, and returns the result with i.
+func (x *historyExpansion) replace(text string, i int, global bool) (string, int, error) {
+	h := x.history
+	if h.lastOld == "" || !strings.Contains(text, h.lastOld) {
+		return "", i, ErrSubstitutionFailed
+	}
+
+	replacement := strings.ReplaceAll(h.lastNew, `\&`, "\x00")
+	replacement = strings.ReplaceAll(replacement, "&", h.lastOld)
+	replacement = strings.ReplaceAll(replacement, "\x00", "&")
+
+	if global {
+		return strings.ReplaceAll(text, h.lastOld, replacement), i, nil
+	}
+
+	return strings.Replace(text, h.lastOld, replacement, 1), i, nil
+}
+
+// magicSpace expands the history references before the cursor, for the
+// magic-space action, so that they can be seen and edited before the
+// line is entered. A reference that fails to expand is left as it is.
+func (r *Readline) magicSpace() {
+	if !r.histExpand {
+		return
+	}
+
+	expanded, _, err := r.history.Expand(string(r.buffer[:r.cursor]))
+	if err != nil || expanded == string(r.buffer[:r.cursor]) {
+		return
+	}
+
+	runes := []rune(expanded)
+	r.buffer = append(runes, r.buffer[r.cursor:]...)
+	r.cursor = len(runes)
+	r.redraw()
+}
+
+// historyWords splits command into words at blanks outside quotes, with
+// each run of metacharacters such as | or >> a word of its own.
+func historyWords(command string) []string {
+	var words []string
+
+	start := -1
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		if quote == 0 && (isHistoryBlank(c) || strings.IndexByte(historyMetacharacters, c) >= 0) {
+			if start >= 0 {
+				words = append(words, command[start:i])
+				start = -1
+			}
+
+			end := i
+			for end < len(command) && strings.IndexByte(historyMetacharacters, command[end]) >= 0 {
+				end++
+			}
+			if end > i {
+				words = append(words, command[i:end])
+				i = end - 1
+			}
+
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, command[start:])
+	}
+
+	return words
+}
+
+// isHistoryWordEnd reports whether c ends the prefix of a !prefix
+// reference.
+func isHistoryWordEnd(c byte) bool {
+	return isHistoryBlank(c) || strings.IndexByte(historyMetacharacters+":\"'`", c) >= 0
+}
+
+// isHistoryBlank reports whether c separates words.
+func isHistoryBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// isHistoryDigit reports whether c is a decimal digit.
+func isHistoryDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
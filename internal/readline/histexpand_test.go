@@ -0,0 +1,133 @@
+package readline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dsh/internal/terminal"
+	"dsh/test/rendering"
+)
+
+func TestHistory_Expand(t *testing.T) {
+	tests := []struct {
+		line      string
+		want      string
+		printOnly bool
+		err       error
+	}{
+		{"sudo !!", "sudo cp /tmp/a.tar.gz 'my dir' | wc -l", false, nil},
+		{"echo !$", "echo -l", false, nil},
+		{"echo !^", "echo /tmp/a.tar.gz", false, nil},
+		{"echo !*", "echo /tmp/a.tar.gz 'my dir' | wc -l", false, nil},
+		{"echo !1 !-2", "echo make test echo hello world", false, nil},
+		{"!ec", "echo hello world", false, nil},
+		{"!?hello?:0", "echo", false, nil},
+		{"echo !!:2", "echo 'my dir'", false, nil},
+		{"echo !!:1-2", "echo /tmp/a.tar.gz 'my dir'", false, nil},
+		{"echo !!:-1", "echo cp /tmp/a.tar.gz", false, nil},
+		{"echo !!:3*", "echo | wc -l", false, nil},
+		{"echo !!:4-", "echo wc", false, nil},
+		{"echo !ec:*", "echo hello world", false, nil},
+		{"echo !!:1:h", "echo /tmp", false, nil},
+		{"echo !!:1:t", "echo a.tar.gz", false, nil},
+		{"echo !!:1:r", "echo /tmp/a.tar", false, nil},
+		{"echo !!:1:e", "echo .gz", false, nil},
+		{"echo !!:1:t:r:r", "echo a", false, nil},
+		{"!ec:s/world/there/", "echo hello there", false, nil},
+		{"!ec:s/l/L/", "echo heLlo world", false, nil},
+		{"!ec:gs/l/L/", "echo heLLo worLd", false, nil},
+		{"!ec:s,hello,& &", "echo hello hello world", false, nil},
+		{"!ec:p", "echo hello world", true, nil},
+		{"^wc^grep -c x", "cp /tmp/a.tar.gz 'my dir' | grep -c x -l", false, nil},
+		{"^tmp^var^ && ls", "cp /var/a.tar.gz 'my dir' | wc -l && ls", false, nil},
+		{"echo a !# b", "echo a echo a  b", false, nil},
+		{"echo '!!' \\!! \"!!\"", "echo '!!' \\!! \"cp /tmp/a.tar.gz 'my dir' | wc -l\"", false, nil},
+		{"echo $! ${!name} ! !=", "echo $! ${!name} ! !=", false, nil},
+		{"echo \"wow!\"", "echo \"wow!\"", false, nil},
+		{"!nosuch", "", false, ErrEventNotFound},
+		{"!9", "", false, ErrEventNotFound},
+		{"echo !!:9", "", false, ErrBadWordSpecifier},
+		{"echo !!:z", "", false, ErrBadModifier},
+		{"^nosuch^x", "", false, ErrSubstitutionFailed},
+	}
+
+	h := NewEmptyHistory()
+	for _, entry := range []string{"make test", "echo hello world", "cp /tmp/a.tar.gz 'my dir' | wc -l"} {
+		h.Add(entry)
+	}
+
+	for _, tt := range tests {
+		got, printOnly, err := h.Expand(tt.line)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected error %v, got %v", tt.line, tt.err, err)
+
+			continue
+		}
+		if got != tt.want || printOnly != tt.printOnly {
+			t.Errorf("%q: expected %q (print only %v), got %q (%v)", tt.line, tt.want, tt.printOnly, got, printOnly)
+		}
+	}
+
+	// :s remembers the substitution for :& and for an empty old text
+	if got, _, _ := h.Expand("!ec:s/o/0/ !ec:& !ec:s//O/"); got != "ech0 hello world ech0 hello world echO hello world" {
+		t.Errorf("Expected the last substitution repeated, got %q", got)
+	}
+}
+
+func TestHistoryExpansion(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.history.Add("echo one two")
+
+	// Off by default, as in a shell that is not interactive
+	queueViKeys(mockTerm, "echo !$\r")
+	if line, _ := rl.ReadLine(); line != "echo !$" {
+		t.Errorf("Expected no expansion when off, got %q", line)
+	}
+	rl.history.Add("echo one two")
+
+	rl.SetHistoryExpansion(true)
+	mockTerm.ClearOutput()
+	queueViKeys(mockTerm, "echo !$\r")
+	if line, _ := rl.ReadLine(); line != "echo two" {
+		t.Errorf("Expected the expanded line, got %q", line)
+	}
+	if !strings.Contains(mockTerm.GetOutput(), "echo two\r\n") {
+		t.Errorf("Expected the expanded line shown, got %q", mockTerm.GetOutput())
+	}
+	if entries := rl.history.Entries(); entries[len(entries)-1] != "echo two" {
+		t.Errorf("Expected the expanded line in history, got %q", entries)
+	}
+
+	// A failed expansion and :p run nothing
+	mockTerm.ClearOutput()
+	queueViKeys(mockTerm, "!nosuch\r")
+	if line, _ := rl.ReadLine(); line != "" || !strings.Contains(mockTerm.GetOutput(), "!nosuch: event not found") {
+		t.Errorf("Expected the failed expansion reported, got %q and output %q", line, mockTerm.GetOutput())
+	}
+	if entries := rl.history.Entries(); entries[len(entries)-1] != "echo two" {
+		t.Errorf("Expected the failed line left out of history, got %q", entries)
+	}
+	queueViKeys(mockTerm, "!echo:p\r")
+	if line, _ := rl.ReadLine(); line != "" {
+		t.Errorf("Expected :p to run nothing, got %q", line)
+	}
+
+	// magic-space expands the line as it is typed
+	if err := Bind("Space: magic-space"); err != nil {
+		t.Fatal(err)
+	}
+	resetKeymap(t)
+
+	queueViKeys(mockTerm, "!!")
+	mockTerm.QueueKey(terminal.KeyEvent{Rune: ' '})
+	queueViKeys(mockTerm, "three")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlA})
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlK})
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlY})
+	queueViKeys(mockTerm, "\r")
+	if line, _ := rl.ReadLine(); line != "echo two three" {
+		t.Errorf("Expected magic-space to expand !!, got %q", line)
+	}
+}
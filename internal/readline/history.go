@@ -16,6 +16,9 @@ type History struct {
 	file     string
 	maxSize  int
 	modified bool
+	// lastOld and lastNew are the text replaced by the last :s or ^old^new
+	// of history expansion, and its replacement.
+	lastOld, lastNew string
 }
 
 // NewHistory creates a new history manager with persistent storage.
@@ -155,6 +158,11 @@ func (h *History) Reload() {
 	}
 }
 
+// Entries returns the commands in history, the oldest first.
+func (h *History) Entries() []string {
+	return append([]string(nil), h.items...)
+}
+
 // ResetPosition resets history position to end.
 func (h *History) ResetPosition() {
 	h.pos = len(h.items)
@@ -197,6 +205,32 @@ func (h *History) NextWithPrefix(prefix string) string {
 	return ""
 }
 
+// PreviousContaining moves to the closest older item that contains text.
+func (h *History) PreviousContaining(text string) (string, bool) {
+	for i := min(h.pos, len(h.items)) - 1; i >= 0; i-- {
+		if strings.Contains(h.items[i], text) {
+			h.pos = i
+
+			return h.items[i], true
+		}
+	}
+
+	return "", false
+}
+
+// NextContaining moves to the closest newer item that contains text.
+func (h *History) NextContaining(text string) (string, bool) {
+	for i := h.pos + 1; i < len(h.items); i++ {
+		if strings.Contains(h.items[i], text) {
+			h.pos = i
+
+			return h.items[i], true
+		}
+	}
+
+	return "", false
+}
+
 // load reads history from disk.
 func (h *History) load() {
 	file, err := os.Open(h.file)
@@ -207,20 +241,32 @@ func (h *History) load() {
 		_ = file.Close() // Ignore close error on read-only file
 	}()
 
+	var entry []string
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			h.items = append(h.items, line)
+		line := scanner.Text()
+		if entry == nil {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+		}
+
+		line, more := decodeHistoryLine(line)
+		entry = append(entry, line)
+		if !more {
+			h.items = append(h.items, strings.Join(entry, "\n"))
+			entry = nil
 		}
 	}
 
 	h.pos = len(h.items)
 }
 
-// save writes history to disk.
+// save writes history to disk, unless it has no file.
 func (h *History) save() {
-	if !h.modified {
+	if !h.modified || h.file == "" {
 		return
 	}
 
@@ -244,8 +290,33 @@ func (h *History) save() {
 	if len(h.items) > 0 {
 		lastItem := h.items[len(h.items)-1]
 		timestamp := time.Now().Format("2006-01-02 15:04:05")
-		_, _ = fmt.Fprintf(file, "# %s\n%s\n", timestamp, lastItem)
+		_, _ = fmt.Fprintf(file, "# %s\n%s\n", timestamp, encodeHistoryEntry(lastItem))
 	}
 
 	h.modified = false
 }
+
+// encodeHistoryEntry writes a multi-line entry as one line per line of the
+// command. Trailing backslashes are doubled, and every line but the last
+// gets one more, so an odd count marks a line that continues.
+func encodeHistoryEntry(entry string) string {
+	lines := strings.Split(entry, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, `\`)
+		lines[i] = line + line[len(trimmed):]
+		if i < len(lines)-1 {
+			lines[i] += `\`
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// decodeHistoryLine reverses encodeHistoryEntry for one line and reports
+// whether the entry continues on the next line.
+func decodeHistoryLine(line string) (string, bool) {
+	trimmed := strings.TrimRight(line, `\`)
+	count := len(line) - len(trimmed)
+
+	return trimmed + strings.Repeat(`\`, count/2), count%2 == 1
+}
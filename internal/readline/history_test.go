@@ -2,7 +2,12 @@ package readline
 
 import (
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"dsh/internal/terminal"
+	"dsh/test/rendering"
 )
 
 func TestHistory_Add(t *testing.T) {
@@ -26,6 +31,33 @@ func TestHistory_Add(t *testing.T) {
 	}
 }
 
+func TestReadline_AnswerHistory(t *testing.T) {
+	history := answers
+	answers = NewEmptyHistory()
+	t.Cleanup(func() { answers = history })
+
+	// Each read -e has an editor of its own over the answers of the session
+	for _, keys := range [][]terminal.KeyEvent{
+		{{Rune: 's'}, {Rune: 'e'}, {Rune: 'c'}, {Key: terminal.KeyEnter}},
+		{{Key: terminal.KeyArrowUp}, {Key: terminal.KeyEnter}},
+	} {
+		mockTerm := rendering.NewMockTerminalInterface(80, 24)
+		rl := NewTestReadline(mockTerm)
+		rl.history = answers
+		for _, key := range keys {
+			mockTerm.QueueKey(key)
+		}
+
+		if line, err := rl.ReadLine(); err != nil || line != "sec" {
+			t.Fatalf("Expected the answer read, got %q (%v)", line, err)
+		}
+	}
+
+	if answers.file != "" {
+		t.Errorf("Expected the answers kept in memory only, got file %q", answers.file)
+	}
+}
+
 func TestHistory_AddEmpty(t *testing.T) {
 	h := &History{
 		items:   make([]string, 0, 1000),
@@ -168,3 +200,21 @@ func TestHistory_MaxSize(t *testing.T) {
 		t.Errorf("Expected 'command1199' last, got '%s'", h.items[len(h.items)-1])
 	}
 }
+
+func TestHistory_MultiLineEntries(t *testing.T) {
+	h := NewEmptyHistory()
+	h.file = filepath.Join(t.TempDir(), "history")
+
+	entries := []string{"if true\nthen echo a \\\n  b\nfi", `echo \\`, "single"}
+	for _, entry := range entries {
+		h.Add(entry)
+	}
+
+	loaded := NewEmptyHistory()
+	loaded.file = h.file
+	loaded.load()
+
+	if !reflect.DeepEqual(loaded.items, entries) {
+		t.Errorf("Expected %q after reloading, got %q", entries, loaded.items)
+	}
+}
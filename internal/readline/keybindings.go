@@ -1,10 +1,11 @@
 package readline
 
 import (
-	"fmt"
+	"strconv"
 	"strings"
 
 	"dsh/internal/terminal"
+	"dsh/internal/variables"
 )
 
 const (
@@ -13,188 +14,372 @@ const (
 	itemTypeDirectory = "directory"
 )
 
-func (r *Readline) handleKeyEvent(keyEvent terminal.KeyEvent) bool { //nolint:cyclop,funlen // Key handling naturally requires many branches and statements
-	// Handle printable characters first
-	if keyEvent.Rune != 0 && keyEvent.Key == terminal.KeyNone {
-		r.killRing.ResetYank()
-		if r.completionMenu.IsActive() {
-			r.clearTabCompletion()
+// handleKeyEvent handles a key and reports whether to go on reading, which
+// it does until a key accepts the line or, on an empty one, ends the input.
+// Keys are looked up in the keymap, together with the keys before them
+// while those start a longer key sequence.
+func (r *Readline) handleKeyEvent(keyEvent terminal.KeyEvent) bool {
+	if r.viEnabled && len(r.pendingKeys) == 0 {
+		if more, handled := r.handleViKey(keyEvent); handled {
+			return more
 		}
-		r.insertRune(keyEvent.Rune)
-		r.updateSuggestion()
+	}
+
+	// Escape closes the completion menu
+	if keyEvent.Key == terminal.KeyEscape && !keyEvent.Alt && len(r.pendingKeys) == 0 && r.completionMenu.IsActive() {
+		r.clearTabCompletion()
+		r.redraw()
+
 		return true
 	}
 
-	switch keyEvent.Key {
-	case terminal.KeyEnter:
-		if r.completionMenu.IsActive() {
-			r.acceptTabCompletion()
-			return true
-		}
-		return false // Signal completion
-	case terminal.KeyCtrlA:
-		r.killRing.ResetYank()
-		r.moveCursorToStart()
-	case terminal.KeyCtrlE:
-		r.killRing.ResetYank()
-		r.moveCursorToEnd()
-	case terminal.KeyCtrlB:
-		r.killRing.ResetYank()
-		r.moveCursorLeft()
-	case terminal.KeyCtrlC:
-		r.killRing.ResetYank()
-		if r.completionMenu.IsActive() {
-			r.clearTabCompletion()
-		}
-		r.clearLine()
-		_, _ = r.terminal.WriteString("^C\r\n")
-		r.displayPrompt()
-	case terminal.KeyCtrlF:
-		r.killRing.ResetYank()
-		if r.suggestion != "" {
-			r.acceptSuggestion()
-		} else {
-			r.moveCursorRight()
-		}
-	case terminal.KeyCtrlD:
-		r.killRing.ResetYank()
-		if len(r.buffer) == 0 {
-			// EOF case - let ReadLine handle it
-			return false
-		}
-		r.deleteChar()
-	case terminal.KeyCtrlK:
-		r.killToEnd()
-	case terminal.KeyCtrlL:
-		r.killRing.ResetYank()
-		r.clearScreen()
-	case terminal.KeyCtrlN:
-		r.killRing.ResetYank()
-		r.historyNext()
-	case terminal.KeyCtrlP:
-		r.killRing.ResetYank()
-		r.historyPrevious()
-	case terminal.KeyCtrlR:
-		r.killRing.ResetYank()
-		// Clear current line before fuzzy search
-		r.moveCursorToStart()
-		_, _ = r.terminal.WriteString("\033[K") // Clear line
-		if selected := r.FuzzyHistorySearchCustom(); selected != "" {
-			r.buffer = []rune(selected)
-			r.cursor = len(r.buffer)
-			r.redraw()
-		} else {
-			// Restore prompt if cancelled
-			r.redraw()
-		}
-	case terminal.KeyCtrlU:
-		r.killLine()
-	case terminal.KeyCtrlW:
-		r.killWordBackward()
-	case terminal.KeyCtrlY:
-		r.yank()
-	case terminal.KeyCtrlZ:
-		r.killRing.ResetYank()
-		_, _ = r.terminal.WriteString("^Z\r\n")
-		r.displayPrompt()
-	case terminal.KeyTab:
-		r.killRing.ResetYank()
-		if r.completionMenu.IsActive() {
-			r.navigateTabCompletion(1)
-		} else {
-			r.handleTabCompletion()
-		}
-	case terminal.KeyEscape:
-		// Always try to handle escape sequences first (including shift-tab)
-		err := r.handleEscapeSequence()
-		if err != nil {
-			// If escape sequence handling fails and we're in menu mode, exit menu
-			if r.completionMenu.IsActive() {
-				r.clearTabCompletion()
-				r.redraw()
+	// Pasted text is inserted as it is, not read as keys
+	if keyEvent.Key == terminal.KeyPaste {
+		r.pendingKeys = nil
+
+		return r.runBinding(Binding{Action: "bracketed-paste"}, keyEvent)
+	}
+
+	keys := append(r.pendingKeys, keyNames(keyEvent)...)
+	binding, bound, longer := lookupKeys(keys)
+
+	if longer {
+		r.pendingKeys = keys
+
+		return true
+	}
+	r.pendingKeys = nil
+
+	if bound {
+		return r.runBinding(binding, keyEvent)
+	}
+
+	// A sequence that went on past a binding runs the binding and then
+	// the key that did not fit
+	if len(keys) > 1 {
+		if prefix, ok, _ := lookupKeys(keys[:len(keys)-1]); ok {
+			if !r.runBinding(prefix, keyEvent) {
+				return false
 			}
 		}
-	case terminal.KeyBackspace:
-		r.killRing.ResetYank()
-		r.backspace()
-		r.searchPrefix = ""
-		r.browseMode = false // Exit browse mode when editing
-		r.updateSuggestion()
-	case terminal.KeyArrowUp:
-		r.killRing.ResetYank()
-		r.historyPrevious()
-	case terminal.KeyArrowDown:
-		r.killRing.ResetYank()
-		r.historyNext()
-	case terminal.KeyArrowLeft:
+
+		last := keyEvent
+		last.Alt = false
+
+		return r.handleKeyEvent(last)
+	}
+
+	if keyEvent.Rune != 0 && keyEvent.Key == terminal.KeyNone {
+		return r.runBinding(Binding{Action: "self-insert"}, keyEvent)
+	}
+
+	return true
+}
+
+// runBinding runs what a key sequence is bound to, remembering the line
+// before for undo, and reports whether to go on reading. keyEvent is its
+// last key.
+func (r *Readline) runBinding(binding Binding, keyEvent terminal.KeyEvent) bool {
+	before := r.takeSnapshot()
+	more := r.runBound(binding, keyEvent)
+	r.recordChange(before, binding.Action)
+
+	return more
+}
+
+// runBound runs what a key sequence is bound to.
+func (r *Readline) runBound(binding Binding, keyEvent terminal.KeyEvent) bool {
+	switch {
+	case binding.Command != "":
 		r.killRing.ResetYank()
-		r.moveCursorLeft()
-	case terminal.KeyArrowRight:
+		r.runBoundCommand(binding)
+	case binding.Action == "":
 		r.killRing.ResetYank()
-		if r.suggestion != "" {
-			r.acceptSuggestion()
-		} else {
-			r.moveCursorRight()
-		}
+		r.insertText(binding.Macro)
 	default:
-		// Handle unimplemented keys
+		action := findAction(binding.Action)
+		if action == nil {
+			return true
+		}
+		if binding.Action != "yank" && binding.Action != "yank-pop" {
+			r.killRing.ResetYank()
+		}
+
+		return action.run(r, keyEvent)
 	}
 
 	return true
 }
 
-func (r *Readline) handleEscapeSequence() error { //nolint:gocognit,cyclop,funlen // Terminal input handling requires complexity
-	// Read additional key events for complex sequences
-	keyEvent, err := r.terminal.ReadKey()
+// runBoundCommand runs the shell command of binding with READLINE_LINE and
+// READLINE_POINT set to the line and the cursor, and inserts its output at
+// the cursor or replaces the line with it.
+func (r *Readline) runBoundCommand(binding Binding) {
+	if commandRunner == nil {
+		return
+	}
+
+	variables.Set("READLINE_LINE", string(r.buffer))
+	variables.Set("READLINE_POINT", strconv.Itoa(r.cursor))
+	defer variables.Unset("READLINE_LINE")
+	defer variables.Unset("READLINE_POINT")
+
+	// The command may read from the terminal, which it expects in the
+	// usual mode
+	if r.rawTerminal != nil {
+		_ = r.rawTerminal.Restore()
+		defer func() { _ = r.rawTerminal.SetRawMode() }()
+	}
+
+	output, err := commandRunner(binding.Command)
 	if err != nil {
-		return fmt.Errorf("failed to read escape sequence: %w", err)
+		_, _ = r.terminal.Printf("\r\ndsh: %v\r\n", err)
+		r.displayPrompt()
 	}
 
-	// Handle Alt+key combinations
-	if keyEvent.Alt {
-		switch keyEvent.Rune {
-		case 127: // Alt+Backspace
-			r.killRing.ResetYank()
+	if binding.Replace {
+		r.buffer = r.buffer[:0]
+		r.cursor = 0
+	}
+	r.insertText(output)
+}
+
+// editorAction is an action keys can be bound to. run performs it, given
+// the last key of the sequence, and reports whether to go on reading.
+type editorAction struct {
+	name string
+	run  func(r *Readline, keyEvent terminal.KeyEvent) bool
+}
+
+// findAction returns the action called name, or nil if there is none.
+func findAction(name string) *editorAction {
+	for _, action := range editorActions() {
+		if action.name == name {
+			return &action
+		}
+	}
+
+	return nil
+}
+
+// editorActions returns the actions keys can be bound to.
+func editorActions() []editorAction { //nolint:funlen // One entry per action
+	return []editorAction{
+		{"accept-line", func(r *Readline, _ terminal.KeyEvent) bool {
+			if r.completionMenu.IsActive() {
+				r.acceptTabCompletion()
+
+				return true
+			}
+
+			return false
+		}},
+		{"self-insert", selfInsert},
+		{"bracketed-paste", func(r *Readline, keyEvent terminal.KeyEvent) bool {
+			if r.completionMenu.IsActive() {
+				r.clearTabCompletion()
+			}
+			r.searchPrefix = ""
+			r.browseMode = false
+			r.insertText(keyEvent.Text)
+
+			return true
+		}},
+		{"beginning-of-line", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.moveCursorToStart()
+
+			return true
+		}},
+		{"end-of-line", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.moveCursorToEnd()
+
+			return true
+		}},
+		{"backward-char", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.moveCursorLeft()
+
+			return true
+		}},
+		{"forward-char", func(r *Readline, _ terminal.KeyEvent) bool {
+			if r.suggestion != "" {
+				r.acceptSuggestion()
+			} else {
+				r.moveCursorRight()
+			}
+
+			return true
+		}},
+		{"backward-word", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.moveWordBackward()
+
+			return true
+		}},
+		{"forward-word", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.moveWordForward()
+
+			return true
+		}},
+		{"cancel-line", func(r *Readline, _ terminal.KeyEvent) bool {
+			if r.completionMenu.IsActive() {
+				r.clearTabCompletion()
+			}
+			r.clearLine()
+			r.resetUndo("")
+			_, _ = r.terminal.WriteString("^C\r\n")
+			r.cancelContinuation()
+			r.resetVi()
+			r.displayPrompt()
+
+			return true
+		}},
+		{"delete-char", func(r *Readline, _ terminal.KeyEvent) bool {
+			if len(r.buffer) == 0 {
+				// End of input, which ReadLine handles
+				r.eof = true
+
+				return false
+			}
+			r.deleteChar()
+
+			return true
+		}},
+		{"backward-delete-char", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.backspace()
+			r.searchPrefix = ""
+			r.browseMode = false // Exit browse mode when editing
+			r.updateSuggestion()
+
+			return true
+		}},
+		{"kill-line", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.killToEnd()
+
+			return true
+		}},
+		{"unix-line-discard", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.killLine()
+
+			return true
+		}},
+		{"unix-word-rubout", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.killWordBackward()
+
+			return true
+		}},
+		{"backward-kill-word", func(r *Readline, _ terminal.KeyEvent) bool {
 			r.killWordBackward()
-		case 'd': // Alt+D
+
+			return true
+		}},
+		{"kill-word", func(r *Readline, _ terminal.KeyEvent) bool {
 			r.killWordForward()
-		case 'y': // Alt+Y
+
+			return true
+		}},
+		{"yank", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.yank()
+
+			return true
+		}},
+		{"yank-pop", func(r *Readline, _ terminal.KeyEvent) bool {
 			r.yankPop()
-		}
-		return nil
-	}
 
-	// Handle special sequences
-	switch keyEvent.Key {
-	case terminal.KeyArrowUp:
-		if r.completionMenu.IsActive() {
-			r.navigateTabCompletion(-1)
-		} else {
+			return true
+		}},
+		{"clear-screen", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.clearScreen()
+
+			return true
+		}},
+		{"previous-history", func(r *Readline, _ terminal.KeyEvent) bool {
 			r.historyPrevious()
-		}
-	case terminal.KeyArrowDown:
-		if r.completionMenu.IsActive() {
-			r.navigateTabCompletion(1)
-		} else {
+
+			return true
+		}},
+		{"next-history", func(r *Readline, _ terminal.KeyEvent) bool {
 			r.historyNext()
-		}
-	case terminal.KeyArrowLeft:
-		if r.completionMenu.IsActive() {
-			r.navigateTabCompletion(-1)
-		} else {
-			r.moveCursorLeft()
-		}
-	case terminal.KeyArrowRight:
-		if r.completionMenu.IsActive() {
-			r.navigateTabCompletion(1)
-		} else {
-			r.moveCursorRight()
-		}
-	default:
-		// Handle unimplemented keys
+
+			return true
+		}},
+		{"fuzzy-history", func(r *Readline, _ terminal.KeyEvent) bool {
+			// Clear current line before fuzzy search
+			r.moveCursorToStart()
+			_, _ = r.terminal.WriteString("\033[K")
+			if selected := r.FuzzyHistorySearchCustom(); selected != "" {
+				r.buffer = []rune(selected)
+				r.cursor = len(r.buffer)
+			}
+			r.redraw()
+
+			return true
+		}},
+		{"complete", func(r *Readline, _ terminal.KeyEvent) bool {
+			if r.completionMenu.IsActive() {
+				r.navigateTabCompletion(1)
+			} else {
+				r.handleTabCompletion()
+			}
+
+			return true
+		}},
+		{"edit-command-line", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.editCommandLine()
+
+			return true
+		}},
+		{"edit-and-execute-command", func(r *Readline, _ terminal.KeyEvent) bool {
+			// An edited line is run at once, as Enter would
+			return !r.editCommandLine()
+		}},
+		{"undo", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.undo.skip = true
+			if r.undoChange() {
+				r.redraw()
+			}
+
+			return true
+		}},
+		{"redo", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.undo.skip = true
+			if r.redoChange() {
+				r.redraw()
+			}
+
+			return true
+		}},
+		{"revert-line", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.revertLine()
+			r.redraw()
+
+			return true
+		}},
+		{"magic-space", func(r *Readline, _ terminal.KeyEvent) bool {
+			r.magicSpace()
+
+			return selfInsert(r, terminal.KeyEvent{Rune: ' '})
+		}},
+		{"ignore-suspend", func(r *Readline, _ terminal.KeyEvent) bool {
+			_, _ = r.terminal.WriteString("^Z\r\n")
+			r.displayPrompt()
+
+			return true
+		}},
 	}
+}
 
-	return nil
+// selfInsert inserts the character typed.
+func selfInsert(r *Readline, keyEvent terminal.KeyEvent) bool {
+	if keyEvent.Rune == 0 {
+		return true
+	}
+
+	if r.completionMenu.IsActive() {
+		r.clearTabCompletion()
+	}
+	r.insertRune(keyEvent.Rune)
+	r.updateSuggestion()
+
+	return true
 }
 
 // handleTabCompletion handles tab key press for completion.
@@ -297,7 +482,7 @@ func (r *Readline) acceptTabCompletion() {
 	// Redraw the current input line cleanly
 	_, _ = r.terminal.WriteString("\033[2K") // Clear the current line
 	_, _ = r.terminal.WriteString("\r")      // Move to beginning of line
-	fullLine := r.prompt + string(r.buffer)
+	fullLine := terminal.LastLine(r.shownPrompt()) + r.styledText()
 	_, _ = r.terminal.WriteString(fullLine)
 }
 
@@ -331,6 +516,7 @@ func (r *Readline) historyNext() {
 		} else {
 			r.buffer = r.buffer[:0]
 			r.cursor = 0
+			r.resetUndo("")
 			r.browseMode = false
 			r.searchPrefix = ""
 			r.redraw()
@@ -346,6 +532,7 @@ func (r *Readline) historyNext() {
 func (r *Readline) setBufferFromHistory(line string) {
 	r.buffer = []rune(line)
 	r.cursor = len(r.buffer)
+	r.resetUndo(line)
 	r.redraw()
 }
 
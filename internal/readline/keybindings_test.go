@@ -1,6 +1,7 @@
 package readline
 
 import (
+	"strings"
 	"testing"
 
 	"dsh/internal/terminal"
@@ -198,3 +199,50 @@ func TestKeyBindings_EscapeSequences(t *testing.T) {
 		// Should not crash
 	})
 }
+
+func TestKeyBindings_Paste(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	queueViKeys(mockTerm, "# ")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyPaste, Text: "echo a\n\tec"})
+	queueViKeys(mockTerm, "\r")
+
+	line, err := rl.ReadLine()
+	if err != nil {
+		t.Fatalf("ReadLine failed: %v", err)
+	}
+	if line != "# echo a\n\tec" {
+		t.Errorf("Expected the paste inserted as it is, got %q", line)
+	}
+	if rl.completionMenu.IsActive() {
+		t.Error("Expected the pasted tab not to complete")
+	}
+	if output := mockTerm.GetOutput(); !strings.Contains(output, "# echo a↵^Iec") || strings.Contains(output, "\t") {
+		t.Errorf("Expected the pasted tab drawn as ^I, got %q", output)
+	}
+
+	// Control characters are shown the same way through the highlighter,
+	// apart from the escape sequences it adds
+	rl.SetHighlighter(func(text string) string { return "\033[1m" + text + "\033[0m" })
+	mockTerm.ClearOutput()
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyPaste, Text: "a\tb\033c"})
+	queueViKeys(mockTerm, "\r")
+
+	if line, _ := rl.ReadLine(); line != "a\tb\033c" {
+		t.Errorf("Expected the paste inserted as it is, got %q", line)
+	}
+	if output := mockTerm.GetOutput(); !strings.Contains(output, "\033[1ma^Ib^[c\033[0m") {
+		t.Errorf("Expected the pasted tab and escape drawn as ^I and ^[, got %q", output)
+	}
+	rl.SetHighlighter(nil)
+
+	// A paste is undone at once
+	queueViKeys(mockTerm, "ls")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyPaste, Text: " -l\n -a"})
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlUnderscore})
+	queueViKeys(mockTerm, "\r")
+
+	if line, _ := rl.ReadLine(); line != "ls" {
+		t.Errorf("Expected the paste undone, got %q", line)
+	}
+}
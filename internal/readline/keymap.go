@@ -0,0 +1,725 @@
+package readline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"dsh/internal/options"
+	"dsh/internal/terminal"
+)
+
+var (
+	// ErrUnknownAction indicates a binding to an action that does not exist.
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrInvalidKeys indicates a key sequence that cannot be read.
+	ErrInvalidKeys = errors.New("invalid key sequence")
+	// ErrInvalidBinding indicates a binding without a colon after its keys.
+	ErrInvalidBinding = errors.New("expected a key sequence, a colon and a binding")
+	// ErrNotBound indicates a key sequence that is bound to nothing.
+	ErrNotBound = errors.New("key sequence not bound")
+)
+
+// CommandRunner runs command and returns its output without the trailing
+// newlines.
+type CommandRunner func(command string) (string, error)
+
+// commandRunner is installed by the shell, since running commands belongs
+// to the executor.
+var commandRunner CommandRunner //nolint:gochecknoglobals // Hook installed once at startup
+
+// SetCommandRunner installs the function that runs the shell commands keys
+// are bound to. Until one is installed, such keys do nothing.
+func SetCommandRunner(fn CommandRunner) {
+	commandRunner = fn
+}
+
+// Binding is what a key sequence is bound to: an editor action such as
+// beginning-of-line, a macro whose text is inserted, or a shell command
+// whose output is inserted at the cursor or, with Replace, replaces the
+// line.
+type Binding struct {
+	Action  string
+	Macro   string
+	Command string
+	Replace bool
+}
+
+// keymap holds the key bindings and the settings that bind and the inputrc
+// file change. The keys of bindings are key sequences as keyNames gives
+// them, separated by spaces, as in "C-x C-e".
+var keymap struct { //nolint:gochecknoglobals // Shared by every line editor and the bind builtin
+	sync.Mutex
+	bindings map[string]Binding
+	// indicators are drawn before the prompt in vi mode, by mode.
+	indicators []string
+}
+
+// defaultBindings returns the keys bound until bind changes them.
+func defaultBindings() []struct{ keys, action string } {
+	return []struct{ keys, action string }{
+		{"RET", "accept-line"},
+		{"C-a", "beginning-of-line"},
+		{"Home", "beginning-of-line"},
+		{"C-e", "end-of-line"},
+		{"End", "end-of-line"},
+		{"C-b", "backward-char"},
+		{"Left", "backward-char"},
+		{"C-f", "forward-char"},
+		{"Right", "forward-char"},
+		{"ESC b", "backward-word"},
+		{"ESC f", "forward-word"},
+		{"C-c", "cancel-line"},
+		{"C-d", "delete-char"},
+		{"DEL", "backward-delete-char"},
+		{"C-k", "kill-line"},
+		{"C-u", "unix-line-discard"},
+		{"C-w", "unix-word-rubout"},
+		{"ESC DEL", "backward-kill-word"},
+		{"ESC d", "kill-word"},
+		{"C-y", "yank"},
+		{"ESC y", "yank-pop"},
+		{"C-l", "clear-screen"},
+		{"C-p", "previous-history"},
+		{"Up", "previous-history"},
+		{"C-n", "next-history"},
+		{"Down", "next-history"},
+		{"C-r", "fuzzy-history"},
+		{"TAB", "complete"},
+		{"C-x C-e", "edit-command-line"},
+		{"C-_", "undo"},
+		{"C-x C-u", "undo"},
+		{"ESC /", "redo"},
+		{"ESC r", "revert-line"},
+		{"C-z", "ignore-suspend"},
+	}
+}
+
+// loadKeymap fills in the default bindings the first time they are
+// needed. The lock must be held.
+func loadKeymap() {
+	if keymap.bindings != nil {
+		return
+	}
+
+	keymap.bindings = map[string]Binding{}
+	for _, binding := range defaultBindings() {
+		keymap.bindings[binding.keys] = Binding{Action: binding.action}
+	}
+	keymap.indicators = []string{viInsertIndicator, viCommandIndicator, viVisualIndicator}
+}
+
+// lookupKeys returns the binding of keys, and reports whether they are
+// bound and whether they start a longer sequence that is.
+func lookupKeys(keys []string) (Binding, bool, bool) {
+	keymap.Lock()
+	defer keymap.Unlock()
+	loadKeymap()
+
+	sequence := strings.Join(keys, " ")
+	binding, bound := keymap.bindings[sequence]
+
+	for other := range keymap.bindings {
+		if strings.HasPrefix(other, sequence+" ") {
+			return binding, bound, true
+		}
+	}
+
+	return binding, bound, false
+}
+
+// viIndicator returns what is drawn before the prompt in the vi mode.
+func viIndicator(mode viMode) string {
+	keymap.Lock()
+	defer keymap.Unlock()
+	loadKeymap()
+
+	return keymap.indicators[mode]
+}
+
+// Bind reads line as a line of an inputrc file: a key sequence, a colon,
+// and the name of an action or a quoted macro, as in "\C-t": "text", or
+// set and the name and value of a setting. The settings are editing-mode,
+// emacs or vi, and vi-ins-mode-string, vi-cmd-mode-string and
+// vi-visual-mode-string, the indicators of the vi modes. Like readline, it
+// ignores settings it does not know.
+func Bind(line string) error {
+	if setting, ok := strings.CutPrefix(strings.TrimSpace(line), "set "); ok {
+		return bindSetting(strings.TrimSpace(setting))
+	}
+
+	keys, value, err := splitBinding(line)
+	if err != nil {
+		return err
+	}
+
+	var binding Binding
+	if strings.HasPrefix(value, "\"") {
+		binding.Macro, err = unquote(value)
+		if err != nil {
+			return err
+		}
+	} else {
+		if findAction(value) == nil {
+			return fmt.Errorf("%s: %w", value, ErrUnknownAction)
+		}
+		binding.Action = value
+	}
+
+	setBinding(keys, binding)
+
+	return nil
+}
+
+// BindCommand binds the key sequence before the colon in line to the
+// shell command after it, which may be in double quotes, as in bash. The
+// output of the command is inserted at the cursor or, with replace,
+// replaces the line.
+func BindCommand(line string, replace bool) error {
+	keys, command, err := splitBinding(line)
+	if err != nil {
+		return err
+	}
+
+	if len(command) > 1 && command[0] == '"' && command[len(command)-1] == '"' {
+		command = command[1 : len(command)-1]
+	}
+
+	setBinding(keys, Binding{Command: command, Replace: replace})
+
+	return nil
+}
+
+// Unbind removes the binding of the key sequence text.
+func Unbind(text string) error {
+	keys, err := parseKeys(text)
+	if err != nil {
+		return err
+	}
+
+	keymap.Lock()
+	defer keymap.Unlock()
+	loadKeymap()
+
+	sequence := strings.Join(keys, " ")
+	if _, ok := keymap.bindings[sequence]; !ok {
+		return fmt.Errorf("%s: %w", text, ErrNotBound)
+	}
+	delete(keymap.bindings, sequence)
+
+	return nil
+}
+
+// Bindings returns the bindings sorted by key sequence, each as a line
+// that ReadInputrc reads back.
+func Bindings() []string {
+	keymap.Lock()
+	defer keymap.Unlock()
+	loadKeymap()
+
+	lines := make([]string, 0, len(keymap.bindings))
+	for sequence, binding := range keymap.bindings {
+		keys := formatKeys(strings.Fields(sequence))
+
+		switch {
+		case binding.Command != "" && binding.Replace:
+			lines = append(lines, "-X "+keys+": "+binding.Command)
+		case binding.Command != "":
+			lines = append(lines, "-x "+keys+": "+binding.Command)
+		case binding.Action == "":
+			lines = append(lines, keys+": "+quote(binding.Macro))
+		default:
+			lines = append(lines, keys+": "+binding.Action)
+		}
+	}
+	sort.Strings(lines)
+
+	return lines
+}
+
+// Actions returns the names of the editor actions keys can be bound to.
+func Actions() []string {
+	var names []string
+	for _, action := range editorActions() {
+		names = append(names, action.name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ReadInputrc reads the bindings in the file at path, one per line as Bind
+// reads them, skipping blank lines and lines starting with #. Lines
+// starting with -x or -X bind shell commands, as BindCommand does. A line
+// in error is reported and the rest are still read.
+func ReadInputrc(path string) error {
+	file, err := os.Open(path) //nolint:gosec // Reading the file the user names is the point
+	if err != nil {
+		return fmt.Errorf("reading key bindings: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var errs []error
+
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if command, ok := strings.CutPrefix(line, "-x "); ok {
+			err = BindCommand(command, false)
+		} else if command, ok := strings.CutPrefix(line, "-X "); ok {
+			err = BindCommand(command, true)
+		} else {
+			err = Bind(line)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: line %d: %w", path, number, err))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// bindSetting sets a setting from the name and value in text.
+func bindSetting(text string) error {
+	name, value, _ := strings.Cut(text, " ")
+	value = strings.TrimSpace(value)
+
+	if name == "editing-mode" {
+		switch value {
+		case "emacs", "vi":
+			return options.Set(options.Vi, value == "vi")
+		default:
+			return fmt.Errorf("editing-mode %s: %w", value, options.ErrInvalidOption)
+		}
+	}
+
+	mode := -1
+	switch name {
+	case "vi-ins-mode-string":
+		mode = int(viInsert)
+	case "vi-cmd-mode-string":
+		mode = int(viCommand)
+	case "vi-visual-mode-string":
+		mode = int(viVisual)
+	}
+	if mode < 0 {
+		return nil
+	}
+
+	if strings.HasPrefix(value, "\"") {
+		var err error
+		if value, err = unquote(value); err != nil {
+			return err
+		}
+	}
+
+	keymap.Lock()
+	defer keymap.Unlock()
+	loadKeymap()
+
+	keymap.indicators[mode] = value
+
+	return nil
+}
+
+// setBinding binds keys to binding.
+func setBinding(keys []string, binding Binding) {
+	keymap.Lock()
+	defer keymap.Unlock()
+	loadKeymap()
+
+	keymap.bindings[strings.Join(keys, " ")] = binding
+}
+
+// splitBinding splits line at the colon after its key sequence and
+// returns the keys and the text after the colon.
+func splitBinding(line string) ([]string, string, error) {
+	line = strings.TrimSpace(line)
+
+	end := strings.IndexByte(line, ':')
+	if strings.HasPrefix(line, "\"") {
+		end = closingQuote(line)
+		if end > 0 {
+			end = strings.IndexByte(line[end:], ':') + end
+		}
+	}
+	if end <= 0 {
+		return nil, "", fmt.Errorf("%s: %w", line, ErrInvalidBinding)
+	}
+
+	keys, err := parseKeys(strings.TrimSpace(line[:end]))
+	if err != nil {
+		return nil, "", err
+	}
+
+	value := strings.TrimSpace(line[end+1:])
+	if value == "" {
+		return nil, "", fmt.Errorf("%s: %w", line, ErrInvalidBinding)
+	}
+
+	return keys, value, nil
+}
+
+// closingQuote returns the index of the quote that closes the one text
+// starts with, or -1 if there is none.
+func closingQuote(text string) int {
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// keyNames returns the names of the keys keyEvent stands for, as the
+// keymap has them: a character itself, C-a for Control-a, names such as
+// RET, TAB, DEL, ESC, SPC and Up for the others, and ESC and the key for
+// Alt and the key, since Meta is typed as Escape and the key.
+func keyNames(keyEvent terminal.KeyEvent) []string {
+	name := keyName(keyEvent)
+	if keyEvent.Alt {
+		return []string{"ESC", name}
+	}
+
+	return []string{name}
+}
+
+// keyName returns the name of the key of keyEvent, leaving out Alt.
+func keyName(keyEvent terminal.KeyEvent) string { //nolint:cyclop // One case per named key
+	switch keyEvent.Key {
+	case terminal.KeyEnter:
+		return "RET"
+	case terminal.KeyTab:
+		return "TAB"
+	case terminal.KeyBackspace:
+		return "DEL"
+	case terminal.KeyDelete:
+		return "Delete"
+	case terminal.KeyEscape:
+		return "ESC"
+	case terminal.KeyArrowUp:
+		return "Up"
+	case terminal.KeyArrowDown:
+		return "Down"
+	case terminal.KeyArrowLeft:
+		return "Left"
+	case terminal.KeyArrowRight:
+		return "Right"
+	case terminal.KeyHome:
+		return "Home"
+	case terminal.KeyEnd:
+		return "End"
+	case terminal.KeyPageUp:
+		return "PageUp"
+	case terminal.KeyPageDown:
+		return "PageDown"
+	case terminal.KeyNone:
+		if keyEvent.Rune == ' ' {
+			return "SPC"
+		}
+
+		return string(keyEvent.Rune)
+	}
+
+	for _, letter := range "abcdefghijklmnopqrstuvwxyz_" {
+		if terminal.ControlKey(letter) == keyEvent.Key {
+			return "C-" + string(letter)
+		}
+	}
+
+	return ""
+}
+
+// namedKeys returns the keys that have names, with the names inputrc gives
+// them.
+func namedKeys() []struct {
+	name string
+	key  terminal.Key
+} {
+	return []struct {
+		name string
+		key  terminal.Key
+	}{
+		{"RET", terminal.KeyEnter}, {"RETURN", terminal.KeyEnter},
+		{"LFD", terminal.KeyEnter}, {"NEWLINE", terminal.KeyEnter},
+		{"TAB", terminal.KeyTab},
+		{"DEL", terminal.KeyBackspace}, {"RUBOUT", terminal.KeyBackspace},
+		{"ESC", terminal.KeyEscape}, {"ESCAPE", terminal.KeyEscape},
+		{"Delete", terminal.KeyDelete},
+		{"Up", terminal.KeyArrowUp}, {"Down", terminal.KeyArrowDown},
+		{"Left", terminal.KeyArrowLeft}, {"Right", terminal.KeyArrowRight},
+		{"Home", terminal.KeyHome}, {"End", terminal.KeyEnd},
+		{"PageUp", terminal.KeyPageUp}, {"PageDown", terminal.KeyPageDown},
+	}
+}
+
+// escapeSequences returns the keys that terminals send as sequences
+// starting with Escape, which are bound by their names.
+func escapeSequences() []struct{ sequence, name string } {
+	return []struct{ sequence, name string }{
+		{"[A", "Up"}, {"[B", "Down"}, {"[C", "Right"}, {"[D", "Left"},
+		{"[H", "Home"}, {"[F", "End"}, {"OA", "Up"}, {"OB", "Down"},
+		{"OC", "Right"}, {"OD", "Left"}, {"OH", "Home"}, {"OF", "End"},
+		{"[3~", "Delete"}, {"[5~", "PageUp"}, {"[6~", "PageDown"},
+	}
+}
+
+// parseKeys reads a key sequence as inputrc writes it: in quotes, with
+// escapes such as \C-x for Control-x and \e or \M- for Meta, or as key
+// names separated by blanks, as in C-x C-e or Meta-Rubout.
+func parseKeys(text string) ([]string, error) {
+	if !strings.HasPrefix(text, "\"") {
+		var keys []string
+		for _, word := range strings.Fields(text) {
+			names, err := parseKeyName(word)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", text, err)
+			}
+			keys = append(keys, names...)
+		}
+
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("%q: %w", text, ErrInvalidKeys)
+		}
+
+		return keys, nil
+	}
+
+	if closingQuote(text) != len(text)-1 {
+		return nil, fmt.Errorf("%s: %w", text, ErrInvalidKeys)
+	}
+
+	var keys []string
+	runes := []rune(text[1 : len(text)-1])
+	for i := 0; i < len(runes); {
+		names, length, err := parseQuotedKey(runes[i:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", text, err)
+		}
+		keys = append(keys, names...)
+		i += length
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: %w", text, ErrInvalidKeys)
+	}
+
+	return foldEscapeSequences(keys), nil
+}
+
+// parseQuotedKey reads the key at the start of text, the inside of a
+// quoted key sequence, and returns its names and the runes it took.
+func parseQuotedKey(text []rune) ([]string, int, error) {
+	if text[0] != '\\' || len(text) == 1 {
+		return []string{keyName(terminal.KeyEvent{Rune: text[0]})}, 1, nil
+	}
+
+	switch text[1] {
+	case 'C', 'M':
+		if len(text) < 4 || text[2] != '-' {
+			return nil, 0, ErrInvalidKeys
+		}
+
+		if text[1] == 'M' {
+			names, length, err := parseQuotedKey(text[3:])
+			if err != nil {
+				return nil, 0, err
+			}
+
+			return append([]string{"ESC"}, names...), length + 3, nil
+		}
+
+		name, err := controlName(text[3])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return []string{name}, 4, nil
+	case 'e':
+		return []string{"ESC"}, 2, nil
+	case 't':
+		return []string{"TAB"}, 2, nil
+	case 'n', 'r':
+		return []string{"RET"}, 2, nil
+	case '\\', '"', '\'':
+		return []string{string(text[1])}, 2, nil
+	}
+
+	return nil, 0, ErrInvalidKeys
+}
+
+// parseKeyName reads a key written by name, such as C-x, Control-u, M-d,
+// TAB or a character.
+func parseKeyName(word string) ([]string, error) {
+	for _, prefix := range []string{"M-", "Meta-", "m-", "meta-"} {
+		if rest, ok := strings.CutPrefix(word, prefix); ok && rest != "" {
+			names, err := parseKeyName(rest)
+
+			return append([]string{"ESC"}, names...), err
+		}
+	}
+
+	for _, prefix := range []string{"C-", "Control-", "c-", "control-"} {
+		if rest, ok := strings.CutPrefix(word, prefix); ok && len([]rune(rest)) == 1 {
+			name, err := controlName([]rune(rest)[0])
+
+			return []string{name}, err
+		}
+	}
+
+	if len([]rune(word)) == 1 {
+		return []string{keyName(terminal.KeyEvent{Rune: []rune(word)[0]})}, nil
+	}
+
+	switch strings.ToUpper(word) {
+	case "SPC", "SPACE":
+		return []string{"SPC"}, nil
+	}
+
+	for _, named := range namedKeys() {
+		if strings.EqualFold(word, named.name) {
+			return []string{keyName(terminal.KeyEvent{Key: named.key})}, nil
+		}
+	}
+
+	return nil, ErrInvalidKeys
+}
+
+// controlName returns the name of the key typed as Control and ch.
+func controlName(ch rune) (string, error) {
+	key := terminal.ControlKey(unicode.ToLower(ch))
+	if key == terminal.KeyNone {
+		return "", ErrInvalidKeys
+	}
+
+	return keyName(terminal.KeyEvent{Key: key}), nil
+}
+
+// foldEscapeSequences replaces the sequences that terminals send for keys
+// such as the arrows with the names of the keys, which is how they are
+// read.
+func foldEscapeSequences(keys []string) []string {
+	var folded []string
+
+	for i := 0; i < len(keys); i++ {
+		found := false
+
+		if keys[i] == "ESC" {
+			for _, escape := range escapeSequences() {
+				length := len(escape.sequence)
+				if i+length < len(keys) && strings.Join(keys[i+1:i+1+length], "") == escape.sequence {
+					folded = append(folded, escape.name)
+					i += length
+					found = true
+
+					break
+				}
+			}
+		}
+
+		if !found {
+			folded = append(folded, keys[i])
+		}
+	}
+
+	return folded
+}
+
+// formatKeys returns keys as a quoted inputrc key sequence.
+func formatKeys(keys []string) string {
+	var text strings.Builder
+	text.WriteByte('"')
+
+	for _, name := range keys {
+		switch name {
+		case "ESC":
+			text.WriteString(`\e`)
+		case "TAB":
+			text.WriteString(`\t`)
+		case "RET":
+			text.WriteString(`\r`)
+		case "DEL":
+			text.WriteString(`\C-?`)
+		case "SPC":
+			text.WriteByte(' ')
+		case `"`, `\`:
+			text.WriteString(`\` + name)
+		default:
+			sequence := ""
+			for _, escape := range escapeSequences() {
+				if escape.name == name && sequence == "" {
+					sequence = `\e` + escape.sequence
+				}
+			}
+
+			switch {
+			case sequence != "":
+				text.WriteString(sequence)
+			case strings.HasPrefix(name, "C-"):
+				text.WriteString(`\` + name)
+			default:
+				text.WriteString(name)
+			}
+		}
+	}
+
+	text.WriteByte('"')
+
+	return text.String()
+}
+
+// unquote returns the text of a quoted macro, with the escapes \n, \t,
+// \e, \\, \" and \' replaced.
+func unquote(text string) (string, error) {
+	if closingQuote(text) != len(text)-1 {
+		return "", fmt.Errorf("%s: %w", text, ErrInvalidBinding)
+	}
+
+	var result strings.Builder
+	for i := 1; i < len(text)-1; i++ {
+		if text[i] != '\\' {
+			result.WriteByte(text[i])
+
+			continue
+		}
+
+		i++
+		switch text[i] {
+		case 'n':
+			result.WriteByte('\n')
+		case 't':
+			result.WriteByte('\t')
+		case 'e':
+			result.WriteByte('\033')
+		default:
+			result.WriteByte(text[i])
+		}
+	}
+
+	return result.String(), nil
+}
+
+// quote returns text as a quoted macro that unquote reads back.
+func quote(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\033", `\e`)
+
+	return `"` + replacer.Replace(text) + `"`
+}
@@ -0,0 +1,265 @@
+package readline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"dsh/internal/options"
+	"dsh/internal/terminal"
+	"dsh/internal/variables"
+	"dsh/test/rendering"
+)
+
+// resetKeymap restores the default bindings once the test is done.
+func resetKeymap(t *testing.T) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		keymap.Lock()
+		keymap.bindings = nil
+		keymap.Unlock()
+	})
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`"\C-x\C-e"`, "C-x C-e"},
+		{`C-x C-e`, "C-x C-e"},
+		{`Control-u`, "C-u"},
+		{`"\M-d"`, "ESC d"},
+		{`M-DEL`, "ESC DEL"},
+		{`Meta-Rubout`, "ESC DEL"},
+		{`"\ed"`, "ESC d"},
+		{`"\t"`, "TAB"},
+		{`"\C-i"`, "TAB"},
+		{`"\C-m"`, "RET"},
+		{`"\C-?"`, "DEL"},
+		{`"\e[A"`, "Up"},
+		{`"\eOD"`, "Left"},
+		{`"\e[3~"`, "Delete"},
+		{`"ab"`, "a b"},
+		{`"\\\""`, `\ "`},
+		{`"a b"`, "a SPC b"},
+		{`TAB`, "TAB"},
+		{`Space`, "SPC"},
+		{`x`, "x"},
+	}
+
+	for _, tt := range tests {
+		keys, err := parseKeys(tt.text)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.text, err)
+
+			continue
+		}
+		if got := strings.Join(keys, " "); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.text, tt.want, got)
+		}
+	}
+
+	for _, text := range []string{`"\C-"`, `"\q"`, `"abc`, `Nonsense`, `""`} {
+		if _, err := parseKeys(text); !errors.Is(err, ErrInvalidKeys) {
+			t.Errorf("%s: expected ErrInvalidKeys, got %v", text, err)
+		}
+	}
+}
+
+func TestFormatKeys(t *testing.T) {
+	for _, text := range []string{`"\C-x\C-e"`, `"\ed"`, `"\e\C-?"`, `"\e[A"`, `"\t"`, `"a b"`, `"\\\""`} {
+		keys, err := parseKeys(text)
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		if got := formatKeys(keys); got != text {
+			t.Errorf("Expected %s, got %s", text, got)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	resetKeymap(t)
+
+	if err := Bind(`"\C-t": kill-word`); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if err := Bind(`C-x C-g: "hello"`); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if err := BindCommand(`"\C-xd": date`, true); err != nil {
+		t.Fatalf("BindCommand failed: %v", err)
+	}
+	if err := BindCommand(`"\C-y": "echo inserted"`, false); err != nil {
+		t.Fatalf("BindCommand failed: %v", err)
+	}
+
+	lines := Bindings()
+	wanted := []string{`"\C-t": kill-word`, `"\C-x\C-g": "hello"`, `-X "\C-xd": date`, `-x "\C-y": echo inserted`, `"\C-a": beginning-of-line`}
+	for _, want := range wanted {
+		if !slices.Contains(lines, want) {
+			t.Errorf("Expected %q among the bindings, got %q", want, lines)
+		}
+	}
+
+	if err := Unbind(`"\C-t"`); err != nil {
+		t.Errorf("Unbind failed: %v", err)
+	}
+	if err := Unbind(`"\C-t"`); !errors.Is(err, ErrNotBound) {
+		t.Errorf("Expected ErrNotBound, got %v", err)
+	}
+
+	if err := Bind(`"\C-t": no-such-action`); !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("Expected ErrUnknownAction, got %v", err)
+	}
+	if err := Bind(`kill-word`); !errors.Is(err, ErrInvalidBinding) {
+		t.Errorf("Expected ErrInvalidBinding, got %v", err)
+	}
+	if err := Bind(`set bell-style none`); err != nil {
+		t.Errorf("Expected unknown settings to be ignored, got %v", err)
+	}
+
+	if !slices.Contains(Actions(), "fuzzy-history") {
+		t.Errorf("Expected fuzzy-history among the actions, got %q", Actions())
+	}
+}
+
+func TestBind_Settings(t *testing.T) {
+	resetKeymap(t)
+	t.Cleanup(func() { _ = options.Set(options.Vi, false) })
+
+	if err := Bind("set editing-mode vi"); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if !options.Enabled(options.Vi) {
+		t.Error("Expected editing-mode vi to turn on vi mode")
+	}
+
+	if err := Bind(`set vi-cmd-mode-string "(cmd) "`); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	rl, mockTerm := newViReadline()
+	_ = rl.ProcessKey(terminal.KeyEvent{Key: terminal.KeyEscape})
+	if !strings.Contains(mockTerm.GetOutput(), "(cmd) dsh> ") {
+		t.Errorf("Expected the command mode string, got %q", mockTerm.GetOutput())
+	}
+}
+
+func TestKeymap_Sequences(t *testing.T) {
+	resetKeymap(t)
+
+	for _, line := range []string{`"\C-xa": beginning-of-line`, `"\C-t": "macro"`, `"\eb": end-of-line`} {
+		if err := Bind(line); err != nil {
+			t.Fatalf("Bind failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		keys []terminal.KeyEvent
+		want string
+	}{
+		{"chord", []terminal.KeyEvent{{Rune: 'b'}, {Key: terminal.KeyCtrlX}, {Rune: 'a'}, {Rune: 'a'}}, "ab"},
+		{"unbound chord", []terminal.KeyEvent{{Rune: 'b'}, {Key: terminal.KeyCtrlX}, {Rune: 'z'}}, "bz"},
+		{"macro", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlT}}, "amacro"},
+		{"rebound", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlA}, {Rune: 'b', Alt: true}, {Rune: 'c'}}, "ac"},
+		{"escape then key", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlA}, {Key: terminal.KeyEscape}, {Rune: 'b'}, {Rune: 'c'}}, "ac"},
+		{"unbound meta key", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyEscape}, {Rune: 'q'}}, "aq"},
+	}
+
+	for _, tt := range tests {
+		mockTerm := rendering.NewMockTerminalInterface(80, 24)
+		rl := NewTestReadline(mockTerm)
+		for _, key := range tt.keys {
+			mockTerm.QueueKey(key)
+		}
+		mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyEnter})
+
+		line, err := rl.ReadLine()
+		if err != nil {
+			t.Fatalf("%s: ReadLine failed: %v", tt.name, err)
+		}
+		if line != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, line)
+		}
+	}
+}
+
+func TestKeymap_Commands(t *testing.T) {
+	resetKeymap(t)
+	t.Cleanup(func() { SetCommandRunner(nil) })
+
+	var seen string
+	SetCommandRunner(func(command string) (string, error) {
+		line, _ := variables.Get("READLINE_LINE")
+		point, _ := variables.Get("READLINE_POINT")
+		seen = command + " " + line + " " + point
+
+		return "out", nil
+	})
+
+	if err := BindCommand(`"\C-t": insert`, false); err != nil {
+		t.Fatalf("BindCommand failed: %v", err)
+	}
+	if err := BindCommand(`"\C-o": replace`, true); err != nil {
+		t.Fatalf("BindCommand failed: %v", err)
+	}
+
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	queueViKeys(mockTerm, "ab")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlB})
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlT})
+	queueViKeys(mockTerm, "\r")
+
+	if line, _ := rl.ReadLine(); line != "aoutb" {
+		t.Errorf("Expected the output inserted at the cursor, got %q", line)
+	}
+	if seen != "insert ab 1" {
+		t.Errorf("Expected the command to see the line and cursor, got %q", seen)
+	}
+	if variables.IsSet("READLINE_LINE") {
+		t.Error("Expected READLINE_LINE to be unset after the command")
+	}
+
+	queueViKeys(mockTerm, "ab")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlO})
+	queueViKeys(mockTerm, "\r")
+
+	if line, _ := rl.ReadLine(); line != "out" {
+		t.Errorf("Expected the output to replace the line, got %q", line)
+	}
+}
+
+func TestReadInputrc(t *testing.T) {
+	resetKeymap(t)
+
+	path := filepath.Join(t.TempDir(), "inputrc")
+	content := "# bindings\n\n\"\\C-t\": kill-word\n-x \"\\C-o\": date\nnot a binding\n\"\\C-g\": no-such-action\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ReadInputrc(path)
+	if !errors.Is(err, ErrInvalidBinding) || !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("Expected both bad lines reported, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("Expected the line number in the error, got %v", err)
+	}
+
+	lines := Bindings()
+	if !slices.Contains(lines, `"\C-t": kill-word`) || !slices.Contains(lines, `-x "\C-o": date`) {
+		t.Errorf("Expected the good lines bound, got %q", lines)
+	}
+
+	if err := ReadInputrc(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package readline
+
+import (
+	"errors"
+	"io"
+)
+
+// LineEditor gives the builtins read and bind the line editor, which they
+// reach through the hook the shell installs rather than by importing it.
+type LineEditor struct{}
+
+// ReadLine reads a line with an editor whose history is that of the
+// earlier answers of the session, which is never saved since the answers
+// read takes may be secrets.
+func (LineEditor) ReadLine(prompt string) (string, error) {
+	editor, err := NewForAnswers(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := editor.ReadLine()
+	if errors.Is(err, ErrEOF) {
+		return "", io.EOF
+	}
+
+	return text, err
+}
+
+// SetInputMode sets the echo and line buffering of the terminal, and
+// returns the function that restores them.
+func (LineEditor) SetInputMode(echo, canonical bool) (func(), error) {
+	term, err := NewTerminal()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := term.SetInputMode(echo, canonical); err != nil {
+		return nil, err
+	}
+
+	return func() { _ = term.Restore() }, nil
+}
+
+// Bind binds keys as a line of the inputrc file does.
+func (LineEditor) Bind(line string) error {
+	return Bind(line)
+}
+
+// BindCommand binds keys to a shell command.
+func (LineEditor) BindCommand(line string, replace bool) error {
+	return BindCommand(line, replace)
+}
+
+// Unbind removes the binding of keys.
+func (LineEditor) Unbind(keys string) error {
+	return Unbind(keys)
+}
+
+// ReadInputrc reads a file of key bindings.
+func (LineEditor) ReadInputrc(path string) error {
+	return ReadInputrc(path)
+}
+
+// Bindings lists the key bindings.
+func (LineEditor) Bindings() []string {
+	return Bindings()
+}
+
+// Actions lists the editing actions.
+func (LineEditor) Actions() []string {
+	return Actions()
+}
@@ -1,9 +1,12 @@
-// Package readline provides emacs-like line editing functionality.
+// Package readline provides line editing with emacs keys or, with set -o
+// vi, vi keys.
 package readline
 
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
 	"dsh/internal/terminal"
 )
@@ -13,7 +16,15 @@ var (
 	ErrEOF = errors.New("EOF")
 )
 
-// Readline provides emacs-like line editing functionality.
+// ContinuationFunc reports whether text, the input read so far, is an
+// incomplete command, and if so the prompt to show for the next line.
+type ContinuationFunc func(text string) (prompt string, more bool)
+
+// HighlightFunc returns text, the input, with the escape sequences that
+// colour it.
+type HighlightFunc func(text string) string
+
+// Readline provides line editing with emacs or vi keys.
 type Readline struct {
 	prompt         string
 	terminal       terminal.TerminalInterface
@@ -28,10 +39,53 @@ type Readline struct {
 	completion     *Completion
 	completionMenu *CompletionMenu
 	bufferManager  *BufferManager
+	continuation   ContinuationFunc
+	highlight      HighlightFunc
+	// pending holds the lines entered so far of an incomplete command, and
+	// mainPrompt the prompt to restore once it is complete.
+	pending    []string
+	mainPrompt string
+	// linePrompts are the prompts the lines in pending were entered at.
+	linePrompts []string
+	// rightPrompt is drawn at the right end of the first input line, and
+	// transientPrompt, if set, replaces the main prompt once the input is
+	// entered, so that the scrollback keeps only a compact form.
+	rightPrompt     string
+	transientPrompt string
+	// mu is held while a key is handled, so that Repaint, called from
+	// other goroutines, draws between keys and only while reading is set.
+	mu      sync.Mutex
+	reading bool
+	// viEnabled switches the keys to vi mode, whose state is vi.
+	viEnabled bool
+	vi        viState
+	// histExpand turns on history expansion, as set -H chooses.
+	histExpand bool
+	// undo remembers the changes to the line for undo and redo.
+	undo undoState
+	// pendingKeys are the keys typed so far of a longer key sequence, and
+	// eof is set by a key that ends the input.
+	pendingKeys []string
+	eof         bool
 }
 
 // New creates a new readline instance.
 func New(prompt string) (*Readline, error) {
+	return newReadline(prompt, NewHistory())
+}
+
+// answers is the history of the lines read -e takes, kept for the
+// session only since they may be secrets.
+var answers = NewEmptyHistory() //nolint:gochecknoglobals // Shared by every read -e of the session
+
+// NewForAnswers creates a readline instance, as read -e uses, whose
+// history is that of the earlier answers, kept in memory and never saved
+// to the history file.
+func NewForAnswers(prompt string) (*Readline, error) {
+	return newReadline(prompt, answers)
+}
+
+func newReadline(prompt string, history *History) (*Readline, error) {
 	termInterface := terminal.NewInterface()
 
 	rawTerminal, err := NewTerminal()
@@ -45,7 +99,7 @@ func New(prompt string) (*Readline, error) {
 		prompt:         prompt,
 		terminal:       termInterface,
 		rawTerminal:    rawTerminal,
-		history:        NewHistory(),
+		history:        history,
 		killRing:       NewKillRing(),
 		buffer:         make([]rune, 0, 256),
 		cursor:         0,
@@ -58,23 +112,41 @@ func New(prompt string) (*Readline, error) {
 	}, nil
 }
 
-// ReadLine reads a line with emacs-like editing.
+// ReadLine reads a line with line editing.
 func (r *Readline) ReadLine() (string, error) {
-	err := r.rawTerminal.SetRawMode()
-	if err != nil {
-		return "", fmt.Errorf("failed to set raw mode: %w", err)
+	// Test instances have no raw terminal
+	if r.rawTerminal != nil {
+		err := r.rawTerminal.SetRawMode()
+		if err != nil {
+			return "", fmt.Errorf("failed to set raw mode: %w", err)
+		}
+		defer func() {
+			// Clean up any temporary buffers before restoring terminal
+			r.bufferManager.CleanupAll()
+			_ = r.rawTerminal.Restore()
+		}()
 	}
-	defer func() {
-		// Clean up any temporary buffers before restoring terminal
-		r.bufferManager.CleanupAll()
-		_ = r.rawTerminal.Restore()
-	}()
 
+	r.mu.Lock()
 	r.buffer = r.buffer[:0]
 	r.cursor = 0
 	r.history.ResetPosition()
-
+	r.pending = nil
+	r.linePrompts = nil
+	r.mainPrompt = r.prompt
+	r.resetVi()
+	r.pendingKeys = nil
+	r.undo = undoState{}
+	r.reading = true
 	r.displayPrompt()
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.prompt = r.mainPrompt
+		r.reading = false
+		r.mu.Unlock()
+	}()
 
 	for {
 		keyEvent, err := r.terminal.ReadKey()
@@ -82,25 +154,160 @@ func (r *Readline) ReadLine() (string, error) {
 			return "", fmt.Errorf("failed to read key: %w", err)
 		}
 
-		if r.handleKeyEvent(keyEvent) {
-			continue
+		r.mu.Lock()
+		line, done, err := r.handleInput(keyEvent)
+		r.mu.Unlock()
+
+		if done {
+			return line, err
 		}
+	}
+}
 
-		// Check for EOF case
-		if keyEvent.Key == terminal.KeyCtrlD && len(r.buffer) == 0 {
-			return "", ErrEOF
+// handleInput handles a key read by ReadLine and reports whether it ends
+// the input, and if so with which line.
+func (r *Readline) handleInput(keyEvent terminal.KeyEvent) (string, bool, error) {
+	if r.handleKeyEvent(keyEvent) {
+		return "", false, nil
+	}
+
+	// Check for EOF case
+	if r.eof {
+		r.eof = false
+		if len(r.pending) == 0 {
+			return "", true, ErrEOF
 		}
 
-		// Return completed line
-		r.moveCursorToEnd()
+		// Hand over the incomplete command so its error is reported
 		_, _ = r.terminal.WriteString("\r\n")
-		line := string(r.buffer)
-		if line != "" {
-			r.history.Add(line)
+
+		return r.finishInput(strings.Join(r.pending, "\n")), true, nil
+	}
+
+	r.moveCursorToEnd()
+	text := strings.Join(append(r.pending, string(r.buffer)), "\n")
+
+	// Keep reading while the command is incomplete
+	if r.continuation != nil {
+		if prompt, more := r.continuation(text); more {
+			_, _ = r.terminal.WriteString("\r\n")
+			r.pending = append(r.pending, string(r.buffer))
+			r.linePrompts = append(r.linePrompts, r.prompt)
+			r.prompt = prompt
+			r.buffer = r.buffer[:0]
+			r.cursor = 0
+			r.undo = undoState{}
+			r.displayPrompt()
+
+			return "", false, nil
+		}
+	}
+
+	// Return completed input
+	r.drawTransientPrompt()
+	_, _ = r.terminal.WriteString("\r\n")
+
+	if r.histExpand {
+		return r.expandHistory(text), true, nil
+	}
+
+	return r.finishInput(text), true, nil
+}
+
+// expandHistory finishes text, the input entered, after history expansion,
+// showing the command it becomes if that differs. An expansion that fails,
+// or one with the :p modifier, is only shown and nothing is run.
+func (r *Readline) expandHistory(text string) string {
+	expanded, printOnly, err := r.history.Expand(text)
+	if err != nil {
+		_, _ = r.terminal.Printf("dsh: %v\r\n", err)
+
+		return r.finishInput("")
+	}
+
+	if expanded != text || printOnly {
+		_, _ = r.terminal.WriteString(strings.ReplaceAll(expanded, "\n", "\r\n") + "\r\n")
+	}
+	if printOnly {
+		r.finishInput(expanded)
+
+		return ""
+	}
+
+	return r.finishInput(expanded)
+}
+
+// drawTransientPrompt draws the lines just entered again with the
+// transient prompt in place of the main prompt and without the right
+// prompt, leaving the cursor at the end of the last line.
+func (r *Readline) drawTransientPrompt() {
+	if r.transientPrompt == "" || r.terminal == nil {
+		return
+	}
+
+	lines := append(r.pending, string(r.buffer))
+	prompts := append(append([]string{}, r.linePrompts...), r.prompt)
+	prompts[0] = r.transientPrompt
+
+	// Go back to the first line of the main prompt and draw from there
+	if rows := strings.Count(r.mainPrompt, "\n") + len(r.pending); rows > 0 {
+		_, _ = r.terminal.Printf("\033[%dA", rows)
+	}
+	_, _ = r.terminal.WriteString("\r\033[J")
+
+	for i, line := range lines {
+		if i > 0 {
+			_, _ = r.terminal.WriteString("\r\n")
 		}
+		_, _ = r.terminal.WriteString(strings.ReplaceAll(prompts[i], "\n", "\r\n") + displayText([]rune(line)))
+	}
+}
+
+// finishInput records text, the whole of a possibly multi-line command, as
+// one history entry and returns it.
+func (r *Readline) finishInput(text string) string {
+	if text != "" {
+		r.history.Add(text)
+	}
+	r.pending = nil
+
+	return text
+}
 
-		return line, nil
+// cancelContinuation discards the lines of an incomplete command and goes
+// back to the main prompt.
+func (r *Readline) cancelContinuation() {
+	if r.pending == nil {
+		return
 	}
+
+	r.pending = nil
+	r.linePrompts = nil
+	r.prompt = r.mainPrompt
+}
+
+// SetContinuation installs the function that decides whether Enter
+// completes the input or starts a continuation line.
+func (r *Readline) SetContinuation(fn ContinuationFunc) {
+	r.continuation = fn
+}
+
+// SetViMode switches between vi mode, as set -o vi chooses, and the
+// default emacs keys.
+func (r *Readline) SetViMode(on bool) {
+	r.viEnabled = on
+}
+
+// SetHistoryExpansion turns history expansion of the input, as set -H
+// chooses, on or off.
+func (r *Readline) SetHistoryExpansion(on bool) {
+	r.histExpand = on
+}
+
+// SetHighlighter installs the function that colours the input as it is
+// drawn.
+func (r *Readline) SetHighlighter(fn HighlightFunc) {
+	r.highlight = fn
 }
 
 // GetBuffer returns the current input buffer (for testing)
@@ -139,10 +346,80 @@ func NewTestReadline(mockTerm terminal.TerminalInterface) *Readline {
 	}
 }
 
+// displayPrompt writes the whole prompt, and the right prompt on its last
+// line. In raw mode a newline only moves down, so the lines of a
+// multi-line prompt each need a carriage return.
 func (r *Readline) displayPrompt() {
-	if r.terminal != nil {
-		_, _ = r.terminal.WriteString(r.prompt)
+	if r.terminal == nil {
+		return
 	}
+
+	prompt := r.shownPrompt()
+	_, _ = r.terminal.WriteString(strings.ReplaceAll(prompt, "\n", "\r\n"))
+	if r.drawRightPrompt(promptWidth(prompt)) {
+		_, _ = r.terminal.Printf("\033[%dG", promptWidth(prompt)+1)
+	}
+}
+
+// SetRightPrompt sets the prompt drawn at the right end of the first
+// input line, as RPS1 gives it. Only its first line is used.
+func (r *Readline) SetRightPrompt(prompt string) {
+	r.rightPrompt, _, _ = strings.Cut(prompt, "\n")
+}
+
+// SetTransientPrompt sets the prompt that replaces the main prompt once
+// the input is entered, or with "" keeps the main prompt.
+func (r *Readline) SetTransientPrompt(prompt string) {
+	r.transientPrompt = prompt
+}
+
+// Repaint replaces the main and right prompts with those prompts returns
+// and draws them again, with the input after them, while a line is being
+// read. It may be called from any goroutine, as when a part of the prompt
+// computed in the background arrives. prompts is called without the lock
+// held, since it may run commands, and only while the line is read, never
+// at the same time as the shell runs commands.
+func (r *Readline) Repaint(prompts func() (string, string)) {
+	r.mu.Lock()
+	ok := r.repaintable()
+	r.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	left, right := prompts()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A key handled meanwhile may have ended the line or opened a menu
+	if !r.repaintable() {
+		return
+	}
+
+	previous := r.prompt
+	r.prompt, r.mainPrompt = left, left
+	r.SetRightPrompt(right)
+
+	if r.terminal == nil {
+		return
+	}
+
+	// Go back to the first line of the prompt and draw from there
+	if lines := strings.Count(previous, "\n"); lines > 0 {
+		_, _ = r.terminal.Printf("\033[%dA", lines)
+	}
+	_, _ = r.terminal.WriteString("\r\033[J")
+	r.displayPrompt()
+	r.redraw()
+}
+
+// repaintable reports whether Repaint may draw the prompt again: only while
+// a line is read, and not under a continuation line or a menu below the
+// input, which are left alone. The caller holds r.mu.
+func (r *Readline) repaintable() bool {
+	return r.reading && r.pending == nil && !r.completionMenu.IsActive()
 }
 
 // SetPrompt sets the prompt string
@@ -0,0 +1,239 @@
+package readline
+
+import (
+	"strings"
+	"testing"
+
+	"dsh/internal/terminal"
+	"dsh/test/rendering"
+)
+
+// queueLine queues the keys that type text and press Enter.
+func queueLine(mockTerm *rendering.MockTerminalInterface, text string) {
+	for _, ch := range text {
+		mockTerm.QueueKey(terminal.KeyEvent{Rune: ch})
+	}
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyEnter})
+}
+
+// openIf is a continuation function that asks for more input until fi.
+func openIf(text string) (string, bool) {
+	if strings.HasPrefix(text, "if") && !strings.HasSuffix(text, "fi") {
+		return "> ", true
+	}
+
+	return "", false
+}
+
+func TestReadline_Continuation(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.SetContinuation(openIf)
+
+	queueLine(mockTerm, "if true")
+	queueLine(mockTerm, "then echo yes")
+	queueLine(mockTerm, "fi")
+
+	line, err := rl.ReadLine()
+	if err != nil {
+		t.Fatalf("ReadLine failed: %v", err)
+	}
+	if line != "if true\nthen echo yes\nfi" {
+		t.Errorf("Expected the lines to be joined, got %q", line)
+	}
+	if !strings.Contains(mockTerm.GetOutput(), "\r\n> ") || !strings.Contains(mockTerm.GetOutput(), "> then echo yes") {
+		t.Errorf("Expected the continuation prompt, got %q", mockTerm.GetOutput())
+	}
+	if rl.GetPrompt() != "dsh> " {
+		t.Errorf("Expected the main prompt to be restored, got %q", rl.GetPrompt())
+	}
+
+	history := rl.GetHistory()
+	if len(history.items) != 1 || history.items[0] != line {
+		t.Errorf("Expected one history entry, got %q", history.items)
+	}
+}
+
+func TestReadline_ContinuationCancel(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.SetContinuation(openIf)
+
+	queueLine(mockTerm, "if true")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlC})
+	queueLine(mockTerm, "echo next")
+
+	line, err := rl.ReadLine()
+	if err != nil {
+		t.Fatalf("ReadLine failed: %v", err)
+	}
+	if line != "echo next" {
+		t.Errorf("Expected Ctrl+C to discard the open command, got %q", line)
+	}
+
+	// Ctrl+D hands over an unfinished command instead of ending input
+	queueLine(mockTerm, "if false")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlD})
+
+	line, err = rl.ReadLine()
+	if err != nil || line != "if false" {
+		t.Errorf("Expected the unfinished command, got %q (%v)", line, err)
+	}
+}
+
+func TestReadline_MultiLinePrompt(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.SetPrompt("~/src\n\033[32mλ\033[0m ")
+
+	queueLine(mockTerm, "ls")
+
+	if _, err := rl.ReadLine(); err != nil {
+		t.Fatalf("ReadLine failed: %v", err)
+	}
+
+	output := mockTerm.GetOutput()
+	if !strings.HasPrefix(output, "~/src\r\n\033[32mλ\033[0m ") {
+		t.Errorf("Expected the prompt's lines to start at the left edge, got %q", output)
+	}
+	if strings.Count(output, "~/src") != 1 {
+		t.Errorf("Expected redraws to leave the first line of the prompt, got %q", output)
+	}
+	// The colour codes take no columns, so "ls" starts at column 3
+	if !strings.Contains(output, "\033[5G") {
+		t.Errorf("Expected the cursor after λ, a space and ls, got %q", output)
+	}
+}
+
+func TestReadline_Repaint(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+
+	called := false
+	rl.Repaint(func() (string, string) {
+		called = true
+
+		return "new> ", ""
+	})
+	if called || rl.GetPrompt() != "dsh> " {
+		t.Errorf("Expected no repaint while no line is read, got prompt %q", rl.GetPrompt())
+	}
+
+	// As in the middle of ReadLine, with a two-line prompt shown
+	rl.SetPrompt("~/src\n$ ")
+	rl.reading = true
+	rl.SetBuffer("git st")
+
+	locked := false
+	rl.Repaint(func() (string, string) {
+		// The prompts are expanded without the lock, which keys need
+		if locked = !rl.mu.TryLock(); !locked {
+			rl.mu.Unlock()
+		}
+
+		return "~/src (main*)\n$ ", ""
+	})
+	if locked {
+		t.Error("Expected the prompts expanded without the lock held")
+	}
+
+	output := mockTerm.GetOutput()
+	if !strings.HasPrefix(output, "\033[1A\r\033[J~/src (main*)\r\n$ ") {
+		t.Errorf("Expected the prompt drawn again from its first line, got %q", output)
+	}
+	if !strings.HasSuffix(output, "$ git st\033[9G") {
+		t.Errorf("Expected the input after the new prompt, got %q", output)
+	}
+	if rl.mainPrompt != "~/src (main*)\n$ " {
+		t.Errorf("Expected the new prompt to be kept, got %q", rl.mainPrompt)
+	}
+
+	// A line ended while the prompts were expanded is not drawn over
+	mockTerm.ClearOutput()
+	rl.Repaint(func() (string, string) {
+		rl.mu.Lock()
+		rl.reading = false
+		rl.mu.Unlock()
+
+		return "late> ", ""
+	})
+	if output := mockTerm.GetOutput(); output != "" || rl.mainPrompt == "late> " {
+		t.Errorf("Expected no repaint after the line ended, got %q", output)
+	}
+}
+
+func TestReadline_RightPrompt(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(20, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.SetPrompt("$ ")
+	rl.SetRightPrompt("\033[33m12:00\033[0m\nignored")
+
+	queueLine(mockTerm, "echo hi there")
+
+	if _, err := rl.ReadLine(); err != nil {
+		t.Fatalf("ReadLine failed: %v", err)
+	}
+
+	// 20 columns, less the last one, leave 12:00 starting at column 15
+	output := mockTerm.GetOutput()
+	if !strings.HasPrefix(output, "$ \033[15G\033[33m12:00\033[0m\033[3G") {
+		t.Errorf("Expected the right prompt beside the empty input, got %q", output)
+	}
+	if !strings.Contains(output, "$ echo hi\033[15G\033[33m12:00") {
+		t.Errorf("Expected the right prompt beside a short input, got %q", output)
+	}
+	if strings.Contains(output, "ignored") {
+		t.Errorf("Expected only the first line of the right prompt, got %q", output)
+	}
+
+	// Once the input would reach it, the right prompt is left out
+	last := output[strings.LastIndex(output, "\r\033[K"):]
+	if strings.Contains(last, "12:00") {
+		t.Errorf("Expected no right prompt beside a long input, got %q", last)
+	}
+}
+
+func TestReadline_TransientPrompt(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.SetContinuation(openIf)
+	rl.SetPrompt("~/src (main)\n$ ")
+	rl.SetTransientPrompt("% ")
+
+	queueLine(mockTerm, "if true")
+	queueLine(mockTerm, "fi")
+
+	if _, err := rl.ReadLine(); err != nil {
+		t.Fatalf("ReadLine failed: %v", err)
+	}
+
+	// Up past the prompt's first line and the continuation line, then the
+	// lines again with the compact prompt
+	output := mockTerm.GetOutput()
+	if !strings.HasSuffix(output, "\033[2A\r\033[J% if true\r\n> fi\r\n") {
+		t.Errorf("Expected the entered lines redrawn with the transient prompt, got %q", output)
+	}
+}
+
+func TestReadline_Highlighter(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.SetHighlighter(func(text string) string {
+		return strings.ReplaceAll(text, "ls", "\033[32mls\033[0m")
+	})
+
+	rl.SetBuffer("ls -l")
+	rl.redraw()
+
+	if output := mockTerm.GetOutput(); !strings.HasSuffix(output, "dsh> \033[32mls\033[0m -l\033[11G") {
+		t.Errorf("Expected the coloured input with the cursor after it, got %q", output)
+	}
+
+	// Moving over the input must not write it again uncoloured
+	mockTerm.ClearOutput()
+	rl.cursor = 0
+	rl.moveCursorRight()
+	if output := mockTerm.GetOutput(); output != "\033[7G" {
+		t.Errorf("Expected only a cursor movement, got %q", output)
+	}
+}
@@ -9,6 +9,13 @@ import (
 	"dsh/internal/terminal"
 )
 
+// Bracketed paste mode makes the terminal mark pasted text, so that it is
+// inserted as typed rather than run a line at a time.
+const (
+	bracketedPasteOn  = "\033[?2004h"
+	bracketedPasteOff = "\033[?2004l"
+)
+
 // Terminal handles raw terminal operations with foundation.
 type Terminal struct {
 	fd            int
@@ -33,7 +40,7 @@ func NewTerminal() (*Terminal, error) {
 	}, nil
 }
 
-// SetRawMode enables raw terminal mode.
+// SetRawMode enables raw terminal mode and bracketed paste.
 func (t *Terminal) SetRawMode() error {
 	raw := t.original
 	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
@@ -46,13 +53,31 @@ func (t *Terminal) SetRawMode() error {
 	err := setTermios(t.fd, &raw)
 	if err == nil {
 		_ = t.termInterface.EnableRawMode()
+		_, _ = t.termInterface.WriteString(bracketedPasteOn)
 	}
 	return err
 }
 
-// Restore restores original terminal mode.
+// SetInputMode turns echo and line buffering on or off, keeping the rest
+// of the original mode, as reading a password or single characters needs.
+func (t *Terminal) SetInputMode(echo, canonical bool) error {
+	mode := t.original
+	if !echo {
+		mode.Lflag &^= syscall.ECHO
+	}
+	if !canonical {
+		mode.Lflag &^= syscall.ICANON
+		mode.Cc[syscall.VMIN] = 1
+		mode.Cc[syscall.VTIME] = 0
+	}
+
+	return setTermios(t.fd, &mode)
+}
+
+// Restore restores original terminal mode and turns bracketed paste off.
 func (t *Terminal) Restore() error {
 	_ = t.termInterface.DisableRawMode()
+	_, _ = t.termInterface.WriteString(bracketedPasteOff)
 	return setTermios(t.fd, &t.original)
 }
 
@@ -0,0 +1,118 @@
+package readline
+
+// snapshot is the line and the cursor as they were at some point, which
+// undo, redo and revert-line go back to.
+type snapshot struct {
+	buffer []rune
+	cursor int
+}
+
+// undoState is what undo and redo remember of the line being edited.
+type undoState struct {
+	// before holds the line before each change, the last change last, and
+	// undone the lines undo went back from.
+	before []snapshot
+	undone []snapshot
+	// original is the line as it was before any change: empty, or the
+	// history entry being edited.
+	original snapshot
+	// group is the action that made the last change, so that typed
+	// characters are undone together, and skip is set by an action whose
+	// change is not itself undone, such as undo.
+	group string
+	skip  bool
+}
+
+// takeSnapshot returns a copy of the line and the cursor.
+func (r *Readline) takeSnapshot() snapshot {
+	return snapshot{buffer: append([]rune(nil), r.buffer...), cursor: r.cursor}
+}
+
+// restoreSnapshot puts back the line and the cursor of s.
+func (r *Readline) restoreSnapshot(s snapshot) {
+	r.buffer = append(r.buffer[:0], s.buffer...)
+	r.cursor = min(s.cursor, len(r.buffer))
+}
+
+// resetUndo starts the undo list afresh for the history entry line, which
+// the action that loaded it leaves out of the list.
+func (r *Readline) resetUndo(line string) {
+	r.undo = undoState{original: snapshot{buffer: []rune(line), cursor: len([]rune(line))}, skip: true}
+}
+
+// saveUndo remembers the line before a change.
+func (r *Readline) saveUndo() {
+	r.undo.before = append(r.undo.before, r.takeSnapshot())
+	r.undo.undone = nil
+	r.undo.group = ""
+}
+
+// recordChange remembers before as the line before the change the action
+// called name made, if it made one. Characters typed one after the other
+// are a single change.
+func (r *Readline) recordChange(before snapshot, name string) {
+	if r.undo.skip {
+		r.undo.skip = false
+		r.undo.group = ""
+
+		return
+	}
+
+	// vi remembers a change together with the text typed for it
+	if r.viEnabled && r.vi.inserting {
+		return
+	}
+
+	if string(before.buffer) == string(r.buffer) {
+		if name != "self-insert" {
+			r.undo.group = ""
+		}
+
+		return
+	}
+
+	if name != "self-insert" || r.undo.group != name {
+		r.undo.before = append(r.undo.before, before)
+	}
+	r.undo.undone = nil
+	r.undo.group = name
+}
+
+// undoChange goes back to the line as it was before the last change, and
+// reports whether there was one.
+func (r *Readline) undoChange() bool {
+	for len(r.undo.before) > 0 {
+		last := r.undo.before[len(r.undo.before)-1]
+		r.undo.before = r.undo.before[:len(r.undo.before)-1]
+
+		if string(last.buffer) != string(r.buffer) {
+			r.undo.undone = append(r.undo.undone, r.takeSnapshot())
+			r.restoreSnapshot(last)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// redoChange makes the last change undone again, and reports whether
+// there was one.
+func (r *Readline) redoChange() bool {
+	if len(r.undo.undone) == 0 {
+		return false
+	}
+
+	last := r.undo.undone[len(r.undo.undone)-1]
+	r.undo.undone = r.undo.undone[:len(r.undo.undone)-1]
+	r.undo.before = append(r.undo.before, r.takeSnapshot())
+	r.restoreSnapshot(last)
+
+	return true
+}
+
+// revertLine goes back to the line as it was before any change, which may
+// itself be undone.
+func (r *Readline) revertLine() {
+	r.restoreSnapshot(r.undo.original)
+}
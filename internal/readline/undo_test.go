@@ -0,0 +1,95 @@
+package readline
+
+import (
+	"testing"
+
+	"dsh/internal/terminal"
+	"dsh/test/rendering"
+)
+
+func TestUndo(t *testing.T) {
+	undo := terminal.KeyEvent{Key: terminal.KeyCtrlUnderscore}
+	redo := terminal.KeyEvent{Rune: '/', Alt: true}
+
+	tests := []struct {
+		name string
+		keys []terminal.KeyEvent
+		want string
+	}{
+		{"typing is one change", []terminal.KeyEvent{{Rune: 'a'}, {Rune: 'b'}, undo}, ""},
+		{"moving starts a new change", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlA}, {Rune: 'b'}, undo}, "a"},
+		{"kill", []terminal.KeyEvent{{Rune: 'a'}, {Rune: 'b'}, {Key: terminal.KeyCtrlU}, undo}, "ab"},
+		{"chord", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlW}, {Key: terminal.KeyCtrlX}, {Key: terminal.KeyCtrlU}}, "a"},
+		{"undo twice", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlA}, {Rune: 'b'}, undo, undo}, ""},
+		{"nothing to undo", []terminal.KeyEvent{undo, {Rune: 'a'}}, "a"},
+		{"redo", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlU}, undo, redo}, ""},
+		{"redo twice", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlA}, {Rune: 'b'}, undo, undo, redo, redo}, "ba"},
+		{"editing forgets redo", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlU}, undo, {Rune: 'b'}, redo}, "ab"},
+		{"cancel forgets the line", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlC}, undo}, ""},
+	}
+
+	for _, tt := range tests {
+		mockTerm := rendering.NewMockTerminalInterface(80, 24)
+		rl := NewTestReadline(mockTerm)
+		for _, key := range tt.keys {
+			mockTerm.QueueKey(key)
+		}
+		mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyEnter})
+
+		line, err := rl.ReadLine()
+		if err != nil {
+			t.Fatalf("%s: ReadLine failed: %v", tt.name, err)
+		}
+		if line != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, line)
+		}
+	}
+}
+
+func TestUndo_Completion(t *testing.T) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	queueViKeys(mockTerm, "ec")
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyTab})
+	mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyCtrlUnderscore})
+	queueViKeys(mockTerm, "\r")
+
+	if line, _ := rl.ReadLine(); line != "ec" {
+		t.Errorf("Expected the completion undone, got %q", line)
+	}
+}
+
+func TestRevertLine(t *testing.T) {
+	revert := terminal.KeyEvent{Rune: 'r', Alt: true}
+
+	tests := []struct {
+		name string
+		keys []terminal.KeyEvent
+		want string
+	}{
+		{"new line", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyCtrlA}, {Rune: 'b'}, revert}, ""},
+		{"history entry", []terminal.KeyEvent{{Key: terminal.KeyArrowUp}, {Key: terminal.KeyCtrlW}, {Rune: 'x'}, revert}, "git status"},
+		{"older entry", []terminal.KeyEvent{{Key: terminal.KeyArrowUp}, {Key: terminal.KeyArrowUp}, {Key: terminal.KeyCtrlU}, revert}, "ls -la"},
+		{"undone", []terminal.KeyEvent{{Key: terminal.KeyArrowUp}, {Key: terminal.KeyCtrlW}, revert, {Key: terminal.KeyCtrlUnderscore}}, "git "},
+		{"undo stops at the entry", []terminal.KeyEvent{{Rune: 'a'}, {Key: terminal.KeyArrowUp}, {Key: terminal.KeyCtrlUnderscore}}, "git status"},
+	}
+
+	for _, tt := range tests {
+		mockTerm := rendering.NewMockTerminalInterface(80, 24)
+		rl := NewTestReadline(mockTerm)
+		rl.history.Add("ls -la")
+		rl.history.Add("git status")
+		for _, key := range tt.keys {
+			mockTerm.QueueKey(key)
+		}
+		mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyEnter})
+
+		line, err := rl.ReadLine()
+		if err != nil {
+			t.Fatalf("%s: ReadLine failed: %v", tt.name, err)
+		}
+		if line != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, line)
+		}
+	}
+}
@@ -0,0 +1,754 @@
+package readline
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"dsh/internal/terminal"
+)
+
+// viMode is the mode of the vi keys: typing text, giving commands, or
+// selecting text for a command.
+type viMode int
+
+const (
+	viInsert viMode = iota
+	viCommand
+	viVisual
+)
+
+// The indicators drawn before the last line of the prompt in vi mode,
+// until the inputrc file sets others.
+const (
+	viInsertIndicator  = "[I] "
+	viCommandIndicator = "[N] "
+	viVisualIndicator  = "[V] "
+)
+
+// viState is what the vi keys remember between keys.
+type viState struct {
+	mode viMode
+	// count is the count being typed, and operator a d, c or y waiting
+	// for its motion, with operatorCount the count typed before it.
+	count         int
+	operator      rune
+	operatorCount int
+	// find is an f, F, t or T waiting for its character, and replace an r;
+	// lastFind and lastFindChar are repeated by ; and ,.
+	find         rune
+	replace      bool
+	lastFind     rune
+	lastFindChar rune
+	// visualStart is where the selection started in visual mode.
+	visualStart int
+	// keys are the keys of the change being made, which becomes lastChange
+	// for . to repeat once it is done. inserting is set while the text of
+	// a change such as cw is typed, changed once a command changed the
+	// line, and repeating while . replays keys.
+	keys       []terminal.KeyEvent
+	lastChange []terminal.KeyEvent
+	inserting  bool
+	changed    bool
+	repeating  bool
+	// search is the / or ? whose pattern, searchText, is being typed, and
+	// lastSearch and lastSearchKey the search n and N repeat.
+	search        rune
+	searchText    []rune
+	lastSearch    string
+	lastSearchKey rune
+}
+
+// pending reports whether the keys so far are the start of a command.
+func (v *viState) pending() bool {
+	return v.count != 0 || v.operator != 0 || v.find != 0 || v.replace
+}
+
+// resetVi starts a line in insert mode, forgetting the changes to the last
+// one but keeping what ., ;, n and the like repeat.
+func (r *Readline) resetVi() {
+	v := &r.vi
+	v.mode = viInsert
+	v.count, v.operator, v.operatorCount = 0, 0, 0
+	v.find, v.replace = 0, false
+	v.keys, v.inserting, v.changed = nil, false, false
+	v.search, v.searchText = 0, nil
+}
+
+// shownPrompt returns the prompt as drawn: in vi mode with the indicator
+// of the mode at the start of its last line.
+func (r *Readline) shownPrompt() string {
+	if !r.viEnabled {
+		return r.prompt
+	}
+
+	indicator := viIndicator(r.vi.mode)
+	last := strings.LastIndexByte(r.prompt, '\n') + 1
+
+	return r.prompt[:last] + indicator + r.prompt[last:]
+}
+
+// handleViKey handles keyEvent in vi mode and reports whether it did, and
+// if so whether to go on reading, as handleKeyEvent does. Keys it leaves,
+// such as Enter and the control keys, work as in emacs mode; in insert
+// mode that is all but Escape.
+func (r *Readline) handleViKey(keyEvent terminal.KeyEvent) (bool, bool) {
+	v := &r.vi
+
+	if v.search != 0 {
+		r.handleViSearch(keyEvent)
+
+		return true, true
+	}
+
+	// Escape and a key typed quickly arrive as Alt and the key
+	if keyEvent.Alt {
+		r.handleViKey(terminal.KeyEvent{Key: terminal.KeyEscape})
+
+		return r.handleViKey(terminal.KeyEvent{Rune: keyEvent.Rune})
+	}
+
+	if !v.repeating && (v.mode != viInsert || v.inserting) {
+		v.keys = append(v.keys, keyEvent)
+	}
+
+	if v.mode == viInsert {
+		if keyEvent.Key != terminal.KeyEscape {
+			return false, false
+		}
+
+		if v.inserting && !v.repeating {
+			v.lastChange = v.keys
+		}
+		v.keys, v.inserting = nil, false
+		v.mode = viCommand
+		if r.cursor > 0 {
+			r.cursor--
+		}
+		r.redraw()
+
+		return true, true
+	}
+
+	switch keyEvent.Key {
+	case terminal.KeyNone:
+		r.viCommand(keyEvent.Rune)
+	case terminal.KeyEscape:
+		v.count, v.operator, v.operatorCount = 0, 0, 0
+		v.find, v.replace = 0, false
+		v.mode = viCommand
+	case terminal.KeyBackspace, terminal.KeyArrowLeft:
+		r.viCommand('h')
+	case terminal.KeyArrowRight:
+		r.viCommand('l')
+	case terminal.KeyArrowUp:
+		r.viCommand('k')
+	case terminal.KeyArrowDown:
+		r.viCommand('j')
+	case terminal.KeyHome:
+		r.viCommand('0')
+	case terminal.KeyEnd:
+		r.viCommand('$')
+	default:
+		v.keys = nil
+		v.count, v.operator, v.operatorCount = 0, 0, 0
+		v.find, v.replace = 0, false
+
+		return false, false
+	}
+
+	r.viFinish()
+
+	return true, true
+}
+
+// viFinish ends a key handled in command or visual mode: once a command is
+// complete it keeps its keys if it changed the line, so that . repeats it,
+// and the cursor rests on a character.
+func (r *Readline) viFinish() {
+	v := &r.vi
+
+	if v.search != 0 {
+		r.drawViSearch()
+
+		return
+	}
+
+	if v.pending() {
+		return
+	}
+
+	if !v.repeating && !v.inserting {
+		if v.changed {
+			v.lastChange = v.keys
+		}
+		v.keys = nil
+	}
+	v.changed = false
+
+	if v.mode != viInsert && r.cursor >= len(r.buffer) {
+		r.cursor = max(len(r.buffer)-1, 0)
+	}
+	r.redraw()
+}
+
+// viCount returns the count the command typed and starts a new one.
+func (r *Readline) viCount() int {
+	v := &r.vi
+	count := max(v.count, 1) * max(v.operatorCount, 1)
+	v.count, v.operatorCount = 0, 0
+
+	return count
+}
+
+// viCommand handles ch typed in command or visual mode.
+func (r *Readline) viCommand(ch rune) { //nolint:cyclop,funlen,gocognit // One case per vi command
+	v := &r.vi
+
+	switch {
+	case v.find != 0:
+		kind := v.find
+		v.find = 0
+		v.lastFind, v.lastFindChar = kind, ch
+		r.viFindMotion(kind, ch, r.viCount(), false)
+
+		return
+	case v.replace:
+		v.replace = false
+		r.viReplace(ch, r.viCount())
+
+		return
+	case ch >= '1' && ch <= '9', ch == '0' && v.count > 0:
+		v.count = v.count*10 + int(ch-'0')
+
+		return
+	}
+
+	counted := v.count > 0
+	count := r.viCount()
+
+	if target, inclusive, ok := r.viMotion(ch, count); ok {
+		r.viMove(target, inclusive)
+
+		return
+	}
+
+	switch ch {
+	case 'f', 'F', 't', 'T':
+		v.find, v.count = ch, count
+
+		return
+	case ';', ',':
+		if v.lastFind == 0 {
+			v.operator = 0
+
+			return
+		}
+		kind := v.lastFind
+		if ch == ',' {
+			kind = reverseFind(kind)
+		}
+		r.viFindMotion(kind, v.lastFindChar, count, true)
+
+		return
+	case 'd', 'c', 'y', 'x', 's':
+		r.viOperator(ch, count)
+
+		return
+	case 'v':
+		v.operator = 0
+		if v.mode == viVisual {
+			v.mode = viCommand
+		} else {
+			v.mode, v.visualStart = viVisual, r.cursor
+		}
+
+		return
+	}
+
+	// Only motions and operators work on a selection, and a key that is
+	// neither cancels a waiting operator
+	if v.mode == viVisual || v.operator != 0 {
+		v.operator = 0
+
+		return
+	}
+
+	switch ch {
+	case 'D', 'C':
+		v.operator = unicode.ToLower(ch)
+		r.viMove(len(r.buffer), false)
+	case 'S':
+		r.viOperate('c', 0, len(r.buffer))
+	case 'Y':
+		r.viOperateLine('y')
+	case 'X':
+		v.operator = 'd'
+		r.viMove(max(r.cursor-count, 0), false)
+	case 'i':
+		r.viStartInsert()
+	case 'a':
+		r.viStartInsert()
+		r.cursor = min(r.cursor+1, len(r.buffer))
+	case 'I':
+		r.viStartInsert()
+		r.cursor = firstNonBlank(r.buffer)
+	case 'A':
+		r.viStartInsert()
+		r.cursor = len(r.buffer)
+	case 'p', 'P':
+		r.viPut(ch == 'p', count)
+	case 'r':
+		v.replace, v.count = true, count
+	case '~':
+		r.viToggleCase(count)
+	case 'u':
+		r.undoChange()
+	case '.':
+		r.viRepeat(count, counted)
+	case '/', '?':
+		v.search, v.searchText = ch, nil
+	case 'n', 'N':
+		if v.lastSearch == "" {
+			return
+		}
+		// n searches the way the last search did, and N the other way
+		backward := v.lastSearchKey == '/'
+		if ch == 'N' {
+			backward = !backward
+		}
+		r.viSearch(backward, v.lastSearch)
+	case 'k':
+		for range count {
+			r.historyPrevious()
+		}
+		r.cursor = 0
+	case 'j':
+		for range count {
+			r.historyNext()
+		}
+		r.cursor = 0
+	}
+}
+
+// viMotion returns where the motion key moves the cursor count times, and
+// whether an operator takes the character there too. It reports false if
+// key is not a motion, or one that needs a character, such as f.
+func (r *Readline) viMotion(key rune, count int) (int, bool, bool) {
+	switch key {
+	case 'h':
+		return max(r.cursor-count, 0), false, true
+	case 'l', ' ':
+		return min(r.cursor+count, len(r.buffer)), false, true
+	case '0':
+		return 0, false, true
+	case '^':
+		return firstNonBlank(r.buffer), false, true
+	case '$':
+		return max(len(r.buffer)-1, 0), true, true
+	case 'w', 'W':
+		// cw changes to the end of the word, leaving the blanks after it
+		if r.vi.operator == 'c' && r.cursor < len(r.buffer) && !unicode.IsSpace(r.buffer[r.cursor]) {
+			return r.wordEnd(r.cursor, count, key == 'W'), true, true
+		}
+
+		return r.wordStart(r.cursor, count, key == 'W'), false, true
+	case 'b', 'B':
+		return r.wordBackward(r.cursor, count, key == 'B'), false, true
+	case 'e', 'E':
+		return r.wordEnd(r.cursor, count, key == 'E'), true, true
+	}
+
+	return 0, false, false
+}
+
+// viFindMotion moves to the count'th ch that kind, an f, F, t or T, looks
+// for. When ; repeats a t or T, the character next to the cursor, which
+// it stopped before, is passed over.
+func (r *Readline) viFindMotion(kind, ch rune, count int, repeat bool) {
+	forward := kind == 'f' || kind == 't'
+	till := kind == 't' || kind == 'T'
+
+	step := -1
+	if forward {
+		step = 1
+	}
+
+	pos := r.cursor
+	if till && repeat {
+		pos += step
+	}
+
+	for range count {
+		pos += step
+		for pos >= 0 && pos < len(r.buffer) && r.buffer[pos] != ch {
+			pos += step
+		}
+		if pos < 0 || pos >= len(r.buffer) {
+			r.vi.operator = 0
+
+			return
+		}
+	}
+
+	if till {
+		pos -= step
+	}
+
+	r.viMove(pos, forward)
+}
+
+// reverseFind returns the find that goes the other way, which , uses.
+func reverseFind(kind rune) rune {
+	switch kind {
+	case 'f':
+		return 'F'
+	case 'F':
+		return 'f'
+	case 't':
+		return 'T'
+	default:
+		return 't'
+	}
+}
+
+// viMove moves the cursor to target or, if an operator is waiting, applies
+// it to the text up to target, and to the character there if inclusive.
+func (r *Readline) viMove(target int, inclusive bool) {
+	v := &r.vi
+	if v.operator == 0 {
+		r.cursor = target
+
+		return
+	}
+
+	operator := v.operator
+	v.operator = 0
+
+	start, end := min(r.cursor, target), max(r.cursor, target)
+	if inclusive && end < len(r.buffer) {
+		end++
+	}
+	r.viOperate(operator, start, end)
+}
+
+// viOperator handles the operator keys: in visual mode it applies to the
+// selection, a second d, c or y applies to the whole line, and otherwise
+// it waits for a motion. x and s stand for dl and cl.
+func (r *Readline) viOperator(ch rune, count int) {
+	v := &r.vi
+
+	operator := ch
+	switch ch {
+	case 'x':
+		operator = 'd'
+	case 's':
+		operator = 'c'
+	}
+
+	switch {
+	case v.mode == viVisual:
+		start, end := min(v.visualStart, r.cursor), max(v.visualStart, r.cursor)
+		v.mode = viCommand
+		r.viOperate(operator, start, min(end+1, len(r.buffer)))
+		// The size of a selection is not repeated
+		v.changed, v.inserting = false, false
+	case ch == 'x' || ch == 's':
+		v.operator = operator
+		r.viMove(min(r.cursor+count, len(r.buffer)), false)
+	case v.operator == ch:
+		v.operator = 0
+		r.viOperateLine(operator)
+	case v.operator == 0:
+		v.operator, v.operatorCount = operator, count
+	default:
+		v.operator = 0
+	}
+}
+
+// viOperate applies operator to the text from start to end: y copies it
+// to the kill ring, d deletes it, and c deletes it and starts inserting.
+func (r *Readline) viOperate(operator rune, start, end int) {
+	r.killRing.Add(string(r.buffer[start:end]))
+	r.cursor = start
+	if operator == 'y' {
+		return
+	}
+
+	r.saveUndo()
+	r.buffer = append(r.buffer[:start], r.buffer[end:]...)
+	r.vi.changed = true
+
+	if operator == 'c' {
+		r.vi.mode, r.vi.inserting = viInsert, true
+	}
+}
+
+// viOperateLine applies operator to the whole line, as dd, cc, yy and Y
+// do. Copying the line leaves the cursor where it was.
+func (r *Readline) viOperateLine(operator rune) {
+	cursor := r.cursor
+	r.viOperate(operator, 0, len(r.buffer))
+	if operator == 'y' {
+		r.cursor = cursor
+	}
+}
+
+// viStartInsert switches to insert mode for the text of a change.
+func (r *Readline) viStartInsert() {
+	r.saveUndo()
+	r.vi.mode = viInsert
+	r.vi.inserting, r.vi.changed = true, true
+}
+
+// viPut inserts the last text killed or copied count times, after the
+// cursor for p and before it for P, and leaves the cursor on its end.
+func (r *Readline) viPut(after bool, count int) {
+	text := []rune(r.killRing.Yank())
+	if len(text) == 0 {
+		return
+	}
+
+	r.saveUndo()
+
+	at := r.cursor
+	if after && len(r.buffer) > 0 {
+		at++
+	}
+
+	var inserted []rune
+	for range count {
+		inserted = append(inserted, text...)
+	}
+
+	r.buffer = append(r.buffer[:at], append(inserted, r.buffer[at:]...)...)
+	r.cursor = at + len(inserted) - 1
+	r.vi.changed = true
+}
+
+// viReplace replaces count characters from the cursor with ch, as r does,
+// and leaves the cursor on the last of them.
+func (r *Readline) viReplace(ch rune, count int) {
+	if r.cursor+count > len(r.buffer) {
+		return
+	}
+
+	r.saveUndo()
+	for i := range count {
+		r.buffer[r.cursor+i] = ch
+	}
+	r.cursor += count - 1
+	r.vi.changed = true
+}
+
+// viToggleCase switches the case of count characters from the cursor and
+// moves past them, as ~ does.
+func (r *Readline) viToggleCase(count int) {
+	if len(r.buffer) == 0 {
+		return
+	}
+
+	r.saveUndo()
+	end := min(r.cursor+count, len(r.buffer))
+	for i := r.cursor; i < end; i++ {
+		if unicode.IsUpper(r.buffer[i]) {
+			r.buffer[i] = unicode.ToLower(r.buffer[i])
+		} else {
+			r.buffer[i] = unicode.ToUpper(r.buffer[i])
+		}
+	}
+	r.cursor = end
+	r.vi.changed = true
+}
+
+// viRepeat replays the keys of the last change, with the count given to
+// . in place of its own if there was one.
+func (r *Readline) viRepeat(count int, counted bool) {
+	v := &r.vi
+
+	keys := v.lastChange
+	if counted {
+		for len(keys) > 0 && keys[0].Key == terminal.KeyNone && unicode.IsDigit(keys[0].Rune) {
+			keys = keys[1:]
+		}
+
+		var digits []terminal.KeyEvent
+		for _, digit := range strconv.Itoa(count) {
+			digits = append(digits, terminal.KeyEvent{Rune: digit})
+		}
+		keys = append(digits, keys...)
+	}
+
+	v.repeating = true
+	for _, key := range keys {
+		r.handleKeyEvent(key)
+	}
+	v.repeating = false
+}
+
+// handleViSearch handles a key while the pattern of / or ? is typed.
+func (r *Readline) handleViSearch(keyEvent terminal.KeyEvent) {
+	v := &r.vi
+
+	switch keyEvent.Key {
+	case terminal.KeyEnter:
+		text := string(v.searchText)
+		if text == "" {
+			text = v.lastSearch
+		}
+		v.lastSearch, v.lastSearchKey = text, v.search
+		backward := v.search == '/'
+		v.search, v.searchText = 0, nil
+
+		if text == "" {
+			r.redraw()
+
+			return
+		}
+		r.viSearch(backward, text)
+	case terminal.KeyEscape, terminal.KeyCtrlC:
+		v.search, v.searchText = 0, nil
+		r.redraw()
+	case terminal.KeyBackspace:
+		if len(v.searchText) == 0 {
+			v.search = 0
+			r.redraw()
+
+			return
+		}
+		v.searchText = v.searchText[:len(v.searchText)-1]
+		r.drawViSearch()
+	case terminal.KeyNone:
+		if keyEvent.Rune != 0 && !keyEvent.Alt {
+			v.searchText = append(v.searchText, keyEvent.Rune)
+			r.drawViSearch()
+		}
+	default:
+	}
+}
+
+// viSearch replaces the line with the closest history entry containing
+// text: an older one for /, which searches back, and a newer one for ?.
+func (r *Readline) viSearch(backward bool, text string) {
+	line, found := r.history.NextContaining(text)
+	if backward {
+		line, found = r.history.PreviousContaining(text)
+	}
+
+	if !found {
+		_, _ = r.terminal.WriteString("\a")
+	} else {
+		r.buffer, r.cursor = []rune(line), 0
+	}
+	r.redraw()
+}
+
+// drawViSearch draws the search being typed in place of the line.
+func (r *Readline) drawViSearch() {
+	if r.terminal == nil {
+		return
+	}
+
+	_, _ = r.terminal.WriteString("\r\033[K" + string(r.vi.search) + string(r.vi.searchText))
+}
+
+// visualText returns the line with the selection of visual mode shown in
+// reverse video.
+func (r *Readline) visualText() string {
+	start, end := min(r.vi.visualStart, r.cursor), max(r.vi.visualStart, r.cursor)
+	end = min(end+1, len(r.buffer))
+	start = min(start, end)
+
+	return displayText(r.buffer[:start]) + "\033[7m" + displayText(r.buffer[start:end]) + "\033[27m" +
+		displayText(r.buffer[end:])
+}
+
+// wordClass sorts characters for the word motions: blanks are 0, and a
+// word is a run of letters, digits and underscores, class 1, or of other
+// characters, class 2. For the WORD motions, W, B and E, any run of
+// characters other than blanks is a word.
+func wordClass(ch rune, bigWord bool) int {
+	switch {
+	case unicode.IsSpace(ch):
+		return 0
+	case bigWord, ch == '_', unicode.IsLetter(ch), unicode.IsDigit(ch):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// wordStart returns where the count'th word after pos starts, or the end
+// of the line.
+func (r *Readline) wordStart(pos, count int, bigWord bool) int {
+	class := func(i int) int { return wordClass(r.buffer[i], bigWord) }
+
+	for range count {
+		if pos < len(r.buffer) {
+			if c := class(pos); c != 0 {
+				for pos < len(r.buffer) && class(pos) == c {
+					pos++
+				}
+			}
+		}
+		for pos < len(r.buffer) && class(pos) == 0 {
+			pos++
+		}
+	}
+
+	return pos
+}
+
+// wordEnd returns the last character of the count'th word that ends after
+// pos.
+func (r *Readline) wordEnd(pos, count int, bigWord bool) int {
+	class := func(i int) int { return wordClass(r.buffer[i], bigWord) }
+
+	for range count {
+		pos++
+		for pos < len(r.buffer) && class(pos) == 0 {
+			pos++
+		}
+		if pos >= len(r.buffer) {
+			return max(len(r.buffer)-1, 0)
+		}
+		for pos+1 < len(r.buffer) && class(pos+1) == class(pos) {
+			pos++
+		}
+	}
+
+	return pos
+}
+
+// wordBackward returns where the count'th word before pos starts.
+func (r *Readline) wordBackward(pos, count int, bigWord bool) int {
+	class := func(i int) int { return wordClass(r.buffer[i], bigWord) }
+
+	for range count {
+		pos = min(pos, len(r.buffer))
+		for pos > 0 && class(pos-1) == 0 {
+			pos--
+		}
+		if pos == 0 {
+			return 0
+		}
+		c := class(pos - 1)
+		for pos > 0 && class(pos-1) == c {
+			pos--
+		}
+	}
+
+	return pos
+}
+
+// firstNonBlank returns the position of the first character of text that
+// is not a blank.
+func firstNonBlank(text []rune) int {
+	for i, ch := range text {
+		if !unicode.IsSpace(ch) {
+			return i
+		}
+	}
+
+	return 0
+}
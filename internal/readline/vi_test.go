@@ -0,0 +1,151 @@
+package readline
+
+import (
+	"strings"
+	"testing"
+
+	"dsh/internal/terminal"
+	"dsh/test/rendering"
+)
+
+// queueViKeys queues the keys that type keys, in which \x1b stands for
+// Escape and \r for Enter.
+func queueViKeys(mockTerm *rendering.MockTerminalInterface, keys string) {
+	for _, ch := range keys {
+		switch ch {
+		case '\x1b':
+			mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyEscape})
+		case '\r':
+			mockTerm.QueueKey(terminal.KeyEvent{Key: terminal.KeyEnter})
+		default:
+			mockTerm.QueueKey(terminal.KeyEvent{Rune: ch})
+		}
+	}
+}
+
+// newViReadline returns a test instance in vi mode.
+func newViReadline() (*Readline, *rendering.MockTerminalInterface) {
+	mockTerm := rendering.NewMockTerminalInterface(80, 24)
+	rl := NewTestReadline(mockTerm)
+	rl.SetViMode(true)
+
+	return rl, mockTerm
+}
+
+func TestVi_Editing(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"insert", "echo hi\r", "echo hi"},
+		{"delete word", "echo one two\x1b0wdw\r", "echo two"},
+		{"counts", "echo a b c d\x1b0w2dw\r", "echo c d"},
+		{"count on the motion", "echo a b c d\x1b0wd2w\r", "echo c d"},
+		{"delete to end", "echo one two\x1b0wD\r", "echo "},
+		{"delete line", "echo one\x1bdd\r", ""},
+		{"change line", "echo one\x1bccls\x1b\r", "ls"},
+		{"change word", "echo one two\x1b0wcwthree\x1b\r", "echo three two"},
+		{"end of word", "echo one.two\x1b0wde\r", "echo .two"},
+		{"word backward", "echo one two\x1bbbD\r", "echo "},
+		{"WORD motions", "echo a.b c\x1b0WdW\r", "echo c"},
+		{"find", "a-b-c-d\x1b0f-;x\r", "a-bc-d"},
+		{"find backward", "a-b-c-d\x1bF-,x\r", "a-b-cd"},
+		{"till", "echo a,b\x1b0dt,\r", ",b"},
+		{"delete characters", "abcdef\x1b0l3x\r", "aef"},
+		{"delete before", "abcdef\x1b2X\r", "abcf"},
+		{"append", "echo\x1b0a!\x1bA?\x1bIx\x1b\r", "xe!cho?"},
+		{"substitute", "abc\x1b0sz\x1b\r", "zbc"},
+		{"replace", "abc\x1b02rx\r", "xxc"},
+		{"toggle case", "abc\x1b0~~\r", "ABc"},
+		{"put after", "one two\x1b0dwp\r", "tone wo"},
+		{"put before", "one two\x1b0dw$P\r", "twone o"},
+		{"yank and put", "ab\x1b0ylp3p\r", "aaaaab"},
+		{"yank line", "ab\x1bYp\r", "abab"},
+		{"yank line in place", "echo aaa\x1byyp\r", "echo aaaecho aaa"},
+		{"undo", "echo one\x1bdbu\r", "echo one"},
+		{"undo insert", "echo one\x1bu\r", ""},
+		{"repeat", "foo foo foo\x1b0cwbar\x1bw.\r", "bar bar foo"},
+		{"repeat with count", "a b c d e\x1b0dw2.\r", "d e"},
+		{"repeat delete", "abcdef\x1b0x..\r", "def"},
+		{"visual delete", "abcdef\x1b0lvlld\r", "aef"},
+		{"visual change", "abcdef\x1b0vecx\x1b\r", "x"},
+		{"visual yank", "abc\x1b0vly$p\r", "abcab"},
+		{"escape cancels", "abc\x1b0d\x1bx\r", "bc"},
+	}
+
+	for _, tt := range tests {
+		rl, mockTerm := newViReadline()
+		queueViKeys(mockTerm, tt.keys)
+
+		line, err := rl.ReadLine()
+		if err != nil {
+			t.Fatalf("%s: ReadLine failed: %v", tt.name, err)
+		}
+		if line != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, line)
+		}
+	}
+}
+
+func TestVi_ModeIndicator(t *testing.T) {
+	rl, mockTerm := newViReadline()
+
+	_ = rl.ProcessKey(terminal.KeyEvent{Rune: 'l'})
+	if !strings.Contains(mockTerm.GetOutput(), viInsertIndicator+"dsh> l") {
+		t.Errorf("Expected the insert mode indicator, got %q", mockTerm.GetOutput())
+	}
+
+	mockTerm.ClearOutput()
+	_ = rl.ProcessKey(terminal.KeyEvent{Key: terminal.KeyEscape})
+	if !strings.Contains(mockTerm.GetOutput(), viCommandIndicator+"dsh> l") {
+		t.Errorf("Expected the command mode indicator, got %q", mockTerm.GetOutput())
+	}
+
+	mockTerm.ClearOutput()
+	_ = rl.ProcessKey(terminal.KeyEvent{Rune: 'v'})
+	if !strings.Contains(mockTerm.GetOutput(), viVisualIndicator+"dsh> \033[7ml\033[27m") {
+		t.Errorf("Expected the selection in reverse video, got %q", mockTerm.GetOutput())
+	}
+
+	// Escape arriving together with a key is seen as Alt and the key
+	rl, mockTerm = newViReadline()
+	queueViKeys(mockTerm, "echo one")
+	mockTerm.QueueKey(terminal.KeyEvent{Rune: 'b', Alt: true})
+	queueViKeys(mockTerm, "D\r")
+
+	if line, _ := rl.ReadLine(); line != "echo " {
+		t.Errorf("Expected Alt+b to leave insert mode and move back, got %q", line)
+	}
+}
+
+func TestVi_HistorySearch(t *testing.T) {
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{"\x1b/sta\r\r", "git stash"},
+		{"\x1b/sta\rn\r", "git status"},
+		{"\x1b/sta\rnN\r", "git stash"},
+		{"\x1b?sta\r\r", ""},
+		{"\x1b/nomatch\r\r", ""},
+		{"\x1bkk\r", "git stash"},
+		{"\x1b/sta\x1b\r", ""},
+	}
+
+	for _, tt := range tests {
+		rl, mockTerm := newViReadline()
+		for _, line := range []string{"git status", "ls -la", "git stash", "make"} {
+			rl.history.Add(line)
+		}
+		queueViKeys(mockTerm, tt.keys)
+
+		line, err := rl.ReadLine()
+		if err != nil {
+			t.Fatalf("%q: ReadLine failed: %v", tt.keys, err)
+		}
+		if line != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.keys, tt.want, line)
+		}
+	}
+}
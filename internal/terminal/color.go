@@ -171,3 +171,46 @@ func (c *ColorManager) bgCode(color Color) string {
 func (c *ColorManager) resetCode() string {
 	return "\033[0m"
 }
+
+// ParseColor returns the colour with the given name, such as red or
+// brightblue, as prompts name them.
+func ParseColor(name string) (Color, bool) {
+	switch strings.ToLower(name) {
+	case "reset", "default":
+		return ColorReset, true
+	case "black":
+		return ColorBlack, true
+	case "red":
+		return ColorRed, true
+	case "green":
+		return ColorGreen, true
+	case "yellow":
+		return ColorYellow, true
+	case "blue":
+		return ColorBlue, true
+	case "magenta":
+		return ColorMagenta, true
+	case "cyan":
+		return ColorCyan, true
+	case "white":
+		return ColorWhite, true
+	case "brightblack", "gray", "grey":
+		return ColorBrightBlack, true
+	case "brightred":
+		return ColorBrightRed, true
+	case "brightgreen":
+		return ColorBrightGreen, true
+	case "brightyellow":
+		return ColorBrightYellow, true
+	case "brightblue":
+		return ColorBrightBlue, true
+	case "brightmagenta":
+		return ColorBrightMagenta, true
+	case "brightcyan":
+		return ColorBrightCyan, true
+	case "brightwhite":
+		return ColorBrightWhite, true
+	}
+
+	return ColorReset, false
+}
@@ -2,7 +2,9 @@ package terminal
 
 import (
 	"bufio"
+	"bytes"
 	"io"
+	"strings"
 )
 
 // Key represents special keys.
@@ -30,15 +32,25 @@ const (
 	KeyCtrlD
 	KeyCtrlE
 	KeyCtrlF
+	KeyCtrlG
 	KeyCtrlK
 	KeyCtrlL
 	KeyCtrlN
+	KeyCtrlO
 	KeyCtrlP
+	KeyCtrlQ
 	KeyCtrlR
+	KeyCtrlS
+	KeyCtrlT
 	KeyCtrlU
+	KeyCtrlV
 	KeyCtrlW
+	KeyCtrlX
 	KeyCtrlY
 	KeyCtrlZ
+	KeyCtrlUnderscore
+	// KeyPaste is text pasted in bracketed paste mode, in Text
+	KeyPaste
 )
 
 // KeyEvent represents a key press.
@@ -47,6 +59,8 @@ type KeyEvent struct {
 	Rune rune
 	Alt  bool
 	Ctrl bool
+	// Text is the text of a paste, with its line breaks as newlines.
+	Text string
 }
 
 // InputReader handles terminal input.
@@ -75,7 +89,7 @@ func (r *InputReader) ReadKey() (KeyEvent, error) {
 
 	// Handle control characters and DEL
 	if b < 32 || b == 127 {
-		return KeyEvent{Key: r.ctrlKey(b)}, nil
+		return KeyEvent{Key: controlKey(b)}, nil
 	}
 
 	// Handle printable characters
@@ -84,6 +98,13 @@ func (r *InputReader) ReadKey() (KeyEvent, error) {
 
 // readEscapeSequence reads ANSI escape sequences.
 func (r *InputReader) readEscapeSequence() (KeyEvent, error) {
+	// Terminals send the bytes of a sequence together, so an escape with
+	// nothing after it yet is the Escape key itself, which vi mode must
+	// see at once
+	if r.reader.Buffered() == 0 {
+		return KeyEvent{Key: KeyEscape}, nil
+	}
+
 	// Peek next byte
 	next, err := r.reader.Peek(1)
 	if err != nil || len(next) == 0 {
@@ -124,13 +145,105 @@ func (r *InputReader) readCSISequence() (KeyEvent, error) {
 		return KeyEvent{Key: KeyHome}, nil
 	case 'F':
 		return KeyEvent{Key: KeyEnd}, nil
-	default:
-		return KeyEvent{Rune: rune(b)}, nil
 	}
+
+	if b >= '0' && b <= '9' {
+		return r.readNumberedSequence(b)
+	}
+
+	return KeyEvent{Rune: rune(b)}, nil
+}
+
+// readNumberedSequence reads the rest of a sequence such as ESC [ 3 ~,
+// whose first digit is first. Sequences it does not know are read whole
+// and dropped.
+func (r *InputReader) readNumberedSequence(first byte) (KeyEvent, error) {
+	params := []byte{first}
+
+	for {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return KeyEvent{}, err
+		}
+
+		// Parameters end at a byte from @ to ~
+		if b >= 0x40 && b <= 0x7e {
+			if b != '~' {
+				return KeyEvent{}, nil
+			}
+
+			break
+		}
+		params = append(params, b)
+	}
+
+	switch string(params) {
+	case "200":
+		return r.readPaste()
+	case "1", "7":
+		return KeyEvent{Key: KeyHome}, nil
+	case "3":
+		return KeyEvent{Key: KeyDelete}, nil
+	case "4", "8":
+		return KeyEvent{Key: KeyEnd}, nil
+	case "5":
+		return KeyEvent{Key: KeyPageUp}, nil
+	case "6":
+		return KeyEvent{Key: KeyPageDown}, nil
+	}
+
+	return KeyEvent{}, nil
+}
+
+// readPaste reads pasted text up to the ESC [ 201 ~ that ends it, which
+// the terminal sends in bracketed paste mode.
+func (r *InputReader) readPaste() (KeyEvent, error) {
+	const end = "\033[201~"
+
+	var text []byte
+	for !bytes.HasSuffix(text, []byte(end)) {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			// A paste cut short keeps what arrived, and the error comes
+			// again with the next key
+			if len(text) > 0 {
+				return KeyEvent{Key: KeyPaste, Text: pasteText(text)}, nil
+			}
+
+			return KeyEvent{}, err
+		}
+		text = append(text, b)
+	}
+
+	return KeyEvent{Key: KeyPaste, Text: pasteText(text[:len(text)-len(end)])}, nil
+}
+
+// pasteText returns pasted text with its line breaks, which terminals send
+// as carriage returns, as newlines.
+func pasteText(text []byte) string {
+	return strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(text))
+}
+
+// ControlKey returns the key typed as Control and ch, such as KeyCtrlA
+// for Control-a, or KeyNone if the terminal does not tell it apart from
+// others. Control-? is Backspace and Control-[ is Escape.
+func ControlKey(ch rune) Key {
+	switch {
+	case ch == '?':
+		return KeyBackspace
+	case ch == '[':
+		return KeyEscape
+	case ch == '_':
+		return KeyCtrlUnderscore
+	case ch >= 'a' && ch <= 'z':
+		return controlKey(byte(ch - 'a' + 1))
+	}
+
+	return KeyNone
 }
 
-// ctrlKey maps control characters to keys.
-func (r *InputReader) ctrlKey(b byte) Key {
+// controlKey maps control characters to keys.
+func controlKey(b byte) Key {
 	switch b {
 	case 1:
 		return KeyCtrlA
@@ -144,6 +257,8 @@ func (r *InputReader) ctrlKey(b byte) Key {
 		return KeyCtrlE
 	case 6:
 		return KeyCtrlF
+	case 7:
+		return KeyCtrlG
 	case 8, 127:
 		return KeyBackspace
 	case 9:
@@ -156,18 +271,32 @@ func (r *InputReader) ctrlKey(b byte) Key {
 		return KeyCtrlL
 	case 14:
 		return KeyCtrlN
+	case 15:
+		return KeyCtrlO
 	case 16:
 		return KeyCtrlP
+	case 17:
+		return KeyCtrlQ
 	case 18:
 		return KeyCtrlR
+	case 19:
+		return KeyCtrlS
+	case 20:
+		return KeyCtrlT
 	case 21:
 		return KeyCtrlU
+	case 22:
+		return KeyCtrlV
 	case 23:
 		return KeyCtrlW
+	case 24:
+		return KeyCtrlX
 	case 25:
 		return KeyCtrlY
 	case 26:
 		return KeyCtrlZ
+	case 31:
+		return KeyCtrlUnderscore
 	default:
 		return KeyNone
 	}
@@ -2,6 +2,8 @@ package terminal
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"testing"
 )
 
@@ -81,12 +83,13 @@ func TestInputReader_ControlCharacterBoundaries(t *testing.T) {
 		shouldBeKey bool
 		expectedKey Key
 	}{
-		{1, true, KeyCtrlA},       // Ctrl+A
-		{8, true, KeyBackspace},   // BS
-		{31, true, KeyNone},       // Unmapped control char
-		{32, false, KeyNone},      // Space (first printable)
-		{126, false, KeyNone},     // Tilde (last printable)
-		{127, true, KeyBackspace}, // DEL (special case)
+		{1, true, KeyCtrlA},           // Ctrl+A
+		{8, true, KeyBackspace},       // BS
+		{28, true, KeyNone},           // Unmapped control char
+		{31, true, KeyCtrlUnderscore}, // Ctrl+_
+		{32, false, KeyNone},          // Space (first printable)
+		{126, false, KeyNone},         // Tilde (last printable)
+		{127, true, KeyBackspace},     // DEL (special case)
 	}
 
 	for _, tc := range testCases {
@@ -115,3 +118,49 @@ func TestInputReader_ControlCharacterBoundaries(t *testing.T) {
 		}
 	}
 }
+
+func TestInputReader_Escape(t *testing.T) {
+	// A lone Escape is read apart from the key typed after it
+	reader := NewInputReader(io.MultiReader(bytes.NewReader([]byte{27}), bytes.NewReader([]byte("b"))))
+
+	if event, err := reader.ReadKey(); err != nil || event.Key != KeyEscape {
+		t.Errorf("Expected Escape, got %+v (%v)", event, err)
+	}
+	if event, err := reader.ReadKey(); err != nil || event.Rune != 'b' || event.Alt {
+		t.Errorf("Expected a plain b, got %+v (%v)", event, err)
+	}
+
+	// Escape arriving with the key is Alt and the key
+	reader = NewInputReader(bytes.NewReader([]byte{27, 'b'}))
+	if event, err := reader.ReadKey(); err != nil || event.Rune != 'b' || !event.Alt {
+		t.Errorf("Expected Alt+b, got %+v (%v)", event, err)
+	}
+}
+
+func TestInputReader_Paste(t *testing.T) {
+	reader := NewInputReader(bytes.NewReader([]byte("\033[200~echo a\r\techo \033b\r\n\033[201~\033[3~x")))
+
+	event, err := reader.ReadKey()
+	if err != nil || event.Key != KeyPaste {
+		t.Fatalf("Expected a paste, got %+v (%v)", event, err)
+	}
+	if event.Text != "echo a\n\techo \033b\n" {
+		t.Errorf("Expected the pasted text with newlines, got %q", event.Text)
+	}
+
+	if event, err := reader.ReadKey(); err != nil || event.Key != KeyDelete {
+		t.Errorf("Expected Delete after the paste, got %+v (%v)", event, err)
+	}
+	if event, err := reader.ReadKey(); err != nil || event.Rune != 'x' {
+		t.Errorf("Expected x, got %+v (%v)", event, err)
+	}
+
+	// A paste cut short keeps what arrived
+	reader = NewInputReader(bytes.NewReader([]byte("\033[200~ls")))
+	if event, err := reader.ReadKey(); err != nil || event.Key != KeyPaste || event.Text != "ls" {
+		t.Errorf("Expected the partial paste, got %+v (%v)", event, err)
+	}
+	if _, err := reader.ReadKey(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected EOF after the partial paste, got %v", err)
+	}
+}
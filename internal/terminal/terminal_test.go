@@ -47,3 +47,37 @@ func TestInterface(t *testing.T) {
 		t.Error("Interface should have InputReader")
 	}
 }
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+	}{
+		{"dsh> ", 5},
+		{"\033[31mred\033[0m> ", 5},
+		{"\033]0;title\a$ ", 2},
+		{"\033]0;title\033\\$ ", 2},
+		{"λ ", 2},
+		{"日本> ", 6},
+		{"\033", 0},
+	}
+
+	for _, tt := range tests {
+		if width := DisplayWidth(tt.text); width != tt.width {
+			t.Errorf("DisplayWidth(%q) = %d, want %d", tt.text, width, tt.width)
+		}
+	}
+
+	if line := LastLine("user@host\n$ "); line != "$ " {
+		t.Errorf("LastLine = %q, want %q", line, "$ ")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	if color, ok := ParseColor("BrightBlue"); !ok || color != ColorBrightBlue {
+		t.Errorf("ParseColor(BrightBlue) = %v, %v", color, ok)
+	}
+	if _, ok := ParseColor("mauve"); ok {
+		t.Error("Expected an unknown colour to be rejected")
+	}
+}
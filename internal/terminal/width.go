@@ -0,0 +1,65 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// DisplayWidth returns the number of columns text takes on the screen.
+// Escape sequences, such as those that set colours, take none, and wide
+// characters take two.
+func DisplayWidth(text string) int {
+	width := 0
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\033' {
+			i = skipEscape(runes, i)
+
+			continue
+		}
+		width += runewidth.RuneWidth(runes[i])
+	}
+
+	return width
+}
+
+// LastLine returns the part of text after its last newline, which is
+// what shares the screen line with whatever is written after it.
+func LastLine(text string) string {
+	return text[strings.LastIndexByte(text, '\n')+1:]
+}
+
+// skipEscape returns the index of the last rune of the escape sequence
+// starting at runes[start]: a CSI sequence such as \033[31m, which ends
+// at a letter, an OSC sequence such as a window title, which ends at BEL
+// or \033\, or an escape followed by a single character.
+func skipEscape(runes []rune, start int) int {
+	i := start + 1
+	if i >= len(runes) {
+		return start
+	}
+
+	switch runes[i] {
+	case '[':
+		for i++; i < len(runes); i++ {
+			if runes[i] >= 0x40 && runes[i] <= 0x7e {
+				return i
+			}
+		}
+	case ']':
+		for i++; i < len(runes); i++ {
+			if runes[i] == '\a' {
+				return i
+			}
+			if runes[i] == '\033' && i+1 < len(runes) && runes[i+1] == '\\' {
+				return i + 1
+			}
+		}
+	default:
+		return i
+	}
+
+	return len(runes) - 1
+}
@@ -0,0 +1,693 @@
+// Package variables stores shell variables, indexed and associative arrays,
+// and the special parameters that the executor keeps up to date.
+package variables
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind identifies how a variable holds its value.
+type Kind int
+
+const (
+	// Scalar is a plain string variable.
+	Scalar Kind = iota
+	// Indexed is an array addressed by non-negative integers.
+	Indexed
+	// Associative is an array addressed by strings (declare -A).
+	Associative
+)
+
+var (
+	// ErrBadSubscript indicates an array index that is not an integer or is
+	// out of range.
+	ErrBadSubscript = errors.New("bad array subscript")
+	// ErrNotAssociative indicates a string key used on a non-associative array.
+	ErrNotAssociative = errors.New("cannot convert indexed array to associative array")
+)
+
+// StateEnv is the environment variable through which a child dsh, run for
+// a pipeline stage or a process substitution, receives the variables of
+// the shell that started it.
+const StateEnv = "DSH_STATE"
+
+// Variable is a single shell variable.
+type Variable struct {
+	kind    Kind
+	value   string
+	indexed map[int]string
+	assoc   map[string]string
+	// keys keeps associative keys in insertion order so listings are stable.
+	keys []string
+}
+
+var (
+	store      = map[string]*Variable{} //nolint:gochecknoglobals // Shell-wide variable table
+	lastStatus int                      //nolint:gochecknoglobals // Special parameter $?
+	positional []string                 //nolint:gochecknoglobals // Positional parameters $1, $2 and so on
+	scriptName = "dsh"                  //nolint:gochecknoglobals // Special parameter $0
+	// optionOffset is where getopts is within a cluster of options such
+	// as -abc in the word OPTIND names, or 0 at the start of a word. Any
+	// assignment to OPTIND resets it, as OPTIND=1 does to start over.
+	optionOffset int          //nolint:gochecknoglobals // getopts state kept with OPTIND
+	storeMu      sync.RWMutex //nolint:gochecknoglobals // Guards store and the special parameters
+)
+
+// optionIndex is the variable getopts keeps its place in.
+const optionIndex = "OPTIND"
+
+// LastStatus returns the exit status of the most recent command ($?).
+func LastStatus() int {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	return lastStatus
+}
+
+// SetLastStatus records the exit status of the most recent command.
+func SetLastStatus(status int) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	lastStatus = status
+}
+
+// Positional returns the positional parameters, $1 onwards.
+func Positional() []string {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	return slices.Clone(positional)
+}
+
+// SetPositional replaces the positional parameters, as set -- does.
+func SetPositional(args []string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	positional = slices.Clone(args)
+}
+
+// ScriptName returns $0, the name of the script or of the shell.
+func ScriptName() string {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	return scriptName
+}
+
+// SetScriptName sets $0 for a script or a -c command given a name.
+func SetScriptName(name string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	scriptName = name
+}
+
+// OptionOffset returns where getopts is within the cluster of options in
+// the word OPTIND names, or 0 at the start of a word.
+func OptionOffset() int {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	return optionOffset
+}
+
+// SetOptionOffset records where getopts is within a cluster of options,
+// once it has assigned OPTIND.
+func SetOptionOffset(offset int) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	optionOffset = offset
+}
+
+// assigned forgets the getopts offset when name is OPTIND. The caller
+// holds storeMu.
+func assigned(name string) {
+	if name == optionIndex {
+		optionOffset = 0
+	}
+}
+
+// Get returns the value of a scalar variable, or element 0 of an array.
+// Variables the shell has not set are looked up in the environment.
+func Get(name string) (string, bool) {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	v, ok := store[name]
+	if !ok {
+		return os.LookupEnv(name)
+	}
+
+	switch v.kind {
+	case Indexed:
+		value, ok := v.indexed[0]
+
+		return value, ok
+	case Associative:
+		value, ok := v.assoc["0"]
+
+		return value, ok
+	default:
+		return v.value, true
+	}
+}
+
+// Set assigns a scalar value. Variables inherited from the environment are
+// updated there too, so child processes see the new value. Setting an
+// array variable assigns its element 0, as in bash.
+func Set(name, value string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	assigned(name)
+
+	if v, ok := store[name]; ok && v.kind != Scalar {
+		v.setElement("0", value)
+
+		return
+	}
+
+	if _, inEnv := os.LookupEnv(name); inEnv {
+		delete(store, name)
+		_ = os.Setenv(name, value)
+
+		return
+	}
+
+	store[name] = &Variable{kind: Scalar, value: value}
+}
+
+// SetArray replaces name with an indexed array holding values.
+func SetArray(name string, values []string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	assigned(name)
+
+	v := newArray(Indexed)
+	for i, value := range values {
+		v.indexed[i] = value
+	}
+
+	_ = os.Unsetenv(name)
+	store[name] = v
+}
+
+// Declare makes name an empty array of the given kind unless it already
+// is one. A scalar value becomes element 0 of a new indexed array.
+func Declare(name string, kind Kind) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	current, exists := lookup(name)
+	if kind == Scalar {
+		if !exists {
+			store[name] = &Variable{kind: Scalar}
+		}
+
+		return nil
+	}
+
+	switch {
+	case !exists:
+		store[name] = newArray(kind)
+	case current.kind == kind:
+	case current.kind == Indexed && kind == Associative:
+		return fmt.Errorf("%s: %w", name, ErrNotAssociative)
+	case current.kind == Scalar:
+		v := newArray(kind)
+		v.setElement("0", current.value)
+		_ = os.Unsetenv(name)
+		store[name] = v
+	}
+
+	return nil
+}
+
+// KindOf returns the kind of name. Unset names are scalars.
+func KindOf(name string) Kind {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	if v, ok := store[name]; ok {
+		return v.kind
+	}
+
+	return Scalar
+}
+
+// SetElement assigns one element of an array. For indexed arrays key must
+// be an integer; a negative index counts back from the end. A scalar or
+// unset name becomes an indexed array.
+func SetElement(name, key, value string) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	assigned(name)
+
+	v, exists := lookup(name)
+	if !exists || v.kind == Scalar {
+		converted := newArray(Indexed)
+		if exists {
+			converted.indexed[0] = v.value
+		}
+		_ = os.Unsetenv(name)
+		store[name] = converted
+		v = converted
+	}
+
+	if v.kind == Indexed {
+		index, err := v.resolveIndex(key)
+		if err != nil {
+			return fmt.Errorf("%s[%s]: %w", name, key, err)
+		}
+		key = strconv.Itoa(index)
+	}
+
+	v.setElement(key, value)
+
+	return nil
+}
+
+// GetElement returns one element of an array. Scalars have a single
+// element at index 0.
+func GetElement(name, key string) (string, bool) {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	v, ok := lookup(name)
+	if !ok {
+		return "", false
+	}
+
+	switch v.kind {
+	case Associative:
+		value, ok := v.assoc[key]
+
+		return value, ok
+	case Indexed:
+		index, err := v.resolveIndex(key)
+		if err != nil {
+			return "", false
+		}
+		value, ok := v.indexed[index]
+
+		return value, ok
+	default:
+		if key == "0" {
+			return v.value, true
+		}
+
+		return "", false
+	}
+}
+
+// AppendArray adds values after the highest index of an indexed array.
+func AppendArray(name string, values []string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	assigned(name)
+
+	v, exists := lookup(name)
+	if !exists || v.kind == Scalar {
+		converted := newArray(Indexed)
+		if exists {
+			converted.indexed[0] = v.value
+		}
+		_ = os.Unsetenv(name)
+		store[name] = converted
+		v = converted
+	}
+
+	next := 0
+	for _, index := range v.sortedIndices() {
+		next = index + 1
+	}
+	for i, value := range values {
+		v.setElement(strconv.Itoa(next+i), value)
+	}
+}
+
+// GetArray returns the values of an array: indexed arrays in index order,
+// associative arrays in insertion order. A scalar is treated as an array
+// of one element.
+func GetArray(name string) []string {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	v, ok := lookup(name)
+	if !ok {
+		return nil
+	}
+
+	keys := v.keyList()
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, _ := v.element(key)
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// Keys returns the indices or keys of an array, in the order GetArray
+// returns the values.
+func Keys(name string) []string {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	v, ok := lookup(name)
+	if !ok {
+		return nil
+	}
+
+	return v.keyList()
+}
+
+// IsSet reports whether name is set in the shell or the environment.
+func IsSet(name string) bool {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	_, ok := lookup(name)
+
+	return ok
+}
+
+// Unset removes name from the shell and the environment.
+func Unset(name string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	assigned(name)
+
+	delete(store, name)
+	_ = os.Unsetenv(name)
+}
+
+// UnsetElement removes one element of an array.
+func UnsetElement(name, key string) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	assigned(name)
+
+	v, ok := store[name]
+	if !ok {
+		return nil
+	}
+
+	switch v.kind {
+	case Associative:
+		delete(v.assoc, key)
+		for i, existing := range v.keys {
+			if existing == key {
+				v.keys = append(v.keys[:i], v.keys[i+1:]...)
+
+				break
+			}
+		}
+	case Indexed:
+		index, err := v.resolveIndex(key)
+		if err != nil {
+			return fmt.Errorf("%s[%s]: %w", name, key, err)
+		}
+		delete(v.indexed, index)
+	default:
+		if key == "0" {
+			delete(store, name)
+		}
+	}
+
+	return nil
+}
+
+// lookup finds name in the store, falling back to the environment. The
+// caller must hold storeMu.
+func lookup(name string) (*Variable, bool) {
+	if v, ok := store[name]; ok {
+		return v, true
+	}
+
+	if value, inEnv := os.LookupEnv(name); inEnv {
+		return &Variable{kind: Scalar, value: value}, true
+	}
+
+	return nil, false
+}
+
+func newArray(kind Kind) *Variable {
+	if kind == Associative {
+		return &Variable{kind: Associative, assoc: map[string]string{}}
+	}
+
+	return &Variable{kind: Indexed, indexed: map[int]string{}}
+}
+
+// resolveIndex converts an indexed-array subscript to an index. Negative
+// subscripts count back from one past the highest index.
+func (v *Variable) resolveIndex(key string) (int, error) {
+	index, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, ErrBadSubscript
+	}
+
+	if index < 0 {
+		indices := v.sortedIndices()
+		if len(indices) == 0 {
+			return 0, ErrBadSubscript
+		}
+		index += indices[len(indices)-1] + 1
+		if index < 0 {
+			return 0, ErrBadSubscript
+		}
+	}
+
+	return index, nil
+}
+
+func (v *Variable) setElement(key, value string) {
+	if v.kind == Associative {
+		if _, exists := v.assoc[key]; !exists {
+			v.keys = append(v.keys, key)
+		}
+		v.assoc[key] = value
+
+		return
+	}
+
+	index, err := strconv.Atoi(key)
+	if err != nil {
+		index = 0
+	}
+	v.indexed[index] = value
+}
+
+func (v *Variable) element(key string) (string, bool) {
+	switch v.kind {
+	case Associative:
+		value, ok := v.assoc[key]
+
+		return value, ok
+	case Indexed:
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return "", false
+		}
+		value, ok := v.indexed[index]
+
+		return value, ok
+	default:
+		return v.value, key == "0"
+	}
+}
+
+func (v *Variable) keyList() []string {
+	switch v.kind {
+	case Associative:
+		return append([]string(nil), v.keys...)
+	case Indexed:
+		indices := v.sortedIndices()
+		keys := make([]string, 0, len(indices))
+		for _, index := range indices {
+			keys = append(keys, strconv.Itoa(index))
+		}
+
+		return keys
+	default:
+		return []string{"0"}
+	}
+}
+
+func (v *Variable) sortedIndices() []int {
+	indices := make([]int, 0, len(v.indexed))
+	for index := range v.indexed {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	return indices
+}
+
+// Names returns the names of the variables the shell has set itself, in
+// sorted order. Variables only present in the environment are omitted.
+func Names() []string {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	names := make([]string, 0, len(store))
+	for name := range store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Snapshot is a copy of the shell's variables and environment.
+type Snapshot struct {
+	store        map[string]*Variable
+	positional   []string
+	optionOffset int
+	env          []string
+}
+
+// TakeSnapshot copies every variable so that RestoreSnapshot can undo
+// later changes, as a subshell requires.
+func TakeSnapshot() *Snapshot {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	copied := make(map[string]*Variable, len(store))
+	for name, v := range store {
+		copied[name] = v.clone()
+	}
+
+	return &Snapshot{store: copied, positional: slices.Clone(positional), optionOffset: optionOffset, env: os.Environ()}
+}
+
+// RestoreSnapshot puts back the variables and environment of snapshot.
+// The last status is kept, since it reports how the subshell ended.
+func RestoreSnapshot(snapshot *Snapshot) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	store = snapshot.store
+	positional = snapshot.positional
+	optionOffset = snapshot.optionOffset
+
+	os.Clearenv()
+	for _, entry := range snapshot.env {
+		name, value, _ := strings.Cut(entry, "=")
+		_ = os.Setenv(name, value)
+	}
+}
+
+func (v *Variable) clone() *Variable {
+	return &Variable{
+		kind:    v.kind,
+		value:   v.value,
+		indexed: maps.Clone(v.indexed),
+		assoc:   maps.Clone(v.assoc),
+		keys:    slices.Clone(v.keys),
+	}
+}
+
+// encodedVariable is a variable as Encode writes it. A scalar has one
+// value and no keys.
+type encodedVariable struct {
+	Name   string   `json:"name"`
+	Kind   Kind     `json:"kind"`
+	Keys   []string `json:"keys,omitempty"`
+	Values []string `json:"values"`
+}
+
+// encodedState is what Encode writes: the variables, the positional
+// parameters and where getopts is within a cluster of options.
+type encodedState struct {
+	Variables    []encodedVariable `json:"variables"`
+	Positional   []string          `json:"positional"`
+	ScriptName   string            `json:"scriptName"`
+	OptionOffset int               `json:"optionOffset"`
+}
+
+// Encode returns the variables the shell has set itself, arrays included,
+// and the positional parameters in the form Decode reads, so that a child
+// dsh can start with them. Variables in the environment reach it anyway.
+func Encode() (string, error) {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	encoded := make([]encodedVariable, 0, len(store))
+	for name, v := range store {
+		entry := encodedVariable{Name: name, Kind: v.kind}
+		if v.kind == Scalar {
+			entry.Values = []string{v.value}
+		} else {
+			entry.Keys = v.keyList()
+			for _, key := range entry.Keys {
+				value, _ := v.element(key)
+				entry.Values = append(entry.Values, value)
+			}
+		}
+		encoded = append(encoded, entry)
+	}
+
+	data, err := json.Marshal(encodedState{
+		Variables: encoded, Positional: positional, ScriptName: scriptName, OptionOffset: optionOffset,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding variables: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Decode sets the variables and positional parameters that Encode
+// described in state.
+func Decode(state string) error {
+	var decoded encodedState
+	if err := json.Unmarshal([]byte(state), &decoded); err != nil {
+		return fmt.Errorf("decoding variables: %w", err)
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	positional = decoded.Positional
+	scriptName = decoded.ScriptName
+	optionOffset = decoded.OptionOffset
+
+	for _, entry := range decoded.Variables {
+		if entry.Kind == Scalar {
+			if len(entry.Values) == 1 {
+				store[entry.Name] = &Variable{kind: Scalar, value: entry.Values[0]}
+			}
+
+			continue
+		}
+
+		v := newArray(entry.Kind)
+		for i, key := range entry.Keys {
+			if i < len(entry.Values) {
+				v.setElement(key, entry.Values[i])
+			}
+		}
+		store[entry.Name] = v
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package variables
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSetAndGet(t *testing.T) {
+	Set("DSH_TEST_SCALAR", "value")
+	defer Unset("DSH_TEST_SCALAR")
+
+	value, ok := Get("DSH_TEST_SCALAR")
+	if !ok || value != "value" {
+		t.Errorf("Expected value, got %q (set=%v)", value, ok)
+	}
+
+	if _, inEnv := os.LookupEnv("DSH_TEST_SCALAR"); inEnv {
+		t.Error("Shell variables should not be exported to the environment")
+	}
+}
+
+func TestSetUpdatesEnvironment(t *testing.T) {
+	t.Setenv("DSH_TEST_ENV", "old")
+
+	Set("DSH_TEST_ENV", "new")
+
+	if got := os.Getenv("DSH_TEST_ENV"); got != "new" {
+		t.Errorf("Expected environment to be updated, got %q", got)
+	}
+	if value, _ := Get("DSH_TEST_ENV"); value != "new" {
+		t.Errorf("Expected new, got %q", value)
+	}
+}
+
+func TestArrays(t *testing.T) {
+	SetArray("DSH_TEST_ARRAY", []string{"a", "b", "c"})
+	defer Unset("DSH_TEST_ARRAY")
+
+	got := GetArray("DSH_TEST_ARRAY")
+	if len(got) != 3 || got[0] != "a" || got[2] != "c" {
+		t.Errorf("Expected [a b c], got %v", got)
+	}
+
+	if value, _ := Get("DSH_TEST_ARRAY"); value != "a" {
+		t.Errorf("Expected element 0 for a scalar lookup, got %q", value)
+	}
+
+	Set("DSH_TEST_ONE", "x")
+	defer Unset("DSH_TEST_ONE")
+	if got := GetArray("DSH_TEST_ONE"); len(got) != 1 || got[0] != "x" {
+		t.Errorf("Expected a scalar to behave as a one-element array, got %v", got)
+	}
+}
+
+func TestUnsetAndIsSet(t *testing.T) {
+	Set("DSH_TEST_UNSET", "x")
+	if !IsSet("DSH_TEST_UNSET") {
+		t.Fatal("Expected variable to be set")
+	}
+
+	Unset("DSH_TEST_UNSET")
+	if IsSet("DSH_TEST_UNSET") {
+		t.Error("Expected variable to be unset")
+	}
+}
+
+func TestLastStatus(t *testing.T) {
+	SetLastStatus(42)
+	if LastStatus() != 42 {
+		t.Errorf("Expected 42, got %d", LastStatus())
+	}
+	SetLastStatus(0)
+}
+
+func TestAssociativeArrays(t *testing.T) {
+	err := Declare("DSH_TEST_ASSOC", Associative)
+	if err != nil {
+		t.Fatalf("Declare failed: %v", err)
+	}
+	defer Unset("DSH_TEST_ASSOC")
+
+	for _, key := range []string{"b", "a", "c"} {
+		err := SetElement("DSH_TEST_ASSOC", key, "value-"+key)
+		if err != nil {
+			t.Fatalf("SetElement(%q) failed: %v", key, err)
+		}
+	}
+
+	keys := Keys("DSH_TEST_ASSOC")
+	if len(keys) != 3 || keys[0] != "b" || keys[1] != "a" || keys[2] != "c" {
+		t.Errorf("Expected keys in insertion order, got %v", keys)
+	}
+
+	if value, ok := GetElement("DSH_TEST_ASSOC", "a"); !ok || value != "value-a" {
+		t.Errorf("Expected value-a, got %q (set=%v)", value, ok)
+	}
+
+	err = UnsetElement("DSH_TEST_ASSOC", "a")
+	if err != nil {
+		t.Fatalf("UnsetElement failed: %v", err)
+	}
+	if keys := Keys("DSH_TEST_ASSOC"); len(keys) != 2 {
+		t.Errorf("Expected 2 keys after unset, got %v", keys)
+	}
+}
+
+func TestIndexedArrayElements(t *testing.T) {
+	SetArray("DSH_TEST_SPARSE", []string{"a", "b"})
+	defer Unset("DSH_TEST_SPARSE")
+
+	err := SetElement("DSH_TEST_SPARSE", "5", "f")
+	if err != nil {
+		t.Fatalf("SetElement failed: %v", err)
+	}
+
+	if value, _ := GetElement("DSH_TEST_SPARSE", "-1"); value != "f" {
+		t.Errorf("Expected negative index to count from the end, got %q", value)
+	}
+
+	AppendArray("DSH_TEST_SPARSE", []string{"g"})
+	keys := Keys("DSH_TEST_SPARSE")
+	if len(keys) != 4 || keys[3] != "6" {
+		t.Errorf("Expected append after the highest index, got keys %v", keys)
+	}
+
+	if err := SetElement("DSH_TEST_SPARSE", "x", "bad"); !errors.Is(err, ErrBadSubscript) {
+		t.Errorf("Expected ErrBadSubscript, got %v", err)
+	}
+
+	if err := Declare("DSH_TEST_SPARSE", Associative); !errors.Is(err, ErrNotAssociative) {
+		t.Errorf("Expected ErrNotAssociative, got %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	Set("DSH_TEST_KEPT", "before")
+	SetArray("DSH_TEST_LIST", []string{"a"})
+	t.Cleanup(func() {
+		Unset("DSH_TEST_KEPT")
+		Unset("DSH_TEST_LIST")
+		Unset("DSH_TEST_NEW")
+		_ = os.Unsetenv("DSH_TEST_ENV")
+	})
+
+	snapshot := TakeSnapshot()
+
+	Set("DSH_TEST_KEPT", "after")
+	Set("DSH_TEST_NEW", "new")
+	_ = os.Setenv("DSH_TEST_ENV", "exported")
+	SetArray("DSH_TEST_LIST", []string{"b", "c"})
+
+	RestoreSnapshot(snapshot)
+
+	if value, _ := Get("DSH_TEST_KEPT"); value != "before" {
+		t.Errorf("Expected the old value back, got %q", value)
+	}
+	if IsSet("DSH_TEST_NEW") {
+		t.Error("Expected a new variable to be gone")
+	}
+	if _, ok := os.LookupEnv("DSH_TEST_ENV"); ok {
+		t.Error("Expected the environment to be restored")
+	}
+	if list := GetArray("DSH_TEST_LIST"); len(list) != 1 || list[0] != "a" {
+		t.Errorf("Expected the old array back, got %q", list)
+	}
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	Set("DSH_TEST_SCALAR", "a b")
+	SetArray("DSH_TEST_ARRAY", []string{"p", "q"})
+	_ = SetElement("DSH_TEST_ARRAY", "5", "r")
+	_ = Declare("DSH_TEST_ASSOC", Associative)
+	_ = SetElement("DSH_TEST_ASSOC", "key", "value")
+	SetPositional([]string{"x y", "z"})
+	t.Cleanup(func() {
+		SetPositional(nil)
+		for _, name := range []string{"DSH_TEST_SCALAR", "DSH_TEST_ARRAY", "DSH_TEST_ASSOC"} {
+			Unset(name)
+		}
+	})
+
+	state, err := Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := TakeSnapshot()
+	for _, name := range []string{"DSH_TEST_SCALAR", "DSH_TEST_ARRAY", "DSH_TEST_ASSOC"} {
+		Unset(name)
+	}
+	SetPositional(nil)
+	if err := Decode(state); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, _ := Get("DSH_TEST_SCALAR"); value != "a b" {
+		t.Errorf("Expected the scalar back, got %q", value)
+	}
+	if keys := Keys("DSH_TEST_ARRAY"); len(keys) != 3 || keys[2] != "5" {
+		t.Errorf("Expected the indices kept, got %q", keys)
+	}
+	if value, _ := GetElement("DSH_TEST_ASSOC", "key"); value != "value" || KindOf("DSH_TEST_ASSOC") != Associative {
+		t.Errorf("Expected the associative array back, got %q", value)
+	}
+	if args := Positional(); len(args) != 2 || args[0] != "x y" || ScriptName() != "dsh" {
+		t.Errorf("Expected the positional parameters back, got %q", args)
+	}
+	RestoreSnapshot(snapshot)
+
+	if err := Decode("not json"); err == nil {
+		t.Error("Expected bad state to fail")
+	}
+}
+
+func TestOptionOffset(t *testing.T) {
+	t.Cleanup(func() { Unset("OPTIND") })
+
+	Set("OPTIND", "2")
+	SetOptionOffset(3)
+	Set("DSH_TEST_OTHER", "x")
+	Unset("DSH_TEST_OTHER")
+	if offset := OptionOffset(); offset != 3 {
+		t.Errorf("Expected other variables to leave the offset, got %d", offset)
+	}
+
+	Set("OPTIND", "1")
+	if offset := OptionOffset(); offset != 0 {
+		t.Errorf("Expected assigning OPTIND to reset the offset, got %d", offset)
+	}
+}
@@ -4,41 +4,234 @@ package main
 import (
 	"bufio"
 	"errors"
-	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/mattn/go-isatty"
 
+	"dsh/internal/builtins"
+	"dsh/internal/dirs"
 	"dsh/internal/executor"
+	"dsh/internal/expand"
+	"dsh/internal/highlight"
 	"dsh/internal/lexer"
+	"dsh/internal/options"
 	"dsh/internal/parser"
+	"dsh/internal/prompt"
 	"dsh/internal/readline"
+	"dsh/internal/variables"
 )
 
+// syntaxErrorStatus is the exit status after a command line fails to parse.
+const syntaxErrorStatus = 2
+
+// scriptNotFoundStatus is the exit status when a script cannot be opened.
+const scriptNotFoundStatus = 127
+
+// usageStatus is the exit status after an invalid command line option.
+const usageStatus = 2
+
+// defaultPS1 is the prompt used when PS1 is unset.
+const defaultPS1 = "dsh> "
+
+// defaultTransientPS1 is the compact prompt left in the scrollback, with
+// the transientprompt option on, when TRANSIENT_PS1 is unset.
+const defaultTransientPS1 = `\$ `
+
+// defaultPS2 is the continuation prompt used when PS2 is unset.
+const defaultPS2 = "> "
+
+// usage summarises the command line.
+const usage = "usage: dsh [-CHefux] [-o option] [-O shopt_option] [-c command [name [arg ...]] | script [arg ...]]"
+
+var (
+	// ErrMissingArgument indicates an option given without its argument.
+	ErrMissingArgument = errors.New("option requires an argument")
+	// ErrInvalidFlag indicates a command line option dsh does not know.
+	ErrInvalidFlag = errors.New("invalid option")
+)
+
+// invocation is what the command line asks the shell to run.
+type invocation struct {
+	command    string
+	hasCommand bool
+	script     string
+	// operands follow the command or the script: for -c the first is $0,
+	// and the rest are the positional parameters.
+	operands []string
+	// histExpandSet records a -H or +H, which overrides history expansion
+	// being on by default in an interactive shell.
+	histExpandSet bool
+}
+
+// parseArguments applies the option flags of the command line, which are
+// those of set and shopt, and returns the command or script to run. A
+// -c among the flags takes the next argument as the command; otherwise
+// the first operand names a script. The operands left over are for $0
+// and the positional parameters.
+func parseArguments(args []string) (invocation, error) {
+	var result invocation
+
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || arg == "-" {
+			i++
+
+			break
+		}
+
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+
+		on := arg[0] == '-'
+		for _, letter := range []byte(arg[1:]) {
+			switch letter {
+			case 'c':
+				if i+1 >= len(args) {
+					return result, fmt.Errorf("-c: %w", ErrMissingArgument)
+				}
+				i++
+				result.command, result.hasCommand = args[i], true
+			case 'o', 'O':
+				if i+1 >= len(args) {
+					return result, fmt.Errorf("%c%c: %w", arg[0], letter, ErrMissingArgument)
+				}
+				i++
+
+				option, ok := options.Lookup(args[i])
+				if !ok || option.Extended != (letter == 'O') {
+					return result, fmt.Errorf("%s: %w", args[i], options.ErrInvalidOption)
+				}
+				_ = options.Set(option.Name, on)
+				result.histExpandSet = result.histExpandSet || option.Name == options.HistExpand
+			default:
+				option, ok := options.ByLetter(letter)
+				if !ok {
+					return result, fmt.Errorf("%c%c: %w", arg[0], letter, ErrInvalidFlag)
+				}
+				_ = options.Set(option.Name, on)
+				result.histExpandSet = result.histExpandSet || option.Name == options.HistExpand
+			}
+		}
+	}
+
+	if !result.hasCommand && i < len(args) {
+		result.script = args[i]
+		i++
+	}
+	result.operands = args[i:]
+
+	return result, nil
+}
+
+// inheritVariables takes on the variables of the shell that started this
+// one for a pipeline stage or a process substitution, and keeps them from
+// the commands it runs in turn.
+func inheritVariables() {
+	state, ok := os.LookupEnv(variables.StateEnv)
+	if !ok {
+		return
+	}
+	_ = os.Unsetenv(variables.StateEnv)
+
+	if err := variables.Decode(state); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+	}
+}
+
+// loadInputrc reads the key bindings in ~/.config/dsh/inputrc, if there is
+// one.
+func loadInputrc() {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+
+	err = readline.ReadInputrc(filepath.Join(homeDir, ".config", "dsh", "inputrc"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+	}
+}
+
 func main() {
-	var commandFlag = flag.String("c", "", "execute command and exit")
-	flag.Parse()
+	inheritVariables()
+
+	invocation, err := parseArguments(os.Args[1:])
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n%s\n", err, usage)
+		os.Exit(usageStatus)
+	}
+
+	expand.SetProcessSubstituter(executor.StartProcessSubstitution)
+	prompt.SetCommandRunner(executor.CommandOutput)
+	builtins.SetLineEditor(readline.LineEditor{})
+	dirs.Initialize()
 
 	// If -c flag is provided, execute command and exit
-	if *commandFlag != "" {
-		success := processCommandLine(*commandFlag)
-		if !success {
-			os.Exit(1)
+	if invocation.hasCommand {
+		if len(invocation.operands) > 0 {
+			variables.SetScriptName(invocation.operands[0])
+			variables.SetPositional(invocation.operands[1:])
 		}
+		processSource(invocation.command, "-c", 1)
 		// Exit with last command's exit status
 		os.Exit(executor.GetLastExitStatus())
 	}
 
+	// A script named on the command line runs like input from stdin
+	if invocation.script != "" {
+		script, err := os.Open(invocation.script)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+			os.Exit(scriptNotFoundStatus)
+		}
+		variables.SetScriptName(invocation.script)
+		variables.SetPositional(invocation.operands)
+		runScript(invocation.script, script)
+		_ = script.Close()
+
+		os.Exit(executor.GetLastExitStatus())
+	}
+
 	// Interactive mode
 	if isatty.IsTerminal(os.Stdin.Fd()) {
-		rl, err := readline.New("dsh> ")
+		rl, err := readline.New(defaultPS1)
 		if err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "dsh: failed to initialize readline: %v\n", err)
 			os.Exit(1)
 		}
+		rl.SetContinuation(continuationPrompt)
+		readline.SetCommandRunner(executor.CommandOutput)
+		executor.SetHistory(rl.GetHistory())
+		executor.SetInteractive(true)
+		loadInputrc()
+		// History expansion is on in an interactive shell unless turned off
+		if !invocation.histExpandSet {
+			_ = options.Set(options.HistExpand, true)
+		}
+
+		highlighter := highlight.New()
+		rl.SetHighlighter(highlighter.Line)
+
+		// Parts of PS1 read in the background, such as \g, redraw it
+		prompt.SetRepaint(func() {
+			rl.Repaint(func() (string, string) { return expandPrompt("PS1", defaultPS1), rightPrompt() })
+		})
 
 		for {
+			// The prompts are expanded afresh for each command
+			rl.SetPrompt(expandPrompt("PS1", defaultPS1))
+			rl.SetRightPrompt(rightPrompt())
+			rl.SetTransientPrompt(transientPrompt())
+			rl.SetViMode(options.Enabled(options.Vi))
+			rl.SetHistoryExpansion(options.Enabled(options.HistExpand))
+			// The last command may have made new commands or files
+			highlighter.Reset()
+
 			line, err := rl.ReadLine()
 			if err != nil {
 				if errors.Is(err, readline.ErrEOF) {
@@ -55,31 +248,64 @@ func main() {
 			}
 
 			if !processCommandLine(line) {
-				// Command returned false (likely exit command)
-				os.Exit(0)
+				// Command returned false (exit command)
+				os.Exit(executor.GetLastExitStatus())
 			}
 		}
 	} else {
 		// Non-interactive mode - read from stdin
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue
-			}
-			if !processCommandLine(line) {
-				// Command returned false (likely exit command)
-				os.Exit(0)
-			}
-		}
+		runScript("stdin", os.Stdin)
 	}
 
 	// Exit with last command's exit status
 	os.Exit(executor.GetLastExitStatus())
 }
 
+// runScript reads commands from input and runs each as soon as it is
+// complete. name identifies the input in syntax errors.
+func runScript(name string, input io.Reader) {
+	var pending string
+	lineNumber, firstLine := 0, 0
+
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNumber++
+		if pending != "" {
+			line = pending + "\n" + line
+		} else if line == "" {
+			continue
+		} else {
+			firstLine = lineNumber
+		}
+
+		if isIncomplete(line) {
+			pending = line
+
+			continue
+		}
+		pending = ""
+
+		if !processSource(line, name, firstLine) {
+			// Command returned false (exit command)
+			os.Exit(executor.GetLastExitStatus())
+		}
+	}
+
+	// Report a command left incomplete at end of input
+	if pending != "" {
+		processSource(pending, name, firstLine)
+	}
+}
+
 func processCommandLine(line string) bool {
-	l := lexer.New(line)
+	return processSource(line, "", 1)
+}
+
+// processSource parses and runs text, which starts at line firstLine of
+// the input called name. Interactive input has no name.
+func processSource(text, name string, firstLine int) bool {
+	l := lexer.New(text)
 	p := parser.New(l)
 
 	pipelines, err := p.ParseCommandLine()
@@ -88,16 +314,80 @@ func processCommandLine(line string) bool {
 			// Skip empty pipelines, continue parsing
 			return true
 		}
-		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+		reportSyntaxError(err, name, firstLine)
+		variables.SetLastStatus(syntaxErrorStatus)
 
 		return true
 	}
 
-	for _, pipeline := range pipelines {
-		if !executor.ExecutePipeline(pipeline) {
-			return false
+	return executor.ExecuteList(pipelines)
+}
+
+// reportSyntaxError prints err as name:line:column followed by the
+// offending line and a caret under the column.
+func reportSyntaxError(err error, name string, firstLine int) {
+	var syntaxErr *parser.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		_, _ = fmt.Fprintf(os.Stderr, "dsh: %v\n", err)
+
+		return
+	}
+
+	syntaxErr.Pos.Line += firstLine - 1
+	if name != "" {
+		name += ":"
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "dsh: %s%v\n%s\n", name, syntaxErr, syntaxErr.Excerpt())
+}
+
+// isIncomplete reports whether text is the beginning of a command that
+// continues on a following line.
+func isIncomplete(text string) bool {
+	_, err := parser.New(lexer.New(text)).ParseCommandLine()
+
+	return errors.Is(err, parser.ErrIncompleteInput)
+}
+
+// continuationPrompt decides whether readline keeps reading after Enter,
+// prompting with PS2.
+func continuationPrompt(text string) (string, bool) {
+	if !isIncomplete(text) {
+		return "", false
+	}
+
+	return expandPrompt("PS2", defaultPS2), true
+}
+
+// rightPrompt returns the prompt drawn at the right end of the input line,
+// which RPS1 describes, or RPROMPT as zsh calls it, or "" if neither is
+// set.
+func rightPrompt() string {
+	for _, name := range []string{"RPS1", "RPROMPT"} {
+		if text, ok := variables.Get(name); ok {
+			return prompt.Expand(text)
 		}
 	}
 
-	return true
+	return ""
+}
+
+// transientPrompt returns the compact prompt that replaces PS1 once a line
+// is entered, or "" unless the transientprompt option is on.
+func transientPrompt() string {
+	if !options.Enabled(options.TransientPrompt) {
+		return ""
+	}
+
+	return expandPrompt("TRANSIENT_PS1", defaultTransientPS1)
+}
+
+// expandPrompt returns the prompt that the variable name describes, or
+// fallback if it is unset.
+func expandPrompt(name, fallback string) string {
+	text, ok := variables.Get(name)
+	if !ok {
+		text = fallback
+	}
+
+	return prompt.Expand(text)
 }
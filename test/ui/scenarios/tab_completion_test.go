@@ -262,10 +262,10 @@ func TestTabCompletionDoubleTabNavigation(t *testing.T) {
 				Check: func(f *framework.UITestFramework) bool {
 					buffer := f.GetShell().GetBuffer()
 					t.Logf("Buffer after double tab + enter: %q", buffer)
-					// The actual result is "e2freefrag" - assert exactly that
-					return f.AssertBuffer().Equals("e2freefrag").Passed
+					// The builtins come first: echo, then eval
+					return f.AssertBuffer().Equals("eval").Passed
 				},
-				Message: "Should complete to exactly 'e2freefrag' after double tab navigation",
+				Message: "Should complete to exactly 'eval' after double tab navigation",
 			},
 			{
 				Name: "Should have clean rendering without excessive cursor movement",
@@ -328,9 +328,9 @@ func TestTabCompletionCleanup(t *testing.T) {
 			{
 				Name: "Buffer should contain selected completion",
 				Check: func(f *framework.UITestFramework) bool {
-					return f.AssertBuffer().Equals("e2freefrag").Passed
+					return f.AssertBuffer().Equals("eval").Passed
 				},
-				Message: "Should complete to e2freefrag",
+				Message: "Should complete to eval",
 			},
 			{
 				Name: "Output should restore cursor after selection",
@@ -422,8 +422,8 @@ func TestTabCompletionExactOutput(t *testing.T) {
 					output := f.GetOutput()
 					t.Logf("Full final output: %q", output)
 
-					// Should end with exactly "dsh> e2freefrag" without duplication
-					expectedEnd := "dsh> e2freefrag"
+					// Should end with exactly "dsh> eval" without duplication
+					expectedEnd := "dsh> eval"
 					if !strings.HasSuffix(output, expectedEnd) {
 						t.Errorf("Output should end with %q", expectedEnd)
 						return false
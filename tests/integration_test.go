@@ -3,9 +3,11 @@ package tests
 import (
 	"bytes"
 	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -88,6 +90,19 @@ func TestShell_FileRedirection(t *testing.T) {
 	if !strings.Contains(output, "test content") {
 		t.Errorf("Expected 'test content' in output, got: %s", output)
 	}
+
+	// Redirections apply left to right
+	for _, test := range []struct{ redirections, expected string }{
+		{"2>&1 >" + outputFile, ""},
+		{">" + outputFile + " 2>&1", "err\n"},
+	} {
+		if _, err := runShellWithArgs("", "-c", "sh -c 'echo err >&2' "+test.redirections); err != nil {
+			t.Fatalf("Shell execution failed: %v", err)
+		}
+		if content, _ := os.ReadFile(outputFile); string(content) != test.expected {
+			t.Errorf("%s: expected %q in the file, got %q", test.redirections, test.expected, content)
+		}
+	}
 }
 
 // TestShell_BackgroundCommands tests background process execution.
@@ -160,8 +175,281 @@ func TestShell_ErrorHandling(t *testing.T) {
 	}
 }
 
+// TestShell_Options tests set options given as commands and as flags.
+func TestShell_Options(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		input    string
+		expected string
+		absent   string
+	}{
+		{
+			name:     "set -e stops at a failure",
+			input:    "set -e\nfalse || echo tested\nfalse\necho after\n",
+			expected: "tested",
+			absent:   "after",
+		},
+		{
+			name:   "errexit flag",
+			args:   []string{"-e"},
+			input:  "false\necho after\n",
+			absent: "after",
+		},
+		{
+			name:     "flags in -c",
+			args:     []string{"-eu", "-o", "pipefail", "-c", "echo $-; false | true; echo $?"},
+			expected: "eu",
+			absent:   "0",
+		},
+		{
+			name:     "unbound variable ends the shell",
+			args:     []string{"-c", "set -u; echo $nope; echo after"},
+			expected: "nope: unbound variable",
+			absent:   "after",
+		},
+		{
+			name:     "xtrace",
+			args:     []string{"-x", "-c", "echo traced"},
+			expected: "+ echo traced",
+		},
+		{
+			name:     "invalid flag",
+			args:     []string{"-Z"},
+			expected: "invalid option",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, _ := runShellWithArgs(tt.input, tt.args...)
+
+			if !strings.Contains(output, tt.expected) {
+				t.Errorf("Expected output to contain '%s', got: %s", tt.expected, output)
+			}
+			if tt.absent != "" && strings.Contains(output, tt.absent) {
+				t.Errorf("Expected output not to contain '%s', got: %s", tt.absent, output)
+			}
+		})
+	}
+}
+
+// TestShell_PipelineVariables tests that pipeline stages run in a child
+// shell see the shell's unexported variables and arrays.
+func TestShell_PipelineVariables(t *testing.T) {
+	script := `x=5; hosts=(b a); for h in "${hosts[@]}"; do echo "$h$x"; done | sort; { echo "${hosts[1]}"; } | cat`
+
+	output, err := runShellWithArgs("", "-c", script)
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	if expected := "a5\nb5\na\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+// TestShell_ProcessSubstitutionVariables tests that a process substitution
+// run in a child shell sees the shell's unexported variables and arrays.
+func TestShell_ProcessSubstitutionVariables(t *testing.T) {
+	output, err := runShellWithArgs("", "-c", `x=3; list=(p q); cat <(echo "v=$x"; echo "${list[@]}")`)
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	if expected := "v=3\np q\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+// TestShell_Exec tests that exec replaces the shell.
+func TestShell_Exec(t *testing.T) {
+	output, err := runShellWithArgs("", "-c", "exec sh -c 'echo replaced; exit 3'; echo not reached")
+	if !strings.Contains(output, "replaced") || strings.Contains(output, "not reached") {
+		t.Errorf("Expected only the exec'd command to run, got: %s", output)
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("Expected the exec'd command's exit status 3, got %v", err)
+	}
+
+	// The command keeps the shell's environment
+	t.Setenv("DSH_EXEC", "kept")
+	output, err = runShellWithArgs("", "-c", "DSH_PREFIX=too exec sh -c 'echo $DSH_EXEC $DSH_PREFIX $HOME'")
+	if err != nil || output != "kept too "+os.Getenv("HOME")+"\n" {
+		t.Errorf("Expected the environment passed on, got %q (%v)", output, err)
+	}
+
+	// A command that cannot be found ends the shell
+	output, err = runShellWithArgs("", "-c", "exec dsh-no-such-command; echo after")
+	if strings.Contains(output, "after") || !errors.As(err, &exitErr) || exitErr.ExitCode() != 127 {
+		t.Errorf("Expected the shell to exit with 127, got %q (%v)", output, err)
+	}
+}
+
+func TestShell_Hash(t *testing.T) {
+	output, err := runShellWithArgs("", "-c", "hash; ls >/dev/null; ls >/dev/null; hash | cat; type cd if ls")
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	for _, expected := range []string{"hash table empty", "   2\t", "cd is a shell builtin", "if is a shell keyword", "ls is hashed ("} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+}
+
+func TestShell_Time(t *testing.T) {
+	output, err := runShellWithArgs("", "-c", "TIMEFORMAT='took %1R'; time sleep 0.2 | cat; time -p { true; }; times")
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	// A loaded machine may take longer than the sleep
+	if !regexp.MustCompile(`took (0\.[2-9]|[1-9]\.[0-9])\n`).MatchString(output) {
+		t.Errorf("Expected the pipeline timed as at least 0.2 seconds, got: %s", output)
+	}
+
+	for _, expected := range []string{"real 0.00\nuser ", "0m0."} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+}
+
+func TestShell_Limits(t *testing.T) {
+	output, err := runShellWithArgs("", "-c", "ulimit -S -n 64; sh -c 'ulimit -n'; umask 027; sh -c umask; umask -S")
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	if expected := "64\n0027\nu=rwx,g=rx,o=\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestShell_SubshellLimits(t *testing.T) {
+	script := `ulimit -Hn; ulimit -Sn 64; ulimit -n | cat; (ulimit -Hn 128); u=uli; ("${u}mit" -Hn 100); ulimit -Hn; ` +
+		"umask 022; (umask 077); umask"
+
+	output, err := runShellWithArgs("", "-c", script)
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	lines := strings.Split(output, "\n")
+	if len(lines) != 5 || lines[1] != "64" || lines[2] != lines[0] || lines[3] != "0022" {
+		t.Errorf("Expected the subshell's limits and umask undone, got %q", output)
+	}
+}
+
+func TestShell_SubshellExec(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out")
+
+	output, err := runShellWithArgs("", "-c", "(exec echo hi; echo not reached); echo after; (exec 3>"+file+"); echo x >&3")
+	if err == nil {
+		t.Errorf("Expected writing to descriptor 3 to fail, got %q", output)
+	}
+
+	if !strings.HasPrefix(output, "hi\nafter\n") || strings.Contains(output, "not reached") {
+		t.Errorf("Expected exec to replace only the subshell, got %q", output)
+	}
+}
+
+func TestShell_Kill(t *testing.T) {
+	output, err := runShellWithArgs("", "-c", "sleep 5 | sleep 6 & PATH=/nonexistent; kill -TERM %1; kill -0 $!; /bin/sleep 0.2; kill %1")
+	if err == nil {
+		t.Fatalf("Expected the last kill to fail, got: %s", output)
+	}
+
+	for _, expected := range []string{"[1] ", "kill: %1: no such job"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+}
+
+func TestShell_Getopts(t *testing.T) {
+	script := `for i in 1 2 3; do getopts ":vo:" opt -v -ofile -x; echo "$? $opt $OPTARG $OPTIND"; done`
+
+	output, err := runShellWithArgs("", "-c", script)
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	if expected := "0 v  2\n0 o file 3\n0 ? x 4\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestShell_PositionalParameters(t *testing.T) {
+	script := `echo "$0 $#"; for i in 1 2; do getopts "vo:" opt; echo "$opt $OPTARG"; done; ` +
+		`set -- "a b" c; for arg; do echo "[$arg]"; done | cat; echo "$*"`
+
+	output, err := runShellWithArgs("", "-c", script, "name", "-v", "-o", "out", "file")
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	if expected := "name 4\nv \no out\n[a b]\n[c]\na b c\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	scriptFile := filepath.Join(t.TempDir(), "args.sh")
+	if err := os.WriteFile(scriptFile, []byte("echo \"$0 $1 ${2}\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = runShellWithArgs("", scriptFile, "one", "two")
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	if expected := scriptFile + " one two\n"; output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestShell_Bind(t *testing.T) {
+	script := `bind '"\C-t": kill-word'; bind -x '"\C-o": date'; bind -r '"\C-b"'; bind -p | cat; bind -l; bind '"\C-t": nope'`
+
+	output, err := runShellWithArgs("", "-c", script)
+	if err == nil {
+		t.Fatalf("Expected binding an unknown action to fail, got: %s", output)
+	}
+
+	for _, expected := range []string{"\"\\C-t\": kill-word\n", "-x \"\\C-o\": date\n", "beginning-of-line\n", "bind: "} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+	if strings.Contains(output, `"\C-b"`) {
+		t.Errorf("Expected the binding of C-b removed, got: %s", output)
+	}
+}
+
+func TestShell_DirectoryStack(t *testing.T) {
+	output, err := runShellWithArgs("", "-c", "cd /; cd /tmp; cd -; sh -c 'echo child $PWD'; pushd /tmp >/dev/null; dirs -v | cat")
+	if err != nil {
+		t.Fatalf("Shell execution failed: %v", err)
+	}
+
+	for _, expected := range []string{"/\nchild /\n", " 0  /tmp\n 1  /\n"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+}
+
 // runShellCommand executes the shell with given input and returns output.
 func runShellCommand(input string) (string, error) {
+	return runShellWithArgs(input)
+}
+
+// runShellWithArgs is runShellCommand with command line arguments.
+func runShellWithArgs(input string, args ...string) (string, error) {
 	ctx := context.Background()
 
 	// Build the shell first
@@ -174,7 +462,7 @@ func runShellCommand(input string) (string, error) {
 	defer func() { _ = os.Remove("../dsh_test") }()
 
 	// Run the shell with input
-	cmd := exec.CommandContext(ctx, "./dsh_test")
+	cmd := exec.CommandContext(ctx, "./dsh_test", args...)
 	cmd.Dir = ".."
 	cmd.Stdin = strings.NewReader(input)
 